@@ -470,6 +470,9 @@ const initKernel = (initData) => {
           case 23:
             showErrorWindow('⚠️ 无法读写块树文件 Failed to access blocktree file', `<div>块树文件正在被其他程序锁定。如果你使用了第三方同步盘，请在思源运行期间关闭同步。</div><div>The block tree file is being locked by another program. If you use a third-party sync disk, please turn off the sync while SiYuan is running.</div>`)
             break
+          case 24:
+            showErrorWindow('⚠️ 工作空间被锁定 The workspace is locked', `<div>工作空间正在被其他思源内核或者命令行子命令使用，请关闭后再启动思源。</div><div>The workspace is being used by another SiYuan kernel or command-line subcommand, please close it and then start SiYuan again.</div>`)
+            break
           case 0:
           case 1: // Fatal error
             break
@@ -128,6 +128,9 @@ export const exportConfig = {
                 tagOpenMarker: (exportConfig.element.querySelector("#tagOpenMarker") as HTMLInputElement).value,
                 tagCloseMarker: (exportConfig.element.querySelector("#tagCloseMarker") as HTMLInputElement).value,
                 pandocBin: pandocBin || window.siyuan.config.export.pandocBin,
+                siteGenerator: window.siyuan.config.export.siteGenerator,
+                frontMatter: window.siyuan.config.export.frontMatter,
+                siteAssetsPrefix: window.siyuan.config.export.siteAssetsPrefix,
             }, (response) => {
                 exportConfig.onSetexport(response.data);
                 pandocBinPathElement.textContent = response.data.pandocBin;
@@ -5,9 +5,9 @@ import {fetchPost} from "../../util/fetch";
 import {getIconByType} from "../../editor/getIcon";
 import {preventScroll} from "../../protyle/scroll/preventScroll";
 
-const onRecentblocks = (data: IWebSocketData) => {
+const onRecentblocks = (data: IBlock[]) => {
     let resultHTML = "";
-    data.data.forEach((item: IBlock) => {
+    data.forEach((item: IBlock) => {
         resultHTML += `<div class="b3-list-item b3-list-item--two" data-url="${item.box}" data-path="${item.path}" data-id="${item.id}">
 <div class="b3-list-item__first">
     <svg class="b3-list-item__graphic"><use xlink:href="#${getIconByType(item.type)}"></use></svg>
@@ -27,11 +27,11 @@ const toolbarSearchEvent = () => {
         const inputElement = document.getElementById("toolbarSearch") as HTMLInputElement;
         if (inputElement.value === "") {
             fetchPost("/api/block/getRecentUpdatedBlocks", {}, (response) => {
-                onRecentblocks(response);
+                onRecentblocks(response.data);
             });
         } else {
             fetchPost("/api/search/fullTextSearchBlock", {query: inputElement.value,}, (response) => {
-                onRecentblocks(response);
+                onRecentblocks(response.data.blocks);
             });
         }
         const localData = JSON.parse(localStorage.getItem(Constants.LOCAL_SEARCHEDATA) || "{}");
@@ -1267,7 +1267,7 @@ export class Toolbar {
             k: "",
         }, (response) => {
             let html = "";
-            response.data.forEach((item: { hName: string, path: string }, index: number) => {
+            response.data.assets.concat(response.data.contents).forEach((item: { hName: string, path: string }, index: number) => {
                 html += `<di data-value="${item.path}" class="b3-list-item${index === 0 ? " b3-list-item--focus" : ""}">${item.hName}</di>`;
             });
             this.subElement.style.width = "";
@@ -1296,7 +1296,7 @@ export class Toolbar {
                     k: inputElement.value,
                 }, (response) => {
                     let searchHTML = "";
-                    response.data.forEach((item: { path: string, hName: string }, index: number) => {
+                    response.data.assets.concat(response.data.contents).forEach((item: { path: string, hName: string }, index: number) => {
                         searchHTML += `<div data-value="${item.path}" class="b3-list-item${index === 0 ? " b3-list-item--focus" : ""}">${item.hName}</div>`;
                     });
                     this.subElement.firstElementChild.lastElementChild.innerHTML = searchHTML;
@@ -233,7 +233,7 @@ export class Search extends Model {
             this.parent.updateTitle(this.text);
             loadElement.classList.remove("fn__none");
             fetchPost("/api/search/fullTextSearchBlock", {query: this.text}, (response) => {
-                this.onSearch(response.data);
+                this.onSearch(response.data.blocks);
                 loadElement.classList.add("fn__none");
             });
         }, Constants.TIMEOUT_SEARCH);
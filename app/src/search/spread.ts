@@ -384,7 +384,7 @@ export const openSearch = async (hotkey: string, key?: string, notebookId?: stri
                     },
                     path: !searchPathElement.classList.contains("b3-button--cancel") ? localData.idPath : ""
                 }, (response) => {
-                    onSearch(response.data, dialog);
+                    onSearch(response.data.blocks, dialog, response.data.suggestions);
                     loadingElement.classList.add("fn__none");
                 });
             }
@@ -588,6 +588,14 @@ export const openSearch = async (hotkey: string, key?: string, notebookId?: stri
     searchPanelElement.addEventListener("click", (event: MouseEvent) => {
         let target = event.target as HTMLElement;
         while (target && !target.isEqualNode(dialog.element)) {
+            if (target.getAttribute("data-type") === "search-suggestion") {
+                searchInputElement.value = target.getAttribute("data-query");
+                inputEvent();
+                searchInputElement.focus();
+                event.preventDefault();
+                event.stopPropagation();
+                break;
+            }
             if (target.getAttribute("data-type") === "search-item") {
                 if (event.detail === 1) {
                     clickTimeout = window.setTimeout(() => {
@@ -675,7 +683,7 @@ const getArticle = (options: {
     }
 };
 
-const onSearch = (data: IBlock[], dialog: Dialog) => {
+const onSearch = (data: IBlock[], dialog: Dialog, suggestions: string[] = []) => {
     let resultHTML = "";
     data.forEach((item, index) => {
         const title = escapeHtml(getNotebookName(item.box)) + getDisplayName(item.hPath, false);
@@ -707,5 +715,13 @@ const onSearch = (data: IBlock[], dialog: Dialog) => {
             dialog.element.querySelector("#searchPreview").classList.add("fn__none");
         }
     }
-    dialog.element.querySelector("#searchList").innerHTML = resultHTML || `<div class="b3-list--empty">${window.siyuan.languages.emptyContent}</div>`;
+    if (!resultHTML) {
+        resultHTML = `<div class="b3-list--empty">${window.siyuan.languages.emptyContent}</div>`;
+        suggestions.forEach((item) => {
+            resultHTML += `<div data-type="search-suggestion" class="b3-list-item" data-query="${escapeHtml(item)}">
+<span class="b3-list-item__text">${window.siyuan.languages.didYouMean} <b>${escapeHtml(item)}</b></span>
+</div>`;
+        });
+    }
+    dialog.element.querySelector("#searchList").innerHTML = resultHTML;
 };
@@ -169,6 +169,9 @@ declare interface IExport {
     tagOpenMarker: string
     tagCloseMarker: string
     pandocBin: string
+    siteGenerator: string
+    frontMatter: string
+    siteAssetsPrefix: string
     paragraphBeginningSpace: boolean;
     addTitle: boolean;
 }
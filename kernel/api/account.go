@@ -29,7 +29,7 @@ func useActivationcode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("data"))
 	if !ok {
 		return
 	}
@@ -47,7 +47,7 @@ func checkActivationcode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("data"))
 	if !ok {
 		return
 	}
@@ -72,10 +72,8 @@ func login(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	ret.Code = -1
 
-	arg := map[string]interface{}{}
-	if err := c.BindJSON(&arg); nil != err {
-		ret.Code = -1
-		ret.Msg = "parses request failed"
+	arg, ok := util.JsonArg(c, ret, util.StrArg("userName"), util.StrArg("userPassword"), util.StrArg("captcha"))
+	if !ok {
 		c.JSON(http.StatusOK, ret)
 		return
 	}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -28,11 +29,22 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+// uploadArg 和 uploadResult 仅用于描述 model.Upload 的表单参数和返回值。
+type uploadArg struct {
+	AssetsDirPath string                  `form:"assetsDirPath"` // 资源文件存放的文件夹路径，以 data 文件夹作为根路径
+	Files         []*multipart.FileHeader `form:"file[]" binding:"required"`
+}
+
+type uploadResult struct {
+	ErrFiles []string          `json:"errFiles"` // 处理时遇到错误的文件名
+	SuccMap  map[string]string `json:"succMap"`  // 处理成功的文件，key 为上传时的文件名，value 为 assets/foo-id.png
+}
+
 func getDocImageAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -51,7 +63,7 @@ func setFileAnnotation(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("path"), util.StrArg("data"))
 	if !ok {
 		return
 	}
@@ -77,7 +89,7 @@ func getFileAnnotation(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("path"))
 	if !ok {
 		return
 	}
@@ -123,7 +135,7 @@ func removeUnusedAsset(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("path"))
 	if !ok {
 		return
 	}
@@ -159,7 +171,7 @@ func resolveAssetPath(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("path"))
 	if !ok {
 		return
 	}
@@ -180,7 +192,7 @@ func uploadCloud(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -200,7 +212,7 @@ func insertLocalAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ArrArg("assetPaths", util.ArgString), util.StrArg("id"))
 	if !ok {
 		return
 	}
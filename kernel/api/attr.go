@@ -36,31 +36,29 @@ func getBlockAttrs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &idArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	id := arg["id"].(string)
-	ret.Data = model.GetBlockAttrs(id)
+	ret.Data = model.GetBlockAttrs(arg.ID)
+}
+
+type setBlockAttrsArg struct {
+	ID    string            `json:"id" binding:"required"`
+	Attrs map[string]string `json:"attrs" binding:"required"` // 属性值为空时删除该属性
 }
 
 func setBlockAttrs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &setBlockAttrsArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	id := arg["id"].(string)
-	attrs := arg["attrs"].(map[string]interface{})
-	nameValues := map[string]string{}
-	for name, value := range attrs {
-		nameValues[name] = value.(string)
-	}
-	err := model.SetBlockAttrs(id, nameValues)
+	err := model.SetBlockAttrs(arg.ID, arg.Attrs)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -72,7 +70,7 @@ func resetBlockAttrs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.ObjArg("attrs", util.ArgString))
 	if !ok {
 		return
 	}
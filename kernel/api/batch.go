@@ -0,0 +1,436 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type batchArg struct {
+	Requests []*batchRequest `json:"requests" binding:"required,min=1,dive"`
+}
+
+type batchRequest struct {
+	Ref  string                 `json:"ref"`                     // 引用名，后续子请求参数中的 ${ref:引用名} 会被替换为该子请求生成的 ID，也可以使用 ${ref:序号} 引用
+	Path string                 `json:"path" binding:"required"` // 接口路径，仅支持块、属性、文档树和笔记本接口
+	Arg  map[string]interface{} `json:"arg"`
+}
+
+type batchResult struct {
+	Results []*batchItemResult `json:"results"` // 已经执行的子请求的返回值
+	IDs     map[string]string  `json:"ids"`     // 引用名和序号对应的子请求生成的 ID
+	Failed  int                `json:"failed"`  // 失败的子请求序号，全部成功时为 -1
+}
+
+type batchItemResult struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+// batchPaths 是批量调用支持的接口路径前缀。
+var batchPaths = []string{"/api/block/", "/api/attr/", "/api/filetree/", "/api/notebook/"}
+
+var batchRefRegexp = regexp.MustCompile(`\$\{ref:([^{}]+)\}`)
+
+// batchLock 用于串行执行批量调用，避免多个批量调用的快照相互覆盖。
+var batchLock = sync.Mutex{}
+
+// batch 按顺序执行多个子请求，任一子请求失败时回滚整个批量调用。
+//
+// 连续的块增删改子请求合并为一个事务执行，其他子请求转发给对应的接口处理。
+func batch(ginServer *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ret := gulu.Ret.NewResult()
+		defer c.JSON(http.StatusOK, ret)
+
+		arg := &batchArg{}
+		if !util.BindJsonArg(c, ret, arg) {
+			return
+		}
+		if argErr := checkBatchRequests(arg.Requests, ginServer.Routes()); nil != argErr {
+			util.InvalidArg(c, ret, argErr)
+			return
+		}
+
+		batchLock.Lock()
+		defer batchLock.Unlock()
+
+		// 批量调用期间持有事务锁，避免其他事务写入涉及的文档后被回滚覆盖
+		model.LockTx()
+		defer model.UnlockTx()
+
+		snapshot, err := model.NewBatchSnapshot()
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		defer snapshot.Close()
+
+		runner := &batchRunner{ginServer: ginServer, c: c, snapshot: snapshot, ids: map[string]string{}, pendingIDs: map[string]bool{}}
+		result := runner.run(arg.Requests)
+		ret.Data = result
+		if -1 == result.Failed {
+			return
+		}
+
+		ret.Code = -1
+		ret.Msg = fmt.Sprintf("request [%d] %s failed: %s", result.Failed, arg.Requests[result.Failed].Path, result.Results[result.Failed].Msg)
+		if err = snapshot.Rollback(); nil != err {
+			ret.Msg += ", rollback failed: " + err.Error()
+		}
+	}
+}
+
+func checkBatchRequests(requests []*batchRequest, routes gin.RoutesInfo) *util.ArgErr {
+	postPaths := map[string]bool{}
+	for _, route := range routes {
+		if http.MethodPost == route.Method {
+			postPaths[route.Path] = true
+		}
+	}
+
+	refs := map[string]bool{}
+	for i, request := range requests {
+		if !postPaths[request.Path] || !isBatchPath(request.Path) {
+			return &util.ArgErr{Arg: fmt.Sprintf("requests[%d].path", i), Expected: "block, attr, filetree or notebook API path"}
+		}
+
+		for _, s := range batchStrings(request.Arg, nil) {
+			for _, match := range batchRefRegexp.FindAllStringSubmatch(s, -1) {
+				if !refs[match[1]] {
+					return &util.ArgErr{Arg: fmt.Sprintf("requests[%d].arg", i), Expected: "references to earlier requests"}
+				}
+			}
+		}
+
+		if "" != request.Ref {
+			if _, err := strconv.Atoi(request.Ref); nil == err || refs[request.Ref] {
+				return &util.ArgErr{Arg: fmt.Sprintf("requests[%d].ref", i), Expected: "unique non-numeric ref"}
+			}
+			refs[request.Ref] = true
+		}
+		refs[strconv.Itoa(i)] = true
+	}
+	return nil
+}
+
+func isBatchPath(p string) bool {
+	for _, prefix := range batchPaths {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// batchBlockOps 是可以合并为一个事务执行的块增删改接口，返回子请求对应的块操作，参数校验失败时返回子请求的返回值。
+var batchBlockOps = map[string]func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult){
+	"/api/block/insertBlock": func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult) {
+		blockArg := &insertBlockArg{}
+		if result := bindBatchArg(arg, blockArg); nil != result {
+			return nil, result
+		}
+		return insertBlockOps(blockArg), nil
+	},
+	"/api/block/prependBlock": func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult) {
+		blockArg := &childBlockArg{}
+		if result := bindBatchArg(arg, blockArg); nil != result {
+			return nil, result
+		}
+		return childBlockOps("prependInsert", blockArg), nil
+	},
+	"/api/block/appendBlock": func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult) {
+		blockArg := &childBlockArg{}
+		if result := bindBatchArg(arg, blockArg); nil != result {
+			return nil, result
+		}
+		return childBlockOps("appendInsert", blockArg), nil
+	},
+	"/api/block/updateBlock": func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult) {
+		blockArg := &updateBlockArg{}
+		if result := bindBatchArg(arg, blockArg); nil != result {
+			return nil, result
+		}
+		ops, err := updateBlockOps(blockArg)
+		if nil != err {
+			return nil, &batchItemResult{Code: -1, Msg: err.Error()}
+		}
+		return ops, nil
+	},
+	"/api/block/deleteBlock": func(arg map[string]interface{}) ([]*model.Operation, *batchItemResult) {
+		blockArg := &idArg{}
+		if result := bindBatchArg(arg, blockArg); nil != result {
+			return nil, result
+		}
+		return deleteBlockOps(blockArg), nil
+	},
+}
+
+func bindBatchArg(arg map[string]interface{}, obj interface{}) *batchItemResult {
+	code, msg, argErr := util.BindArg(arg, obj)
+	if 0 == code {
+		return nil
+	}
+
+	ret := &batchItemResult{Code: code, Msg: msg}
+	if nil != argErr {
+		ret.Data = argErr
+	}
+	return ret
+}
+
+type batchRunner struct {
+	ginServer *gin.Engine
+	c         *gin.Context
+	snapshot  *model.BatchSnapshot
+	ids       map[string]string // 引用名和序号对应的子请求生成的 ID
+	results   []*batchItemResult
+
+	pending    []*model.Transaction // 等待合并执行的块操作，每个子请求对应一个事务
+	pendingIDs map[string]bool      // 等待合并执行的块操作生成的块 ID
+}
+
+func (runner *batchRunner) run(requests []*batchRequest) (ret *batchResult) {
+	ret = &batchResult{Failed: -1}
+	defer func() {
+		ret.Results = runner.results
+		ret.IDs = runner.ids
+	}()
+
+	for i, request := range requests {
+		arg, missing := resolveBatchRefs(request.Arg, runner.ids)
+		if "" != missing {
+			runner.results = append(runner.results, &batchItemResult{Code: -1, Msg: "request [" + missing + "] has no ID to reference"})
+			ret.Failed = i
+			return
+		}
+
+		args, _ := arg.(map[string]interface{})
+		if nil == args {
+			args = map[string]interface{}{}
+		}
+
+		buildOps := batchBlockOps[request.Path]
+		if id, _ := args["id"].(string); nil == buildOps || ("/api/block/updateBlock" == request.Path && runner.pendingIDs[id]) {
+			// 非块增删改接口需要先执行之前的块操作；更新块时需要读取块所在的文档，如果该块是之前的块操作生成的也需要先执行
+			if failed := runner.flush(); -1 != failed {
+				ret.Failed = failed
+				return
+			}
+		}
+
+		err := runner.snapshot.SaveByIDs(batchStrings(args, nil))
+		if notebook, _ := args["notebook"].(string); nil == err && "/api/notebook/removeNotebook" == request.Path {
+			err = runner.snapshot.SaveBox(notebook)
+		}
+		if nil != err {
+			runner.results = append(runner.results, &batchItemResult{Code: -1, Msg: err.Error()})
+			ret.Failed = i
+			return
+		}
+
+		var id string
+		if nil != buildOps {
+			ops, result := buildOps(args)
+			if nil != result {
+				runner.results = append(runner.results, result)
+				ret.Failed = i
+				return
+			}
+
+			transactions := []*model.Transaction{{DoOperations: ops}}
+			runner.pending = append(runner.pending, transactions[0])
+			runner.results = append(runner.results, &batchItemResult{Data: transactions})
+			id = batchBlockID(args, ops)
+			runner.pendingIDs[id] = true
+		} else {
+			result := runner.serve(request.Path, args)
+			runner.results = append(runner.results, result)
+			if 0 != result.Code {
+				ret.Failed = i
+				return
+			}
+			id = batchResultID(result.Data)
+		}
+
+		if "" != id {
+			runner.ids[strconv.Itoa(i)] = id
+			if "" != request.Ref {
+				runner.ids[request.Ref] = id
+			}
+			runner.snapshot.Created(id)
+		}
+	}
+
+	ret.Failed = runner.flush()
+	return
+}
+
+// flush 将等待合并执行的块操作作为一个事务执行，返回失败的子请求序号，成功时返回 -1。
+//
+// 合并执行的块操作失败时无法区分是哪个子请求导致的，这时返回这些子请求中的第一个。
+func (runner *batchRunner) flush() (failed int) {
+	failed = -1
+	if 1 > len(runner.pending) {
+		return
+	}
+
+	transactions := runner.pending
+	first := len(runner.results) - len(transactions)
+	runner.pending = nil
+	runner.pendingIDs = map[string]bool{}
+	if err := model.PerformTransactionsNow(&transactions); nil != err {
+		for _, result := range runner.results[first:] {
+			result.Code = 1
+			result.Msg = err.Error()
+		}
+		return first
+	}
+
+	broadcastTransactions(transactions)
+	return
+}
+
+// serve 将子请求转发给对应的接口处理，子请求使用批量调用的请求头进行鉴权。
+func (runner *batchRunner) serve(p string, arg map[string]interface{}) (ret *batchItemResult) {
+	body, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		return &batchItemResult{Code: -1, Msg: err.Error()}
+	}
+	req, err := http.NewRequest(http.MethodPost, p, bytes.NewReader(body))
+	if nil != err {
+		return &batchItemResult{Code: -1, Msg: err.Error()}
+	}
+	req.Header = runner.c.Request.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Set("Content-Type", "application/json")
+	req.Host = runner.c.Request.Host
+	req.RemoteAddr = runner.c.Request.RemoteAddr
+	req.RequestURI = p
+
+	recorder := httptest.NewRecorder()
+	runner.ginServer.ServeHTTP(recorder, req)
+	model.WaitForWritingFiles()
+
+	ret = &batchItemResult{}
+	if err = gulu.JSON.UnmarshalJSON(recorder.Body.Bytes(), ret); nil != err {
+		return &batchItemResult{Code: -1, Msg: fmt.Sprintf("%d %s", recorder.Code, http.StatusText(recorder.Code))}
+	}
+	if 0 == ret.Code && http.StatusOK != recorder.Code {
+		ret.Code = -1
+	}
+	return
+}
+
+// batchBlockID 返回块增删改子请求生成的块 ID：更新和删除时为目标块 ID，插入时为插入的第一个块的 ID。
+func batchBlockID(arg map[string]interface{}, ops []*model.Operation) string {
+	if id, _ := arg["id"].(string); "" != id {
+		return id
+	}
+
+	data, _ := ops[len(ops)-1].Data.(string)
+	luteEngine := model.NewLute()
+	tree := luteEngine.BlockDOM2Tree(data)
+	if nil == tree || nil == tree.Root || nil == tree.Root.FirstChild {
+		return ""
+	}
+	return tree.Root.FirstChild.ID
+}
+
+// batchResultID 返回转发的子请求生成的 ID：data 为 ID 字符串时返回该字符串，否则返回 data.id 或者 data.notebook.id。
+func batchResultID(data interface{}) string {
+	switch v := data.(type) {
+	case string:
+		if util.IsIDPattern(v) {
+			return v
+		}
+	case map[string]interface{}:
+		if id, ok := v["id"].(string); ok {
+			return id
+		}
+		if notebook, ok := v["notebook"].(map[string]interface{}); ok {
+			id, _ := notebook["id"].(string)
+			return id
+		}
+	}
+	return ""
+}
+
+// resolveBatchRefs 将 value 中字符串里的 ${ref:xxx} 替换为引用的子请求生成的 ID，引用的子请求没有生成 ID 时返回该引用。
+func resolveBatchRefs(value interface{}, ids map[string]string) (ret interface{}, missing string) {
+	switch v := value.(type) {
+	case string:
+		ret = batchRefRegexp.ReplaceAllStringFunc(v, func(ref string) string {
+			name := batchRefRegexp.FindStringSubmatch(ref)[1]
+			id, ok := ids[name]
+			if !ok && "" == missing {
+				missing = name
+			}
+			return id
+		})
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			if values[i], missing = resolveBatchRefs(e, ids); "" != missing {
+				return
+			}
+		}
+		ret = values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if values[k], missing = resolveBatchRefs(e, ids); "" != missing {
+				return
+			}
+		}
+		ret = values
+	default:
+		ret = value
+	}
+	return
+}
+
+// batchStrings 返回 value 中的所有字符串。
+func batchStrings(value interface{}, ret []string) []string {
+	switch v := value.(type) {
+	case string:
+		ret = append(ret, v)
+	case []interface{}:
+		for _, e := range v {
+			ret = batchStrings(e, ret)
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			ret = batchStrings(e, ret)
+		}
+	}
+	return ret
+}
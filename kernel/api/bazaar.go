@@ -29,7 +29,7 @@ func getBazaarPackageREAME(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("repoURL"), util.StrArg("repoHash"))
 	if !ok {
 		return
 	}
@@ -54,7 +54,7 @@ func installBazaarWidget(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("repoURL"), util.StrArg("repoHash"), util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -79,7 +79,7 @@ func uninstallBazaarWidget(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -110,7 +110,7 @@ func installBazaarIcon(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("repoURL"), util.StrArg("repoHash"), util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -136,7 +136,7 @@ func uninstallBazaarIcon(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -168,7 +168,7 @@ func installBazaarTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("repoURL"), util.StrArg("repoHash"), util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -194,7 +194,7 @@ func uninstallBazaarTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -225,7 +225,7 @@ func installBazaarTheme(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("repoURL"), util.StrArg("repoHash"), util.StrArg("packageName"), util.NumArg("mode"), util.BoolArg("update").Optional())
 	if !ok {
 		return
 	}
@@ -256,7 +256,7 @@ func uninstallBazaarTheme(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("packageName"))
 	if !ok {
 		return
 	}
@@ -33,7 +33,7 @@ func setBlockReminder(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.StrArg("timed"))
 	if !ok {
 		return
 	}
@@ -53,7 +53,7 @@ func checkBlockFold(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -66,7 +66,7 @@ func checkBlockExist(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -85,7 +85,7 @@ func getDocInfo(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -107,7 +107,7 @@ func getBlockWordCount(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -126,7 +126,7 @@ func getRefText(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -139,7 +139,7 @@ func getRefIDs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -157,7 +157,7 @@ func getRefIDsByFileAnnotationID(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -174,7 +174,7 @@ func getBlockDefIDsByRefText(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("anchor"), util.ArrArg("excludeIDs", util.ArgString))
 	if !ok {
 		return
 	}
@@ -194,7 +194,7 @@ func getBlockBreadcrumb(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -214,7 +214,7 @@ func getBlockInfo(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -268,7 +268,7 @@ func getBlockDOM(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/88250/gulu"
@@ -32,28 +33,14 @@ func appendBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &childBlockArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	data := arg["data"].(string)
-	dataType := arg["dataType"].(string)
-	parentID := arg["parentID"].(string)
-	if "markdown" == dataType {
-		luteEngine := model.NewLute()
-		data = dataBlockDOM(data, luteEngine)
-	}
-
 	transactions := []*model.Transaction{
 		{
-			DoOperations: []*model.Operation{
-				{
-					Action:   "appendInsert",
-					Data:     data,
-					ParentID: parentID,
-				},
-			},
+			DoOperations: childBlockOps("appendInsert", arg),
 		},
 	}
 
@@ -74,28 +61,14 @@ func prependBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &childBlockArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	data := arg["data"].(string)
-	dataType := arg["dataType"].(string)
-	parentID := arg["parentID"].(string)
-	if "markdown" == dataType {
-		luteEngine := model.NewLute()
-		data = dataBlockDOM(data, luteEngine)
-	}
-
 	transactions := []*model.Transaction{
 		{
-			DoOperations: []*model.Operation{
-				{
-					Action:   "prependInsert",
-					Data:     data,
-					ParentID: parentID,
-				},
-			},
+			DoOperations: childBlockOps("prependInsert", arg),
 		},
 	}
 
@@ -112,40 +85,37 @@ func prependBlock(c *gin.Context) {
 	broadcastTransactions(transactions)
 }
 
+type insertBlockArg struct {
+	DataType   string `json:"dataType" binding:"required,oneof=markdown dom"`
+	Data       string `json:"data"`
+	ParentID   string `json:"parentID"`   // 父块 ID，和 previousID 至少需要传入一个
+	PreviousID string `json:"previousID"` // 前一个块 ID，用于锚定插入位置
+}
+
+type childBlockArg struct {
+	DataType string `json:"dataType" binding:"required,oneof=markdown dom"`
+	Data     string `json:"data"`
+	ParentID string `json:"parentID" binding:"required"`
+}
+
+type updateBlockArg struct {
+	DataType string `json:"dataType" binding:"required,oneof=markdown dom"`
+	Data     string `json:"data"`
+	ID       string `json:"id" binding:"required"`
+}
+
 func insertBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &insertBlockArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	data := arg["data"].(string)
-	dataType := arg["dataType"].(string)
-	var parentID, previousID string
-	if nil != arg["parentID"] {
-		parentID = arg["parentID"].(string)
-	}
-	if nil != arg["previousID"] {
-		previousID = arg["previousID"].(string)
-	}
-
-	if "markdown" == dataType {
-		luteEngine := model.NewLute()
-		data = dataBlockDOM(data, luteEngine)
-	}
-
 	transactions := []*model.Transaction{
 		{
-			DoOperations: []*model.Operation{
-				{
-					Action:     "insert",
-					Data:       data,
-					ParentID:   parentID,
-					PreviousID: previousID,
-				},
-			},
+			DoOperations: insertBlockOps(arg),
 		},
 	}
 
@@ -166,76 +136,22 @@ func updateBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &updateBlockArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	data := arg["data"].(string)
-	dataType := arg["dataType"].(string)
-	id := arg["id"].(string)
-
-	luteEngine := model.NewLute()
-	if "markdown" == dataType {
-		data = dataBlockDOM(data, luteEngine)
-	}
-	tree := luteEngine.BlockDOM2Tree(data)
-	if nil == tree || nil == tree.Root || nil == tree.Root.FirstChild {
-		ret.Code = -1
-		ret.Msg = "parse tree failed"
-		return
-	}
-
-	block, err := model.GetBlock(id)
+	ops, err := updateBlockOps(arg)
 	if nil != err {
 		ret.Code = -1
-		ret.Msg = "get block failed: " + err.Error()
+		ret.Msg = err.Error()
 		return
 	}
 
-	var transactions []*model.Transaction
-	if "NodeDocument" == block.Type {
-		oldTree, err := model.LoadTree(block.Box, block.Path)
-		if nil != err {
-			ret.Code = -1
-			ret.Msg = "load tree failed: " + err.Error()
-			return
-		}
-		var toRemoves []*ast.Node
-		var ops []*model.Operation
-		for n := oldTree.Root.FirstChild; nil != n; n = n.Next {
-			toRemoves = append(toRemoves, n)
-			ops = append(ops, &model.Operation{Action: "delete", ID: n.ID})
-		}
-		for _, n := range toRemoves {
-			n.Unlink()
-		}
-		ops = append(ops, &model.Operation{Action: "appendInsert", Data: data, ParentID: id})
-		transactions = append(transactions, &model.Transaction{
+	transactions := []*model.Transaction{
+		{
 			DoOperations: ops,
-		})
-	} else {
-		if "NodeListItem" == block.Type {
-			// 使用 API `api/block/updateBlock` 更新列表项时渲染错误 https://github.com/siyuan-note/siyuan/issues/4658
-
-			tree.Root.AppendChild(tree.Root.FirstChild.FirstChild) // 将列表下的第一个列表项移到文档结尾，移动以后根下面直接挂列表项，渲染器可以正常工作
-			tree.Root.FirstChild.Unlink()                          // 删除列表
-			tree.Root.FirstChild.Unlink()                          // 继续删除列表 IAL
-		}
-		tree.Root.FirstChild.SetIALAttr("id", id)
-
-		data = luteEngine.Tree2BlockDOM(tree, luteEngine.RenderOptions)
-		transactions = []*model.Transaction{
-			{
-				DoOperations: []*model.Operation{
-					{
-						Action: "update",
-						ID:     id,
-						Data:   data,
-					},
-				},
-			},
-		}
+		},
 	}
 
 	err = model.PerformTransactions(&transactions)
@@ -255,21 +171,14 @@ func deleteBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &idArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	id := arg["id"].(string)
-
 	transactions := []*model.Transaction{
 		{
-			DoOperations: []*model.Operation{
-				{
-					Action: "delete",
-					ID:     id,
-				},
-			},
+			DoOperations: deleteBlockOps(arg),
 		},
 	}
 
@@ -284,6 +193,80 @@ func deleteBlock(c *gin.Context) {
 	broadcastTransactions(transactions)
 }
 
+func childBlockOps(action string, arg *childBlockArg) []*model.Operation {
+	data := arg.Data
+	if "markdown" == arg.DataType {
+		luteEngine := model.NewLute()
+		data = dataBlockDOM(data, luteEngine)
+	}
+	return []*model.Operation{{Action: action, Data: data, ParentID: arg.ParentID}}
+}
+
+func insertBlockOps(arg *insertBlockArg) []*model.Operation {
+	data := arg.Data
+	if "markdown" == arg.DataType {
+		luteEngine := model.NewLute()
+		data = dataBlockDOM(data, luteEngine)
+	}
+	return []*model.Operation{{Action: "insert", Data: data, ParentID: arg.ParentID, PreviousID: arg.PreviousID}}
+}
+
+func updateBlockOps(arg *updateBlockArg) (ret []*model.Operation, err error) {
+	data := arg.Data
+	id := arg.ID
+
+	luteEngine := model.NewLute()
+	if "markdown" == arg.DataType {
+		data = dataBlockDOM(data, luteEngine)
+	}
+	tree := luteEngine.BlockDOM2Tree(data)
+	if nil == tree || nil == tree.Root || nil == tree.Root.FirstChild {
+		err = errors.New("parse tree failed")
+		return
+	}
+
+	block, err := model.GetBlock(id)
+	if nil != err {
+		err = errors.New("get block failed: " + err.Error())
+		return
+	}
+
+	if "NodeDocument" == block.Type {
+		oldTree, loadErr := model.LoadTree(block.Box, block.Path)
+		if nil != loadErr {
+			err = errors.New("load tree failed: " + loadErr.Error())
+			return
+		}
+		var toRemoves []*ast.Node
+		for n := oldTree.Root.FirstChild; nil != n; n = n.Next {
+			toRemoves = append(toRemoves, n)
+			ret = append(ret, &model.Operation{Action: "delete", ID: n.ID})
+		}
+		for _, n := range toRemoves {
+			n.Unlink()
+		}
+		ret = append(ret, &model.Operation{Action: "appendInsert", Data: data, ParentID: id})
+		return
+	}
+
+	if "NodeListItem" == block.Type {
+		// 使用 API `api/block/updateBlock` 更新列表项时渲染错误 https://github.com/siyuan-note/siyuan/issues/4658
+
+		tree.Root.AppendChild(tree.Root.FirstChild.FirstChild) // 将列表下的第一个列表项移到文档结尾，移动以后根下面直接挂列表项，渲染器可以正常工作
+		tree.Root.FirstChild.Unlink()                          // 删除列表
+		tree.Root.FirstChild.Unlink()                          // 继续删除列表 IAL
+	}
+	tree.Root.FirstChild.SetIALAttr("id", id)
+
+	data = luteEngine.Tree2BlockDOM(tree, luteEngine.RenderOptions)
+	ret = []*model.Operation{{Action: "update", ID: id, Data: data}}
+	return
+}
+
+func deleteBlockOps(arg *idArg) []*model.Operation {
+	return []*model.Operation{{Action: "delete", ID: arg.ID}}
+}
+
 func broadcastTransactions(transactions []*model.Transaction) {
 	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast, util.PushModeBroadcast)
 	evt.Data = transactions
@@ -36,7 +36,7 @@ func renameBookmark(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("oldBookmark"), util.StrArg("newBookmark"))
 	if !ok {
 		return
 	}
@@ -30,13 +30,13 @@ func exportDataInFolder(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("folder"))
 	if !ok {
 		return
 	}
 
 	exportFolder := arg["folder"].(string)
-	err := model.ExportDataInFolder(exportFolder)
+	err := model.ExportDataInFolder(exportFolder, util.ProgressOf(c.Request.Context()))
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -49,7 +49,7 @@ func exportData(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	zipPath := model.ExportData()
+	zipPath := model.ExportData(util.ProgressOf(c.Request.Context()))
 	ret.Data = map[string]interface{}{
 		"zip": zipPath,
 	}
@@ -59,7 +59,7 @@ func batchExportMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("path"))
 	if !ok {
 		return
 	}
@@ -77,7 +77,7 @@ func exportMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -90,52 +90,242 @@ func exportMd(c *gin.Context) {
 	}
 }
 
-func exportSY(c *gin.Context) {
+func exportEPUB(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional(), util.StrArg("notebook").Optional())
+	if !ok {
+		return
+	}
+
+	var id, notebook string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	name, zipPath, err := model.ExportEPUB(notebook, id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
+func exportSite(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional(), util.StrArg("notebook").Optional(), util.StrArg("path").Optional())
+	if !ok {
+		return
+	}
+
+	var id, notebook, p string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	if nil != arg["path"] {
+		p = arg["path"].(string)
+	}
+	name, zipPath, err := model.ExportSite(notebook, p, id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
+func exportSiteMarkdown(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional(), util.StrArg("notebook").Optional(), util.StrArg("path").Optional(), util.StrArg("generator").Optional(), util.StrArg("frontMatter").Optional(), util.StrArg("assetsPrefix").Optional())
+	if !ok {
+		return
+	}
+
+	var id, notebook, p, generator, frontMatter, assetsPrefix string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	if nil != arg["path"] {
+		p = arg["path"].(string)
+	}
+	if nil != arg["generator"] {
+		generator = arg["generator"].(string)
+	}
+	if nil != arg["frontMatter"] {
+		frontMatter = arg["frontMatter"].(string)
+	}
+	if nil != arg["assetsPrefix"] {
+		assetsPrefix = arg["assetsPrefix"].(string)
+	}
+	name, zipPath, err := model.ExportSiteMarkdown(notebook, p, id, generator, frontMatter, assetsPrefix)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
+func exportOPML(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional(), util.StrArg("notebook").Optional())
+	if !ok {
+		return
+	}
+
+	var id, notebook string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	name, opmlPath, err := model.ExportOPML(notebook, id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"path": opmlPath,
+	}
+}
+
+func exportLaTeX(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
 
 	id := arg["id"].(string)
-	name, zipPath := model.ExportSY(id)
+	name, zipPath, err := model.ExportLaTeX(id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
 	}
 }
 
-func exportMdContent(c *gin.Context) {
+func getLaTeXPreamble(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"preamble": model.GetLaTeXPreamble(),
+	}
+}
+
+func setLaTeXPreamble(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("preamble"))
+	if !ok {
+		return
+	}
+
+	preamble := arg["preamble"].(string)
+	if err := model.SetLaTeXPreamble(preamble); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"preamble": model.GetLaTeXPreamble(),
+	}
+}
+
+func exportSY(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
 
 	id := arg["id"].(string)
-	hPath, content := model.ExportMarkdownContent(id)
+	name, zipPath := model.ExportSY(id)
 	ret.Data = map[string]interface{}{
-		"hPath":   hPath,
-		"content": content,
+		"name": name,
+		"zip":  zipPath,
 	}
 }
 
+type exportMdContentResult struct {
+	HPath   string `json:"hPath"`
+	Content string `json:"content"`
+}
+
+func exportMdContent(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg := &idArg{}
+	if !util.BindJsonArg(c, ret, arg) {
+		return
+	}
+
+	id := arg.ID
+	hPath, content := model.ExportMarkdownContent(id)
+	ret.Data = &exportMdContentResult{HPath: hPath, Content: content}
+}
+
 func exportDocx(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.StrArg("savePath"), util.BoolArg("pandoc").Optional())
 	if !ok {
 		return
 	}
 
 	id := arg["id"].(string)
 	savePath := arg["savePath"].(string)
-	err := model.ExportDocx(id, savePath)
+	// 未指定导出方式时，如果配置了 Pandoc 则使用 Pandoc 导出
+	pandoc := util.IsValidPandocBin(model.Conf.Export.PandocBin)
+	if pandocArg := arg["pandoc"]; nil != pandocArg {
+		pandoc = pandocArg.(bool)
+	}
+	err := model.ExportDocx(id, savePath, pandoc)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -148,7 +338,7 @@ func exportMdHTML(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.StrArg("savePath"))
 	if !ok {
 		return
 	}
@@ -167,7 +357,7 @@ func exportHTML(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.BoolArg("pdf"), util.StrArg("savePath"))
 	if !ok {
 		return
 	}
@@ -187,7 +377,7 @@ func addPDFOutline(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.StrArg("path"))
 	if !ok {
 		return
 	}
@@ -206,7 +396,7 @@ func exportPreview(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
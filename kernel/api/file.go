@@ -33,15 +33,27 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+type getFileArg struct {
+	Path string `json:"path" binding:"required"` // 工作空间下的文件路径
+}
+
+// putFileArg 仅用于描述 putFile 的表单参数。
+type putFileArg struct {
+	Path    string                `form:"path" binding:"required"` // 工作空间下的文件路径
+	IsDir   bool                  `form:"isDir"`                   // 为 true 时仅创建文件夹，忽略 file
+	ModTime int64                 `form:"modTime"`                 // 最近访问和修改时间，Unix 时间毫秒数
+	File    *multipart.FileHeader `form:"file"`
+}
+
 func getFile(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &getFileArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		c.JSON(http.StatusOK, ret)
 		return
 	}
 
-	filePath := arg["path"].(string)
+	filePath := arg.Path
 	filePath = filepath.Join(util.WorkspaceDir, filePath)
 	info, err := os.Stat(filePath)
 	if os.IsNotExist(err) {
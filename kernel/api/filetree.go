@@ -35,14 +35,14 @@ func refreshFiletree(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	model.RefreshFileTree()
+	model.RefreshFileTree(util.ProgressOf(c.Request.Context()))
 }
 
 func doc2Heading(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("srcID"), util.StrArg("targetID"), util.BoolArg("after"))
 	if !ok {
 		return
 	}
@@ -68,7 +68,7 @@ func heading2Doc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("srcHeadingID"), util.StrArg("targetNoteBook"), util.StrArg("targetPath"))
 	if !ok {
 		return
 	}
@@ -112,7 +112,7 @@ func li2Doc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("srcListItemID"), util.StrArg("targetNoteBook"), util.StrArg("targetPath"))
 	if !ok {
 		return
 	}
@@ -156,13 +156,13 @@ func getHPathByPath(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &docPathArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
-	p := arg["path"].(string)
+	notebook := arg.Notebook
+	p := arg.Path
 
 	hPath, err := model.GetHPathByPath(notebook, p)
 	if nil != err {
@@ -177,12 +177,12 @@ func getHPathByID(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &idArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	id := arg["id"].(string)
+	id := arg.ID
 	hPath, err := model.GetHPathByID(id)
 	if nil != err {
 		ret.Code = -1
@@ -196,7 +196,7 @@ func getFullHPathByID(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -215,15 +215,15 @@ func moveDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &moveDocArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	fromNotebook := arg["fromNotebook"].(string)
-	toNotebook := arg["toNotebook"].(string)
-	fromPath := arg["fromPath"].(string)
-	toPath := arg["toPath"].(string)
+	fromNotebook := arg.FromNotebook
+	toNotebook := arg.ToNotebook
+	fromPath := arg.FromPath
+	toPath := arg.ToPath
 
 	newPath, err := model.MoveDoc(fromNotebook, fromPath, toNotebook, toPath)
 	if nil != err {
@@ -248,13 +248,13 @@ func removeDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &docPathArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
-	p := arg["path"].(string)
+	notebook := arg.Notebook
+	p := arg.Path
 
 	err := model.RemoveDoc(notebook, p)
 	if nil != err {
@@ -275,14 +275,14 @@ func renameDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &renameDocArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
-	p := arg["path"].(string)
-	title := arg["title"].(string)
+	notebook := arg.Notebook
+	p := arg.Path
+	title := arg.Title
 
 	err := model.RenameDoc(notebook, p, title)
 	if nil != err {
@@ -297,7 +297,7 @@ func duplicateDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -322,14 +322,14 @@ func duplicateDoc(c *gin.Context) {
 		return
 	}
 
-	pushCreate(box, p, tree.Root.ID, arg)
+	pushCreate(box, p, tree.Root.ID, arg["callback"])
 }
 
 func createDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("path"), util.StrArg("title"), util.StrArg("md"))
 	if !ok {
 		return
 	}
@@ -355,14 +355,14 @@ func createDoc(c *gin.Context) {
 		return
 	}
 
-	pushCreate(box, p, tree.Root.ID, arg)
+	pushCreate(box, p, tree.Root.ID, arg["callback"])
 }
 
 func createDailyNote(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"))
 	if !ok {
 		return
 	}
@@ -402,18 +402,43 @@ func createDailyNote(c *gin.Context) {
 	util.PushEvent(evt)
 }
 
+type docPathArg struct {
+	Notebook string `json:"notebook" binding:"required"`
+	Path     string `json:"path" binding:"required"`
+}
+
+type createDocWithMdArg struct {
+	Notebook string      `json:"notebook" binding:"required"`
+	Path     string      `json:"path" binding:"required"` // 人类可读路径，比如 /foo/bar
+	Markdown string      `json:"markdown"`
+	Callback interface{} `json:"callback,omitempty"`
+}
+
+type renameDocArg struct {
+	Notebook string `json:"notebook" binding:"required"`
+	Path     string `json:"path" binding:"required"`
+	Title    string `json:"title"`
+}
+
+type moveDocArg struct {
+	FromNotebook string `json:"fromNotebook" binding:"required"`
+	FromPath     string `json:"fromPath" binding:"required"`
+	ToNotebook   string `json:"toNotebook" binding:"required"`
+	ToPath       string `json:"toPath" binding:"required"`
+}
+
 func createDocWithMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &createDocWithMdArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
-	hPath := arg["path"].(string)
-	markdown := arg["markdown"].(string)
+	notebook := arg.Notebook
+	hPath := arg.Path
+	markdown := arg.Markdown
 
 	baseName := path.Base(hPath)
 	dir := path.Dir(hPath)
@@ -438,14 +463,14 @@ func createDocWithMd(c *gin.Context) {
 	box := model.Conf.Box(notebook)
 	b, _ := model.GetBlock(id)
 	p := b.Path
-	pushCreate(box, p, id, arg)
+	pushCreate(box, p, id, arg.Callback)
 }
 
 func lockFile(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -463,7 +488,7 @@ func getDocNameTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"))
 	if !ok {
 		return
 	}
@@ -493,7 +518,7 @@ func changeSort(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.ArrArg("paths", util.ArgString))
 	if !ok {
 		return
 	}
@@ -511,20 +536,29 @@ func searchDocs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"), util.BoolArg("fuzzy").Optional())
 	if !ok {
 		return
 	}
 
 	k := arg["k"].(string)
-	ret.Data = model.SearchDocsByKeyword(k)
+	fuzzyArg := arg["fuzzy"]
+	if nil == fuzzyArg {
+		ret.Data, _ = model.SearchDocsByKeyword(k, false)
+		return
+	}
+	docs, suggestions := model.SearchDocsByKeyword(k, fuzzyArg.(bool))
+	ret.Data = map[string]interface{}{
+		"docs":        docs,
+		"suggestions": suggestions,
+	}
 }
 
 func listDocsByPath(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("path"), util.NumArg("sort").Optional())
 	if !ok {
 		return
 	}
@@ -561,7 +595,7 @@ func getDoc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.NumArg("index").Optional(), util.StrArg("k").Optional(), util.NumArg("mode").Optional(), util.NumArg("size").Optional())
 	if !ok {
 		return
 	}
@@ -620,7 +654,7 @@ func getDoc(c *gin.Context) {
 	}
 }
 
-func pushCreate(box *model.Box, p, treeID string, arg map[string]interface{}) {
+func pushCreate(box *model.Box, p, treeID string, callback interface{}) {
 	evt := util.NewCmdResult("create", 0, util.PushModeBroadcast, util.PushModeNone)
 	name := path.Base(p)
 	files, _, _ := model.ListDocTree(box.ID, path.Dir(p), model.Conf.FileTree.Sort)
@@ -631,6 +665,6 @@ func pushCreate(box *model.Box, p, treeID string, arg map[string]interface{}) {
 		"name":  name,
 		"id":    treeID,
 	}
-	evt.Callback = arg["callback"]
+	evt.Callback = callback
 	util.PushEvent(evt)
 }
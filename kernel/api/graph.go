@@ -54,12 +54,16 @@ func getGraph(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"), util.StrArg("savedSearch").Optional())
 	if !ok {
 		return
 	}
 
 	query := arg["k"].(string)
+	var savedSearch string
+	if nil != arg["savedSearch"] {
+		savedSearch = arg["savedSearch"].(string)
+	}
 
 	graphConf, err := gulu.JSON.MarshalJSON(arg["conf"])
 	if nil != err {
@@ -78,7 +82,7 @@ func getGraph(c *gin.Context) {
 	model.Conf.Graph.Global = global
 	model.Conf.Save()
 
-	boxID, nodes, links := model.BuildGraph(query)
+	boxID, nodes, links := model.BuildGraph(query, savedSearch)
 	ret.Data = map[string]interface{}{
 		"nodes": nodes,
 		"links": links,
@@ -93,7 +97,7 @@ func getLocalGraph(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"), util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -77,7 +77,7 @@ func getDocHistory(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"))
 	if !ok {
 		return
 	}
@@ -100,7 +100,7 @@ func getDocHistoryContent(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("historyPath"))
 	if !ok {
 		return
 	}
@@ -122,7 +122,7 @@ func rollbackDocHistory(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("historyPath"))
 	if !ok {
 		return
 	}
@@ -145,7 +145,7 @@ func rollbackAssetsHistory(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("historyPath"))
 	if !ok {
 		return
 	}
@@ -163,7 +163,7 @@ func rollbackNotebookHistory(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("historyPath"))
 	if !ok {
 		return
 	}
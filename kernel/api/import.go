@@ -18,6 +18,7 @@ package api
 
 import (
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -32,22 +33,12 @@ func importSY(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(200, ret)
 
-	form, err := c.MultipartForm()
-	if nil != err {
-		util.LogErrorf("parse import .sy.zip failed: %s", err)
-		ret.Code = -1
-		ret.Msg = err.Error()
+	form, ok := util.FormArg(c, ret, "file", "notebook", "toPath")
+	if !ok {
 		return
 	}
 
-	files := form.File["file"]
-	if 1 > len(files) {
-		util.LogErrorf("parse import .sy.zip failed: %s", err)
-		ret.Code = -1
-		ret.Msg = err.Error()
-		return
-	}
-	file := files[0]
+	file := form.File["file"][0]
 	reader, err := file.Open()
 	if nil != err {
 		util.LogErrorf("read import .sy.zip failed: %s", err)
@@ -92,27 +83,258 @@ func importSY(c *gin.Context) {
 	}
 }
 
-func importData(c *gin.Context) {
+func importOPML(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	form, err := c.MultipartForm()
+	form, ok := util.FormArg(c, ret, "file", "notebook")
+	if !ok {
+		return
+	}
+
+	files := form.File["file"]
+	reader, err := files[0].Open()
 	if nil != err {
-		util.LogErrorf("import data failed: %s", err)
+		util.LogErrorf("read import opml failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if nil != err {
+		util.LogErrorf("read import opml failed: %s", err)
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
 	}
 
-	if 1 > len(form.File["file"]) {
-		util.LogErrorf("import data failed: %s", err)
+	notebook := form.Value["notebook"][0]
+	toPath := "/"
+	if 0 < len(form.Value["toPath"]) {
+		toPath = form.Value["toPath"][0]
+	}
+	docs := 0 < len(form.Value["docs"]) && "true" == form.Value["docs"][0]
+	ids, err := model.ImportOPML(notebook, toPath, data, docs)
+	if nil != err {
 		ret.Code = -1
-		ret.Msg = "file not found"
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = ids
+
+	box := model.Conf.Box(notebook)
+	for _, id := range ids {
+		if b, _ := model.GetBlock(id); nil != b {
+			pushCreate(box, b.Path, id, nil)
+		}
+	}
+}
+
+func importCSV(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, ok := util.FormArg(c, ret, "file", "notebook")
+	if !ok {
+		return
+	}
+
+	files := form.File["file"]
+	reader, err := files[0].Open()
+	if nil != err {
+		util.LogErrorf("read import csv failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if nil != err {
+		util.LogErrorf("read import csv failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	notebook := form.Value["notebook"][0]
+	toPath := "/"
+	if 0 < len(form.Value["toPath"]) {
+		toPath = form.Value["toPath"][0]
+	}
+	rowDocs := 0 < len(form.Value["rowDocs"]) && "true" == form.Value["rowDocs"][0]
+	titleColumn := ""
+	if 0 < len(form.Value["titleColumn"]) {
+		titleColumn = form.Value["titleColumn"][0]
+	}
+	ids, err := model.ImportCSV(notebook, toPath, files[0].Filename, data, rowDocs, titleColumn)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = ids
+
+	box := model.Conf.Box(notebook)
+	for _, id := range ids {
+		if b, _ := model.GetBlock(id); nil != b {
+			pushCreate(box, b.Path, id, nil)
+		}
+	}
+}
+
+func importNotion(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, ok := util.FormArg(c, ret, "file", "notebook")
+	if !ok {
+		return
+	}
+
+	files := form.File["file"]
+	writePath, err := saveImportFile(files[0])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer os.RemoveAll(filepath.Dir(writePath))
+
+	notebook := form.Value["notebook"][0]
+	toPath := "/"
+	if 0 < len(form.Value["toPath"]) {
+		toPath = form.Value["toPath"][0]
+	}
+	dbAsDocs := 0 < len(form.Value["dbAsDocs"]) && "true" == form.Value["dbAsDocs"][0]
+	err = model.ImportNotion(writePath, notebook, toPath, dbAsDocs)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func importENEX(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, ok := util.FormArg(c, ret, "file", "notebook")
+	if !ok {
+		return
+	}
+
+	files := form.File["file"]
+	enexPaths, err := saveImportFiles(files)
+	defer removeImportFiles(enexPaths)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	notebook := form.Value["notebook"][0]
+	toPath := "/"
+	if 0 < len(form.Value["toPath"]) {
+		toPath = form.Value["toPath"][0]
+	}
+	err = model.ImportENEX(enexPaths, notebook, toPath)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func importHTML(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, ok := util.FormArg(c, ret, "file", "notebook")
+	if !ok {
+		return
+	}
+
+	files := form.File["file"]
+	htmlPaths, err := saveImportFiles(files)
+	defer removeImportFiles(htmlPaths)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	notebook := form.Value["notebook"][0]
+	toPath := "/"
+	if 0 < len(form.Value["toPath"]) {
+		toPath = form.Value["toPath"][0]
+	}
+	err = model.ImportHTML(htmlPaths, notebook, toPath)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+// saveImportFiles 保存上传的多个导入文件，返回保存的路径。
+func saveImportFiles(files []*multipart.FileHeader) (writePaths []string, err error) {
+	for _, file := range files {
+		writePath, saveErr := saveImportFile(file)
+		if nil != saveErr {
+			err = saveErr
+			return
+		}
+		writePaths = append(writePaths, writePath)
+	}
+	return
+}
+
+// removeImportFiles 删除 saveImportFile 保存的导入文件。
+func removeImportFiles(writePaths []string) {
+	for _, writePath := range writePaths {
+		os.RemoveAll(filepath.Dir(writePath))
+	}
+}
+
+// saveImportFile 将上传的导入文件保存到临时文件夹下的一个新文件夹中，返回保存的路径，文件名保持不变。
+func saveImportFile(file *multipart.FileHeader) (writePath string, err error) {
+	reader, err := file.Open()
+	if nil != err {
+		util.LogErrorf("read import file failed: %s", err)
+		return
+	}
+	defer reader.Close()
+
+	importDir := filepath.Join(util.TempDir, "import", gulu.Rand.String(7))
+	if err = os.MkdirAll(importDir, 0755); nil != err {
+		util.LogErrorf("make import dir [%s] failed: %s", importDir, err)
+		return
+	}
+	writePath = filepath.Join(importDir, filepath.Base(file.Filename))
+	writer, err := os.Create(writePath)
+	if nil != err {
+		util.LogErrorf("create import file [%s] failed: %s", writePath, err)
+		return
+	}
+	defer writer.Close()
+	if _, err = io.Copy(writer, reader); nil != err {
+		util.LogErrorf("write import file [%s] failed: %s", writePath, err)
+	}
+	return
+}
+
+func importData(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, ok := util.FormArg(c, ret, "file")
+	if !ok {
 		return
 	}
 
 	tmpImport := filepath.Join(util.TempDir, "import")
-	err = os.MkdirAll(tmpImport, 0755)
+	err := os.MkdirAll(tmpImport, 0755)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = "create temp import dir failed"
@@ -162,7 +384,7 @@ func importStdMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("localPath"), util.StrArg("toPath"))
 	if !ok {
 		return
 	}
@@ -177,3 +399,23 @@ func importStdMd(c *gin.Context) {
 		return
 	}
 }
+
+func importVault(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("localPath"), util.StrArg("toPath"))
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	localPath := arg["localPath"].(string)
+	toPath := arg["toPath"].(string)
+	err := model.ImportVault(localPath, notebook, toPath)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
@@ -29,7 +29,7 @@ func removeShorthands(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ArrArg("ids", util.ArgString))
 	if !ok {
 		return
 	}
@@ -52,7 +52,7 @@ func getShorthands(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.NumArg("page"))
 	if !ok {
 		return
 	}
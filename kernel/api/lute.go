@@ -23,9 +23,7 @@ import (
 
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
-	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
-	"github.com/88250/protyle"
 	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -35,7 +33,7 @@ func copyStdMarkdown(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -48,40 +46,19 @@ func html2BlockDOM(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("dom"))
 	if !ok {
 		return
 	}
 
 	dom := arg["dom"].(string)
 	luteEngine := model.NewLute()
-	markdown, err := luteEngine.HTML2Markdown(dom)
+	tree, err := model.HTML2Tree(dom, luteEngine)
 	if nil != err {
 		ret.Data = "Failed to convert"
 		return
 	}
 
-	var unlinks []*ast.Node
-	tree := parse.Parse("", []byte(markdown), luteEngine.ParseOptions)
-	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
-		if !entering {
-			return ast.WalkContinue
-		}
-
-		if ast.NodeListItem == n.Type && nil == n.FirstChild {
-			newNode := protyle.NewParagraph()
-			n.AppendChild(newNode)
-			n.SetIALAttr("updated", util.TimeFromID(newNode.ID))
-			return ast.WalkSkipChildren
-		} else if ast.NodeBlockquote == n.Type && nil == n.FirstChild.Next {
-			unlinks = append(unlinks, n)
-		}
-		return ast.WalkContinue
-	})
-	for _, n := range unlinks {
-		n.Unlink()
-	}
-
 	if "std" == model.Conf.System.Container {
 		// 处理本地资源文件复制
 		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
@@ -129,7 +106,7 @@ func spinBlockDOM(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("dom"))
 	if !ok {
 		return
 	}
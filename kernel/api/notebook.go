@@ -17,11 +17,13 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -30,7 +32,7 @@ func setNotebookIcon(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("notebook"), util.StrArg("icon"))
 	if !ok {
 		return
 	}
@@ -44,7 +46,7 @@ func changeSortNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ArrArg("notebooks", util.ArgString))
 	if !ok {
 		return
 	}
@@ -57,17 +59,50 @@ func changeSortNotebook(c *gin.Context) {
 	model.ChangeBoxSort(ids)
 }
 
+type notebookArg struct {
+	Notebook string      `json:"notebook" binding:"required"`
+	Callback interface{} `json:"callback,omitempty"`
+}
+
+type renameNotebookArg struct {
+	Notebook string `json:"notebook" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+}
+
+type createNotebookArg struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type createNotebookResult struct {
+	Notebook *model.Box `json:"notebook"`
+}
+
+type getNotebookConfResult struct {
+	Box  string        `json:"box"`
+	Name string        `json:"name"`
+	Conf *conf.BoxConf `json:"conf"`
+}
+
+type setNotebookConfArg struct {
+	Notebook string          `json:"notebook" binding:"required"`
+	Conf     json.RawMessage `json:"conf" binding:"required"` // 只更新传入的配置项
+}
+
+type lsNotebooksResult struct {
+	Notebooks []*model.Box `json:"notebooks"`
+}
+
 func renameNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &renameNotebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
-	name := arg["name"].(string)
+	notebook := arg.Notebook
+	name := arg.Name
 	err := model.RenameBox(notebook, name)
 	if nil != err {
 		ret.Code = -1
@@ -88,12 +123,12 @@ func removeNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &notebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
+	notebook := arg.Notebook
 	err := model.RemoveBox(notebook)
 	if nil != err {
 		ret.Code = -1
@@ -105,7 +140,7 @@ func removeNotebook(c *gin.Context) {
 	evt.Data = map[string]interface{}{
 		"box": notebook,
 	}
-	evt.Callback = arg["callback"]
+	evt.Callback = arg.Callback
 	util.PushEvent(evt)
 }
 
@@ -113,12 +148,12 @@ func createNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &createNotebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	name := arg["name"].(string)
+	name := arg.Name
 	id, err := model.CreateBox(name)
 	if nil != err {
 		ret.Code = -1
@@ -133,9 +168,7 @@ func createNotebook(c *gin.Context) {
 		return
 	}
 
-	ret.Data = map[string]interface{}{
-		"notebook": model.Conf.Box(id),
-	}
+	ret.Data = &createNotebookResult{Notebook: model.Conf.Box(id)}
 
 	evt := util.NewCmdResult("createnotebook", 0, util.PushModeBroadcast, util.PushModeNone)
 	evt.Data = map[string]interface{}{
@@ -149,12 +182,12 @@ func openNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &notebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
+	notebook := arg.Notebook
 	util.PushMsg(model.Conf.Language(45), 1000*60*15)
 	existed, err := model.Mount(notebook)
 	if nil != err {
@@ -168,7 +201,7 @@ func openNotebook(c *gin.Context) {
 		"box":     model.Conf.Box(notebook),
 		"existed": existed,
 	}
-	evt.Callback = arg["callback"]
+	evt.Callback = arg.Callback
 	util.PushEvent(evt)
 }
 
@@ -176,12 +209,12 @@ func closeNotebook(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &notebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
+	notebook := arg.Notebook
 	model.Unmount(notebook)
 }
 
@@ -189,41 +222,30 @@ func getNotebookConf(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &notebookArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
+	notebook := arg.Notebook
 	box := model.Conf.Box(notebook)
-	ret.Data = map[string]interface{}{
-		"box":  box.ID,
-		"name": box.Name,
-		"conf": box.GetConf(),
-	}
+	ret.Data = &getNotebookConfResult{Box: box.ID, Name: box.Name, Conf: box.GetConf()}
 }
 
 func setNotebookConf(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &setNotebookConfArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	notebook := arg["notebook"].(string)
+	notebook := arg.Notebook
 	box := model.Conf.Box(notebook)
 
-	param, err := gulu.JSON.MarshalJSON(arg["conf"])
-	if nil != err {
-		ret.Code = -1
-		ret.Msg = err.Error()
-		return
-	}
-
 	boxConf := box.GetConf()
-	if err = gulu.JSON.UnmarshalJSON(param, boxConf); nil != err {
+	if err := gulu.JSON.UnmarshalJSON(arg.Conf, boxConf); nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
@@ -271,7 +293,5 @@ func lsNotebooks(c *gin.Context) {
 		return
 	}
 
-	ret.Data = map[string]interface{}{
-		"notebooks": notebooks,
-	}
+	ret.Data = &lsNotebooksResult{Notebooks: notebooks}
 }
@@ -0,0 +1,341 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type idArg struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// apiSpec 描述了一个接口的参数和返回值，用于生成 OpenAPI 文档。
+type apiSpec struct {
+	summary string
+	arg     interface{} // 参数结构体，为 nil 时表示没有参数
+	form    bool        // 参数使用 HTTP Multipart 表单
+	data    interface{} // 返回值中 data 字段的类型，为 nil 时表示 data 为空
+	raw     bool        // 直接返回文件内容而不是 {code, msg, data}
+	noAuth  bool        // 不需要鉴权
+}
+
+// apiSpecs 是公开接口（API.md）的描述，没有描述的接口在 OpenAPI 文档中使用任意 JSON 对象作为参数和返回值。
+var apiSpecs = map[string]*apiSpec{
+	"/api/notebook/lsNotebooks":     {summary: "List notebooks", data: lsNotebooksResult{}},
+	"/api/notebook/openNotebook":    {summary: "Open a notebook", arg: notebookArg{}},
+	"/api/notebook/closeNotebook":   {summary: "Close a notebook", arg: notebookArg{}},
+	"/api/notebook/renameNotebook":  {summary: "Rename a notebook", arg: renameNotebookArg{}},
+	"/api/notebook/createNotebook":  {summary: "Create a notebook", arg: createNotebookArg{}, data: createNotebookResult{}},
+	"/api/notebook/removeNotebook":  {summary: "Remove a notebook", arg: notebookArg{}},
+	"/api/notebook/getNotebookConf": {summary: "Get notebook configuration", arg: notebookArg{}, data: getNotebookConfResult{}},
+	"/api/notebook/setNotebookConf": {summary: "Save notebook configuration", arg: setNotebookConfArg{}, data: conf.BoxConf{}},
+
+	"/api/filetree/createDocWithMd": {summary: "Create a document with Markdown", arg: createDocWithMdArg{}, data: ""},
+	"/api/filetree/renameDoc":       {summary: "Rename a document", arg: renameDocArg{}},
+	"/api/filetree/removeDoc":       {summary: "Remove a document", arg: docPathArg{}},
+	"/api/filetree/moveDoc":         {summary: "Move a document", arg: moveDocArg{}},
+	"/api/filetree/getHPathByPath":  {summary: "Get human-readable path based on path", arg: docPathArg{}, data: ""},
+	"/api/filetree/getHPathByID":    {summary: "Get human-readable path based on ID", arg: idArg{}, data: ""},
+
+	"/api/asset/upload": {summary: "Upload assets", arg: uploadArg{}, form: true, data: uploadResult{}},
+
+	"/api/block/insertBlock":  {summary: "Insert blocks", arg: insertBlockArg{}, data: []*model.Transaction{}},
+	"/api/block/prependBlock": {summary: "Prepend blocks", arg: childBlockArg{}, data: []*model.Transaction{}},
+	"/api/block/appendBlock":  {summary: "Append blocks", arg: childBlockArg{}, data: []*model.Transaction{}},
+	"/api/block/updateBlock":  {summary: "Update a block", arg: updateBlockArg{}, data: []*model.Transaction{}},
+	"/api/block/deleteBlock":  {summary: "Delete a block", arg: idArg{}, data: []*model.Transaction{}},
+
+	"/api/attr/setBlockAttrs": {summary: "Set block attributes", arg: setBlockAttrsArg{}},
+	"/api/attr/getBlockAttrs": {summary: "Get block attributes", arg: idArg{}, data: map[string]string{}},
+
+	"/api/query/sql": {summary: "Execute SQL query", arg: sqlArg{}, data: []map[string]interface{}{}},
+
+	"/api/search/fullTextSearchBlock": {summary: "Search blocks", arg: fullTextSearchBlockArg{}, data: fullTextSearchBlockResult{}},
+
+	"/api/batch": {summary: "Execute requests in a batch", arg: batchArg{}, data: batchResult{}},
+
+	"/api/template/render": {summary: "Render a template", arg: renderTemplateArg{}, data: renderTemplateResult{}},
+
+	"/api/file/getFile": {summary: "Get file", arg: getFileArg{}, raw: true},
+	"/api/file/putFile": {summary: "Put file", arg: putFileArg{}, form: true},
+
+	"/api/export/exportMdContent": {summary: "Export Markdown", arg: idArg{}, data: exportMdContentResult{}},
+
+	"/api/system/bootProgress": {summary: "Get boot progress", data: bootProgressResult{}, noAuth: true},
+	"/api/system/version":      {summary: "Get system version", data: "", noAuth: true},
+	"/api/system/currentTime":  {summary: "Get the current time of the system", data: int64(0), noAuth: true},
+	"/api/system/uiproc":       {arg: map[string]interface{}{}, noAuth: true},
+	"/api/system/loginAuth":    {arg: map[string]interface{}{}, noAuth: true},
+	"/api/system/logoutAuth":   {noAuth: true},
+}
+
+// openAPI 返回根据路由生成的 OpenAPI 3 文档。
+func openAPI(ginServer *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, genOpenAPI(ginServer.Routes(), "http://"+c.Request.Host))
+	}
+}
+
+func genOpenAPI(routes gin.RoutesInfo, serverURL string) (ret map[string]interface{}) {
+	g := &openAPIGenerator{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/api/") || "/api/openapi.json" == route.Path {
+			continue
+		}
+
+		operations, _ := paths[route.Path].(map[string]interface{})
+		if nil == operations {
+			operations = map[string]interface{}{}
+			paths[route.Path] = operations
+		}
+		operations[strings.ToLower(route.Method)] = g.operation(route.Method, route.Path)
+	}
+
+	ret = map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "SiYuan API",
+			"version": util.Ver,
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": serverURL}},
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{"token": []string{}}},
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "Token xxx，xxx 为设置 - 关于中的 API token",
+				},
+			},
+		},
+	}
+	return
+}
+
+type openAPIGenerator struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func (g *openAPIGenerator) operation(method, p string) (ret map[string]interface{}) {
+	group := strings.Split(strings.TrimPrefix(p, "/api/"), "/")[0]
+	operationID := path.Base(p)
+	if group != operationID {
+		operationID = group + strings.ToUpper(operationID[:1]) + operationID[1:]
+	}
+	if "POST" != method {
+		operationID = strings.ToLower(method) + strings.ToUpper(operationID[:1]) + operationID[1:]
+	}
+	ret = map[string]interface{}{
+		"operationId": operationID,
+		"tags":        []string{group},
+	}
+
+	spec := apiSpecs[p]
+	if nil == spec {
+		ret["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}},
+		}
+		ret["responses"] = g.responses(g.result(map[string]interface{}{}))
+		return
+	}
+
+	if "" != spec.summary {
+		ret["summary"] = spec.summary
+	}
+	if spec.noAuth {
+		ret["security"] = []interface{}{}
+	}
+	if nil != spec.arg && "GET" != method {
+		contentType := "application/json"
+		if spec.form {
+			contentType = "multipart/form-data"
+		}
+		ret["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{contentType: map[string]interface{}{"schema": g.schema(reflect.TypeOf(spec.arg))}},
+		}
+	}
+	if spec.raw {
+		ret["responses"] = g.responses(map[string]interface{}{
+			"description": "文件内容",
+			"content":     map[string]interface{}{"application/octet-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}},
+		})
+		return
+	}
+	var data map[string]interface{}
+	if nil == spec.data {
+		data = map[string]interface{}{"nullable": true}
+	} else {
+		data = g.schema(reflect.TypeOf(spec.data))
+	}
+	ret["responses"] = g.responses(g.result(data))
+	return
+}
+
+// responses 返回接口的响应，参数校验失败时 HTTP 状态码为 400。
+func (g *openAPIGenerator) responses(ok map[string]interface{}) map[string]interface{} {
+	argErr := g.result(g.schema(reflect.TypeOf(util.ArgErr{})))
+	argErr["description"] = "参数校验失败，code 为 4000（请求体不是 JSON 对象，data 为 null）、4001（缺少必填参数）或者 4002（参数类型或者取值不合法）"
+	return map[string]interface{}{"200": ok, "400": argErr}
+}
+
+// result 返回 {code, msg, data} 结构的响应。
+func (g *openAPIGenerator) result(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": "code 非 0 时表示异常，msg 为错误信息",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"code", "msg", "data"},
+					"properties": map[string]interface{}{
+						"code": map[string]interface{}{"type": "integer"},
+						"msg":  map[string]interface{}{"type": "string"},
+						"data": data,
+					},
+				},
+			},
+		},
+	}
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// schema 根据 Go 类型生成 JSON Schema，命名结构体放到 components 中通过 $ref 引用。
+func (g *openAPIGenerator) schema(t reflect.Type) map[string]interface{} {
+	for reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+
+	switch t {
+	case rawMessageType:
+		return map[string]interface{}{"type": "object"}
+	case fileHeaderType:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if reflect.Uint8 == t.Elem().Kind() {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if "" == t.Name() {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+			if _, exists := g.schemas[name]; exists {
+				pkg := path.Base(t.PkgPath())
+				name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+			}
+			g.names[t] = name
+			g.schemas[name] = map[string]interface{}{} // 占位，避免递归类型无限展开
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	g.fields(t, properties, &required)
+	ret := map[string]interface{}{"type": "object", "properties": properties}
+	if 0 < len(required) {
+		ret["required"] = required
+	}
+	return ret
+}
+
+func (g *openAPIGenerator) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if "" == tag {
+			tag = field.Tag.Get("form")
+		}
+		name := strings.Split(tag, ",")[0]
+		if "-" == name {
+			continue
+		}
+		if field.Anonymous && "" == name {
+			embedded := field.Type
+			if reflect.Ptr == embedded.Kind() {
+				embedded = embedded.Elem()
+			}
+			if reflect.Struct == embedded.Kind() {
+				g.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if "" == name {
+			name = field.Name
+		}
+
+		schema := g.schema(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			if "required" == rule {
+				*required = append(*required, name)
+			} else if strings.HasPrefix(rule, "oneof=") {
+				schema["enum"] = strings.Fields(strings.TrimPrefix(rule, "oneof="))
+			}
+		}
+		properties[name] = schema
+	}
+}
@@ -29,7 +29,7 @@ func getDocOutline(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional())
 	if !ok {
 		return
 	}
@@ -29,7 +29,7 @@ func refreshBacklink(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
 	if !ok {
 		return
 	}
@@ -42,7 +42,7 @@ func getBacklink(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id").Optional(), util.StrArg("k"), util.StrArg("mk"), util.NumArg("beforeLen"))
 	if !ok {
 		return
 	}
@@ -72,7 +72,7 @@ func createBacklink(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("defID"), util.StrArg("refID"), util.StrArg("refText"), util.BoolArg("isDynamic"))
 	if !ok {
 		return
 	}
@@ -32,9 +32,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/system/uiproc", addUIProcess)
 	ginServer.Handle("POST", "/api/system/loginAuth", model.LoginAuth)
 	ginServer.Handle("POST", "/api/system/logoutAuth", model.LogoutAuth)
+	ginServer.Handle("GET", "/api/openapi.json", openAPI(ginServer))
 
 	// 需要鉴权
 
+	ginServer.Handle("GET", "/metrics", model.CheckAuth, metrics)
 	ginServer.Handle("POST", "/api/system/getEmojiConf", model.CheckAuth, getEmojiConf)
 	ginServer.Handle("POST", "/api/system/setAccessAuthCode", model.CheckAuth, setAccessAuthCode)
 	ginServer.Handle("POST", "/api/system/setNetworkServe", model.CheckAuth, setNetworkServe)
@@ -111,6 +113,10 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/lute/copyStdMarkdown", model.CheckAuth, copyStdMarkdown)
 
 	ginServer.Handle("POST", "/api/query/sql", model.CheckAuth, SQL)
+	ginServer.Handle("POST", "/api/query/exportSQL", model.CheckAuth, exportSQL)
+	ginServer.Handle("POST", "/api/query/insertSQLTable", model.CheckAuth, model.CheckReadonly, insertSQLTable)
+
+	ginServer.Handle("POST", "/api/batch", model.CheckAuth, model.CheckReadonly, batch(ginServer))
 
 	ginServer.Handle("POST", "/api/search/searchTag", model.CheckAuth, searchTag)
 	ginServer.Handle("POST", "/api/search/searchTemplate", model.CheckAuth, searchTemplate)
@@ -120,6 +126,14 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/search/fullTextSearchBlock", model.CheckAuth, fullTextSearchBlock)
 	ginServer.Handle("POST", "/api/search/searchAsset", model.CheckAuth, searchAsset)
 	ginServer.Handle("POST", "/api/search/findReplace", model.CheckAuth, findReplace)
+	ginServer.Handle("POST", "/api/search/findReplacePreview", model.CheckAuth, findReplacePreview)
+	ginServer.Handle("POST", "/api/search/findReplaceApply", model.CheckAuth, model.CheckReadonly, findReplaceApply)
+	ginServer.Handle("POST", "/api/search/rollbackFindReplace", model.CheckAuth, model.CheckReadonly, rollbackFindReplace)
+	ginServer.Handle("POST", "/api/search/listSavedSearches", model.CheckAuth, listSavedSearches)
+	ginServer.Handle("POST", "/api/search/getSavedSearch", model.CheckAuth, getSavedSearch)
+	ginServer.Handle("POST", "/api/search/saveSearch", model.CheckAuth, model.CheckReadonly, saveSearch)
+	ginServer.Handle("POST", "/api/search/removeSavedSearch", model.CheckAuth, model.CheckReadonly, removeSavedSearch)
+	ginServer.Handle("POST", "/api/search/execSavedSearch", model.CheckAuth, execSavedSearch)
 
 	ginServer.Handle("POST", "/api/block/getBlockInfo", model.CheckAuth, getBlockInfo)
 	ginServer.Handle("POST", "/api/block/getBlockDOM", model.CheckAuth, getBlockDOM)
@@ -196,14 +210,27 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/export/exportHTML", model.CheckAuth, exportHTML)
 	ginServer.Handle("POST", "/api/export/exportMdHTML", model.CheckAuth, exportMdHTML)
 	ginServer.Handle("POST", "/api/export/exportDocx", model.CheckAuth, exportDocx)
+	ginServer.Handle("POST", "/api/export/exportEPUB", model.CheckAuth, exportEPUB)
+	ginServer.Handle("POST", "/api/export/exportLaTeX", model.CheckAuth, exportLaTeX)
+	ginServer.Handle("POST", "/api/export/exportSite", model.CheckAuth, exportSite)
+	ginServer.Handle("POST", "/api/export/exportSiteMarkdown", model.CheckAuth, exportSiteMarkdown)
+	ginServer.Handle("POST", "/api/export/exportOPML", model.CheckAuth, exportOPML)
+	ginServer.Handle("POST", "/api/export/getLaTeXPreamble", model.CheckAuth, getLaTeXPreamble)
+	ginServer.Handle("POST", "/api/export/setLaTeXPreamble", model.CheckAuth, model.CheckReadonly, setLaTeXPreamble)
 	ginServer.Handle("POST", "/api/export/addPDFOutline", model.CheckAuth, addPDFOutline)
 	ginServer.Handle("POST", "/api/export/preview", model.CheckAuth, exportPreview)
 	ginServer.Handle("POST", "/api/export/exportData", model.CheckAuth, exportData)
 	ginServer.Handle("POST", "/api/export/exportDataInFolder", model.CheckAuth, exportDataInFolder)
 
 	ginServer.Handle("POST", "/api/import/importStdMd", model.CheckAuth, model.CheckReadonly, importStdMd)
+	ginServer.Handle("POST", "/api/import/importVault", model.CheckAuth, model.CheckReadonly, importVault)
 	ginServer.Handle("POST", "/api/import/importData", model.CheckAuth, model.CheckReadonly, importData)
 	ginServer.Handle("POST", "/api/import/importSY", model.CheckAuth, model.CheckReadonly, importSY)
+	ginServer.Handle("POST", "/api/import/importOPML", model.CheckAuth, model.CheckReadonly, importOPML)
+	ginServer.Handle("POST", "/api/import/importCSV", model.CheckAuth, model.CheckReadonly, importCSV)
+	ginServer.Handle("POST", "/api/import/importNotion", model.CheckAuth, model.CheckReadonly, importNotion)
+	ginServer.Handle("POST", "/api/import/importENEX", model.CheckAuth, model.CheckReadonly, importENEX)
+	ginServer.Handle("POST", "/api/import/importHTML", model.CheckAuth, model.CheckReadonly, importHTML)
 
 	ginServer.Handle("POST", "/api/template/render", model.CheckAuth, renderTemplate)
 	ginServer.Handle("POST", "/api/template/docSaveAsTemplate", model.CheckAuth, docSaveAsTemplate)
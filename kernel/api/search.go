@@ -31,7 +31,7 @@ func findReplace(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"), util.StrArg("r"), util.ArrArg("ids", util.ArgString), util.NumArg("method").Optional())
 	if !ok {
 		return
 	}
@@ -43,7 +43,11 @@ func findReplace(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
-	err := model.FindReplace(k, r, ids)
+	method := model.SearchMethodKeyword
+	if nil != arg["method"] {
+		method = int(arg["method"].(float64))
+	}
+	err := model.FindReplace(k, r, ids, method)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -53,21 +57,165 @@ func findReplace(c *gin.Context) {
 	return
 }
 
-func searchAsset(c *gin.Context) {
+func findReplacePreview(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, append(findReplaceOptionRules(), util.StrArg("k"), util.StrArg("r"))...)
 	if !ok {
 		return
 	}
 
 	k := arg["k"].(string)
-	ret.Data = model.SearchAssetsByName(k)
+	r := arg["r"].(string)
+	matches, err := model.FindReplacePreview(k, r, findReplaceOptions(arg))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 3000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"matches": matches,
+	}
+}
+
+type findReplaceMatchesArg struct {
+	Matches []*findReplaceMatchArg `json:"matches" binding:"dive,required"`
+}
+
+type findReplaceMatchArg struct {
+	ID     string `json:"id" binding:"required"`
+	RootID string `json:"rootID" binding:"required"`
+	Field  string `json:"field" binding:"required,oneof=content title name alias memo"`
+	Index  *int   `json:"index" binding:"required,min=0"`
+}
+
+func findReplaceApply(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, append(findReplaceOptionRules(), util.StrArg("k"), util.StrArg("r"), util.ArrArg("matches", util.ArgObject).Optional())...)
+	if !ok {
+		return
+	}
+
+	matchesArg := &findReplaceMatchesArg{}
+	if !util.BindMapArg(c, ret, map[string]interface{}{"matches": arg["matches"]}, matchesArg) {
+		return
+	}
+
+	k := arg["k"].(string)
+	r := arg["r"].(string)
+	var selected []*model.FindReplaceMatch
+	for _, match := range matchesArg.Matches {
+		selected = append(selected, &model.FindReplaceMatch{ID: match.ID, RootID: match.RootID, Field: match.Field, Index: *match.Index})
+	}
+	historyPath, count, err := model.FindReplaceApply(k, r, findReplaceOptions(arg), selected)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 3000, "historyPath": historyPath}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"historyPath": historyPath,
+		"count":       count,
+	}
+}
+
+func rollbackFindReplace(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("historyPath"))
+	if !ok {
+		return
+	}
+
+	historyPath := arg["historyPath"].(string)
+	if err := model.RollbackFindReplace(historyPath); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 3000}
+		return
+	}
+}
+
+// findReplaceOptionRules 返回 findReplaceOptions 使用的参数的校验规则。
+func findReplaceOptionRules() []*util.JsonArgRule {
+	return []*util.JsonArgRule{
+		util.NumArg("method").Optional(),
+		util.BoolArg("caseSensitive").Optional(),
+		util.BoolArg("wholeWord").Optional(),
+		util.BoolArg("ial").Optional(),
+		util.StrArg("path").Optional(),
+		util.ObjArg("types", util.ArgBool).Optional(),
+	}
+}
+
+func findReplaceOptions(arg map[string]interface{}) (ret *model.FindReplaceOptions) {
+	ret = &model.FindReplaceOptions{Method: model.SearchMethodKeyword, CaseSensitive: model.Conf.Search.CaseSensitive}
+	if nil != arg["method"] {
+		ret.Method = int(arg["method"].(float64))
+	}
+	if nil != arg["caseSensitive"] {
+		ret.CaseSensitive = arg["caseSensitive"].(bool)
+	}
+	if nil != arg["wholeWord"] {
+		ret.WholeWord = arg["wholeWord"].(bool)
+	}
+	if nil != arg["ial"] {
+		ret.IAL = arg["ial"].(bool)
+	}
+	if nil != arg["path"] {
+		if p := arg["path"].(string); "" != p {
+			ret.Box = strings.Split(p, "/")[0]
+			ret.Path = strings.TrimPrefix(p, ret.Box)
+		}
+	}
+	if nil != arg["types"] {
+		ret.Types = map[string]bool{}
+		for t, b := range arg["types"].(map[string]interface{}) {
+			ret.Types[t] = b.(bool)
+		}
+	}
 	return
 }
 
-func searchTag(c *gin.Context) {
+func listSavedSearches(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	searches, err := model.ListSavedSearches()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = searches
+}
+
+func getSavedSearch(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	search, err := model.GetSavedSearch(id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = search
+}
+
+func saveSearch(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
@@ -76,6 +224,109 @@ func searchTag(c *gin.Context) {
 		return
 	}
 
+	data, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	search := &model.SavedSearch{}
+	if err = gulu.JSON.UnmarshalJSON(data, search); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	search, err = model.SaveSearch(search)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 3000}
+		return
+	}
+	ret.Data = search
+}
+
+func removeSavedSearch(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"))
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RemoveSavedSearch(id); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 3000}
+		return
+	}
+}
+
+func execSavedSearch(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, append(searchPageRules(), util.StrArg("id"))...)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	page, ok := searchPage(c, ret, arg)
+	if !ok {
+		return
+	}
+	blocks, err := model.ExecSavedSearch(id, nil, page)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	data := map[string]interface{}{
+		"blocks": blocks,
+	}
+	pageData(data, page)
+	ret.Data = data
+}
+
+func searchAsset(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, append(searchPageRules(), util.StrArg("k"))...)
+	if !ok {
+		return
+	}
+
+	k := arg["k"].(string)
+	page, ok := searchPage(c, ret, arg)
+	if !ok {
+		return
+	}
+	data := map[string]interface{}{
+		"assets":   model.SearchAssetsByName(k, page),
+		"contents": []*model.Asset{},
+	}
+	if "" == page.Cursor {
+		// 资源文件文本中的命中只在第一页返回
+		data["contents"] = model.SearchAssetContents(k, false)
+	}
+	pageData(data, page)
+	ret.Data = data
+}
+
+func searchTag(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"))
+	if !ok {
+		return
+	}
+
 	k := arg["k"].(string)
 	tags := model.SearchTags(k)
 	ret.Data = map[string]interface{}{
@@ -88,7 +339,7 @@ func searchWidget(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"))
 	if !ok {
 		return
 	}
@@ -105,7 +356,7 @@ func searchTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("k"))
 	if !ok {
 		return
 	}
@@ -122,7 +373,7 @@ func searchEmbedBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, append(searchPageRules(), util.StrArg("stmt"), util.ArrArg("excludeIDs", util.ArgString), util.NumArg("headingMode").Optional())...)
 	if !ok {
 		return
 	}
@@ -138,18 +389,24 @@ func searchEmbedBlock(c *gin.Context) {
 	if nil != headingModeArg {
 		headingMode = int(headingModeArg.(float64))
 	}
-	blocks := model.SearchEmbedBlock(stmt, excludeIDs, headingMode)
+	page, ok := searchPage(c, ret, arg)
+	if !ok {
+		return
+	}
+	blocks := model.SearchEmbedBlock(stmt, excludeIDs, headingMode, page)
 
-	ret.Data = map[string]interface{}{
+	data := map[string]interface{}{
 		"blocks": blocks,
 	}
+	pageData(data, page)
+	ret.Data = data
 }
 
 func searchRefBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, append(searchPageRules(), util.StrArg("rootID"), util.StrArg("id"), util.StrArg("k"), util.NumArg("beforeLen"))...)
 	if !ok {
 		return
 	}
@@ -158,48 +415,141 @@ func searchRefBlock(c *gin.Context) {
 	id := arg["id"].(string)
 	keyword := arg["k"].(string)
 	beforeLen := int(arg["beforeLen"].(float64))
-	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen)
-	ret.Data = map[string]interface{}{
+	page, ok := searchPage(c, ret, arg)
+	if !ok {
+		return
+	}
+	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen, page)
+	data := map[string]interface{}{
 		"blocks": blocks,
 		"newDoc": newDoc,
 		"k":      html.EscapeHTMLStr(keyword),
 		"reqId":  arg["reqId"],
 	}
+	pageData(data, page)
+	ret.Data = data
+}
+
+type fullTextSearchBlockArg struct {
+	Query       string          `json:"query"`
+	Path        string          `json:"path"`                                   // 搜索路径，以笔记本 ID 开头
+	Types       map[string]bool `json:"types"`                                  // 搜索的块类型，为空时使用搜索设置
+	Method      *int            `json:"method" binding:"omitempty,min=0,max=4"` // 0：关键字，1：查询语法，2：SQL，3：正则表达式，4：模糊匹配
+	QuerySyntax bool            `json:"querySyntax"`                            // 未传入 method 时是否使用查询语法
+	OrderBy     int             `json:"orderBy" binding:"min=0,max=4"`          // 0：相关度，1：更新时间降序，2：更新时间升序，3：创建时间降序，4：创建时间升序
+	Cursor      *string         `json:"cursor"`                                 // 分页游标，第一页传入空字符串，之后传入上一页返回的 nextCursor
+	GroupBy     string          `json:"groupBy" binding:"omitempty,oneof=doc"`  // 为 doc 时按文档分组统计命中数
+}
+
+// searchPageResult 是搜索返回值中的分页字段。
+type searchPageResult struct {
+	NextCursor string             `json:"nextCursor"`     // 下一页游标，为空表示没有更多结果
+	Total      int                `json:"total"`          // 命中总数
+	Docs       []*model.SearchDoc `json:"docs,omitempty"` // 按文档分组时每个文档下的命中数
+}
+
+type fullTextSearchBlockResult struct {
+	Blocks      []*model.Block `json:"blocks"`
+	Suggestions []string       `json:"suggestions"` // 模糊匹配或者关键字搜索没有结果时纠正拼写后的查询
+	Assets      []*model.Asset `json:"assets"`      // 资源文件文本中的命中，只在第一页返回
+	searchPageResult
 }
 
 func fullTextSearchBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &fullTextSearchBlockArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	query := arg["query"].(string)
-	pathArg := arg["path"]
-	var path string
-	if nil != pathArg {
-		path = pathArg.(string)
-	}
+	path := arg.Path
 	var box string
 	if "" != path {
 		box = strings.Split(path, "/")[0]
 		path = strings.TrimPrefix(path, box)
 	}
-	var types map[string]bool
-	if nil != arg["types"] {
-		typesArg := arg["types"].(map[string]interface{})
-		types = map[string]bool{}
-		for t, b := range typesArg {
-			types[t] = b.(bool)
+	method := model.SearchMethodKeyword
+	if nil != arg.Method {
+		method = *arg.Method
+	} else if arg.QuerySyntax {
+		method = model.SearchMethodQuerySyntax
+	}
+	page, ok := newSearchPage(c, ret, arg.Cursor, arg.GroupBy)
+	if !ok {
+		return
+	}
+	blocks, suggestions, err := model.FullTextSearchBlock(arg.Query, box, path, arg.Types, nil, method, arg.OrderBy, page)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	assets := []*model.Asset{}
+	if model.SearchMethodSQL != method && model.SearchMethodRegex != method && "" == page.Cursor {
+		// 资源文件文本中的命中只在第一页返回
+		assets = model.SearchAssetContents(arg.Query, model.SearchMethodQuerySyntax == method)
+		if !page.Paging {
+			// 不分页时资源文件文本中的命中同时合并为引用了该资源文件的块
+			blocks = model.MergeAssetContentBlocks(blocks, assets)
 		}
 	}
-	querySyntaxArg := arg["querySyntax"]
-	var querySyntax bool
-	if nil != querySyntaxArg {
-		querySyntax = querySyntaxArg.(bool)
+	ret.Data = &fullTextSearchBlockResult{
+		Blocks:           blocks,
+		Suggestions:      suggestions,
+		Assets:           assets,
+		searchPageResult: pageResult(page),
+	}
+}
+
+// searchPageRules 返回 searchPage 使用的参数的校验规则。
+func searchPageRules() []*util.JsonArgRule {
+	return []*util.JsonArgRule{util.StrArg("cursor").Optional(), util.StrArg("groupBy").Optional()}
+}
+
+// searchPage 解析 searchPageRules 校验过的分页参数。
+func searchPage(c *gin.Context, result *gulu.Result, arg map[string]interface{}) (ret *model.SearchPage, ok bool) {
+	var cursor *string
+	if cursorArg, isStr := arg["cursor"].(string); isStr {
+		cursor = &cursorArg
+	}
+	groupBy, _ := arg["groupBy"].(string)
+	return newSearchPage(c, result, cursor, groupBy)
+}
+
+// newSearchPage 返回游标 cursor 对应的页，未传入 cursor 时返回第一页，游标不合法时返回 400。
+func newSearchPage(c *gin.Context, result *gulu.Result, cursor *string, groupBy string) (ret *model.SearchPage, ok bool) {
+	var cursorStr string
+	if nil != cursor {
+		cursorStr = *cursor
+	}
+	ret, err := model.NewSearchPage(cursorStr)
+	if nil != err {
+		util.InvalidArg(c, result, &util.ArgErr{Arg: "cursor", Expected: "nextCursor of the previous page"})
+		return
+	}
+	ret.Paging = nil != cursor
+	ret.GroupByDoc = "doc" == groupBy
+	ok = true
+	return
+}
+
+// pageResult 返回 page 的分页游标和命中统计，按文档分组时还包含每个文档下的命中数。
+func pageResult(page *model.SearchPage) (ret searchPageResult) {
+	ret = searchPageResult{NextCursor: page.NextCursor, Total: page.Total}
+	if page.GroupByDoc {
+		ret.Docs = page.Docs
+	}
+	return
+}
+
+// pageData 在返回数据 data 中设置 pageResult 中的字段。
+func pageData(data map[string]interface{}, page *model.SearchPage) {
+	result := pageResult(page)
+	data["nextCursor"] = result.NextCursor
+	data["total"] = result.Total
+	if nil != result.Docs {
+		data["docs"] = result.Docs
 	}
-	blocks := model.FullTextSearchBlock(query, box, path, types, querySyntax)
-	ret.Data = blocks
 }
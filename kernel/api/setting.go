@@ -198,6 +198,9 @@ func setSearch(c *gin.Context) {
 	if 1 > s.Limit {
 		s.Limit = 32
 	}
+	if nil == s.Weight {
+		s.Weight = model.Conf.Search.Weight
+	}
 
 	model.Conf.Search = s
 	model.Conf.Save()
@@ -210,7 +213,7 @@ func setKeymap(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ObjArg("data", util.ArgAny))
 	if !ok {
 		return
 	}
@@ -268,7 +271,7 @@ func getCloudUser(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("token").Optional())
 	if !ok {
 		return
 	}
@@ -297,7 +300,7 @@ func login2faCloudUser(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("token"), util.StrArg("code"))
 	if !ok {
 		return
 	}
@@ -317,7 +320,7 @@ func getCustomCSS(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("theme"))
 	if !ok {
 		return
 	}
@@ -336,7 +339,7 @@ func setCustomCSS(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("theme"), util.ObjArg("css", util.ArgObject))
 	if !ok {
 		return
 	}
@@ -354,7 +357,7 @@ func setEmoji(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ArrArg("emoji", util.ArgString))
 	if !ok {
 		return
 	}
@@ -372,7 +375,7 @@ func setSearchCaseSensitive(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("caseSensitive"))
 	if !ok {
 		return
 	}
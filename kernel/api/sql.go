@@ -21,20 +21,25 @@ import (
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+type sqlArg struct {
+	Stmt string `json:"stmt" binding:"required"`
+}
+
 func SQL(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &sqlArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	stmt := arg["stmt"].(string)
+	stmt := arg.Stmt
 	result, err := sql.Query(stmt)
 	if nil != err {
 		ret.Code = 1
@@ -44,3 +49,97 @@ func SQL(c *gin.Context) {
 
 	ret.Data = result
 }
+
+// attachmentWriter 在第一次写入时设置下载响应头，这样查询出错时仍然可以返回 JSON 结果。
+type attachmentWriter struct {
+	c           *gin.Context
+	filename    string
+	contentType string
+	written     bool
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(p)
+}
+
+func exportSQL(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("stmt"), util.StrArg("format").Optional())
+	if !ok {
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+
+	stmt := arg["stmt"].(string)
+	format := "csv"
+	if nil != arg["format"] {
+		format = arg["format"].(string)
+	}
+	if err := model.CheckQueryExport(stmt, format); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+
+	fileFormat := model.QueryExportFormats[format]
+	writer := &attachmentWriter{c: c, filename: "query" + fileFormat[0], contentType: fileFormat[1]}
+	if err := model.ExportQueryResult(stmt, format, writer); nil != err {
+		if writer.written { // 已经开始输出查询结果，无法再返回错误
+			util.LogErrorf("export sql [%s] result failed: %s", stmt, err)
+			return
+		}
+		ret.Code = -1
+		ret.Msg = err.Error()
+		c.JSON(http.StatusOK, ret)
+	}
+}
+
+func insertSQLTable(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret, util.StrArg("stmt"), util.StrArg("parentID"))
+	if !ok {
+		return
+	}
+
+	stmt := arg["stmt"].(string)
+	parentID := arg["parentID"].(string)
+	md, err := model.QueryResultTable(stmt)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	luteEngine := model.NewLute()
+	transactions := []*model.Transaction{
+		{
+			DoOperations: []*model.Operation{
+				{
+					Action:   "appendInsert",
+					Data:     dataBlockDOM(md, luteEngine),
+					ParentID: parentID,
+				},
+			},
+		},
+	}
+	if err = model.PerformTransactions(&transactions); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	model.WaitForWritingFiles()
+
+	ret.Data = transactions
+	broadcastTransactions(transactions)
+}
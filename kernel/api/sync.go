@@ -29,7 +29,7 @@ func getSyncDirection(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("name"))
 	if !ok {
 		return
 	}
@@ -85,7 +85,7 @@ func removeCloudSyncDir(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("name"))
 	if !ok {
 		return
 	}
@@ -106,7 +106,7 @@ func createCloudSyncDir(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("name"))
 	if !ok {
 		return
 	}
@@ -125,7 +125,7 @@ func setSyncEnable(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("enabled"))
 	if !ok {
 		return
 	}
@@ -144,7 +144,7 @@ func setCloudSyncDir(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("name"))
 	if !ok {
 		return
 	}
@@ -31,6 +31,15 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+// metrics 以 Prometheus 文本格式输出内核运行指标。
+func metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := util.WriteMetrics(c.Writer); nil != err {
+		util.LogErrorf("write metrics failed: %s", err)
+	}
+}
+
 func getEmojiConf(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -121,7 +130,7 @@ func checkUpdate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("showMsg"))
 	if !ok {
 		return
 	}
@@ -141,7 +150,7 @@ func setUILayout(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ObjArg("layout", util.ArgAny))
 	if !ok {
 		return
 	}
@@ -168,7 +177,7 @@ func setAccessAuthCode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("accessAuthCode"))
 	if !ok {
 		return
 	}
@@ -207,19 +216,24 @@ func currentTime(c *gin.Context) {
 	ret.Data = util.CurrentTimeMillis()
 }
 
+type bootProgressResult struct {
+	Progress float64 `json:"progress"`
+	Details  string  `json:"details"`
+}
+
 func bootProgress(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
 	progress, details := util.GetBootProgressDetails()
-	ret.Data = map[string]interface{}{"progress": progress, "details": details}
+	ret.Data = &bootProgressResult{Progress: progress, Details: details}
 }
 
 func setAppearanceMode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.NumArg("mode"))
 	if !ok {
 		return
 	}
@@ -242,7 +256,7 @@ func setNetworkServe(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("networkServe"))
 	if !ok {
 		return
 	}
@@ -259,7 +273,7 @@ func setUploadErrLog(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("uploadErrLog"))
 	if !ok {
 		return
 	}
@@ -276,7 +290,7 @@ func setNetworkProxy(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("scheme"), util.StrArg("host"), util.StrArg("port"))
 	if !ok {
 		return
 	}
@@ -299,7 +313,7 @@ func setE2EEPasswd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.NumArg("mode"), util.StrArg("e2eePasswd").Optional())
 	if !ok {
 		return
 	}
@@ -376,7 +390,7 @@ func exit(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.BoolArg("force").Optional())
 	if !ok {
 		return
 	}
@@ -29,7 +29,7 @@ func getTag(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.NumArg("sort").Optional())
 	if !ok {
 		return
 	}
@@ -50,7 +50,7 @@ func renameTag(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("oldLabel"), util.StrArg("newLabel"))
 	if !ok {
 		return
 	}
@@ -69,7 +69,7 @@ func removeTag(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("label"))
 	if !ok {
 		return
 	}
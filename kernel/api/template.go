@@ -30,7 +30,7 @@ func docSaveAsTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.StrArg("id"), util.BoolArg("overwrite"))
 	if !ok {
 		return
 	}
@@ -46,17 +46,27 @@ func docSaveAsTemplate(c *gin.Context) {
 	ret.Code = code
 }
 
+type renderTemplateArg struct {
+	ID   string `json:"id" binding:"required"`   // 调用渲染所在的文档 ID
+	Path string `json:"path" binding:"required"` // 模板文件绝对路径
+}
+
+type renderTemplateResult struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
 func renderTemplate(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
-	if !ok {
+	arg := &renderTemplateArg{}
+	if !util.BindJsonArg(c, ret, arg) {
 		return
 	}
 
-	p := arg["path"].(string)
-	id := arg["id"].(string)
+	p := arg.Path
+	id := arg.ID
 	content, err := model.RenderTemplate(p, id)
 	if nil != err {
 		ret.Code = -1
@@ -64,8 +74,5 @@ func renderTemplate(c *gin.Context) {
 		return
 	}
 
-	ret.Data = map[string]interface{}{
-		"path":    p,
-		"content": content,
-	}
+	ret.Data = &renderTemplateResult{Path: p, Content: content}
 }
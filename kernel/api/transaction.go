@@ -29,34 +29,54 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+type performTransactionsArg struct {
+	Transactions []*transactionArg `json:"transactions" binding:"dive,required"`
+}
+
+type transactionArg struct {
+	DoOperations   []*operationArg `json:"doOperations" binding:"dive,required"`
+	UndoOperations []*operationArg `json:"undoOperations" binding:"dive,required"`
+}
+
+type operationArg struct {
+	Action     string      `json:"action" binding:"required"`
+	Data       interface{} `json:"data"`
+	ID         string      `json:"id"`
+	ParentID   string      `json:"parentID"`
+	PreviousID string      `json:"previousID"`
+}
+
 func performTransactions(c *gin.Context) {
 	start := time.Now()
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	arg, ok := util.JsonArg(c, ret)
+	arg, ok := util.JsonArg(c, ret, util.ArrArg("transactions", util.ArgObject), util.StrArg("app"), util.StrArg("session"))
 	if !ok {
 		return
 	}
 
-	trans := arg["transactions"]
-	data, err := gulu.JSON.MarshalJSON(trans)
-	if nil != err {
-		ret.Code = -1
-		ret.Msg = "parses request failed"
+	transactionsArg := &performTransactionsArg{}
+	if !util.BindMapArg(c, ret, map[string]interface{}{"transactions": arg["transactions"]}, transactionsArg) {
 		return
 	}
 
+	// 参数已经通过校验，这里的转换不会失败
+	data, _ := gulu.JSON.MarshalJSON(arg["transactions"])
 	var transactions []*model.Transaction
-	if err = gulu.JSON.UnmarshalJSON(data, &transactions); nil != err {
-		ret.Code = -1
-		ret.Msg = "parses request failed"
-		return
-	}
+	gulu.JSON.UnmarshalJSON(data, &transactions)
 
+	var err error
 	if op := model.IsSetAttrs(&transactions); nil != op {
+		attrsArg := &util.ArgErr{Arg: "transactions[0].doOperations[0].data", Expected: "JSON object of string"}
+		attrsData, isStr := op.Data.(string)
+		if !isStr {
+			util.InvalidArg(c, ret, attrsArg)
+			return
+		}
 		attrs := map[string]string{}
-		if err = gulu.JSON.UnmarshalJSON([]byte(op.Data.(string)), &attrs); nil != err {
+		if err = gulu.JSON.UnmarshalJSON([]byte(attrsData), &attrs); nil != err {
+			util.InvalidArg(c, ret, attrsArg)
 			return
 		}
 		err = model.SetBlockAttrs(op.ID, attrs)
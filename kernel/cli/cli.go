@@ -0,0 +1,289 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package cli 实现内核的命令行子命令，比如 SiYuan-Kernel query "SELECT * FROM blocks"。
+//
+// 子命令通过调用内核 API 实现，结果以 API 返回值 {"code": 0, "msg": "", "data": ...} 的 JSON 格式输出到标准输出，
+// code 不为 0 时进程退出码为 1，命令行参数错误时为 2。
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// command 是一个子命令。
+type command struct {
+	usage    string   // 位置参数用法
+	desc     string   // 说明
+	options  []string // 支持的选项，-server 和 -token 所有子命令都支持
+	min, max int      // 位置参数个数范围，max 为 -1 时表示不限
+	run      func(k *kernel, opts options, args []string) (*gulu.Result, error)
+}
+
+var commands = map[string]*command{
+	"index":      {desc: "rebuild the index of the workspace", run: index},
+	"query":      {usage: "<sql|->", desc: "query the database with SQL", min: 1, max: 1, run: query},
+	"export":     {usage: "md|html|sy <id>", desc: "export a document", options: []string{"o"}, min: 2, max: 2, run: export},
+	"import":     {usage: "<path>...", desc: "import files into a notebook", options: []string{"notebook", "to", "format"}, min: 1, max: -1, run: importPaths},
+	"backup":     {desc: "create a local backup of the workspace data", run: backup},
+	"sync":       {desc: "sync the workspace data with the cloud", run: sync},
+	"create-doc": {usage: "[markdown|-]", desc: "create a document with Markdown", options: []string{"notebook", "path"}, max: 1, run: createDoc},
+	"append":     {usage: "<markdown|->", desc: "append Markdown to a block", options: []string{"parent"}, min: 1, max: 1, run: appendBlock},
+}
+
+var optionUsages = map[string]string{
+	"server":   "address of the running kernel, e.g. http://127.0.0.1:6806 (default to the kernel of the workspace, or open the workspace directly)",
+	"token":    "API token (default to the token of the workspace)",
+	"o":        "output path, required by html export",
+	"notebook": "notebook ID",
+	"to":       "path of the parent document, e.g. /20210808180117-6v0mkla (default to the root of the notebook)",
+	"format":   "import format: md, sy, enex, html, csv, opml or notion (default by the file extension)",
+	"path":     "human-readable path of the document, e.g. /foo/bar",
+	"parent":   "ID of the parent block",
+}
+
+// errUsage 表示命令行参数错误。
+var errUsage = errors.New("invalid arguments")
+
+// options 是子命令选项的值。
+type options map[string]*string
+
+func (opts options) get(name string) string {
+	if value := opts[name]; nil != value {
+		return *value
+	}
+	return ""
+}
+
+// Run 执行 args 指定的子命令，返回进程退出码。
+func Run(args []string) (exitCode int) {
+	name := args[0]
+	cmd := commands[name]
+	if nil == cmd {
+		usage()
+		return util.ExitCodeUsage
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: SiYuan-Kernel [-workspace path] %s [options] %s\n\n%s\n\nOptions:\n", name, cmd.usage, cmd.desc)
+		flags.PrintDefaults()
+	}
+	opts := options{}
+	for _, option := range append([]string{"server", "token"}, cmd.options...) {
+		opts[option] = flags.String(option, "", optionUsages[option])
+	}
+	positional, err := parse(flags, args[1:])
+	if nil != err {
+		if flag.ErrHelp == err {
+			return util.ExitCodeOk
+		}
+		return util.ExitCodeUsage
+	}
+	if len(positional) < cmd.min || (-1 < cmd.max && len(positional) > cmd.max) {
+		flags.Usage()
+		return util.ExitCodeUsage
+	}
+
+	k, err := connect(opts.get("server"), opts.get("token"))
+	if nil != err {
+		code := util.ExitCodeFatal
+		if errWorkspaceLocked == err {
+			code = util.ExitCodeWorkspaceLocked
+		}
+		result := gulu.Ret.NewResult()
+		result.Code = -1
+		result.Msg = err.Error()
+		if err = json.NewEncoder(os.Stdout).Encode(result); nil != err {
+			util.LogErrorf("write result failed: %s", err)
+		}
+		return code
+	}
+	defer k.close()
+
+	result, err := cmd.run(k, opts, positional)
+	if errUsage == err {
+		flags.Usage()
+		return util.ExitCodeUsage
+	}
+	if nil != err {
+		result = gulu.Ret.NewResult()
+		result.Code = -1
+		result.Msg = err.Error()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(result); nil != err {
+		util.LogErrorf("write result failed: %s", err)
+		return util.ExitCodeFatal
+	}
+	if 0 != result.Code {
+		return util.ExitCodeFatal
+	}
+	return util.ExitCodeOk
+}
+
+// parse 解析选项，和 flag 包不同的是选项可以出现在位置参数之后，比如 export md -o foo.md 20210808180117-6v0mkla。-- 之后的参数都作为位置参数。
+func parse(flags *flag.FlagSet, args []string) (positional []string, err error) {
+	for {
+		if err = flags.Parse(args); nil != err {
+			return
+		}
+		if 0 == flags.NArg() {
+			return
+		}
+		if parsed := len(args) - flags.NArg(); 0 < parsed && "--" == args[parsed-1] {
+			positional = append(positional, flags.Args()...)
+			return
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &strings.Builder{}
+	buf.WriteString("Usage: SiYuan-Kernel [-workspace path] <command> [options] [arguments]\n\nCommands:\n")
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("  %-11s %s\n", name, commands[name].desc))
+	}
+	buf.WriteString("\nRun 'SiYuan-Kernel <command> -h' for the options of a command.\n")
+	fmt.Fprint(os.Stderr, buf.String())
+}
+
+// readArg 返回参数 arg 的值，arg 为 - 时从标准输入读取。
+func readArg(arg string) (ret string, err error) {
+	if "-" != arg {
+		return arg, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if nil != err {
+		return
+	}
+	return string(data), nil
+}
+
+func index(k *kernel, opts options, args []string) (*gulu.Result, error) {
+	return k.call("/api/filetree/refreshFiletree", nil)
+}
+
+func query(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	stmt, err := readArg(args[0])
+	if nil != err {
+		return
+	}
+	return k.call("/api/query/sql", map[string]interface{}{"stmt": stmt})
+}
+
+func backup(k *kernel, opts options, args []string) (*gulu.Result, error) {
+	return k.call("/api/backup/createLocalBackup", nil)
+}
+
+func sync(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	appConf, err := k.conf()
+	if nil != err {
+		return
+	}
+	// 没有开启同步、没有登录或者没有设置端到端加密密码时内核会跳过同步但是仍然返回成功，所以需要先检查
+	if !appConf.Sync.Enabled || "" == appConf.Sync.CloudName || "" == appConf.UserData || "" == appConf.E2EEPasswd {
+		ret = gulu.Ret.NewResult()
+		ret.Code = -1
+		ret.Msg = "sync is not available, please sign in and check the sync settings"
+		return
+	}
+
+	ret, err = k.call("/api/sync/performBootSync", nil)
+	if nil != err {
+		return
+	}
+	// 返回码为同步结果：0 成功，1 失败，失败原因在同步状态中
+	if 1 == ret.Code {
+		if appConf, err = k.conf(); nil != err {
+			return
+		}
+		ret.Msg = appConf.Sync.Stat
+	}
+	return
+}
+
+func createDoc(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	markdown := ""
+	if 0 < len(args) {
+		if markdown, err = readArg(args[0]); nil != err {
+			return
+		}
+	}
+	return k.call("/api/filetree/createDocWithMd", map[string]interface{}{
+		"notebook": opts.get("notebook"),
+		"path":     opts.get("path"),
+		"markdown": markdown,
+	})
+}
+
+// appendBlock 通过批量调用接口插入块，这样块操作会同步执行，失败时能够返回错误。
+func appendBlock(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	markdown, err := readArg(args[0])
+	if nil != err {
+		return
+	}
+	ret, err = k.call("/api/batch", map[string]interface{}{"requests": []interface{}{
+		map[string]interface{}{
+			"path": "/api/block/appendBlock",
+			"arg": map[string]interface{}{
+				"dataType": "markdown",
+				"data":     markdown,
+				"parentID": opts.get("parent"),
+			},
+		},
+	}})
+	if nil != err {
+		return
+	}
+
+	data, _ := ret.Data.(map[string]interface{})
+	results, _ := data["results"].([]interface{})
+	if 1 > len(results) {
+		return
+	}
+	if result, ok := results[0].(map[string]interface{}); ok {
+		if code, ok := result["code"].(float64); ok && 0 != code {
+			ret.Code = int(code)
+		}
+		if msg, _ := result["msg"].(string); "" != msg {
+			ret.Msg = msg
+		}
+		ret.Data = result["data"]
+	}
+	return
+}
@@ -0,0 +1,90 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		o, server  string
+		err        bool
+	}{
+		{"empty", nil, nil, "", "", false},
+		{"positional only", []string{"md", "20210808180117-6v0mkla"}, []string{"md", "20210808180117-6v0mkla"}, "", "", false},
+		{"options first", []string{"-o", "foo.md", "md", "20210808180117-6v0mkla"}, []string{"md", "20210808180117-6v0mkla"}, "foo.md", "", false},
+		{"options between", []string{"md", "-o", "foo.md", "20210808180117-6v0mkla"}, []string{"md", "20210808180117-6v0mkla"}, "foo.md", "", false},
+		{"options last", []string{"md", "20210808180117-6v0mkla", "-o=foo.md", "-server", "http://127.0.0.1:6806"}, []string{"md", "20210808180117-6v0mkla"}, "foo.md", "http://127.0.0.1:6806", false},
+		{"double dash", []string{"-o", "foo.md", "--", "-o", "bar"}, []string{"-o", "bar"}, "foo.md", "", false},
+		{"double dash after positional", []string{"md", "--", "-server"}, []string{"md", "-server"}, "", "", false},
+		{"stdin", []string{"-"}, []string{"-"}, "", "", false},
+		{"unknown option", []string{"md", "-x"}, nil, "", "", true},
+		{"missing value", []string{"md", "-o"}, nil, "", "", true},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("export", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		o, server := flags.String("o", "", ""), flags.String("server", "", "")
+		positional, err := parse(flags, test.args)
+		if test.err != (nil != err) {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+		}
+		if nil != err {
+			continue
+		}
+		if !reflect.DeepEqual(test.positional, positional) || test.o != *o || test.server != *server {
+			t.Errorf("%s: got %q -o %q -server %q, want %q -o %q -server %q", test.name, positional, *o, *server, test.positional, test.o, test.server)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	// 用法说明输出到标准错误，测试时丢弃
+	stderr := os.Stderr
+	os.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() {
+		os.Stderr.Close()
+		os.Stderr = stderr
+	}()
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"unknown"}, util.ExitCodeUsage},
+		{[]string{"query"}, util.ExitCodeUsage},
+		{[]string{"query", "a", "b"}, util.ExitCodeUsage},
+		{[]string{"export", "md"}, util.ExitCodeUsage},
+		{[]string{"query", "-x", "SELECT 1"}, util.ExitCodeUsage},
+		{[]string{"query", "-h"}, util.ExitCodeOk},
+	}
+	for _, test := range tests {
+		if got := Run(test.args); test.want != got {
+			t.Errorf("Run(%q): got %d, want %d", test.args, got, test.want)
+		}
+	}
+}
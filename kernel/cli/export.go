@@ -0,0 +1,123 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+func export(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	id := args[1]
+	output := opts.get("o")
+	switch args[0] {
+	case "md":
+		return exportMd(k, id, output)
+	case "html":
+		if "" == output {
+			return nil, errUsage
+		}
+		return exportHTML(k, id, output)
+	case "sy":
+		return exportSY(k, id, output)
+	}
+	return nil, errUsage
+}
+
+// exportMd 导出 Markdown，output 为空时内容在返回值中，否则写入 output。
+func exportMd(k *kernel, id, output string) (ret *gulu.Result, err error) {
+	ret, err = k.call("/api/export/exportMdContent", map[string]interface{}{"id": id})
+	if nil != err || 0 != ret.Code || "" == output {
+		return
+	}
+
+	data, _ := ret.Data.(map[string]interface{})
+	content, _ := data["content"].(string)
+	if output, err = filepath.Abs(output); nil != err {
+		return
+	}
+	if err = os.WriteFile(output, []byte(content), 0644); nil != err {
+		return
+	}
+	ret.Data = map[string]interface{}{"hPath": data["hPath"], "path": output}
+	return
+}
+
+// exportHTML 导出 HTML 到文件夹 output，页面和界面上导出 HTML 时生成的一致。
+func exportHTML(k *kernel, id, output string) (ret *gulu.Result, err error) {
+	if output, err = filepath.Abs(output); nil != err {
+		return
+	}
+	ret, err = k.call("/api/export/exportHTML", map[string]interface{}{"id": id, "pdf": false, "savePath": output})
+	if nil != err || 0 != ret.Code {
+		return
+	}
+	data, _ := ret.Data.(map[string]interface{})
+	name, _ := data["name"].(string)
+	content, _ := data["content"].(string)
+	if "" == name {
+		ret.Code = -1
+		ret.Msg = "export html failed"
+		return
+	}
+
+	appConf, err := k.conf()
+	if nil != err {
+		return
+	}
+	page := model.ExportHTMLPage(filepath.Base(output), content, "Copy", appConf.Appearance, appConf.Editor)
+	indexPath := filepath.Join(output, "index.html")
+	if err = os.WriteFile(indexPath, []byte(page), 0644); nil != err {
+		return
+	}
+	ret.Data = map[string]interface{}{"id": id, "name": name, "path": indexPath}
+	return
+}
+
+// exportSY 导出 .sy.zip 到 output，output 为空时保存到当前文件夹。
+func exportSY(k *kernel, id, output string) (ret *gulu.Result, err error) {
+	ret, err = k.call("/api/export/exportSY", map[string]interface{}{"id": id})
+	if nil != err || 0 != ret.Code {
+		return
+	}
+	data, _ := ret.Data.(map[string]interface{})
+	zip, _ := data["zip"].(string)
+	if "" == zip {
+		ret.Code = -1
+		ret.Msg = "export sy failed"
+		return
+	}
+
+	if "" == output {
+		if output, err = url.PathUnescape(path.Base(zip)); nil != err {
+			return
+		}
+	}
+	if output, err = filepath.Abs(output); nil != err {
+		return
+	}
+	if err = k.download(zip, output); nil != err {
+		return
+	}
+	ret.Data = map[string]interface{}{"name": data["name"], "path": output}
+	return
+}
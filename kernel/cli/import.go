@@ -0,0 +1,103 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+)
+
+// importAPIs 是使用上传文件方式导入的格式对应的接口。
+var importAPIs = map[string]string{
+	"sy":     "/api/import/importSY",
+	"enex":   "/api/import/importENEX",
+	"html":   "/api/import/importHTML",
+	"csv":    "/api/import/importCSV",
+	"opml":   "/api/import/importOPML",
+	"notion": "/api/import/importNotion",
+}
+
+// importPaths 依次导入 args 中的文件或文件夹，遇到失败时停止。返回值中的数据为导入生成的文档 ID（如果接口有返回的话）。
+func importPaths(k *kernel, opts options, args []string) (ret *gulu.Result, err error) {
+	notebook, toPath := opts.get("notebook"), opts.get("to")
+	if "" == toPath {
+		toPath = "/"
+	}
+
+	ids := []string{}
+	for _, p := range args {
+		format := opts.get("format")
+		if "" == format {
+			format = importFormat(p)
+		}
+
+		if p, err = filepath.Abs(p); nil != err {
+			return
+		}
+		if "md" == format {
+			// Markdown 导入时由内核直接读取 localPath，所以远程模式下需要是内核所在机器上的路径
+			ret, err = k.call("/api/import/importStdMd", map[string]interface{}{"notebook": notebook, "localPath": p, "toPath": toPath})
+		} else if api := importAPIs[format]; "" != api {
+			ret, err = k.upload(api, map[string]string{"notebook": notebook, "toPath": toPath}, []string{p})
+		} else {
+			return nil, errUsage
+		}
+		if nil != err || 0 != ret.Code {
+			return
+		}
+
+		if data, ok := ret.Data.([]interface{}); ok {
+			for _, id := range data {
+				if id, ok := id.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	ret.Data = map[string]interface{}{"ids": ids}
+	return
+}
+
+// importFormat 根据文件扩展名推断导入格式，文件夹按 Markdown 导入，无法推断时返回空字符串。
+func importFormat(p string) string {
+	if gulu.File.IsDir(p) {
+		return "md"
+	}
+
+	name := strings.ToLower(filepath.Base(p))
+	switch {
+	case strings.HasSuffix(name, ".sy.zip"):
+		return "sy"
+	case strings.HasSuffix(name, ".zip"):
+		return "notion"
+	}
+	switch filepath.Ext(name) {
+	case ".md", ".markdown":
+		return "md"
+	case ".enex":
+		return "enex"
+	case ".html", ".htm":
+		return "html"
+	case ".csv", ".tsv":
+		return "csv"
+	case ".opml":
+		return "opml"
+	}
+	return ""
+}
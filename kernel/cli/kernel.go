@@ -0,0 +1,240 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/server"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// kernel 用于子命令调用内核 API。
+//
+// 远程模式下通过 HTTP 调用正在运行的内核；本地模式下直接打开工作空间，在进程内调用 API，这样两种模式的行为完全一致。
+type kernel struct {
+	server string      // 远程模式时为内核地址，比如 http://127.0.0.1:6806
+	token  string      // API token
+	engine *gin.Engine // 本地模式时为进程内的伺服
+	client *http.Client
+}
+
+// errWorkspaceLocked 表示工作空间正在被其他内核使用，但是无法连接该内核。
+var errWorkspaceLocked = errors.New("workspace is in use by another kernel, use -server and -token to connect to it")
+
+// connect 连接 serverURL 上正在运行的内核。serverURL 为空时尝试连接本机上使用当前工作空间的内核，没有的话锁定并直接打开工作空间。
+//
+// 工作空间已经被其他内核锁定时不能直接打开，否则两个进程会同时写入数据，这时返回 errWorkspaceLocked，无法锁定工作空间时返回对应的错误。
+func connect(serverURL, token string) (ret *kernel, err error) {
+	if "" == token {
+		token = workspaceToken()
+	}
+
+	if "" != serverURL {
+		return &kernel{server: strings.TrimSuffix(serverURL, "/"), token: token}, nil
+	}
+
+	ret = &kernel{server: "http://127.0.0.1:" + util.ServerPort, token: token, client: &http.Client{Timeout: 7 * time.Second}}
+	if appConf, confErr := ret.conf(); nil == confErr && appConf.System.WorkspaceDir == util.WorkspaceDir {
+		ret.client = nil
+		return
+	}
+	if err = util.TryLockWorkspace(); nil != err {
+		if util.ErrWorkspaceLocked == err {
+			err = errWorkspaceLocked
+		}
+		return nil, err
+	}
+	return openWorkspace(), nil
+}
+
+// conf 返回内核的配置。
+func (k *kernel) conf() (ret *model.AppConf, err error) {
+	result, err := k.call("/api/system/getConf", nil)
+	if nil != err {
+		return
+	}
+	if 0 != result.Code {
+		err = errors.New(result.Msg)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(result.Data)
+	if nil != err {
+		return
+	}
+	ret = &model.AppConf{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		return
+	}
+	if nil == ret.System || nil == ret.Appearance || nil == ret.Editor || nil == ret.Sync {
+		err = errors.New("invalid conf")
+	}
+	return
+}
+
+// workspaceToken 从工作空间配置中读取 API token。
+func workspaceToken() string {
+	data, err := os.ReadFile(filepath.Join(util.ConfDir, "conf.json"))
+	if nil != err {
+		return ""
+	}
+	appConf := &model.AppConf{}
+	if err = gulu.JSON.UnmarshalJSON(data, appConf); nil != err || nil == appConf.Api {
+		return ""
+	}
+	return appConf.Api.Token
+}
+
+// openWorkspace 直接打开工作空间，启动流程和内核一致，但是不监听端口，也不启动同步等后台任务。
+func openWorkspace() *kernel {
+	model.InitConf()
+	model.InitAppearance()
+	sql.InitDatabase(false)
+	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
+	model.InitBoxes()
+	util.SetBooted()
+	go model.AutoFlushTx()
+	go sql.AutoFlushTreeQueue()
+	go treenode.AutoFlushBlockTree()
+	return &kernel{token: model.Conf.Api.Token, engine: server.NewServer()}
+}
+
+// close 在本地模式下等待数据写入完成后关闭工作空间。
+func (k *kernel) close() {
+	if nil == k.engine {
+		return
+	}
+
+	model.WaitForWritingFiles()
+	sql.WaitForWritingDatabase()
+	treenode.CloseBlockTree()
+	model.Conf.Close()
+	sql.CloseDatabase()
+	util.UnlockWorkspace()
+}
+
+// call 使用 JSON 参数调用 API。
+func (k *kernel) call(p string, arg interface{}) (ret *gulu.Result, err error) {
+	if nil == arg {
+		arg = map[string]interface{}{}
+	}
+	body, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		return
+	}
+	return k.result(k.do(http.MethodPost, p, "application/json", bytes.NewReader(body)))
+}
+
+// upload 使用 multipart 表单调用 API，文件字段名为 file。
+func (k *kernel) upload(p string, values map[string]string, files []string) (ret *gulu.Result, err error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range values {
+		if err = writer.WriteField(name, value); nil != err {
+			return
+		}
+	}
+	for _, file := range files {
+		var part io.Writer
+		if part, err = writer.CreateFormFile("file", filepath.Base(file)); nil != err {
+			return
+		}
+		var data []byte
+		if data, err = os.ReadFile(file); nil != err {
+			return
+		}
+		if _, err = part.Write(data); nil != err {
+			return
+		}
+	}
+	if err = writer.Close(); nil != err {
+		return
+	}
+	return k.result(k.do(http.MethodPost, p, writer.FormDataContentType(), body))
+}
+
+// download 下载内核伺服的文件 p 并保存到 savePath。
+func (k *kernel) download(p, savePath string) (err error) {
+	status, data, err := k.do(http.MethodGet, p, "", nil)
+	if nil != err {
+		return
+	}
+	if http.StatusOK != status {
+		return errors.New(fmt.Sprintf("download [%s] failed: %d %s", p, status, http.StatusText(status)))
+	}
+	return os.WriteFile(savePath, data, 0644)
+}
+
+// result 解析接口返回值，接口参数校验失败时返回的状态码为 400，但是返回值格式不变，所以不检查状态码。
+func (k *kernel) result(status int, data []byte, err error) (*gulu.Result, error) {
+	if nil != err {
+		return nil, err
+	}
+
+	ret := gulu.Ret.NewResult()
+	if 1 > len(data) || nil != gulu.JSON.UnmarshalJSON(data, ret) {
+		return nil, errors.New(fmt.Sprintf("%d %s", status, http.StatusText(status)))
+	}
+	return ret, nil
+}
+
+func (k *kernel) do(method, p, contentType string, body io.Reader) (status int, data []byte, err error) {
+	req, err := http.NewRequest(method, k.server+p, body)
+	if nil != err {
+		return
+	}
+	req.Header.Set("Authorization", "Token "+k.token)
+	if "" != contentType {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if nil != k.engine {
+		req.RequestURI = p
+		req.RemoteAddr = "127.0.0.1:0"
+		recorder := httptest.NewRecorder()
+		k.engine.ServeHTTP(recorder, req)
+		return recorder.Code, recorder.Body.Bytes(), nil
+	}
+
+	client := k.client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
@@ -0,0 +1,140 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// APIHandler 用于在进程内调用 API，由伺服在注册路由后设置。
+var APIHandler http.Handler
+
+func init() {
+	RegisterAPI("getDoc", "/api/filetree/getDoc", true, false)
+	RegisterAPI("listDocsByPath", "/api/filetree/listDocsByPath", true, false)
+	RegisterAPI("lsNotebooks", "/api/notebook/lsNotebooks", true, false)
+	RegisterAPI("getBlockInfo", "/api/block/getBlockInfo", true, false)
+	RegisterAPI("getBlockAttrs", "/api/attr/getBlockAttrs", true, false)
+	RegisterAPI("search", "/api/search/fullTextSearchBlock", true, false)
+	RegisterAPI("sql", "/api/query/sql", true, false)
+
+	RegisterAPI("transactions", "/api/transactions", false, false)
+	RegisterAPI("setBlockAttrs", "/api/attr/setBlockAttrs", false, false)
+	RegisterAPI("createDocWithMd", "/api/filetree/createDocWithMd", false, false)
+
+	RegisterAPI("index", "/api/filetree/refreshFiletree", false, true)
+	RegisterAPI("exportMd", "/api/export/exportMd", true, true)
+	RegisterAPI("batchExportMd", "/api/export/batchExportMd", true, true)
+	RegisterAPI("exportSY", "/api/export/exportSY", true, true)
+	RegisterAPI("exportData", "/api/export/exportData", true, true)
+	RegisterAPI("exportDataInFolder", "/api/export/exportDataInFolder", true, true)
+}
+
+// RegisterAPI 注册通过调用 API p 实现的命令 name，命令参数和返回值与 API 一致。
+//
+// read 为 false 时只读模式下不能执行该命令；stream 为 true 时执行过程中推送的进度会作为流式响应的中间结果返回，
+// 中间结果的 data 为 {progress, current, total}，progress 为进度类型（util.PushProgressCode*）。
+func RegisterAPI(name, p string, read, stream bool) {
+	Register(name, func(baseCmd *BaseCmd) Cmd {
+		return &apiCmd{BaseCmd: baseCmd, name: name, path: p, read: read, stream: stream}
+	})
+}
+
+type apiCmd struct {
+	*BaseCmd
+	name   string
+	path   string
+	read   bool
+	stream bool
+}
+
+func (cmd *apiCmd) Exec() {
+	var progress *util.Progress
+	if cmd.stream {
+		progress = util.NewProgress(cmd.id, func(code, current, total int, msg string) {
+			cmd.PushStream(0, msg, map[string]interface{}{"progress": code, "current": current, "total": total})
+		})
+	}
+	cmd.call(progress)
+	cmd.Push()
+}
+
+// call 在进程内调用 API，请求头使用 websocket 连接的请求头，所以鉴权和 HTTP 调用一致。
+//
+// progress 不为 nil 时通过请求的 context 传递给 API，API 执行的操作推送的进度会返回给该命令。
+func (cmd *apiCmd) call(progress *util.Progress) {
+	arg := map[string]interface{}{}
+	for k, v := range cmd.param {
+		arg[k] = v
+	}
+	delete(arg, "callback")
+	delete(arg, "pushMode")
+	delete(arg, "reloadPushMode")
+	// 事务接口需要 app 和 session 参数，默认使用当前 websocket 会话
+	if _, ok := arg["app"]; !ok {
+		arg["app"] = cmd.PushPayload.AppId
+	}
+	if _, ok := arg["session"]; !ok {
+		arg["session"] = cmd.PushPayload.SessionId
+	}
+
+	result := cmd.PushPayload
+	body, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		result.Code = -1
+		result.Msg = err.Error()
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, cmd.path, bytes.NewReader(body))
+	if nil != err {
+		result.Code = -1
+		result.Msg = err.Error()
+		return
+	}
+	req.Header = cmd.session.Request.Header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Host = cmd.session.Request.Host
+	req.RemoteAddr = cmd.session.Request.RemoteAddr
+	req.RequestURI = cmd.path
+	if nil != progress {
+		req = req.WithContext(util.WithProgress(req.Context(), progress))
+	}
+
+	recorder := httptest.NewRecorder()
+	APIHandler.ServeHTTP(recorder, req)
+	ret := gulu.Ret.NewResult()
+	if err = gulu.JSON.UnmarshalJSON(recorder.Body.Bytes(), ret); nil != err || 1 > recorder.Body.Len() {
+		result.Code = -1
+		result.Msg = fmt.Sprintf("%d %s", recorder.Code, http.StatusText(recorder.Code))
+		return
+	}
+	result.Code, result.Msg, result.Data = ret.Code, ret.Msg, ret.Data
+}
+
+func (cmd *apiCmd) Name() string {
+	return cmd.name
+}
+
+func (cmd *apiCmd) IsRead() bool {
+	return cmd.read
+}
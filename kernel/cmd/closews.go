@@ -20,6 +20,10 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+func init() {
+	Register("closews", func(baseCmd *BaseCmd) Cmd { return &closews{baseCmd} })
+}
+
 type closews struct {
 	*BaseCmd
 }
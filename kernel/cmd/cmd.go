@@ -48,16 +48,34 @@ func (cmd *BaseCmd) Push() {
 	util.PushEvent(cmd.PushPayload)
 }
 
-func NewCommand(cmdStr string, cmdId float64, param map[string]interface{}, session *melody.Session) (ret Cmd) {
-	baseCmd := &BaseCmd{id: cmdId, param: param, session: session}
-	switch cmdStr {
-	case "closews":
-		ret = &closews{baseCmd}
-	}
+// PushStream 推送流式响应的中间结果，最后需要调用 Push 推送最终结果。
+func (cmd *BaseCmd) PushStream(code int, msg string, data interface{}) {
+	evt := util.NewCmdResult(cmd.PushPayload.Cmd, cmd.id, util.PushModeSingleSelf, util.PushModeSingleSelf)
+	evt.Callback = cmd.param["callback"]
+	evt.AppId = cmd.PushPayload.AppId
+	evt.SessionId = cmd.PushPayload.SessionId
+	evt.Stream = true
+	evt.Code = code
+	evt.Msg = msg
+	evt.Data = data
+	util.PushEvent(evt)
+}
+
+// commands 是已注册的命令，键为命令名。
+var commands = map[string]func(baseCmd *BaseCmd) Cmd{}
 
-	if nil == ret {
+// Register 注册命令 name，newCmd 用于创建命令。同名命令后注册的覆盖先注册的。
+func Register(name string, newCmd func(baseCmd *BaseCmd) Cmd) {
+	commands[name] = newCmd
+}
+
+func NewCommand(cmdStr string, cmdId float64, param map[string]interface{}, session *melody.Session) (ret Cmd) {
+	newCmd := commands[cmdStr]
+	if nil == newCmd {
 		return
 	}
+	baseCmd := &BaseCmd{id: cmdId, param: param, session: session}
+	ret = newCmd(baseCmd)
 
 	pushMode := util.PushModeSingleSelf
 	if pushModeParam := param["pushMode"]; nil != pushModeParam {
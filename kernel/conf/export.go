@@ -27,6 +27,9 @@ type Export struct {
 	TagCloseMarker          string `json:"tagCloseMarker"`          // 标签结束标记符，默认是 #
 	FileAnnotationRefMode   int    `json:"fileAnnotationRefMode"`   // 文件标注引用导出模式，0：文件名 - 页码 - 锚文本，1：仅锚文本
 	PandocBin               string `json:"pandocBin"`               // Pandoc 可执行文件路径
+	SiteGenerator           string `json:"siteGenerator"`           // 静态站点 Markdown 导出的目标生成器，hugo 或者 jekyll
+	FrontMatter             string `json:"frontMatter"`             // 静态站点 Markdown 导出的 Front Matter 格式，yaml 或者 toml（Jekyll 仅支持 yaml）
+	SiteAssetsPrefix        string `json:"siteAssetsPrefix"`        // 静态站点 Markdown 导出时资源文件链接的前缀，默认是 /
 }
 
 func NewExport() *Export {
@@ -41,5 +44,8 @@ func NewExport() *Export {
 		TagCloseMarker:          "#",
 		FileAnnotationRefMode:   0,
 		PandocBin:               "",
+		SiteGenerator:           "hugo",
+		FrontMatter:             "yaml",
+		SiteAssetsPrefix:        "/",
 	}
 }
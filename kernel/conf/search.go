@@ -18,6 +18,7 @@ package conf
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
 	"github.com/88250/lute/ast"
@@ -54,6 +55,57 @@ type Search struct {
 	VirtualRefAlias  bool `json:"virtualRefAlias"`
 	VirtualRefAnchor bool `json:"virtualRefAnchor"`
 	VirtualRefDoc    bool `json:"virtualRefDoc"`
+
+	Weight *SearchWeight `json:"weight"` // 搜索结果排序权重
+}
+
+// SearchWeight 描述了搜索结果排序时各字段的 bm25 权重以及最近更新、被引用的加权系数。
+type SearchWeight struct {
+	Title   float64 `json:"title"`   // 文档标题
+	Name    float64 `json:"name"`    // 命名
+	Alias   float64 `json:"alias"`   // 别名
+	Memo    float64 `json:"memo"`    // 备注
+	Tag     float64 `json:"tag"`     // 标签
+	Content float64 `json:"content"` // 内容
+	IAL     float64 `json:"ial"`     // 自定义属性
+	Recent  float64 `json:"recent"`  // 最近更新加权系数，0 为不加权
+	Ref     float64 `json:"ref"`     // 被引用加权系数，0 为不加权
+}
+
+func NewSearchWeight() *SearchWeight {
+	return &SearchWeight{
+		Title:   10,
+		Name:    8,
+		Alias:   6,
+		Memo:    2,
+		Tag:     4,
+		Content: 1,
+		IAL:     0.5,
+		Recent:  0.5,
+		Ref:     0.5,
+	}
+}
+
+// BM25 返回 blocks_fts 表 bm25() 函数的列权重参数，未建立全文索引和不参与搜索的列权重为 0。
+func (w *SearchWeight) BM25() string {
+	// id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated
+	weights := []float64{0, 0, 0, 0, 0, 0, 0, w.Name, w.Alias, w.Memo, w.Tag, w.Content, 0, 0, 0, 0, 0, w.IAL, 0, 0, 0}
+	buf := bytes.Buffer{}
+	for i, weight := range weights {
+		buf.WriteString(strconv.FormatFloat(weight, 'f', -1, 64))
+		if i < len(weights)-1 {
+			buf.WriteString(", ")
+		}
+	}
+	return buf.String()
+}
+
+// TitleFactor 返回文档块相对于普通块的加权倍数，文档块的 content 列即为文档标题。
+func (w *SearchWeight) TitleFactor() float64 {
+	if 0 >= w.Content {
+		return w.Title
+	}
+	return w.Title / w.Content
 }
 
 func NewSearch() *Search {
@@ -87,6 +139,8 @@ func NewSearch() *Search {
 		VirtualRefAlias:  false,
 		VirtualRefAnchor: true,
 		VirtualRefDoc:    true,
+
+		Weight: NewSearchWeight(),
 	}
 }
 
@@ -45,6 +45,15 @@ type LockItem struct {
 }
 
 func init() {
+	util.NewGaugeFunc("siyuan_file_locks", "Number of files locked by the kernel.", nil, func() []util.MetricValue {
+		count := 0
+		fileLocks.Range(func(k, v interface{}) bool {
+			count++
+			return true
+		})
+		return []util.MetricValue{{Value: float64(count)}}
+	})
+
 	go func() {
 		// 锁定超时自动解锁
 		for range time.Tick(10 * time.Second) {
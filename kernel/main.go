@@ -19,6 +19,10 @@
 package main
 
 import (
+	"flag"
+	"os"
+
+	"github.com/siyuan-note/siyuan/kernel/cli"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/server"
 	"github.com/siyuan-note/siyuan/kernel/sql"
@@ -28,6 +32,9 @@ import (
 
 func main() {
 	util.Boot()
+	if util.Cli {
+		os.Exit(cli.Run(flag.Args()))
+	}
 
 	model.InitConf()
 	go server.Serve(false)
@@ -48,6 +55,7 @@ func main() {
 	go model.AutoFlushTx()
 	go sql.AutoFlushTreeQueue()
 	go treenode.AutoFlushBlockTree()
+	go model.AutoIndexAssetContents()
 	model.WatchAssets()
 	model.HandleSignal()
 }
@@ -61,6 +61,7 @@ func StartKernel(container, appDir, workspaceDir, nativeLibDir, privateDataDir,
 		go model.AutoFlushTx()
 		go sql.AutoFlushTreeQueue()
 		go treenode.AutoFlushBlockTree()
+		go model.AutoIndexAssetContents()
 	}()
 }
 
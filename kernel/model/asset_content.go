@@ -0,0 +1,879 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/88250/gulu"
+	"github.com/88250/pdfcpu/pkg/api"
+	"github.com/88250/pdfcpu/pkg/pdfcpu"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	assetContentIndexLock    = sync.Mutex{}
+	assetContentIndexTrigger = make(chan bool, 1)
+)
+
+const (
+	assetContentIndexInterval = 10 * time.Minute // 定时检查资源文件变动的间隔
+	maxAssetContentFileSize   = 64 * 1024 * 1024 // 超过该大小的资源文件不提取文本
+	maxAssetContentPageLen    = 64 * 1024        // 每页最多索引的字符数
+	maxAssetContentBlocks     = 16               // 每个命中最多返回的引用块数
+)
+
+// assetContentExtractors 按资源文件后缀提取文本，返回的每一项为一页。
+var assetContentExtractors = map[string]func(absPath string) ([]*sql.AssetContent, error){
+	".pdf":      extractPDFContent,
+	".txt":      extractPlainTextContent,
+	".md":       extractPlainTextContent,
+	".markdown": extractPlainTextContent,
+	".html":     extractHTMLContent,
+	".htm":      extractHTMLContent,
+	".docx":     extractDOCXContent,
+}
+
+// AutoIndexAssetContents 在后台提取资源文件中的文本并建立索引，资源文件变动后或者定时检查一次。
+func AutoIndexAssetContents() {
+	for {
+		IndexAssetContents()
+		select {
+		case <-assetContentIndexTrigger:
+			time.Sleep(3 * time.Second) // 等待连续的变动结束
+		case <-time.After(assetContentIndexInterval):
+		}
+	}
+}
+
+// IndexAssetContentsLater 通知后台任务检查资源文件变动。
+func IndexAssetContentsLater() {
+	select {
+	case assetContentIndexTrigger <- true:
+	default:
+	}
+}
+
+// IndexAssetContents 提取 data/assets 下新增或者修改过的资源文件的文本，并删除已经不存在的资源文件的索引。
+func IndexAssetContents() {
+	assetContentIndexLock.Lock()
+	defer assetContentIndexLock.Unlock()
+
+	start := time.Now()
+	assetsDir := filepath.Join(util.DataDir, "assets")
+	indexed := sql.QueryAssetContentHashes()
+	existing := map[string]bool{}
+	count := 0
+	filepath.Walk(assetsDir, func(absPath string, info fs.FileInfo, err error) error {
+		if nil != err || nil == info {
+			return nil
+		}
+		if isSkipFile(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		extract := assetContentExtractors[strings.ToLower(filepath.Ext(info.Name()))]
+		if nil == extract || maxAssetContentFileSize < info.Size() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(util.DataDir, absPath)
+		if nil != err {
+			return nil
+		}
+		p := filepath.ToSlash(relPath)
+		existing[p] = true
+		hash := fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+		if hash == indexed[p] {
+			return nil
+		}
+
+		contents, err := extractAssetContent(absPath, extract)
+		if nil != err {
+			util.LogWarnf("extract asset [%s] content failed: %s", absPath, err)
+		}
+		if err = sql.IndexAssetContents(p, util.RemoveID(info.Name()), hash, contents); nil != err {
+			util.LogErrorf("index asset [%s] content failed: %s", absPath, err)
+			return nil
+		}
+		count++
+		return nil
+	})
+
+	var removed []string
+	for p := range indexed {
+		if !existing[p] {
+			removed = append(removed, p)
+		}
+	}
+	sql.RemoveAssetContents(removed)
+	if 0 < count || 0 < len(removed) {
+		util.LogInfof("indexed [%d] asset contents, removed [%d] asset contents in [%.2fs]", count, len(removed), time.Since(start).Seconds())
+	}
+}
+
+// SearchAssetContents 在资源文件文本中搜索 keyword，每项命中包含命中的页码、片段以及引用了该资源文件的块。
+//
+// 资源文件文本中的命中不分页，只在搜索结果的第一页返回前 32 个。
+func SearchAssetContents(keyword string, querySyntax bool) (ret []*Asset) {
+	ret = []*Asset{}
+	keyword = strings.TrimSpace(util.RemoveInvisible(keyword))
+	if "" == keyword {
+		return
+	}
+
+	match := keyword
+	if !querySyntax {
+		match = stringQuery(keyword)
+	}
+	contents := sql.FullTextSearchAssetContents(match, 32)
+	for _, content := range contents {
+		ret = append(ret, &Asset{
+			HName:   markAssetContent(content.Name),
+			Name:    path.Base(content.Path),
+			Path:    content.Path,
+			Page:    content.Page,
+			Content: markAssetContent(content.Content),
+			Blocks:  assetRefBlocks(content.Path),
+		})
+	}
+	return
+}
+
+// AssetRefBlock 描述了引用资源文件的块。
+type AssetRefBlock struct {
+	ID     string `json:"id"`
+	RootID string `json:"rootID"`
+	Box    string `json:"box"`
+	HPath  string `json:"hPath"`
+}
+
+// MergeAssetContentBlocks 将资源文件文本中的命中 assets 转换为引用了这些资源文件的块并追加到 blocks 后，已经在 blocks 中的块不重复追加。
+func MergeAssetContentBlocks(blocks []*Block, assets []*Asset) (ret []*Block) {
+	ret = blocks
+	added := map[string]bool{}
+	for _, b := range blocks {
+		added[b.ID] = true
+	}
+	for _, asset := range assets {
+		for _, ref := range asset.Blocks {
+			if added[ref.ID] {
+				continue
+			}
+			added[ref.ID] = true
+
+			if block := fromSQLBlock(sql.GetBlock(ref.ID), "", 12); nil != block {
+				ret = append(ret, block)
+			}
+		}
+	}
+	return
+}
+
+func assetRefBlocks(p string) (ret []*AssetRefBlock) {
+	ret = []*AssetRefBlock{}
+	added := map[string]bool{}
+	for _, asset := range sql.QueryAssetsByPath(p) {
+		if added[asset.BlockID] {
+			continue
+		}
+		added[asset.BlockID] = true
+
+		block := &AssetRefBlock{ID: asset.BlockID, RootID: asset.RootID, Box: asset.Box}
+		if root := sql.GetBlock(asset.RootID); nil != root {
+			block.HPath = root.HPath
+		}
+		ret = append(ret, block)
+		if maxAssetContentBlocks <= len(ret) {
+			break
+		}
+	}
+	return
+}
+
+func markAssetContent(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, "__@mark__", "<mark>")
+	return strings.ReplaceAll(text, "__mark@__", "</mark>")
+}
+
+func extractAssetContent(absPath string, extract func(absPath string) ([]*sql.AssetContent, error)) (ret []*sql.AssetContent, err error) {
+	defer func() {
+		if e := recover(); nil != e {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+
+	ret, err = extract(absPath)
+	for _, content := range ret {
+		content.Content = strings.ToValidUTF8(content.Content, "")
+		content.Content = strings.TrimSpace(content.Content)
+		if maxAssetContentPageLen < len(content.Content) {
+			content.Content = gulu.Str.SubStr(content.Content, maxAssetContentPageLen)
+		}
+	}
+	return
+}
+
+func extractPlainTextContent(absPath string) (ret []*sql.AssetContent, err error) {
+	data, err := os.ReadFile(absPath)
+	if nil != err {
+		return
+	}
+	ret = append(ret, &sql.AssetContent{Page: 1, Content: string(data)})
+	return
+}
+
+func extractHTMLContent(absPath string) (ret []*sql.AssetContent, err error) {
+	f, err := os.Open(absPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if nil != err {
+		return
+	}
+	doc.Find("script, style, noscript, template").Remove()
+	text := strings.Join(strings.Fields(doc.Text()), " ")
+	ret = append(ret, &sql.AssetContent{Page: 1, Content: text})
+	return
+}
+
+// extractDOCXContent 提取 DOCX 正文文本。DOCX 没有固定分页，所以只有第 1 页。
+func extractDOCXContent(absPath string) (ret []*sql.AssetContent, err error) {
+	reader, err := zip.OpenReader(absPath)
+	if nil != err {
+		return
+	}
+	defer reader.Close()
+
+	var document *zip.File
+	for _, file := range reader.File {
+		if "word/document.xml" == file.Name {
+			document = file
+			break
+		}
+	}
+	if nil == document {
+		err = errors.New("not found word/document.xml")
+		return
+	}
+
+	rc, err := document.Open()
+	if nil != err {
+		return
+	}
+	defer rc.Close()
+
+	buf := bytes.Buffer{}
+	decoder := xml.NewDecoder(rc)
+	inText := false
+	for {
+		token, tokenErr := decoder.Token()
+		if io.EOF == tokenErr {
+			break
+		}
+		if nil != tokenErr {
+			err = tokenErr
+			return
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				buf.WriteByte('\t')
+			case "br", "cr":
+				buf.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				buf.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				buf.Write(t)
+			}
+		}
+	}
+	ret = append(ret, &sql.AssetContent{Page: 1, Content: buf.String()})
+	return
+}
+
+// extractPDFContent 逐页提取 PDF 文本。
+func extractPDFContent(absPath string) (ret []*sql.AssetContent, err error) {
+	f, err := os.Open(absPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+
+	conf := pdfcpu.NewDefaultConfiguration()
+	conf.ValidationMode = pdfcpu.ValidationRelaxed
+	ctx, err := api.ReadContext(f, conf)
+	if nil != err {
+		return
+	}
+	if err = ctx.EnsurePageCount(); nil != err {
+		return
+	}
+
+	cmaps := map[pdfcpu.IndirectRef]*pdfCMap{}
+	for i := 1; i <= ctx.PageCount; i++ {
+		reader, pageErr := ctx.ExtractPageContent(i)
+		if nil != pageErr || nil == reader {
+			continue
+		}
+		data, readErr := io.ReadAll(reader)
+		if nil != readErr {
+			continue
+		}
+		if text := pdfContentText(data, pdfPageFonts(ctx, i, cmaps)); "" != text {
+			ret = append(ret, &sql.AssetContent{Page: i, Content: text})
+		}
+	}
+	return
+}
+
+// pdfFont 是页面资源中的字体，cmap 为字体的 ToUnicode 映射，composite 表示是否为 Type0 字体（比如 Identity-H 编码的 CID 字体）。
+type pdfFont struct {
+	cmap      *pdfCMap
+	composite bool
+}
+
+// decode 将使用该字体显示的字符串解码为文本。
+func (font *pdfFont) decode(s []byte) string {
+	if nil == font {
+		return pdfDecodeString(s)
+	}
+	if nil != font.cmap {
+		return font.cmap.decode(s, font.composite)
+	}
+	if font.composite { // 没有 ToUnicode 的 CID 字体无法还原为文本
+		return ""
+	}
+	return pdfDecodeString(s)
+}
+
+// pdfPageFonts 返回第 pageNr 页资源中的字体，键为字体资源名。cmaps 用于在页面间复用已经解析过的 ToUnicode 映射。
+func pdfPageFonts(ctx *pdfcpu.Context, pageNr int, cmaps map[pdfcpu.IndirectRef]*pdfCMap) (ret map[string]*pdfFont) {
+	ret = map[string]*pdfFont{}
+	pageDict, _, _, err := ctx.PageDict(pageNr, false)
+	if nil != err || nil == pageDict {
+		return
+	}
+
+	// 资源字典可以从页面树的父节点继承
+	var resources pdfcpu.Dict
+	for d, depth := pageDict, 0; nil != d && nil == resources && 32 > depth; depth++ {
+		if obj, found := d.Find("Resources"); found {
+			resources, _ = ctx.DereferenceDict(obj)
+			break
+		}
+		obj, found := d.Find("Parent")
+		if !found {
+			break
+		}
+		d, _ = ctx.DereferenceDict(obj)
+	}
+	if nil == resources {
+		return
+	}
+	obj, found := resources.Find("Font")
+	if !found {
+		return
+	}
+	fonts, _ := ctx.DereferenceDict(obj)
+	for name, fontObj := range fonts {
+		fontDict, _ := ctx.DereferenceDict(fontObj)
+		if nil == fontDict {
+			continue
+		}
+
+		font := &pdfFont{}
+		if subtype := fontDict.Subtype(); nil != subtype && "Type0" == *subtype {
+			font.composite = true
+		}
+		if toUnicode, ok := fontDict.Find("ToUnicode"); ok {
+			ref, isRef := toUnicode.(pdfcpu.IndirectRef)
+			if cmap, cached := cmaps[ref]; isRef && cached {
+				font.cmap = cmap
+			} else {
+				if sd, _, sdErr := ctx.DereferenceStreamDict(toUnicode); nil == sdErr && nil != sd && nil == sd.Decode() {
+					font.cmap = parsePDFCMap(sd.Content)
+				}
+				if isRef {
+					cmaps[ref] = font.cmap
+				}
+			}
+		}
+		ret[name] = font
+	}
+	return
+}
+
+// pdfCMap 是 ToUnicode CMap，codespaces 为编码空间范围，chars 为字符编码到文本的映射。
+type pdfCMap struct {
+	codespaces [][2][]byte
+	chars      map[string]string
+}
+
+// maxPDFCMapRange 是 bfrange 单个范围最多展开的编码数。
+const maxPDFCMapRange = 0xFFFF
+
+// parsePDFCMap 解析 ToUnicode CMap 中的 codespacerange、bfchar 和 bfrange。
+func parsePDFCMap(data []byte) (ret *pdfCMap) {
+	ret = &pdfCMap{chars: map[string]string{}}
+	var operands, array []interface{}
+	inArray := false
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+		case '%' == c:
+			for i < len(data) && '\n' != data[i] && '\r' != data[i] {
+				i++
+			}
+		case '<' == c:
+			if i+1 < len(data) && '<' == data[i+1] {
+				i += 2
+				continue
+			}
+			s, n := pdfHexString(data[i:])
+			if inArray {
+				array = append(array, s)
+			} else {
+				operands = append(operands, s)
+			}
+			i += n
+		case '(' == c:
+			_, n := pdfLiteralString(data[i:])
+			i += n
+		case '[' == c:
+			inArray, array = true, nil
+			i++
+		case ']' == c:
+			inArray = false
+			operands = append(operands, array)
+			i++
+		case '>' == c || '{' == c || '}' == c:
+			i++
+		default:
+			start := i
+			if '/' == c {
+				i++
+			}
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+			if start == i {
+				i++
+				continue
+			}
+
+			switch string(data[start:i]) {
+			case "endcodespacerange":
+				for j := 0; j+1 < len(operands); j += 2 {
+					lo, _ := operands[j].([]byte)
+					hi, _ := operands[j+1].([]byte)
+					if 0 < len(lo) && len(lo) == len(hi) {
+						ret.codespaces = append(ret.codespaces, [2][]byte{lo, hi})
+					}
+				}
+			case "endbfchar":
+				for j := 0; j+1 < len(operands); j += 2 {
+					src, _ := operands[j].([]byte)
+					dst, _ := operands[j+1].([]byte)
+					if 0 < len(src) {
+						ret.chars[string(src)] = pdfDecodeUTF16(dst)
+					}
+				}
+			case "endbfrange":
+				for j := 0; j+2 < len(operands); j += 3 {
+					lo, _ := operands[j].([]byte)
+					hi, _ := operands[j+1].([]byte)
+					ret.addRange(lo, hi, operands[j+2])
+				}
+			}
+			operands = nil
+		}
+	}
+	return
+}
+
+// addRange 添加 bfrange 中 lo 到 hi 的编码，dst 为起始文本（后续编码依次递增最后一个字符）或者逐个编码对应的文本数组。
+func (cmap *pdfCMap) addRange(lo, hi []byte, dst interface{}) {
+	if 0 == len(lo) || len(lo) != len(hi) || 4 < len(lo) {
+		return
+	}
+	start, end := pdfCode(lo), pdfCode(hi)
+	if start > end || maxPDFCMapRange < end-start {
+		return
+	}
+
+	code := make([]byte, len(lo))
+	for offset := uint32(0); offset <= end-start; offset++ {
+		for k, v := 0, start+offset; k < len(code); k++ {
+			code[len(code)-1-k] = byte(v >> (8 * k))
+		}
+		switch d := dst.(type) {
+		case []byte:
+			if 2 > len(d) {
+				return
+			}
+			text := make([]byte, len(d))
+			copy(text, d)
+			last := uint32(text[len(text)-2])<<8 | uint32(text[len(text)-1]) + offset
+			text[len(text)-2], text[len(text)-1] = byte(last>>8), byte(last)
+			cmap.chars[string(code)] = pdfDecodeUTF16(text)
+		case []interface{}:
+			if int(offset) >= len(d) {
+				return
+			}
+			text, _ := d[offset].([]byte)
+			cmap.chars[string(code)] = pdfDecodeUTF16(text)
+		}
+	}
+}
+
+// decode 按照编码空间切分字符编码并映射为文本，没有编码空间时 composite 字体按照双字节切分，其他字体按照单字节切分。
+func (cmap *pdfCMap) decode(s []byte, composite bool) string {
+	buf := strings.Builder{}
+	for i := 0; i < len(s); {
+		n := cmap.codeLen(s[i:], composite)
+		if text, ok := cmap.chars[string(s[i:i+n])]; ok {
+			buf.WriteString(text)
+		}
+		i += n
+	}
+	return buf.String()
+}
+
+func (cmap *pdfCMap) codeLen(s []byte, composite bool) (ret int) {
+	for _, codespace := range cmap.codespaces {
+		lo, hi := codespace[0], codespace[1]
+		if len(lo) > len(s) {
+			continue
+		}
+		matched := true
+		for k := range lo {
+			if s[k] < lo[k] || s[k] > hi[k] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return len(lo)
+		}
+	}
+
+	ret = 1
+	if 0 < len(cmap.codespaces) {
+		ret = len(cmap.codespaces[0][0])
+	} else if composite {
+		ret = 2
+	}
+	if ret > len(s) {
+		ret = len(s)
+	}
+	return
+}
+
+func pdfCode(s []byte) (ret uint32) {
+	for _, b := range s {
+		ret = ret<<8 | uint32(b)
+	}
+	return
+}
+
+// pdfContentText 从 PDF 页面内容流中提取文本显示操作符（Tj、TJ、'、"）输出的字符串。
+//
+// 字符串按照 Tf 选择的字体解码，fonts 为页面资源中的字体，键为字体资源名。不在 fonts 中的字体按照标准编码或者 UTF-16BE 解码，
+// 没有 ToUnicode 的双字节编码（比如 Identity-H）字体无法还原为文本，这部分字符串会被跳过。
+func pdfContentText(data []byte, fonts map[string]*pdfFont) string {
+	buf := bytes.Buffer{}
+	newline := func() {
+		if 0 < buf.Len() && '\n' != buf.Bytes()[buf.Len()-1] {
+			buf.WriteByte('\n')
+		}
+	}
+
+	var operands, array []interface{}
+	var font *pdfFont
+	inArray := false
+	push := func(operand interface{}) {
+		if inArray {
+			array = append(array, operand)
+		} else {
+			operands = append(operands, operand)
+		}
+	}
+	lastString := func() string {
+		for i := len(operands) - 1; 0 <= i; i-- {
+			if s, ok := operands[i].([]byte); ok {
+				return font.decode(s)
+			}
+		}
+		return ""
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+		case '%' == c:
+			for i < len(data) && '\n' != data[i] && '\r' != data[i] {
+				i++
+			}
+		case '(' == c:
+			s, n := pdfLiteralString(data[i:])
+			push(s)
+			i += n
+		case '<' == c:
+			if i+1 < len(data) && '<' == data[i+1] { // 字典
+				i += 2
+				continue
+			}
+			s, n := pdfHexString(data[i:])
+			push(s)
+			i += n
+		case '[' == c:
+			inArray, array = true, nil
+			i++
+		case ']' == c:
+			inArray = false
+			operands = append(operands, array)
+			i++
+		case '/' == c:
+			start := i + 1
+			for i++; i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]); i++ {
+			}
+			push(pdfcpu.Name(data[start:i]))
+		case '>' == c || '{' == c || '}' == c:
+			i++
+		case ('0' <= c && '9' >= c) || '+' == c || '-' == c || '.' == c:
+			start := i
+			for i++; i < len(data) && (('0' <= data[i] && '9' >= data[i]) || '.' == data[i]); i++ {
+			}
+			number, _ := strconv.ParseFloat(string(data[start:i]), 64)
+			push(number)
+		default:
+			start := i
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+			if start == i {
+				i++
+				continue
+			}
+
+			switch string(data[start:i]) {
+			case "Tf":
+				if 2 <= len(operands) {
+					if name, ok := operands[len(operands)-2].(pdfcpu.Name); ok {
+						font = fonts[string(name)]
+					}
+				}
+			case "Tj":
+				buf.WriteString(lastString())
+			case "'", "\"":
+				newline()
+				buf.WriteString(lastString())
+			case "TJ":
+				if 0 < len(operands) {
+					elements, _ := operands[len(operands)-1].([]interface{})
+					for _, element := range elements {
+						switch e := element.(type) {
+						case []byte:
+							buf.WriteString(font.decode(e))
+						case float64:
+							if -150 > e { // 较大的字距调整通常是单词间隔
+								buf.WriteByte(' ')
+							}
+						}
+					}
+				}
+			case "Td", "TD":
+				if 2 <= len(operands) {
+					if ty, ok := operands[len(operands)-1].(float64); ok && 0 != ty {
+						newline()
+					} else {
+						buf.WriteByte(' ')
+					}
+				}
+			case "T*", "ET":
+				newline()
+			case "BI": // 跳过内联图片数据
+				i = pdfSkipInlineImage(data, i)
+			}
+			operands = nil
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); "" != line {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func pdfLiteralString(data []byte) (ret []byte, n int) {
+	depth := 0
+	for n = 0; n < len(data); n++ {
+		c := data[n]
+		switch c {
+		case '(':
+			depth++
+			if 1 == depth {
+				continue
+			}
+		case ')':
+			depth--
+			if 0 == depth {
+				return ret, n + 1
+			}
+		case '\\':
+			n++
+			if n >= len(data) {
+				return
+			}
+			switch e := data[n]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n': // 续行
+				if '\r' == e && n+1 < len(data) && '\n' == data[n+1] {
+					n++
+				}
+				continue
+			default:
+				if '0' <= e && '7' >= e {
+					octal := int(e - '0')
+					for j := 0; j < 2 && n+1 < len(data) && '0' <= data[n+1] && '7' >= data[n+1]; j++ {
+						n++
+						octal = octal*8 + int(data[n]-'0')
+					}
+					c = byte(octal)
+				} else {
+					c = e
+				}
+			}
+		}
+		ret = append(ret, c)
+	}
+	return
+}
+
+func pdfHexString(data []byte) (ret []byte, n int) {
+	var digits []byte
+	for n = 1; n < len(data) && '>' != data[n]; n++ {
+		if c := data[n]; ('0' <= c && '9' >= c) || ('a' <= c && 'f' >= c) || ('A' <= c && 'F' >= c) {
+			digits = append(digits, c)
+		}
+	}
+	if 1 == len(digits)%2 {
+		digits = append(digits, '0')
+	}
+	ret, _ = hex.DecodeString(string(digits))
+	return ret, n + 1
+}
+
+// pdfDecodeString 解码 PDF 字符串，UTF-16BE 以外的字符串按照 PDFDocEncoding 的 ASCII 和 Latin-1 部分解码。
+func pdfDecodeString(s []byte) string {
+	if 2 <= len(s) && 0xFE == s[0] && 0xFF == s[1] {
+		return pdfDecodeUTF16(s[2:])
+	}
+
+	if bytes.IndexByte(s, 0) >= 0 { // 双字节编码
+		return ""
+	}
+	runes := make([]rune, 0, len(s))
+	for _, b := range s {
+		if r := rune(b); unicode.IsPrint(r) || ' ' == r {
+			runes = append(runes, r)
+		}
+	}
+	return string(runes)
+}
+
+func pdfDecodeUTF16(s []byte) string {
+	var units []uint16
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func pdfSkipInlineImage(data []byte, i int) int {
+	for ; i+1 < len(data); i++ {
+		if 'E' == data[i] && 'I' == data[i+1] && 0 < i && isPDFWhitespace(data[i-1]) && (i+2 == len(data) || isPDFWhitespace(data[i+2])) {
+			return i + 2
+		}
+	}
+	return len(data)
+}
+
+func isPDFWhitespace(c byte) bool {
+	return ' ' == c || '\n' == c || '\r' == c || '\t' == c || '\f' == c || 0 == c
+}
+
+func isPDFDelimiter(c byte) bool {
+	return '(' == c || ')' == c || '<' == c || '>' == c || '[' == c || ']' == c || '{' == c || '}' == c || '/' == c || '%' == c
+}
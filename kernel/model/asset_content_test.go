@@ -0,0 +1,100 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+)
+
+const testPDFCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0001> <601D>
+<0002> <6E90>
+endbfchar
+2 beginbfrange
+<0010> <0012> <0041>
+<0020> <0021> [<0078> <D83DDE00>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+func TestPDFContentText(t *testing.T) {
+	fonts := map[string]*pdfFont{
+		"F1": {cmap: parsePDFCMap([]byte(testPDFCMap)), composite: true},
+		"F2": {},
+		"F3": {composite: true},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"literal", "BT (Hello World) Tj ET", "Hello World"},
+		{"escape", `BT (a\(b\) \101\n) Tj ET`, "a(b) A"},
+		{"hex", "BT <48656C6C6F> Tj ET", "Hello"},
+		{"utf16", "BT <FEFF601D6E90> Tj ET", "思源"},
+		{"TJ", "BT [(Hel) -20 (lo) -300 (World)] TJ ET", "Hello World"},
+		{"lines", "BT (a) Tj 0 -12 Td (b) Tj T* (c) Tj (d) ' ET", "a\nb\nc\nd"},
+		{"same line", "BT (a) Tj 10 0 Td (b) Tj ET", "a b"},
+		{"comment and dict", "% (skip) Tj\n/P << /MCID 0 >> BDC BT (a) Tj ET EMC", "a"},
+		{"inline image", "BI /W 1 /H 1 ID (x) Tj EI BT (a) Tj ET", "a"},
+		{"bfchar", "BT /F1 12 Tf <00010002> Tj ET", "思源"},
+		{"bfrange", "BT /F1 12 Tf [<0010> -300 <00110012>] TJ ET", "A BC"},
+		{"bfrange array", "BT /F1 12 Tf <00200021> Tj ET", "x😀"},
+		{"unmapped code", "BT /F1 12 Tf <00010099> Tj ET", "思"},
+		{"switch font", "BT /F1 12 Tf <0001> Tj /F2 12 Tf (ab) Tj ET", "思ab"},
+		{"cid without cmap", "BT /F3 12 Tf <00010002> Tj /F2 12 Tf (ab) Tj ET", "ab"},
+		{"unknown font", "BT /F9 12 Tf (ab) Tj ET", "ab"},
+		{"two byte without font", "BT <00410042> Tj ET", ""},
+	}
+	for _, test := range tests {
+		if got := pdfContentText([]byte(test.content), fonts); test.want != got {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPDFCMapDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		cmap      string
+		composite bool
+		code      []byte
+		want      string
+	}{
+		{"codespace", testPDFCMap, true, []byte{0, 1, 0, 2}, "思源"},
+		{"mixed codespace", "2 begincodespacerange <00> <80> <8140> <FFFF> endcodespacerange 2 beginbfchar <41> <0041> <8140> <601D> endbfchar", false, []byte{0x41, 0x81, 0x40, 0x41}, "A思A"},
+		{"composite default", "1 beginbfchar <0041> <0061> endbfchar", true, []byte{0, 0x41}, "a"},
+		{"simple default", "1 beginbfchar <41> <0061> endbfchar", false, []byte{0x41, 0x41}, "aa"},
+		{"truncated", testPDFCMap, true, []byte{0, 1, 0}, "思"},
+		{"range too large", "1 beginbfrange <00000000> <FFFFFFFF> <0041> endbfrange", true, []byte{0, 0}, ""},
+	}
+	for _, test := range tests {
+		if got := parsePDFCMap([]byte(test.cmap)).decode(test.code, test.composite); test.want != got {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
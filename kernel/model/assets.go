@@ -144,14 +144,37 @@ func NetImg2LocalAssets(rootID string) (err error) {
 }
 
 type Asset struct {
-	HName string `json:"hName"`
-	Name  string `json:"name"`
-	Path  string `json:"path"`
+	HName   string           `json:"hName"`
+	Name    string           `json:"name"`
+	Path    string           `json:"path"`
+	Page    int              `json:"page,omitempty"`    // 资源文件文本命中的页码
+	Content string           `json:"content,omitempty"` // 资源文件文本命中的片段
+	Blocks  []*AssetRefBlock `json:"blocks,omitempty"`  // 引用该资源文件的块
 }
 
-func SearchAssetsByName(keyword string) (ret []*Asset) {
+// SearchAssetsByName 按名称搜索资源文件，page 为 nil 时返回前 32 个结果。
+func SearchAssetsByName(keyword string, page *SearchPage) (ret []*Asset) {
 	ret = []*Asset{}
-	sqlAssets := sql.QueryAssetsByName(keyword)
+	var sqlAssets []*sql.Asset
+	if nil == page {
+		sqlAssets = sql.QueryAssetsByName(keyword, 32, "")
+	} else {
+		var beforeID string
+		if 0 < len(page.after) {
+			beforeID, _ = page.after[0].(string)
+			if "" == beforeID {
+				// 游标不是资源文件搜索返回的
+				return
+			}
+		}
+		sqlAssets = sql.QueryAssetsByName(keyword, Conf.Search.Limit+1, beforeID)
+		var keys [][]interface{}
+		for _, sqlAsset := range sqlAssets {
+			keys = append(keys, []interface{}{sqlAsset.ID})
+		}
+		sqlAssets = sqlAssets[:page.next(keys)]
+		page.Total = sql.QueryAssetsCountByName(keyword)
+	}
 	for _, sqlAsset := range sqlAssets {
 		hName := util.RemoveID(sqlAsset.Name)
 		_, hName = search.MarkText(hName, keyword, 64, Conf.Search.CaseSensitive)
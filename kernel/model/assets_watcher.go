@@ -78,6 +78,7 @@ func watchAssets() {
 					// 外部修改已有资源文件后纳入云端同步 https://github.com/siyuan-note/siyuan/issues/4694
 					IncWorkspaceDataVer()
 				}
+				IndexAssetContentsLater()
 			}
 		}
 	}()
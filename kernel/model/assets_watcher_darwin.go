@@ -58,6 +58,7 @@ func watchAssets() {
 				if watcher.Write == event.Op {
 					IncWorkspaceDataVer()
 				}
+				IndexAssetContentsLater()
 			case err, ok := <-assetsWatcher.Error:
 				if !ok {
 					return
@@ -52,6 +52,11 @@ type Sync struct {
 	SaveDir   string `json:"saveDir"`   // 本地同步数据存放目录路径
 }
 
+var (
+	backupDuration = util.NewHistogram("siyuan_backup_duration_seconds", "Duration of successful backup operations.", util.LongDurationBuckets, "operation")
+	backupBytes    = util.NewCounter("siyuan_backup_bytes_total", "Bytes of backup data created, recovered or transferred.", "operation")
+)
+
 func RemoveCloudBackup() (err error) {
 	err = removeCloudDirPath("backup")
 	return
@@ -230,10 +235,12 @@ func RecoverLocalBackup() (err error) {
 	size, _ := util.SizeOfDirectory(util.DataDir, false)
 	sizeStr := humanize.Bytes(uint64(size))
 	util.LogInfof("recovered backup [size=%s] in [%.2fs]", sizeStr, elapsed)
+	backupDuration.Observe(elapsed, "recover")
+	backupBytes.Add(float64(size), "recover")
 
 	util.PushEndlessProgress(Conf.Language(62))
 	time.Sleep(2 * time.Second)
-	refreshFileTree()
+	refreshFileTree(nil)
 	if syncEnabled {
 		func() {
 			time.Sleep(5 * time.Second)
@@ -319,6 +326,8 @@ func CreateLocalBackup() (err error) {
 	size, _ := util.SizeOfDirectory(backupDir, false)
 	sizeStr := humanize.Bytes(uint64(size))
 	util.LogInfof("created backup [size=%s] in [%.2fs]", sizeStr, elapsed)
+	backupDuration.Observe(elapsed, "create")
+	backupBytes.Add(float64(size), "create")
 
 	util.PushEndlessProgress(Conf.Language(21))
 	time.Sleep(2 * time.Second)
@@ -355,6 +364,8 @@ func DownloadBackup() (err error) {
 	if nil == err {
 		elapsed := time.Now().Sub(start).Seconds()
 		util.LogInfof("downloaded backup [fetchedFiles=%d, transferSize=%s] in [%.2fs]", fetchedFiles, humanize.Bytes(transferSize), elapsed)
+		backupDuration.Observe(elapsed, "download")
+		backupBytes.Add(float64(transferSize), "download")
 		util.PushEndlessProgress(Conf.Language(69))
 	}
 	return
@@ -378,6 +389,8 @@ func UploadBackup() (err error) {
 	if nil == err {
 		elapsed := time.Now().Sub(start).Seconds()
 		util.LogInfof("uploaded backup [wroteFiles=%d, transferSize=%s] in [%.2fs]", wroteFiles, humanize.Bytes(transferSize), elapsed)
+		backupDuration.Observe(elapsed, "upload")
+		backupBytes.Add(float64(transferSize), "upload")
 		util.PushEndlessProgress(Conf.Language(41))
 		time.Sleep(2 * time.Second)
 		return
@@ -0,0 +1,283 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// BatchSnapshot 用于批量调用接口失败时回滚涉及的文档。
+//
+// 文档在批量调用中第一次被使用前将 .sy 文件、子文档文件夹和笔记本的 .siyuan 文件夹复制到临时文件夹，回滚时使用快照覆盖这些文件后重建索引，
+// 快照时不存在的文件和批量调用中新建的文档、笔记本则直接删除。只有删除笔记本时才会对整个笔记本做快照。
+type BatchSnapshot struct {
+	dir     string
+	start   string                // 快照开始的时间，格式为 20060102150405，用于判断文档是否是批量调用中新建的
+	entries []*batchSnapshotEntry // 按照快照的顺序保存，回滚时逆序恢复
+	boxes   map[string]bool       // 批量调用中新建的笔记本
+}
+
+type batchSnapshotEntry struct {
+	path  string // 相对于 data 文件夹的路径
+	saved bool   // 为 false 表示快照时该文件不存在，回滚时删除
+}
+
+func NewBatchSnapshot() (ret *BatchSnapshot, err error) {
+	if err = os.MkdirAll(util.TempDir, 0755); nil != err {
+		return
+	}
+	dir, err := os.MkdirTemp(util.TempDir, "batch-")
+	if nil != err {
+		return
+	}
+	ret = &BatchSnapshot{dir: dir, start: time.Now().Format("20060102150405"), boxes: map[string]bool{}}
+	return
+}
+
+// SaveByIDs 为 ids 中的块 ID 所在的文档以及笔记本 ID 对应的笔记本配置做快照，ids 中的文档路径按照路径中的 ID 处理，已经做过快照的文件会被跳过。
+func (snapshot *BatchSnapshot) SaveByIDs(ids []string) (err error) {
+	for _, id := range batchSnapshotIDs(ids) {
+		if bt := treenode.GetBlockTree(id); nil != bt {
+			if err = snapshot.saveDoc(bt.BoxID, bt.Path); nil != err {
+				return
+			}
+			continue
+		}
+		if gulu.File.IsDir(filepath.Join(util.DataDir, id)) {
+			if err = snapshot.save(path.Join(id, ".siyuan")); nil != err {
+				return
+			}
+		}
+	}
+	return
+}
+
+// SaveBox 为整个笔记本 boxID 做快照，用于删除笔记本等涉及笔记本中所有文档的操作。
+func (snapshot *BatchSnapshot) SaveBox(boxID string) (err error) {
+	if !util.IsIDPattern(boxID) || !gulu.File.IsDir(filepath.Join(util.DataDir, boxID)) {
+		return
+	}
+	return snapshot.save(boxID)
+}
+
+// batchSnapshotIDs 返回 ids 中的 ID，文档路径（比如 /20210808180117-czj9bvb/20200812220555-lj3enxa.sy）中的每一级 ID 都会返回。
+func batchSnapshotIDs(ids []string) (ret []string) {
+	for _, id := range ids {
+		for _, part := range strings.Split(id, "/") {
+			if part = strings.TrimSuffix(part, ".sy"); util.IsIDPattern(part) {
+				ret = append(ret, part)
+			}
+		}
+	}
+	return
+}
+
+func (snapshot *BatchSnapshot) saveDoc(boxID, p string) (err error) {
+	if err = snapshot.save(path.Join(boxID, ".siyuan")); nil != err {
+		return
+	}
+	if err = snapshot.save(path.Join(boxID, p)); nil != err {
+		return
+	}
+	return snapshot.save(path.Join(boxID, strings.TrimSuffix(p, ".sy")))
+}
+
+// saved 判断 p 或者 p 的上级文件夹是否已经做过快照。
+func (snapshot *BatchSnapshot) saved(p string) bool {
+	for _, entry := range snapshot.entries {
+		if entry.path == p || strings.HasPrefix(p, entry.path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (snapshot *BatchSnapshot) save(p string) (err error) {
+	if snapshot.saved(p) {
+		return
+	}
+
+	WaitForWritingFiles()
+	syncLock.Lock()
+	defer syncLock.Unlock()
+
+	entry := &batchSnapshotEntry{path: p}
+	localPath := filepath.Join(util.DataDir, filepath.FromSlash(p))
+	if gulu.File.IsExist(localPath) {
+		filesys.ReleaseFileLocks(localPath)
+		if err = gulu.File.Copy(localPath, filepath.Join(snapshot.dir, filepath.FromSlash(p))); nil != err {
+			util.LogErrorf("save batch snapshot of [%s] failed: %s", p, err)
+			return
+		}
+		entry.saved = true
+	}
+	snapshot.entries = append(snapshot.entries, entry)
+	return
+}
+
+// Created 记录批量调用中新建的文档或者笔记本，回滚时删除。id 不是新建的文档或者笔记本时忽略。
+func (snapshot *BatchSnapshot) Created(id string) {
+	if !util.IsIDPattern(id) {
+		return
+	}
+
+	if bt := treenode.GetBlockTree(id); nil != bt {
+		if bt.ID != bt.RootID {
+			return
+		}
+
+		// 按照标题路径创建文档时会同时新建上级文档，从最上层开始找到第一个新建的文档，删除该文档及其子文档文件夹即可
+		dir := bt.BoxID
+		for _, part := range strings.Split(strings.Trim(bt.Path, "/"), "/") {
+			id := strings.TrimSuffix(part, ".sy")
+			docPath, docDir := path.Join(dir, id+".sy"), path.Join(dir, id)
+			dir = docDir
+			if !util.IsIDPattern(id) || util.TimeFromID(id) < snapshot.start {
+				continue
+			}
+			if !snapshot.saved(docPath) {
+				snapshot.entries = append(snapshot.entries, &batchSnapshotEntry{path: docPath}, &batchSnapshotEntry{path: docDir})
+			}
+			return
+		}
+		return
+	}
+
+	if gulu.File.IsDir(filepath.Join(util.DataDir, id)) {
+		snapshot.boxes[id] = true
+	}
+}
+
+// Rollback 将涉及的文档恢复到快照时的状态，删除新建的文档和笔记本，然后只重建这些文档和笔记本的索引。
+func (snapshot *BatchSnapshot) Rollback() (err error) {
+	if 1 > len(snapshot.entries) && 1 > len(snapshot.boxes) {
+		return
+	}
+
+	WaitForWritingFiles()
+	syncLock.Lock()
+	defer syncLock.Unlock()
+	for boxID := range snapshot.boxes {
+		unmount0(boxID)
+	}
+	for i := len(snapshot.entries) - 1; 0 <= i; i-- {
+		entry := snapshot.entries[i]
+		unindexBatchSnapshotEntry(entry.path)
+		localPath := filepath.Join(util.DataDir, filepath.FromSlash(entry.path))
+		filesys.ReleaseFileLocks(localPath)
+		if err = os.RemoveAll(localPath); nil != err {
+			util.LogErrorf("remove [%s] failed: %s", localPath, err)
+			break
+		}
+		if !entry.saved {
+			continue
+		}
+		if err = gulu.File.Copy(filepath.Join(snapshot.dir, filepath.FromSlash(entry.path)), localPath); nil != err {
+			util.LogErrorf("rollback [%s] from batch snapshot failed: %s", entry.path, err)
+			break
+		}
+	}
+	if nil == err {
+		for boxID := range snapshot.boxes {
+			localPath := filepath.Join(util.DataDir, boxID)
+			filesys.ReleaseFileLocks(localPath)
+			if err = os.RemoveAll(localPath); nil != err {
+				util.LogErrorf("remove box [%s] failed: %s", boxID, err)
+				break
+			}
+		}
+	}
+	for _, entry := range snapshot.entries {
+		if entry.saved {
+			indexBatchSnapshotEntry(entry.path)
+		}
+	}
+	sql.WaitForWritingDatabase()
+	treenode.SaveBlockTree()
+	IncWorkspaceDataVer()
+	util.ReloadUI()
+	return
+}
+
+// unindexBatchSnapshotEntry 删除快照文件 p 涉及的文档索引，p 可以是笔记本、.siyuan 文件夹、文档或者子文档文件夹。
+func unindexBatchSnapshotEntry(p string) {
+	boxID, docPath := batchSnapshotEntryPath(p)
+	switch {
+	case "" == docPath:
+		(&Box{ID: boxID}).Unindex()
+	case strings.HasSuffix(docPath, ".sy"):
+		sql.RemoveTreePathQueue(boxID, docPath)
+		treenode.RemoveBlockTreesByPathPrefix(docPath)
+		cache.RemoveDocIAL(docPath)
+	case "/.siyuan" != docPath:
+		sql.RemoveTreePathQueue(boxID, docPath+"/")
+		treenode.RemoveBlockTreesByPathPrefix(docPath + "/")
+	}
+}
+
+// indexBatchSnapshotEntry 重建恢复后的快照文件 p 涉及的文档索引。
+func indexBatchSnapshotEntry(p string) {
+	boxID, docPath := batchSnapshotEntryPath(p)
+	if "" == docPath {
+		(&Box{ID: boxID}).Index(true, nil)
+		return
+	}
+	if "/.siyuan" == docPath {
+		return
+	}
+
+	filepath.Walk(filepath.Join(util.DataDir, filepath.FromSlash(p)), func(localPath string, info os.FileInfo, err error) error {
+		if nil != err || info.IsDir() || !strings.HasSuffix(info.Name(), ".sy") {
+			return nil
+		}
+		relPath, _ := filepath.Rel(filepath.Join(util.DataDir, boxID), localPath)
+		tree, loadErr := LoadTree(boxID, "/"+filepath.ToSlash(relPath))
+		if nil != loadErr {
+			return nil
+		}
+		treenode.ReindexBlockTree(tree)
+		sql.UpsertTreeQueue(tree)
+		return nil
+	})
+}
+
+// batchSnapshotEntryPath 将快照文件 p 拆分为笔记本 ID 和笔记本中的路径，p 为笔记本时路径为空。
+func batchSnapshotEntryPath(p string) (boxID, docPath string) {
+	boxID, docPath, _ = strings.Cut(p, "/")
+	if "" != docPath {
+		docPath = "/" + docPath
+	}
+	return
+}
+
+// Close 删除快照临时文件夹。
+func (snapshot *BatchSnapshot) Close() {
+	if err := os.RemoveAll(snapshot.dir); nil != err {
+		util.LogErrorf("remove batch snapshot [%s] failed: %s", snapshot.dir, err)
+	}
+}
@@ -0,0 +1,64 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBatchSnapshotIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{"empty", nil, nil},
+		{"block id", []string{"20200812220555-lj3enxa"}, []string{"20200812220555-lj3enxa"}},
+		{"doc path", []string{"/20210808180117-czj9bvb/20200812220555-lj3enxa.sy"}, []string{"20210808180117-czj9bvb", "20200812220555-lj3enxa"}},
+		{"mixed", []string{"20210808180117-6v0mkxr", "/20200812220555-lj3enxa.sy"}, []string{"20210808180117-6v0mkxr", "20200812220555-lj3enxa"}},
+		{"not id", []string{"foo", "/assets/image.png", "20200812220555"}, nil},
+	}
+	for _, test := range tests {
+		if got := batchSnapshotIDs(test.ids); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBatchSnapshotSaved(t *testing.T) {
+	snapshot := &BatchSnapshot{entries: []*batchSnapshotEntry{
+		{path: "20210808180117-6v0mkxr/.siyuan"},
+		{path: "20210808180117-6v0mkxr/20200812220555-lj3enxa"},
+	}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"20210808180117-6v0mkxr/.siyuan", true},
+		{"20210808180117-6v0mkxr/.siyuan/conf.json", true},
+		{"20210808180117-6v0mkxr/20200812220555-lj3enxa/20210808180117-czj9bvb.sy", true},
+		{"20210808180117-6v0mkxr/20200812220555-lj3enxa.sy", false},
+		{"20210808180117-6v0mkxr/20200812220555-lj3enxab", false},
+		{"20210808180117-6v0mkxr", false},
+	}
+	for _, test := range tests {
+		if got := snapshot.saved(test.path); test.want != got {
+			t.Errorf("saved(%q): got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
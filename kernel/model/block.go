@@ -50,6 +50,8 @@ type Block struct {
 	Children []*Block          `json:"children"`
 	Depth    int               `json:"depth"`
 	Count    int               `json:"count"`
+	Matches  []*SearchMatch    `json:"matches"`
+	Score    float64           `json:"score"`
 }
 
 func (block *Block) IsContainerBlock() bool {
@@ -472,31 +472,33 @@ func parseKTree(kramdown []byte) (ret *parse.Tree) {
 	return
 }
 
-func RefreshFileTree() {
+// RefreshFileTree 重建索引，progress 为 nil 时只广播进度。
+func RefreshFileTree(progress *util.Progress) {
 	WaitForWritingFiles()
 	syncLock.Lock()
 	defer syncLock.Unlock()
-	refreshFileTree()
+	refreshFileTree(progress)
 }
 
-func refreshFileTree() {
+func refreshFileTree(progress *util.Progress) {
 	if err := sql.InitDatabase(true); nil != err {
 		util.PushErrMsg(Conf.Language(85), 5000)
 		return
 	}
 
-	util.PushEndlessProgress(Conf.Language(35))
+	progress.PushEndless(Conf.Language(35))
 	openedBoxes := Conf.GetOpenedBoxes()
 	for _, openedBox := range openedBoxes {
-		openedBox.Index(true)
+		openedBox.Index(true, progress)
 	}
-	IndexRefs()
+	IndexRefs(progress)
+	IndexAssetContentsLater()
 	// 缓存根一级的文档树展开
 	for _, openedBox := range openedBoxes {
 		ListDocTree(openedBox.ID, "/", Conf.FileTree.Sort)
 	}
 	treenode.SaveBlockTree()
-	util.PushEndlessProgress(Conf.Language(58))
+	progress.PushEndless(Conf.Language(58))
 	go func() {
 		time.Sleep(1 * time.Second)
 		util.ReloadUI()
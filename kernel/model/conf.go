@@ -151,6 +151,15 @@ func InitConf() {
 		// 废弃导出选项引用块转换为原始块和引述块 https://github.com/siyuan-note/siyuan/issues/3155
 		Conf.Export.BlockRefMode = 4 // 改为脚注
 	}
+	if "" == Conf.Export.SiteGenerator {
+		Conf.Export.SiteGenerator = "hugo"
+	}
+	if "" == Conf.Export.FrontMatter {
+		Conf.Export.FrontMatter = "yaml"
+	}
+	if "" == Conf.Export.SiteAssetsPrefix {
+		Conf.Export.SiteAssetsPrefix = "/"
+	}
 	if 9 > Conf.Editor.FontSize || 72 < Conf.Editor.FontSize {
 		Conf.Editor.FontSize = 16
 	}
@@ -229,6 +238,9 @@ func InitConf() {
 	if nil == Conf.Search {
 		Conf.Search = conf.NewSearch()
 	}
+	if nil == Conf.Search.Weight {
+		Conf.Search.Weight = conf.NewSearchWeight()
+	}
 
 	if nil == Conf.Stat {
 		Conf.Stat = conf.NewStat()
@@ -294,7 +306,7 @@ func initLang() {
 		for k, v := range kernelLangs {
 			num, err := strconv.Atoi(k)
 			if nil != err {
-				util.LogErrorf("parse language configuration [%s] item [%d] failed [%s] failed: %s", p, num, err)
+				util.LogErrorf("parse language configuration [%s] item [%d] failed: %s", p, num, err)
 				continue
 			}
 			kernelMap[num] = v.(string)
@@ -25,6 +25,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -40,6 +41,7 @@ import (
 	"github.com/88250/pdfcpu/pkg/pdfcpu"
 	"github.com/emirpasic/gods/sets/hashset"
 	"github.com/emirpasic/gods/stacks/linkedliststack"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -70,9 +72,9 @@ func ExportSY(id string) (name, zipPath string) {
 	return
 }
 
-func ExportDataInFolder(exportFolder string) (err error) {
-	util.PushEndlessProgress(Conf.Language(65))
-	defer util.ClearPushProgress(100)
+func ExportDataInFolder(exportFolder string, progress *util.Progress) (err error) {
+	progress.PushEndless(Conf.Language(65))
+	defer progress.Clear(100)
 
 	syncLock.Lock()
 	defer syncLock.Unlock()
@@ -85,9 +87,9 @@ func ExportDataInFolder(exportFolder string) (err error) {
 	return
 }
 
-func ExportData() (zipPath string) {
-	util.PushEndlessProgress(Conf.Language(65))
-	defer util.ClearPushProgress(100)
+func ExportData(progress *util.Progress) (zipPath string) {
+	progress.PushEndless(Conf.Language(65))
+	defer progress.Clear(100)
 
 	syncLock.Lock()
 	defer syncLock.Unlock()
@@ -149,7 +151,15 @@ func Preview(id string) string {
 	return luteEngine.ProtylePreview(tree, luteEngine.RenderOptions)
 }
 
-func ExportDocx(id, savePath string) (err error) {
+// ExportDocx 导出 Word 文档，pandoc 为 true 时使用 Pandoc 导出（保真度更高），否则使用内置的导出实现。
+func ExportDocx(id, savePath string, pandoc bool) (err error) {
+	if !pandoc {
+		return exportDocxNative(id, savePath)
+	}
+	return exportDocxPandoc(id, savePath)
+}
+
+func exportDocxPandoc(id, savePath string) (err error) {
 	if !util.IsValidPandocBin(Conf.Export.PandocBin) {
 		return errors.New(Conf.Language(115))
 	}
@@ -335,6 +345,89 @@ func ExportHTML(id, savePath string, pdf bool) (name, dom string) {
 	return
 }
 
+// ExportHTMLPage 返回导出 HTML 的完整页面，content 为 ExportHTML 导出的内容。页面结构和前端导出 HTML 时使用的模板（app/src/protyle/export/index.ts）一致。
+func ExportHTMLPage(title, content, copyLabel string, appearance *conf.Appearance, editor *conf.Editor) string {
+	return `<!DOCTYPE html><html>
+<head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0, maximum-scale=1.0, user-scalable=0"/>
+    <meta name="apple-mobile-web-app-capable" content="yes">
+    <meta name="mobile-web-app-capable" content="yes"/>
+    <meta name="apple-mobile-web-app-status-bar-style" content="black">
+` + exportHTMLStyles(appearance) + `    <title>` + html.EscapeString(title) + ` - SiYuan v` + util.Ver + `</title>
+    <style>
+        body {background-color: var(--b3-theme-background);color: var(--b3-theme-on-background)}
+        .b3-typography, .protyle-wysiwyg, .protyle-title {font-size:` + strconv.Itoa(editor.FontSize) + `px !important}
+    </style>
+</head>
+<body>
+<div class="protyle-wysiwyg protyle-wysiwyg--attr" style="max-width: 800px;margin: 0 auto;" id="preview">` + content + `</div>
+` + exportHTMLScripts(copyLabel, appearance, editor) + `</body></html>`
+}
+
+// exportHTMLStyles 返回导出 HTML 页面中加载主题样式的标签。
+func exportHTMLStyles(appearance *conf.Appearance) string {
+	theme, themeCSS := appearance.ThemeLight, "theme.css"
+	if 1 == appearance.Mode {
+		theme = appearance.ThemeDark
+	}
+	if appearance.CustomCSS {
+		themeCSS = "custom.css"
+	}
+	ver := "?" + util.Ver
+	return `    <link rel="stylesheet" type="text/css" id="themeDefaultStyle" href="stage/build/export/base.css` + ver + `"/>
+    <link rel="stylesheet" type="text/css" id="themeStyle" href="appearance/themes/` + theme + "/" + themeCSS + ver + `"/>
+`
+}
+
+// exportHTMLScripts 返回导出 HTML 页面中渲染代码块、公式和图表等所需的脚本，渲染 id 为 preview 的元素。
+func exportHTMLScripts(copyLabel string, appearance *conf.Appearance, editor *conf.Editor) string {
+	ver := "?" + util.Ver
+	return `<script src="appearance/icons/` + appearance.Icon + `/icon.js` + ver + `"></script>
+<script src="stage/build/export/protyle-method.js` + ver + `"></script>
+<script src="stage/protyle/js/lute/lute.min.js` + ver + `"></script>
+<script>
+    window.siyuan = {
+      config: {
+        appearance: { mode: ` + strconv.Itoa(appearance.Mode) + `, codeBlockThemeDark: ` + exportJSString(appearance.CodeBlockThemeDark) + `, codeBlockThemeLight: ` + exportJSString(appearance.CodeBlockThemeLight) + ` },
+        editor: {
+          codeLineWrap: true,
+          codeLigatures: ` + strconv.FormatBool(editor.CodeLigatures) + `,
+          plantUMLServePath: ` + exportJSString(editor.PlantUMLServePath) + `,
+          codeSyntaxHighlightLineNum: ` + strconv.FormatBool(editor.CodeSyntaxHighlightLineNum) + `,
+        }
+      },
+      languages: {copy: ` + exportJSString(copyLabel) + `}
+    };
+    const previewElement = document.getElementById('preview');
+    Protyle.highlightRender(previewElement, "stage/protyle");
+    Protyle.mathRender(previewElement, "stage/protyle", false);
+    Protyle.mermaidRender(previewElement, "stage/protyle");
+    Protyle.flowchartRender(previewElement, "stage/protyle");
+    Protyle.graphvizRender(previewElement, "stage/protyle");
+    Protyle.chartRender(previewElement, "stage/protyle");
+    Protyle.mindmapRender(previewElement, "stage/protyle");
+    Protyle.abcRender(previewElement, "stage/protyle");
+    Protyle.plantumlRender(previewElement, "stage/protyle");
+    Protyle.mediaRender(previewElement);
+    document.querySelectorAll(".protyle-action__copy").forEach((item) => {
+      item.addEventListener("click", (event) => {
+            navigator.clipboard.writeText(item.parentElement.nextElementSibling.textContent.trimEnd());
+            event.preventDefault();
+            event.stopPropagation();
+      })
+    });
+</script>
+`
+}
+
+// exportJSString 返回 JavaScript 字符串字面量。
+func exportJSString(s string) string {
+	data, _ := gulu.JSON.MarshalJSON(s)
+	return string(data)
+}
+
 func processIFrame(tree *parse.Tree) {
 	// 导出 PDF/Word 时 IFrame 块使用超链接 https://github.com/siyuan-note/siyuan/issues/4035
 	var unlinks []*ast.Node
@@ -882,6 +975,119 @@ func withoutKramdownBlockIAL(r *render.FormatRenderer, node *ast.Node) bool {
 	return !r.Options.KramdownBlockIAL || 0 == len(node.KramdownIAL)
 }
 
+// exportDoc 描述了导出的文档，文档层级和文档树一致。
+type exportDoc struct {
+	id       string
+	title    string
+	file     string // 导出后的文件名
+	tree     *parse.Tree
+	children []*exportDoc
+}
+
+func loadExportDoc(rootID string) (ret *exportDoc) {
+	tree, err := loadTreeByBlockID(rootID)
+	if nil != err {
+		util.LogErrorf("load tree [%s] failed: %s", rootID, err)
+		return
+	}
+	title := tree.Root.IALAttr("title")
+	if "" == title {
+		title = path.Base(tree.HPath)
+	}
+	return &exportDoc{id: tree.ID, title: title, tree: tree}
+}
+
+// loadExportDocTree 加载需要导出的文档。id 不为空时加载该文档及其子文档，否则加载笔记本 boxID 中路径 p 下的所有文档，
+// p 为空或者 / 时加载整个笔记本。name 为文档标题或者笔记本名称。
+func loadExportDocTree(boxID, p, id string) (ret []*exportDoc, name string, err error) {
+	if "" == id && "" != p && "/" != p {
+		id = strings.TrimSuffix(path.Base(p), ".sy")
+	}
+
+	if "" != id {
+		block := treenode.GetBlockTree(id)
+		if nil == block {
+			err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+			return
+		}
+		doc := loadExportDoc(block.RootID)
+		if nil == doc {
+			err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+			return
+		}
+		doc.children = loadExportDocs(block.BoxID, block.Path)
+		ret = append(ret, doc)
+		name = doc.title
+	} else {
+		box := Conf.Box(boxID)
+		if nil == box {
+			err = errors.New(Conf.Language(0))
+			return
+		}
+		ret = loadExportDocs(boxID, "/")
+		name = box.Name
+	}
+	if 1 > len(ret) {
+		err = errors.New(Conf.Language(140))
+	}
+	return
+}
+
+// loadExportDocs 按照文档树排序加载 p 下的子文档。
+func loadExportDocs(boxID, p string) (ret []*exportDoc) {
+	files, _, err := ListDocTree(boxID, p, Conf.FileTree.Sort)
+	if nil != err {
+		return
+	}
+	for _, file := range files {
+		chapter := loadExportDoc(file.ID)
+		if nil == chapter {
+			continue
+		}
+		if 0 < file.SubFileCount {
+			chapter.children = loadExportDocs(boxID, file.Path)
+		}
+		ret = append(ret, chapter)
+	}
+	return
+}
+
+func flattenExportDocs(chapters []*exportDoc, ret *[]*exportDoc) {
+	for _, chapter := range chapters {
+		*ret = append(*ret, chapter)
+		flattenExportDocs(chapter.children, ret)
+	}
+}
+
+// exportBlockRefsToLinks 将块引用转换为块链，保留被引用块的 ID。
+func exportBlockRefsToLinks(tree *parse.Tree) {
+	var unlinks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeBlockRef != n.Type {
+			return ast.WalkContinue
+		}
+		id, linkText := exportBlockRefLinkText(n)
+		n.InsertBefore(exportBlockRefLink(id, linkText))
+		unlinks = append(unlinks, n)
+		return ast.WalkSkipChildren
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+}
+
+// exportTaskListItemChecked 判断任务列表项是否已勾选，勾选标记符可能是列表项或者列表项中第一个段落的子节点。
+func exportTaskListItemChecked(li *ast.Node) bool {
+	marker := li.ChildByType(ast.NodeTaskListItemMarker)
+	if nil == marker && nil != li.FirstChild && ast.NodeParagraph == li.FirstChild.Type {
+		marker = li.FirstChild.ChildByType(ast.NodeTaskListItemMarker)
+	}
+	if nil != marker {
+		return marker.TaskListItemChecked
+	}
+	return nil != li.ListData && li.ListData.Checked
+}
+
 func exportTree(tree *parse.Tree, wysiwyg bool) (ret *parse.Tree) {
 	luteEngine := NewLute()
 	ret = tree
@@ -897,7 +1103,7 @@ func exportTree(tree *parse.Tree, wysiwyg bool) (ret *parse.Tree) {
 		var defMd string
 		stmt := n.ChildByType(ast.NodeBlockQueryEmbedScript).TokensStr()
 		stmt = html.UnescapeString(stmt)
-		blocks := searchEmbedBlock(stmt, nil, 0)
+		blocks := searchEmbedBlock(stmt, nil, 0, nil)
 		if 1 > len(blocks) {
 			return ast.WalkContinue
 		}
@@ -1041,20 +1247,7 @@ func exportTree(tree *parse.Tree, wysiwyg bool) (ret *parse.Tree) {
 
 		// 处理引用节点
 
-		var linkText string
-		id := n.ChildByType(ast.NodeBlockRefID).TokensStr()
-		if anchor := n.ChildByType(ast.NodeBlockRefText); nil != anchor {
-			linkText = anchor.Text()
-		} else if anchor = n.ChildByType(ast.NodeBlockRefDynamicText); nil != anchor {
-			linkText = anchor.Text()
-		} else {
-			linkText = sql.GetRefText(id)
-		}
-		if Conf.Editor.BlockRefDynamicAnchorTextMaxLen < utf8.RuneCountInString(linkText) {
-			linkText = gulu.Str.SubStr(linkText, Conf.Editor.BlockRefDynamicAnchorTextMaxLen) + "..."
-		}
-		linkText = Conf.Export.BlockRefTextLeft + linkText + Conf.Export.BlockRefTextRight
-
+		id, linkText := exportBlockRefLinkText(n)
 		defTree, _ := loadTreeByBlockID(id)
 		if nil == defTree {
 			return ast.WalkContinue
@@ -1062,15 +1255,7 @@ func exportTree(tree *parse.Tree, wysiwyg bool) (ret *parse.Tree) {
 
 		switch Conf.Export.BlockRefMode {
 		case 2: // 锚文本块链
-			var blockRefLink *ast.Node
-			blockRefLink = &ast.Node{Type: ast.NodeLink}
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeOpenBracket})
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeLinkText, Tokens: []byte(linkText)})
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeCloseBracket})
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeOpenParen})
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeLinkDest, Tokens: []byte("siyuan://blocks/" + id)})
-			blockRefLink.AppendChild(&ast.Node{Type: ast.NodeCloseParen})
-			n.InsertBefore(blockRefLink)
+			n.InsertBefore(exportBlockRefLink(id, linkText))
 		case 3: // 仅锚文本
 			n.InsertBefore(&ast.Node{Type: ast.NodeText, Tokens: []byte(linkText)})
 		case 4: // 脚注
@@ -1159,6 +1344,38 @@ func exportTree(tree *parse.Tree, wysiwyg bool) (ret *parse.Tree) {
 	return ret
 }
 
+// exportMediaSrcRegexp 用于从 iframe、视频、音频和挂件块中提取资源地址。
+var exportMediaSrcRegexp = regexp.MustCompile(`src="([^"]+)"`)
+
+// exportBlockRefLinkText 返回引用节点 n 引用的块 ID 和导出时使用的锚文本。
+func exportBlockRefLinkText(n *ast.Node) (id, linkText string) {
+	id = n.ChildByType(ast.NodeBlockRefID).TokensStr()
+	if anchor := n.ChildByType(ast.NodeBlockRefText); nil != anchor {
+		linkText = anchor.Text()
+	} else if anchor = n.ChildByType(ast.NodeBlockRefDynamicText); nil != anchor {
+		linkText = anchor.Text()
+	} else {
+		linkText = sql.GetRefText(id)
+	}
+	if Conf.Editor.BlockRefDynamicAnchorTextMaxLen < utf8.RuneCountInString(linkText) {
+		linkText = gulu.Str.SubStr(linkText, Conf.Editor.BlockRefDynamicAnchorTextMaxLen) + "..."
+	}
+	linkText = Conf.Export.BlockRefTextLeft + linkText + Conf.Export.BlockRefTextRight
+	return
+}
+
+// exportBlockRefLink 生成指向块 id 的块链节点。
+func exportBlockRefLink(id, linkText string) (ret *ast.Node) {
+	ret = &ast.Node{Type: ast.NodeLink}
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenBracket})
+	ret.AppendChild(&ast.Node{Type: ast.NodeLinkText, Tokens: []byte(linkText)})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseBracket})
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenParen})
+	ret.AppendChild(&ast.Node{Type: ast.NodeLinkDest, Tokens: []byte("siyuan://blocks/" + id)})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseParen})
+	return
+}
+
 func resolveFootnotesDefs(refFootnotes *[]*refAsFootnotes, rootID string) (footnotesDefBlock *ast.Node) {
 	if 1 > len(*refFootnotes) {
 		return nil
@@ -0,0 +1,832 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	docxPageWidth    = 11906 // A4 页宽，单位 twip
+	docxPageHeight   = 16838 // A4 页高
+	docxPageMargin   = 1440  // 页边距
+	docxIndent       = 567   // 每级缩进
+	docxEMUPerTwip   = 635
+	docxEMUPerPixel  = 9525
+	docxMaxListLevel = 8
+)
+
+// docxRunProps 描述了 Word 文本段（run）的格式。
+type docxRunProps struct {
+	bold, italic, strike, underline, code, mark, sup, sub, math bool
+	style                                                       string
+}
+
+// docxContext 描述了渲染块时所在的容器上下文。
+type docxContext struct {
+	style       string // 段落样式
+	inList      bool   // 是否在列表项中
+	numID       int    // 列表编号 ID，为 0 时不编号
+	level       int    // 列表层级，从 0 开始
+	numbered    bool   // 列表项是否已经输出过编号，列表项中只有第一个段落编号，后续段落保持列表缩进
+	checkbox    string // 任务列表项的勾选框，仅用于列表项的第一个段落
+	footnoteRef bool   // 是否需要输出脚注标记，仅用于脚注的第一个段落
+	indent      int    // 额外缩进，单位 twip
+	align       string // 段落对齐方式
+	inTable     bool
+}
+
+type docxMedia struct {
+	name string
+	data []byte
+}
+
+// docxWriter 将导出后的文档树渲染为 WordprocessingML。
+type docxWriter struct {
+	out          *bytes.Buffer
+	body         bytes.Buffer
+	footnotes    bytes.Buffer
+	rels         []string
+	media        []*docxMedia
+	mediaIDs     map[string]string // 资源路径 -> 关系 ID
+	nums         []string          // 每个列表对应的编号实例
+	footnoteIDs  map[string]int    // 脚注标签（^label）-> 脚注 ID
+	footnoteDefs []*ast.Node
+	bookmarks    map[string]bool // 被书内链接指向并且在导出文档中的块 ID
+	bookmarkID   int
+	drawingID    int
+}
+
+// exportDocxNative 使用内置的 WordprocessingML 渲染器导出 Word 文档，不依赖 Pandoc。
+//
+// 支持标题、段落、列表、任务列表、表格、代码块、引述、图片、脚注和公式（以文本形式导出），assets 下的图片会嵌入到文档中。
+func exportDocxNative(id, savePath string) (err error) {
+	tree, _ := loadTreeByBlockID(id)
+	if nil == tree {
+		return errors.New(fmt.Sprintf(Conf.Language(15), id))
+	}
+
+	tree = exportTree(tree, false)
+	name := path.Base(tree.HPath)
+	if err = os.MkdirAll(savePath, 0755); nil != err {
+		util.LogErrorf("mkdir [%s] failed: %s", savePath, err)
+		return errors.New(fmt.Sprintf(Conf.Language(14), err))
+	}
+
+	docxPath := filepath.Join(savePath, name+".docx")
+	if err = writeDocx(docxPath, name, tree); nil != err {
+		util.LogErrorf("export docx failed: %s", err)
+		return errors.New(fmt.Sprintf(Conf.Language(14), err))
+	}
+	return
+}
+
+func writeDocx(docxPath, title string, tree *parse.Tree) (err error) {
+	w := &docxWriter{mediaIDs: map[string]string{}, footnoteIDs: map[string]int{}, bookmarks: map[string]bool{}}
+	w.prepare(tree)
+
+	w.out = &w.body
+	for c := tree.Root.FirstChild; nil != c; c = c.Next {
+		w.block(c, &docxContext{})
+	}
+
+	w.out = &w.footnotes
+	for i, def := range w.footnoteDefs {
+		w.footnotes.WriteString(`<w:footnote w:id="` + strconv.Itoa(i+1) + `">`)
+		ctx := &docxContext{style: "FootnoteText", footnoteRef: true}
+		for c := def.FirstChild; nil != c; c = c.Next {
+			w.block(c, ctx)
+		}
+		if ctx.footnoteRef {
+			w.paragraphStart(nil, ctx)
+			w.out.WriteString("</w:p>")
+		}
+		w.footnotes.WriteString(`</w:footnote>`)
+	}
+
+	f, err := os.Create(docxPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	contentTypes := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+  <Default Extension="xml" ContentType="application/xml"/>
+  <Default Extension="png" ContentType="image/png"/>
+  <Default Extension="jpeg" ContentType="image/jpeg"/>
+  <Default Extension="gif" ContentType="image/gif"/>
+  <Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+  <Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+  <Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>
+  <Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>
+  <Override PartName="/word/footnotes.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml"/>
+  <Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+	if err = writeZipEntry(zw, "[Content_Types].xml", []byte(contentTypes)); nil != err {
+		return
+	}
+
+	rels := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+	if err = writeZipEntry(zw, "_rels/.rels", []byte(rels)); nil != err {
+		return
+	}
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	core := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <dc:title>` + docxEscape(title) + `</dc:title>
+  <dc:creator>SiYuan</dc:creator>
+  <dcterms:created xsi:type="dcterms:W3CDTF">` + now + `</dcterms:created>
+  <dcterms:modified xsi:type="dcterms:W3CDTF">` + now + `</dcterms:modified>
+</cp:coreProperties>`
+	if err = writeZipEntry(zw, "docProps/core.xml", []byte(core)); nil != err {
+		return
+	}
+
+	docRels := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+  <Relationship Id="rIdSettings" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>
+  <Relationship Id="rIdNumbering" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+  <Relationship Id="rIdFootnotes" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footnotes" Target="footnotes.xml"/>
+` + strings.Join(w.rels, "\n") + `
+</Relationships>`
+	if err = writeZipEntry(zw, "word/_rels/document.xml.rels", []byte(docRels)); nil != err {
+		return
+	}
+
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">
+<w:body>` + w.body.String() + `<w:sectPr><w:pgSz w:w="` + strconv.Itoa(docxPageWidth) + `" w:h="` + strconv.Itoa(docxPageHeight) + `"/>` +
+		`<w:pgMar w:top="` + strconv.Itoa(docxPageMargin) + `" w:right="` + strconv.Itoa(docxPageMargin) + `" w:bottom="` + strconv.Itoa(docxPageMargin) + `" w:left="` + strconv.Itoa(docxPageMargin) + `" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr>
+</w:body>
+</w:document>`
+	if err = writeZipEntry(zw, "word/document.xml", []byte(document)); nil != err {
+		return
+	}
+
+	footnotes := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">
+<w:footnote w:type="separator" w:id="-1"><w:p><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:r><w:separator/></w:r></w:p></w:footnote>
+<w:footnote w:type="continuationSeparator" w:id="0"><w:p><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:r><w:continuationSeparator/></w:r></w:p></w:footnote>
+` + w.footnotes.String() + `
+</w:footnotes>`
+	if err = writeZipEntry(zw, "word/footnotes.xml", []byte(footnotes)); nil != err {
+		return
+	}
+
+	settings := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:defaultTabStop w:val="720"/>
+  <w:footnotePr><w:footnote w:id="-1"/><w:footnote w:id="0"/></w:footnotePr>
+  <w:compat><w:compatSetting w:name="compatibilityMode" w:uri="http://schemas.microsoft.com/office/word" w:val="15"/></w:compat>
+</w:settings>`
+	if err = writeZipEntry(zw, "word/settings.xml", []byte(settings)); nil != err {
+		return
+	}
+	if err = writeZipEntry(zw, "word/styles.xml", []byte(docxStyles())); nil != err {
+		return
+	}
+	if err = writeZipEntry(zw, "word/numbering.xml", []byte(docxNumbering(w.nums))); nil != err {
+		return
+	}
+	for _, m := range w.media {
+		if err = writeZipEntry(zw, "word/media/"+m.name, m.data); nil != err {
+			return
+		}
+	}
+	return zw.Close()
+}
+
+// prepare 收集脚注定义和书内链接指向的块，脚注定义不在正文中渲染。
+func (w *docxWriter) prepare(tree *parse.Tree) {
+	var unlinks []*ast.Node
+	linked, blocks := map[string]bool{}, map[string]bool{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if "" != n.ID && n.IsBlock() {
+			blocks[n.ID] = true
+		}
+
+		switch n.Type {
+		case ast.NodeFootnotesDefBlock:
+			for def := n.FirstChild; nil != def; def = def.Next {
+				if ast.NodeFootnotesDef != def.Type {
+					continue
+				}
+				if _, ok := w.footnoteIDs[def.TokensStr()]; ok {
+					continue
+				}
+				w.footnoteDefs = append(w.footnoteDefs, def)
+				w.footnoteIDs[def.TokensStr()] = len(w.footnoteDefs)
+			}
+			unlinks = append(unlinks, n)
+			return ast.WalkSkipChildren
+		case ast.NodeLinkDest:
+			if dest := n.TokensStr(); strings.HasPrefix(dest, "siyuan://blocks/") {
+				linked[strings.TrimPrefix(dest, "siyuan://blocks/")] = true
+			}
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+	for id := range linked {
+		if blocks[id] {
+			w.bookmarks[id] = true
+		}
+	}
+}
+
+func (w *docxWriter) block(n *ast.Node, ctx *docxContext) {
+	switch n.Type {
+	case ast.NodeDocument, ast.NodeSuperBlock:
+		for c := n.FirstChild; nil != c; c = c.Next {
+			w.block(c, ctx)
+		}
+	case ast.NodeParagraph:
+		w.paragraphStart(n, ctx)
+		w.inlines(n, &docxRunProps{})
+		w.out.WriteString("</w:p>")
+	case ast.NodeHeading:
+		style := ctx.style
+		props := &docxRunProps{}
+		if "" == style && !ctx.inTable {
+			ctx.style = "Heading" + strconv.Itoa(n.HeadingLevel)
+		} else {
+			// 引述、脚注等容器中的标题保持容器样式，仅加粗
+			props.bold = true
+		}
+		w.paragraphStart(n, ctx)
+		w.inlines(n, props)
+		w.out.WriteString("</w:p>")
+		ctx.style = style
+	case ast.NodeThematicBreak:
+		w.out.WriteString(`<w:p><w:pPr><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="BFBFBF"/></w:pBdr></w:pPr></w:p>`)
+	case ast.NodeBlockquote:
+		style, indent := ctx.style, ctx.indent
+		if "" == ctx.style {
+			ctx.style = "Quote"
+		}
+		ctx.indent += docxIndent
+		for c := n.FirstChild; nil != c; c = c.Next {
+			w.block(c, ctx)
+		}
+		ctx.style, ctx.indent = style, indent
+	case ast.NodeList:
+		w.list(n, ctx)
+	case ast.NodeCodeBlock:
+		code := n.ChildByType(ast.NodeCodeBlockCode)
+		if nil == code {
+			return
+		}
+		w.preformatted(n, ctx, "Code", strings.TrimSuffix(code.TokensStr(), "\n"))
+	case ast.NodeMathBlock:
+		content := n.ChildByType(ast.NodeMathBlockContent)
+		if nil == content {
+			return
+		}
+		w.preformatted(n, ctx, "MathBlock", content.TokensStr())
+	case ast.NodeHTMLBlock:
+		w.preformatted(n, ctx, "Code", strings.TrimSpace(n.TokensStr()))
+	case ast.NodeTable:
+		w.table(n, ctx)
+	case ast.NodeIFrame, ast.NodeVideo, ast.NodeAudio, ast.NodeWidget:
+		m := exportMediaSrcRegexp.FindStringSubmatch(n.TokensStr())
+		if nil == m {
+			return
+		}
+		src := html.UnescapeString(m[1])
+		w.paragraphStart(n, ctx)
+		w.hyperlink(src, func() { w.text(src, &docxRunProps{}) })
+		w.out.WriteString("</w:p>")
+	}
+}
+
+// paragraphStart 输出段落开始标签和段落属性，列表项的第一个段落会带上编号。
+func (w *docxWriter) paragraphStart(n *ast.Node, ctx *docxContext) {
+	w.out.WriteString("<w:p><w:pPr>")
+	if "" != ctx.style {
+		w.out.WriteString(`<w:pStyle w:val="` + ctx.style + `"/>`)
+	}
+	if 0 < ctx.numID && !ctx.numbered {
+		w.out.WriteString(`<w:numPr><w:ilvl w:val="` + strconv.Itoa(ctx.level) + `"/><w:numId w:val="` + strconv.Itoa(ctx.numID) + `"/></w:numPr>`)
+	}
+	if left, hanging := w.indent(ctx); 0 < hanging {
+		w.out.WriteString(`<w:ind w:left="` + strconv.Itoa(left) + `" w:hanging="` + strconv.Itoa(hanging) + `"/>`)
+	} else if 0 < left {
+		w.out.WriteString(`<w:ind w:left="` + strconv.Itoa(left) + `"/>`)
+	}
+	if "" != ctx.align {
+		w.out.WriteString(`<w:jc w:val="` + ctx.align + `"/>`)
+	}
+	w.out.WriteString("</w:pPr>")
+
+	if nil != n && "" != n.ID && w.bookmarks[n.ID] {
+		w.bookmarkID++
+		bookmarkID := strconv.Itoa(w.bookmarkID)
+		w.out.WriteString(`<w:bookmarkStart w:id="` + bookmarkID + `" w:name="` + docxBookmarkName(n.ID) + `"/><w:bookmarkEnd w:id="` + bookmarkID + `"/>`)
+	}
+
+	if ctx.footnoteRef {
+		w.out.WriteString(`<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteRef/></w:r>`)
+		w.text(" ", &docxRunProps{})
+		ctx.footnoteRef = false
+	}
+	if "" != ctx.checkbox {
+		w.text(ctx.checkbox+" ", &docxRunProps{})
+		ctx.checkbox = ""
+	}
+	if ctx.inList {
+		ctx.numbered = true
+	}
+}
+
+// indent 返回段落的左缩进和悬挂缩进，列表项中的后续段落和编号后的文本对齐。
+//
+// 编号段落没有额外缩进时使用编号定义中的缩进。
+func (w *docxWriter) indent(ctx *docxContext) (left, hanging int) {
+	left = ctx.indent
+	if !ctx.inList {
+		return
+	}
+	numbered := 0 < ctx.numID && !ctx.numbered
+	if numbered && 1 > left {
+		return 0, 0
+	}
+	left += (ctx.level + 1) * docxIndent * 2
+	if numbered {
+		hanging = docxIndent
+	}
+	return
+}
+
+func (w *docxWriter) preformatted(n *ast.Node, ctx *docxContext, style, content string) {
+	ctxStyle := ctx.style
+	if !ctx.inTable && "FootnoteText" != ctx.style {
+		ctx.style = style
+	}
+	w.paragraphStart(n, ctx)
+	props := &docxRunProps{code: "Code" == style, math: "MathBlock" == style}
+	for i, line := range strings.Split(content, "\n") {
+		if 0 < i {
+			w.out.WriteString("<w:r><w:br/></w:r>")
+		}
+		w.text(line, props)
+	}
+	w.out.WriteString("</w:p>")
+	ctx.style = ctxStyle
+}
+
+func (w *docxWriter) list(n *ast.Node, ctx *docxContext) {
+	level := 0
+	if ctx.inList {
+		level = ctx.level + 1
+	}
+	if docxMaxListLevel < level {
+		level = docxMaxListLevel
+	}
+
+	numID := 0
+	if 3 != n.ListData.Typ {
+		abstractNumID, start := 0, 1
+		if 1 == n.ListData.Typ {
+			abstractNumID = 1
+			if 0 < n.ListData.Start {
+				start = n.ListData.Start
+			}
+		}
+		w.nums = append(w.nums, `<w:num w:numId="`+strconv.Itoa(len(w.nums)+1)+`"><w:abstractNumId w:val="`+strconv.Itoa(abstractNumID)+`"/>`+
+			`<w:lvlOverride w:ilvl="`+strconv.Itoa(level)+`"><w:startOverride w:val="`+strconv.Itoa(start)+`"/></w:lvlOverride></w:num>`)
+		numID = len(w.nums)
+	}
+
+	for li := n.FirstChild; nil != li; li = li.Next {
+		if ast.NodeListItem != li.Type {
+			continue
+		}
+		itemCtx := &docxContext{style: ctx.style, inList: true, numID: numID, level: level, indent: ctx.indent, inTable: ctx.inTable}
+		if 0 == numID {
+			itemCtx.numbered = true
+			itemCtx.checkbox = "☐"
+			if exportTaskListItemChecked(li) {
+				itemCtx.checkbox = "☑"
+			}
+		}
+		hasBlock := false
+		for c := li.FirstChild; nil != c; c = c.Next {
+			if ast.NodeTaskListItemMarker == c.Type || ast.NodeKramdownBlockIAL == c.Type {
+				continue
+			}
+			hasBlock = true
+			w.block(c, itemCtx)
+		}
+		if !hasBlock {
+			w.paragraphStart(nil, itemCtx)
+			w.out.WriteString("</w:p>")
+		}
+	}
+}
+
+func (w *docxWriter) table(n *ast.Node, ctx *docxContext) {
+	cols := len(n.TableAligns)
+	if 1 > cols {
+		return
+	}
+	width := docxPageWidth - 2*docxPageMargin - ctx.indent
+	colWidth := width / cols
+
+	w.out.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="` + strconv.Itoa(width) + `" w:type="dxa"/>`)
+	if 0 < ctx.indent {
+		w.out.WriteString(`<w:tblInd w:w="` + strconv.Itoa(ctx.indent) + `" w:type="dxa"/>`)
+	}
+	w.out.WriteString(`<w:tblLook w:val="04A0" w:firstRow="1" w:lastRow="0" w:firstColumn="0" w:lastColumn="0" w:noHBand="0" w:noVBand="1"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < cols; i++ {
+		w.out.WriteString(`<w:gridCol w:w="` + strconv.Itoa(colWidth) + `"/>`)
+	}
+	w.out.WriteString("</w:tblGrid>")
+
+	var rows []*ast.Node
+	for c := n.FirstChild; nil != c; c = c.Next {
+		if ast.NodeTableHead == c.Type {
+			for headRow := c.FirstChild; nil != headRow; headRow = headRow.Next {
+				rows = append(rows, headRow)
+			}
+		} else {
+			rows = append(rows, c)
+		}
+	}
+	for _, row := range rows {
+		if ast.NodeTableRow != row.Type {
+			continue
+		}
+		header := ast.NodeTableHead == row.Parent.Type
+
+		w.out.WriteString("<w:tr>")
+		if header {
+			w.out.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		col := 0
+		for cell := row.FirstChild; nil != cell && col < cols; cell = cell.Next {
+			if ast.NodeTableCell != cell.Type {
+				continue
+			}
+			w.out.WriteString(`<w:tc><w:tcPr><w:tcW w:w="` + strconv.Itoa(colWidth) + `" w:type="dxa"/></w:tcPr>`)
+			cellCtx := &docxContext{inTable: true}
+			switch n.TableAligns[col] {
+			case 2:
+				cellCtx.align = "center"
+			case 3:
+				cellCtx.align = "right"
+			}
+			w.paragraphStart(nil, cellCtx)
+			w.inlines(cell, &docxRunProps{bold: header})
+			w.out.WriteString("</w:p></w:tc>")
+			col++
+		}
+		for ; col < cols; col++ {
+			w.out.WriteString(`<w:tc><w:tcPr><w:tcW w:w="` + strconv.Itoa(colWidth) + `" w:type="dxa"/></w:tcPr><w:p/></w:tc>`)
+		}
+		w.out.WriteString("</w:tr>")
+	}
+	w.out.WriteString("</w:tbl>")
+	if !ctx.inTable {
+		// 表格后需要一个段落，否则相邻的表格会被合并
+		w.out.WriteString("<w:p/>")
+	}
+}
+
+func (w *docxWriter) inlines(n *ast.Node, props *docxRunProps) {
+	for c := n.FirstChild; nil != c; c = c.Next {
+		w.inline(c, props)
+	}
+}
+
+func (w *docxWriter) inline(n *ast.Node, props *docxRunProps) {
+	switch n.Type {
+	case ast.NodeText, ast.NodeHTMLEntity, ast.NodeBackslashContent, ast.NodeEmojiUnicode, ast.NodeEmojiAlias:
+		text := strings.ReplaceAll(n.TokensStr(), "  \n", "\n")
+		if nil != n.Previous && ast.NodeTaskListItemMarker == n.Previous.Type {
+			// 任务列表项的勾选框已经在段落开头输出
+			text = strings.TrimLeft(text, " ")
+		}
+		w.text(text, props)
+	case ast.NodeCodeSpanContent:
+		p := *props
+		p.code = true
+		w.text(n.TokensStr(), &p)
+	case ast.NodeInlineMathContent:
+		p := *props
+		p.math = true
+		w.text(n.TokensStr(), &p)
+	case ast.NodeHardBreak, ast.NodeSoftBreak, ast.NodeBr:
+		w.out.WriteString("<w:r><w:br/></w:r>")
+	case ast.NodeEmphasis, ast.NodeStrong, ast.NodeStrikethrough, ast.NodeMark, ast.NodeSup, ast.NodeSub, ast.NodeKbd, ast.NodeUnderline:
+		p := *props
+		switch n.Type {
+		case ast.NodeEmphasis:
+			p.italic = true
+		case ast.NodeStrong:
+			p.bold = true
+		case ast.NodeStrikethrough:
+			p.strike = true
+		case ast.NodeMark:
+			p.mark = true
+		case ast.NodeSup:
+			p.sup = true
+		case ast.NodeSub:
+			p.sub = true
+		case ast.NodeKbd:
+			p.code = true
+		case ast.NodeUnderline:
+			p.underline = true
+		}
+		w.inlines(n, &p)
+	case ast.NodeLink:
+		dest := n.ChildByType(ast.NodeLinkDest)
+		if nil == dest {
+			w.inlines(n, props)
+			return
+		}
+		w.hyperlink(dest.TokensStr(), func() { w.inlines(n, props) })
+	case ast.NodeLinkText:
+		w.text(n.TokensStr(), props)
+	case ast.NodeImage:
+		w.image(n, props)
+	case ast.NodeFootnotesRef:
+		if id, ok := w.footnoteIDs[n.TokensStr()]; ok {
+			w.out.WriteString(`<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteReference w:id="` + strconv.Itoa(id) + `"/></w:r>`)
+		}
+	case ast.NodeBlockRef:
+		if text := n.ChildByType(ast.NodeBlockRefText); nil != text {
+			w.text(text.Text(), props)
+		} else if text = n.ChildByType(ast.NodeBlockRefDynamicText); nil != text {
+			w.text(text.Text(), props)
+		}
+	case ast.NodeEmojiImg:
+		w.inlines(n, props)
+	case ast.NodeInlineHTML, ast.NodeKramdownSpanIAL, ast.NodeLinkDest, ast.NodeLinkTitle, ast.NodeTaskListItemMarker:
+		// 忽略
+	default:
+		w.inlines(n, props)
+	}
+}
+
+// hyperlink 输出超链接，指向导出文档中其他块的块链使用书签链接。
+func (w *docxWriter) hyperlink(dest string, content func()) {
+	if strings.HasPrefix(dest, "siyuan://blocks/") {
+		id := strings.TrimPrefix(dest, "siyuan://blocks/")
+		if w.bookmarks[id] {
+			w.out.WriteString(`<w:hyperlink w:anchor="` + docxBookmarkName(id) + `" w:history="1">`)
+			content()
+			w.out.WriteString("</w:hyperlink>")
+			return
+		}
+	}
+
+	relID := "rIdLink" + strconv.Itoa(len(w.rels)+1)
+	w.rels = append(w.rels, `  <Relationship Id="`+relID+`" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="`+docxEscape(dest)+`" TargetMode="External"/>`)
+	w.out.WriteString(`<w:hyperlink r:id="` + relID + `" w:history="1">`)
+	content()
+	w.out.WriteString("</w:hyperlink>")
+}
+
+func (w *docxWriter) image(n *ast.Node, props *docxRunProps) {
+	alt := ""
+	if text := n.ChildByType(ast.NodeLinkText); nil != text {
+		alt = text.TokensStr()
+	}
+	dest := n.ChildByType(ast.NodeLinkDest)
+	if nil == dest {
+		w.text(alt, props)
+		return
+	}
+	src := string(html.DecodeDestination(dest.Tokens))
+	if strings.Contains(src, "?") {
+		src = src[:strings.LastIndex(src, "?")]
+	}
+
+	relID, cx, cy := w.embedImage(src)
+	if "" == relID {
+		// 无法嵌入的图片（网络图片或者不支持的格式）以链接形式导出
+		if "" == alt {
+			alt = src
+		}
+		w.hyperlink(src, func() { w.text(alt, props) })
+		return
+	}
+
+	w.drawingID++
+	drawingID := strconv.Itoa(w.drawingID)
+	w.out.WriteString(`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">` +
+		`<wp:extent cx="` + strconv.Itoa(cx) + `" cy="` + strconv.Itoa(cy) + `"/>` +
+		`<wp:docPr id="` + drawingID + `" name="Picture ` + drawingID + `" descr="` + docxEscape(alt) + `"/>` +
+		`<wp:cNvGraphicFramePr><a:graphicFrameLocks noChangeAspect="1"/></wp:cNvGraphicFramePr>` +
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic>` +
+		`<pic:nvPicPr><pic:cNvPr id="` + drawingID + `" name="` + docxEscape(path.Base(src)) + `"/><pic:cNvPicPr/></pic:nvPicPr>` +
+		`<pic:blipFill><a:blip r:embed="` + relID + `"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>` +
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="` + strconv.Itoa(cx) + `" cy="` + strconv.Itoa(cy) + `"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>` +
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`)
+}
+
+// embedImage 将 assets 下的图片嵌入到文档中，返回关系 ID 和按页面宽度缩放后的尺寸（EMU）。
+func (w *docxWriter) embedImage(src string) (relID string, cx, cy int) {
+	if !strings.HasPrefix(src, "assets/") {
+		return
+	}
+
+	absPath, err := GetAssetAbsPath(src)
+	if nil != err {
+		util.LogWarnf("resolve path of asset [%s] failed: %s", src, err)
+		return
+	}
+	data, err := os.ReadFile(absPath)
+	if nil != err {
+		util.LogWarnf("read asset [%s] failed: %s", absPath, err)
+		return
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if nil != err || 1 > config.Width || 1 > config.Height {
+		return
+	}
+
+	cx, cy = config.Width*docxEMUPerPixel, config.Height*docxEMUPerPixel
+	if maxWidth := (docxPageWidth - 2*docxPageMargin) * docxEMUPerTwip; maxWidth < cx {
+		cy = int(int64(cy) * int64(maxWidth) / int64(cx))
+		cx = maxWidth
+	}
+
+	if relID = w.mediaIDs[src]; "" != relID {
+		return
+	}
+	name := "image" + strconv.Itoa(len(w.media)+1) + "." + format
+	w.media = append(w.media, &docxMedia{name: name, data: data})
+	relID = "rIdImage" + strconv.Itoa(len(w.media))
+	w.mediaIDs[src] = relID
+	w.rels = append(w.rels, `  <Relationship Id="`+relID+`" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/`+name+`"/>`)
+	return
+}
+
+func (w *docxWriter) text(text string, props *docxRunProps) {
+	if "" == text {
+		return
+	}
+
+	rPr := bytes.Buffer{}
+	if "" != props.style {
+		rPr.WriteString(`<w:rStyle w:val="` + props.style + `"/>`)
+	}
+	if props.code {
+		rPr.WriteString(`<w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/>`)
+	} else if props.math {
+		rPr.WriteString(`<w:rFonts w:ascii="Cambria Math" w:hAnsi="Cambria Math"/>`)
+	}
+	if props.bold {
+		rPr.WriteString("<w:b/>")
+	}
+	if props.italic || props.math {
+		rPr.WriteString("<w:i/>")
+	}
+	if props.strike {
+		rPr.WriteString("<w:strike/>")
+	}
+	if props.underline {
+		rPr.WriteString(`<w:u w:val="single"/>`)
+	}
+	if props.mark {
+		rPr.WriteString(`<w:highlight w:val="yellow"/>`)
+	}
+	if props.sup {
+		rPr.WriteString(`<w:vertAlign w:val="superscript"/>`)
+	} else if props.sub {
+		rPr.WriteString(`<w:vertAlign w:val="subscript"/>`)
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		w.out.WriteString("<w:r>")
+		if 0 < rPr.Len() {
+			w.out.WriteString("<w:rPr>" + rPr.String() + "</w:rPr>")
+		}
+		if 0 < i {
+			w.out.WriteString("<w:br/>")
+		}
+		w.out.WriteString(`<w:t xml:space="preserve">` + docxEscape(line) + "</w:t></w:r>")
+	}
+}
+
+func docxBookmarkName(id string) string {
+	// 书签名不能超过 40 个字符并且需要以字母开头
+	return "b" + strings.ReplaceAll(id, "-", "_")
+}
+
+// docxEscape 转义 XML 文本，并移除 XML 中不允许出现的控制字符。
+func docxEscape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if (0x20 > r && '\t' != r && '\n' != r && '\r' != r) || utf8.RuneError == r {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
+
+func docxStyles() string {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:docDefaults>
+    <w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:eastAsiaTheme="minorEastAsia" w:hAnsiTheme="minorHAnsi" w:cstheme="minorBidi"/><w:sz w:val="22"/><w:szCs w:val="22"/></w:rPr></w:rPrDefault>
+    <w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault>
+  </w:docDefaults>
+  <w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>
+`)
+	sizes := []int{36, 32, 28, 26, 24, 22}
+	for i, size := range sizes {
+		level := strconv.Itoa(i + 1)
+		buf.WriteString(`  <w:style w:type="paragraph" w:styleId="Heading` + level + `"><w:name w:val="heading ` + level + `"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+			`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="` + strconv.Itoa(i) + `"/></w:pPr>` +
+			`<w:rPr><w:b/><w:bCs/><w:sz w:val="` + strconv.Itoa(size) + `"/><w:szCs w:val="` + strconv.Itoa(size) + `"/></w:rPr></w:style>
+`)
+	}
+	buf.WriteString(`  <w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:qFormat/><w:pPr><w:pBdr><w:left w:val="single" w:sz="18" w:space="8" w:color="D0D7DE"/></w:pBdr></w:pPr><w:rPr><w:color w:val="57606A"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/><w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F6F8FA"/><w:spacing w:after="120" w:line="240" w:lineRule="auto"/></w:pPr><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="20"/><w:szCs w:val="20"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="MathBlock"><w:name w:val="Math Block"/><w:basedOn w:val="Normal"/><w:pPr><w:jc w:val="center"/></w:pPr><w:rPr><w:rFonts w:ascii="Cambria Math" w:hAnsi="Cambria Math"/><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="FootnoteText"><w:name w:val="footnote text"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:rPr><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>
+  <w:style w:type="character" w:styleId="FootnoteReference"><w:name w:val="footnote reference"/><w:rPr><w:vertAlign w:val="superscript"/></w:rPr></w:style>
+  <w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+  <w:style w:type="table" w:default="1" w:styleId="TableNormal"><w:name w:val="Normal Table"/><w:tblPr><w:tblInd w:w="0" w:type="dxa"/><w:tblCellMar><w:top w:w="0" w:type="dxa"/><w:left w:w="108" w:type="dxa"/><w:bottom w:w="0" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>
+  <w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:basedOn w:val="TableNormal"/><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>`)
+	return buf.String()
+}
+
+// docxNumbering 生成编号定义，0 为无序列表，1 为有序列表，nums 为每个列表对应的编号实例。
+func docxNumbering(nums []string) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+`)
+	bullets := []string{"•", "◦", "▪"}
+	for abstractNumID := 0; abstractNumID < 2; abstractNumID++ {
+		buf.WriteString(`  <w:abstractNum w:abstractNumId="` + strconv.Itoa(abstractNumID) + `"><w:multiLevelType w:val="hybridMultilevel"/>`)
+		for level := 0; level <= docxMaxListLevel; level++ {
+			numFmt, text := "bullet", bullets[level%len(bullets)]
+			if 1 == abstractNumID {
+				numFmt, text = "decimal", "%"+strconv.Itoa(level+1)+"."
+			}
+			left := (level + 1) * docxIndent * 2
+			buf.WriteString(`<w:lvl w:ilvl="` + strconv.Itoa(level) + `"><w:start w:val="1"/><w:numFmt w:val="` + numFmt + `"/><w:lvlText w:val="` + text + `"/><w:lvlJc w:val="left"/>` +
+				`<w:pPr><w:ind w:left="` + strconv.Itoa(left) + `" w:hanging="` + strconv.Itoa(docxIndent) + `"/></w:pPr></w:lvl>`)
+		}
+		buf.WriteString("</w:abstractNum>\n")
+	}
+	for _, num := range nums {
+		buf.WriteString("  " + num + "\n")
+	}
+	buf.WriteString("</w:numbering>")
+	return buf.String()
+}
@@ -0,0 +1,381 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/html"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// epubNavItem 描述了 EPUB 目录中的一项。
+type epubNavItem struct {
+	title    string
+	href     string
+	children []*epubNavItem
+}
+
+// epubAllowedAttrs 是导出 XHTML 时保留的属性，其他属性（比如块 IAL 中的 updated）会被移除。
+var epubAllowedAttrs = map[string]bool{
+	"id": true, "href": true, "src": true, "alt": true, "title": true, "class": true, "colspan": true, "rowspan": true,
+	"align": true, "type": true, "checked": true, "disabled": true, "start": true, "lang": true, "width": true, "height": true,
+}
+
+// ExportEPUB 导出 EPUB 3 电子书。id 不为空时导出该文档及其子文档，否则导出笔记本 boxID 下的所有文档。
+//
+// 每个文档为一章，目录由文档层级和文档中的标题构成，块引用按照导出设置中的块引用模式处理，指向书中其他块的块链会改为书内链接。
+func ExportEPUB(boxID, id string) (name, zipPath string, err error) {
+	WaitForWritingFiles()
+
+	chapters, name, err := loadExportDocTree(boxID, "", id)
+	if nil != err {
+		return
+	}
+
+	// 分配章节文件名，记录文档 ID 和章节文件的对应关系用于改写书内块链
+	var flatChapters []*exportDoc
+	flattenExportDocs(chapters, &flatChapters)
+	chapterFiles := map[string]string{}
+	for i, chapter := range flatChapters {
+		chapter.file = "chapter-" + strconv.Itoa(i+1) + ".xhtml"
+		chapterFiles[chapter.id] = chapter.file
+	}
+
+	name = util.FilterFileName(name)
+	if "" == name {
+		name = "Untitled"
+	}
+	exportFolder := filepath.Join(util.TempDir, "export")
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		util.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+	epubPath := filepath.Join(exportFolder, name+".epub")
+	if err = writeEPUB(epubPath, name, chapters, flatChapters, chapterFiles); nil != err {
+		util.LogErrorf("export epub [%s] failed: %s", epubPath, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(14), err))
+		return
+	}
+	zipPath = "/export/" + url.PathEscape(filepath.Base(epubPath))
+	return
+}
+
+func writeEPUB(epubPath, title string, chapters, flatChapters []*exportDoc, chapterFiles map[string]string) (err error) {
+	f, err := os.Create(epubPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+
+	// mimetype 必须是第一个文件并且不能压缩
+	mimetype, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if nil != err {
+		return
+	}
+	if _, err = mimetype.Write([]byte("application/epub+zip")); nil != err {
+		return
+	}
+
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	if err = writeZipEntry(w, "META-INF/container.xml", []byte(container)); nil != err {
+		return
+	}
+
+	// 导出会修改文档树，所以先生成目录
+	var navItems []*epubNavItem
+	for _, chapter := range chapters {
+		navItems = append(navItems, epubChapterNavItem(chapter))
+	}
+
+	luteEngine := NewLute()
+	luteEngine.SetFootnotes(true)
+	luteEngine.RenderOptions.HeadingID = false
+	var assets []string
+	addedAssets := map[string]bool{}
+	for _, chapter := range flatChapters {
+		tree := exportTree(chapter.tree, true)
+		for _, asset := range assetsLinkDestsInTree(tree) {
+			asset = string(html.DecodeDestination([]byte(asset)))
+			if strings.Contains(asset, "?") {
+				asset = asset[:strings.LastIndex(asset, "?")]
+			}
+			if strings.HasPrefix(asset, "assets/") && !addedAssets[asset] {
+				addedAssets[asset] = true
+				assets = append(assets, asset)
+			}
+		}
+
+		body, renderErr := epubXHTMLBody(luteEngine.Tree2HTML(tree, luteEngine.RenderOptions), chapterFiles)
+		if nil != renderErr {
+			return renderErr
+		}
+		if err = writeZipEntry(w, "OEBPS/"+chapter.file, []byte(epubXHTML(chapter.title, body))); nil != err {
+			return
+		}
+	}
+
+	var manifestAssets []string
+	for i, asset := range assets {
+		absPath, assetErr := GetAssetAbsPath(asset)
+		if nil != assetErr {
+			util.LogWarnf("resolve path of asset [%s] failed: %s", asset, assetErr)
+			continue
+		}
+		data, readErr := os.ReadFile(absPath)
+		if nil != readErr {
+			util.LogWarnf("read asset [%s] failed: %s", absPath, readErr)
+			continue
+		}
+		if err = writeZipEntry(w, "OEBPS/"+asset, data); nil != err {
+			return
+		}
+		mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(asset)))
+		if "" == mediaType {
+			mediaType = "application/octet-stream"
+		}
+		if semicolon := strings.Index(mediaType, ";"); 0 < semicolon {
+			mediaType = mediaType[:semicolon]
+		}
+		manifestAssets = append(manifestAssets, `    <item id="asset-`+strconv.Itoa(i+1)+`" href="`+epubEscape((&url.URL{Path: asset}).String())+`" media-type="`+mediaType+`"/>`)
+	}
+
+	navBuf := bytes.Buffer{}
+	writeEPUBNavItems(&navBuf, navItems, 2)
+	nav := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <meta charset="UTF-8"/>
+  <title>` + epubEscape(title) + `</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>` + epubEscape(title) + `</h1>
+` + navBuf.String() + `  </nav>
+</body>
+</html>`
+	if err = writeZipEntry(w, "OEBPS/nav.xhtml", []byte(nav)); nil != err {
+		return
+	}
+
+	var manifestChapters, spine []string
+	for i, chapter := range flatChapters {
+		itemID := "chapter-" + strconv.Itoa(i+1)
+		manifestChapters = append(manifestChapters, `    <item id="`+itemID+`" href="`+chapter.file+`" media-type="application/xhtml+xml"/>`)
+		spine = append(spine, `    <itemref idref="`+itemID+`"/>`)
+	}
+	lang := strings.ReplaceAll(Conf.Lang, "_", "-")
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">urn:siyuan:` + flatChapters[0].id + `</dc:identifier>
+    <dc:title>` + epubEscape(title) + `</dc:title>
+    <dc:language>` + epubEscape(lang) + `</dc:language>
+    <dc:creator>SiYuan</dc:creator>
+    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + `</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+` + strings.Join(append(manifestChapters, manifestAssets...), "\n") + `
+  </manifest>
+  <spine>
+` + strings.Join(spine, "\n") + `
+  </spine>
+</package>`
+	if err = writeZipEntry(w, "OEBPS/content.opf", []byte(opf)); nil != err {
+		return
+	}
+	return w.Close()
+}
+
+// epubChapterNavItem 生成章节的目录项，子项依次为章节中的标题和子章节。
+func epubChapterNavItem(chapter *exportDoc) (ret *epubNavItem) {
+	ret = &epubNavItem{title: chapter.title, href: chapter.file}
+	for _, heading := range outline(chapter.tree) {
+		item := &epubNavItem{title: epubHTMLText(heading.Name), href: chapter.file + "#" + epubAnchor(heading.ID)}
+		epubHeadingNavItems(chapter.file, heading.Blocks, &item.children)
+		ret.children = append(ret.children, item)
+	}
+	for _, child := range chapter.children {
+		ret.children = append(ret.children, epubChapterNavItem(child))
+	}
+	return
+}
+
+func epubHeadingNavItems(file string, headings []*Block, ret *[]*epubNavItem) {
+	for _, heading := range headings {
+		item := &epubNavItem{title: epubHTMLText(heading.Content), href: file + "#" + epubAnchor(heading.ID)}
+		epubHeadingNavItems(file, heading.Children, &item.children)
+		*ret = append(*ret, item)
+	}
+}
+
+func writeEPUBNavItems(buf *bytes.Buffer, items []*epubNavItem, depth int) {
+	indent := strings.Repeat("  ", depth)
+	buf.WriteString(indent + "<ol>\n")
+	for _, item := range items {
+		title := item.title
+		if "" == strings.TrimSpace(title) {
+			title = "-"
+		}
+		buf.WriteString(indent + `  <li><a href="` + epubEscape(item.href) + `">` + epubEscape(title) + "</a>")
+		if 0 < len(item.children) {
+			buf.WriteString("\n")
+			writeEPUBNavItems(buf, item.children, depth+2)
+			buf.WriteString(indent + "  ")
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString(indent + "</ol>\n")
+}
+
+// epubXHTMLBody 将渲染得到的 HTML 规范化为 XHTML：移除多余属性、块 ID 改为合法的锚点，并将书内的块链改为章节链接。
+func epubXHTMLBody(dom string, chapterFiles map[string]string) (ret string, err error) {
+	doc, err := html.Parse(strings.NewReader("<html><body>" + dom + "</body></html>"))
+	if nil != err {
+		return
+	}
+
+	var body *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if html.ElementNode == n.Type {
+			if "body" == n.Data && nil == body {
+				body = n
+			}
+
+			var attrs []*html.Attribute
+			for _, attr := range n.Attr {
+				if !epubAllowedAttrs[attr.Key] {
+					continue
+				}
+				switch attr.Key {
+				case "id":
+					if util.IsIDPattern(attr.Val) {
+						attr.Val = epubAnchor(attr.Val)
+					}
+				case "href":
+					attr.Val = epubHref(attr.Val, chapterFiles)
+				}
+				attrs = append(attrs, attr)
+			}
+			n.Attr = attrs
+		}
+		for c := n.FirstChild; nil != c; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if nil == body {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	for c := body.FirstChild; nil != c; c = c.NextSibling {
+		if err = html.Render(&buf, c); nil != err {
+			return
+		}
+	}
+	ret = buf.String()
+	return
+}
+
+// epubHref 将指向书中块的块链改为章节内链接。
+func epubHref(href string, chapterFiles map[string]string) string {
+	if !strings.HasPrefix(href, "siyuan://blocks/") {
+		return href
+	}
+
+	id := strings.TrimPrefix(href, "siyuan://blocks/")
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return href
+	}
+	file, ok := chapterFiles[bt.RootID]
+	if !ok {
+		return href
+	}
+	if id == bt.RootID {
+		return file
+	}
+	return file + "#" + epubAnchor(id)
+}
+
+// epubAnchor 返回块在 XHTML 中的锚点。块 ID 以数字开头，不是合法的 XML ID，所以加上前缀。
+func epubAnchor(id string) string {
+	return "b" + id
+}
+
+func epubHTMLText(dom string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(dom))
+	if nil != err {
+		return dom
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+func epubXHTML(title, body string) string {
+	heading := ""
+	if !Conf.Export.AddTitle { // 导出设置中开启添加标题时导出的文档树中已经包含标题
+		heading = "<h1>" + epubEscape(title) + "</h1>\n"
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <meta charset="UTF-8"/>
+  <title>` + epubEscape(title) + `</title>
+</head>
+<body>
+` + heading + body + `
+</body>
+</html>`
+}
+
+func epubEscape(s string) string {
+	return html.EscapeString(s)
+}
+
+func writeZipEntry(w *zip.Writer, name string, data []byte) (err error) {
+	entry, err := w.Create(name)
+	if nil != err {
+		return
+	}
+	_, err = io.Copy(entry, bytes.NewReader(data))
+	return
+}
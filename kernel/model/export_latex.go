@@ -0,0 +1,616 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// defaultLaTeXPreamble 是默认的 LaTeX 导言区模板，$title$ 和 $date$ 会被替换为文档标题和导出日期。
+//
+// 导言区中引入了 minted 宏包时代码块使用 minted 环境，否则使用 listings 宏包的 lstlisting 环境。
+const defaultLaTeXPreamble = `\documentclass[11pt]{article}
+\usepackage{iftex}
+\ifPDFTeX
+  \usepackage[utf8]{inputenc}
+  \usepackage[T1]{fontenc}
+\else
+  \usepackage{fontspec}
+  \ifXeTeX
+    \usepackage{xeCJK}
+  \fi
+\fi
+\usepackage{amsmath,amssymb}
+\usepackage{graphicx}
+\usepackage{xcolor}
+\usepackage[normalem]{ulem}
+\usepackage{listings}
+\usepackage{hyperref}
+\lstset{basicstyle=\ttfamily\small,breaklines=true,frame=single,columns=fullflexible}
+
+\title{$title$}
+\author{}
+\date{$date$}
+`
+
+// latexListingsLanguages 是 listings 宏包内置支持的语言，其他语言的代码块不指定语言，避免编译失败。
+var latexListingsLanguages = map[string]string{
+	"bash": "bash", "sh": "bash", "shell": "bash", "c": "C", "cpp": "C++", "c++": "C++", "csharp": "[Sharp]C", "cs": "[Sharp]C",
+	"java": "Java", "python": "Python", "py": "Python", "ruby": "Ruby", "perl": "Perl", "php": "PHP", "sql": "SQL",
+	"html": "HTML", "xml": "XML", "tex": "TeX", "latex": "[LaTeX]TeX", "matlab": "Matlab", "r": "R", "lua": "Lua",
+	"haskell": "Haskell", "fortran": "Fortran", "pascal": "Pascal", "scala": "Scala", "make": "make", "makefile": "make",
+}
+
+var latexMintedRegexp = regexp.MustCompile(`(?m)^[^%\n]*\\usepackage(\[[^\]]*\])?\{[^}]*\bminted\b[^}]*\}`)
+
+// ExportLaTeX 导出 LaTeX 源文件，返回的压缩包中包含 .tex 文件和引用的 assets。
+//
+// 数学公式原样导出，指向文档内块的块引用导出为 \label 和 \ref，块引用模式为脚注时按照脚注导出。
+func ExportLaTeX(id string) (name, zipPath string, err error) {
+	WaitForWritingFiles()
+
+	block := treenode.GetBlockTree(id)
+	if nil == block {
+		err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+		return
+	}
+	tree, err := loadTreeByBlockID(block.RootID)
+	if nil != err {
+		err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+		return
+	}
+
+	title := tree.Root.IALAttr("title")
+	if "" == title {
+		title = path.Base(tree.HPath)
+	}
+	name = util.FilterFileName(title)
+	if "" == name {
+		name = "Untitled"
+	}
+
+	if 4 != Conf.Export.BlockRefMode {
+		// 块引用先转换为块链，渲染时指向文档内的块链使用 \ref
+		exportBlockRefsToLinks(tree)
+	}
+	tree = exportTree(tree, false)
+
+	preamble := GetLaTeXPreamble()
+	r := newLaTeXRenderer(tree, latexMintedRegexp.MatchString(preamble))
+	body := r.render(tree.Root)
+
+	buf := bytes.Buffer{}
+	buf.WriteString(strings.NewReplacer("$title$", latexEscape(title), "$date$", time.Now().Format("2006-01-02")).Replace(preamble))
+	buf.WriteString("\n\\begin{document}\n")
+	if !Conf.Export.AddTitle {
+		buf.WriteString("\\maketitle\n")
+	}
+	buf.WriteString("\n")
+	buf.WriteString(body)
+	buf.WriteString("\\end{document}\n")
+
+	exportFolder := filepath.Join(util.TempDir, "export")
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		util.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+	zipAbsPath := filepath.Join(exportFolder, name+".latex.zip")
+	if err = writeLaTeXZip(zipAbsPath, name, buf.Bytes(), r.assets); nil != err {
+		util.LogErrorf("export latex [%s] failed: %s", zipAbsPath, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(14), err))
+		return
+	}
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipAbsPath))
+	return
+}
+
+// GetLaTeXPreamble 返回 LaTeX 导言区模板，工作空间中没有自定义模板时返回默认模板。
+func GetLaTeXPreamble() string {
+	p := latexPreamblePath()
+	if !gulu.File.IsExist(p) {
+		return defaultLaTeXPreamble
+	}
+	data, err := filesys.LockFileRead(p)
+	if nil != err {
+		util.LogErrorf("read latex preamble [%s] failed: %s", p, err)
+		return defaultLaTeXPreamble
+	}
+	return string(data)
+}
+
+// SetLaTeXPreamble 保存 LaTeX 导言区模板，preamble 为空时恢复默认模板。
+func SetLaTeXPreamble(preamble string) (err error) {
+	p := latexPreamblePath()
+	if "" == strings.TrimSpace(preamble) {
+		if err = os.RemoveAll(p); nil != err {
+			util.LogErrorf("remove latex preamble [%s] failed: %s", p, err)
+			return
+		}
+		IncWorkspaceDataVer()
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		util.LogErrorf("create storage dir failed: %s", err)
+		return
+	}
+	if err = filesys.LockFileWrite(p, []byte(preamble)); nil != err {
+		util.LogErrorf("write latex preamble [%s] failed: %s", p, err)
+		return
+	}
+	IncWorkspaceDataVer()
+	return
+}
+
+func latexPreamblePath() string {
+	return filepath.Join(util.DataDir, "storage", "latex-preamble.tex")
+}
+
+func writeLaTeXZip(zipPath, name string, tex []byte, assets []string) (err error) {
+	f, err := os.Create(zipPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	if err = writeZipEntry(w, name+"/"+name+".tex", tex); nil != err {
+		return
+	}
+	for _, asset := range assets {
+		absPath, assetErr := GetAssetAbsPath(asset)
+		if nil != assetErr {
+			util.LogWarnf("resolve path of asset [%s] failed: %s", asset, assetErr)
+			continue
+		}
+		data, readErr := os.ReadFile(absPath)
+		if nil != readErr {
+			util.LogWarnf("read asset [%s] failed: %s", absPath, readErr)
+			continue
+		}
+		if err = writeZipEntry(w, name+"/"+asset, data); nil != err {
+			return
+		}
+	}
+	return w.Close()
+}
+
+// latexRenderer 将导出后的文档树渲染为 LaTeX。
+type latexRenderer struct {
+	buf          *bytes.Buffer
+	minted       bool                 // 代码块是否使用 minted 环境
+	labels       map[string]bool      // 被文档内块链指向的块 ID
+	footnoteDefs map[string]*ast.Node // 脚注标签（^label）-> 脚注定义
+	assets       []string             // 引用的 assets
+	addedAssets  map[string]bool
+	listDepth    int
+}
+
+func newLaTeXRenderer(tree *parse.Tree, minted bool) (ret *latexRenderer) {
+	ret = &latexRenderer{minted: minted, labels: map[string]bool{}, footnoteDefs: map[string]*ast.Node{}, addedAssets: map[string]bool{}}
+
+	var unlinks []*ast.Node
+	linked, blocks := map[string]bool{}, map[string]bool{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if "" != n.ID && n.IsBlock() {
+			blocks[n.ID] = true
+		}
+		switch n.Type {
+		case ast.NodeFootnotesDefBlock:
+			for def := n.FirstChild; nil != def; def = def.Next {
+				if ast.NodeFootnotesDef == def.Type {
+					ret.footnoteDefs[def.TokensStr()] = def
+				}
+			}
+			unlinks = append(unlinks, n)
+		case ast.NodeLinkDest:
+			if dest := n.TokensStr(); strings.HasPrefix(dest, "siyuan://blocks/") {
+				linked[strings.TrimPrefix(dest, "siyuan://blocks/")] = true
+			}
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+	for id := range linked {
+		if blocks[id] {
+			ret.labels[id] = true
+		}
+	}
+	return
+}
+
+func (r *latexRenderer) render(root *ast.Node) string {
+	r.buf = &bytes.Buffer{}
+	for c := root.FirstChild; nil != c; c = c.Next {
+		r.block(c)
+	}
+	return r.buf.String()
+}
+
+func (r *latexRenderer) block(n *ast.Node) {
+	switch n.Type {
+	case ast.NodeSuperBlock:
+		for c := n.FirstChild; nil != c; c = c.Next {
+			r.block(c)
+		}
+	case ast.NodeParagraph:
+		r.label(n)
+		r.inlines(n)
+		r.buf.WriteString("\n\n")
+	case ast.NodeHeading:
+		commands := []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+		level := n.HeadingLevel
+		if 1 > level || 6 < level {
+			level = 1
+		}
+		r.buf.WriteString("\\" + commands[level-1] + "{")
+		r.inlines(n)
+		r.buf.WriteString("}")
+		if "" != n.ID && r.labels[n.ID] {
+			r.buf.WriteString("\\label{" + latexLabel(n.ID) + "}")
+		}
+		r.buf.WriteString("\n\n")
+	case ast.NodeThematicBreak:
+		r.buf.WriteString("\\noindent\\rule{\\linewidth}{0.4pt}\n\n")
+	case ast.NodeBlockquote:
+		r.label(n)
+		r.buf.WriteString("\\begin{quote}\n")
+		for c := n.FirstChild; nil != c; c = c.Next {
+			r.block(c)
+		}
+		r.buf.WriteString("\\end{quote}\n\n")
+	case ast.NodeList:
+		r.list(n)
+	case ast.NodeCodeBlock:
+		r.codeBlock(n)
+	case ast.NodeMathBlock:
+		content := n.ChildByType(ast.NodeMathBlockContent)
+		if nil == content {
+			return
+		}
+		r.label(n)
+		math := strings.TrimSpace(string(html.UnescapeHTML(content.Tokens)))
+		if strings.HasPrefix(math, "\\begin{") {
+			// 已经是数学环境（比如 align）时原样导出
+			r.buf.WriteString(math + "\n\n")
+		} else {
+			r.buf.WriteString("\\[\n" + math + "\n\\]\n\n")
+		}
+	case ast.NodeTable:
+		r.table(n)
+	case ast.NodeHTMLBlock:
+		// HTML 块无法转换为 LaTeX，以注释形式保留
+		for _, line := range strings.Split(strings.TrimSpace(n.TokensStr()), "\n") {
+			r.buf.WriteString("% " + line + "\n")
+		}
+		r.buf.WriteString("\n")
+	case ast.NodeIFrame, ast.NodeVideo, ast.NodeAudio, ast.NodeWidget:
+		if m := exportMediaSrcRegexp.FindStringSubmatch(n.TokensStr()); nil != m {
+			r.buf.WriteString("\\url{" + latexURL(html.UnescapeString(m[1])) + "}\n\n")
+		}
+	}
+}
+
+// label 为被文档内块链指向的块输出 \label。
+func (r *latexRenderer) label(n *ast.Node) {
+	if "" != n.ID && r.labels[n.ID] {
+		r.buf.WriteString("\\phantomsection\\label{" + latexLabel(n.ID) + "}")
+	}
+}
+
+func (r *latexRenderer) list(n *ast.Node) {
+	env := "itemize"
+	if 1 == n.ListData.Typ {
+		env = "enumerate"
+	}
+	r.listDepth++
+	defer func() { r.listDepth-- }()
+
+	r.label(n)
+	r.buf.WriteString("\\begin{" + env + "}\n")
+	if 1 == n.ListData.Typ && 1 < n.ListData.Start && 4 >= r.listDepth {
+		r.buf.WriteString("\\setcounter{enum" + strings.Repeat("i", r.listDepth) + "}{" + strconv.Itoa(n.ListData.Start-1) + "}\n")
+	}
+	for li := n.FirstChild; nil != li; li = li.Next {
+		if ast.NodeListItem != li.Type {
+			continue
+		}
+		r.buf.WriteString("\\item")
+		if 3 == n.ListData.Typ {
+			if exportTaskListItemChecked(li) {
+				r.buf.WriteString("[$\\boxtimes$]")
+			} else {
+				r.buf.WriteString("[$\\square$]")
+			}
+		}
+		r.buf.WriteString(" ")
+		for c := li.FirstChild; nil != c; c = c.Next {
+			if ast.NodeTaskListItemMarker == c.Type || ast.NodeKramdownBlockIAL == c.Type {
+				continue
+			}
+			r.block(c)
+		}
+	}
+	r.buf.WriteString("\\end{" + env + "}\n\n")
+}
+
+func (r *latexRenderer) codeBlock(n *ast.Node) {
+	code := n.ChildByType(ast.NodeCodeBlockCode)
+	if nil == code {
+		return
+	}
+	var lang string
+	if info := n.ChildByType(ast.NodeCodeBlockFenceInfoMarker); nil != info {
+		if fields := strings.Fields(string(info.CodeBlockInfo)); 0 < len(fields) {
+			lang = strings.ToLower(fields[0])
+		}
+	}
+	content := strings.TrimSuffix(string(html.UnescapeHTML(code.Tokens)), "\n")
+	// 代码中出现结束标记时会提前结束环境
+	content = strings.ReplaceAll(content, "\\end{minted}", "\\end {minted}")
+	content = strings.ReplaceAll(content, "\\end{lstlisting}", "\\end {lstlisting}")
+
+	r.label(n)
+	if r.minted {
+		if "" == lang {
+			lang = "text"
+		}
+		r.buf.WriteString("\\begin{minted}{" + lang + "}\n" + content + "\n\\end{minted}\n\n")
+		return
+	}
+	r.buf.WriteString("\\begin{lstlisting}")
+	if language := latexListingsLanguages[lang]; "" != language {
+		r.buf.WriteString("[language=" + language + "]")
+	}
+	r.buf.WriteString("\n" + content + "\n\\end{lstlisting}\n\n")
+}
+
+func (r *latexRenderer) table(n *ast.Node) {
+	if 1 > len(n.TableAligns) {
+		return
+	}
+	r.label(n)
+	spec := bytes.Buffer{}
+	spec.WriteString("|")
+	for _, align := range n.TableAligns {
+		switch align {
+		case 2:
+			spec.WriteString("c|")
+		case 3:
+			spec.WriteString("r|")
+		default:
+			spec.WriteString("l|")
+		}
+	}
+	r.buf.WriteString("\\begin{center}\n\\begin{tabular}{" + spec.String() + "}\n\\hline\n")
+	var rows []*ast.Node
+	for c := n.FirstChild; nil != c; c = c.Next {
+		if ast.NodeTableHead == c.Type {
+			for headRow := c.FirstChild; nil != headRow; headRow = headRow.Next {
+				rows = append(rows, headRow)
+			}
+		} else {
+			rows = append(rows, c)
+		}
+	}
+	for _, row := range rows {
+		if ast.NodeTableRow != row.Type {
+			continue
+		}
+		header := ast.NodeTableHead == row.Parent.Type
+
+		col := 0
+		for cell := row.FirstChild; nil != cell && col < len(n.TableAligns); cell = cell.Next {
+			if ast.NodeTableCell != cell.Type {
+				continue
+			}
+			if 0 < col {
+				r.buf.WriteString(" & ")
+			}
+			if header {
+				r.buf.WriteString("\\textbf{")
+				r.inlines(cell)
+				r.buf.WriteString("}")
+			} else {
+				r.inlines(cell)
+			}
+			col++
+		}
+		for ; col < len(n.TableAligns); col++ {
+			r.buf.WriteString(" &")
+		}
+		r.buf.WriteString(" \\\\\n\\hline\n")
+	}
+	r.buf.WriteString("\\end{tabular}\n\\end{center}\n\n")
+}
+
+func (r *latexRenderer) inlines(n *ast.Node) {
+	for c := n.FirstChild; nil != c; c = c.Next {
+		r.inline(c)
+	}
+}
+
+func (r *latexRenderer) inline(n *ast.Node) {
+	switch n.Type {
+	case ast.NodeText, ast.NodeHTMLEntity, ast.NodeBackslashContent, ast.NodeEmojiUnicode, ast.NodeEmojiAlias, ast.NodeLinkText:
+		text := strings.ReplaceAll(n.TokensStr(), "  \n", "\n")
+		if nil != n.Previous && ast.NodeTaskListItemMarker == n.Previous.Type {
+			text = strings.TrimLeft(text, " ")
+		}
+		r.buf.WriteString(strings.ReplaceAll(latexEscape(text), "\n", "\\\\\n"))
+	case ast.NodeCodeSpanContent:
+		r.buf.WriteString("\\texttt{" + latexEscape(n.TokensStr()) + "}")
+	case ast.NodeInlineMathContent:
+		r.buf.WriteString("$" + strings.TrimSpace(string(html.UnescapeHTML(n.Tokens))) + "$")
+	case ast.NodeHardBreak, ast.NodeSoftBreak, ast.NodeBr:
+		r.buf.WriteString("\\\\\n")
+	case ast.NodeEmphasis:
+		r.command("emph", n)
+	case ast.NodeStrong:
+		r.command("textbf", n)
+	case ast.NodeStrikethrough:
+		r.command("sout", n)
+	case ast.NodeMark:
+		r.command("colorbox{yellow}", n)
+	case ast.NodeSup:
+		r.command("textsuperscript", n)
+	case ast.NodeSub:
+		r.command("textsubscript", n)
+	case ast.NodeKbd:
+		r.command("texttt", n)
+	case ast.NodeUnderline:
+		r.command("underline", n)
+	case ast.NodeLink:
+		dest := n.ChildByType(ast.NodeLinkDest)
+		if nil == dest {
+			r.inlines(n)
+			return
+		}
+		href := dest.TokensStr()
+		if strings.HasPrefix(href, "siyuan://blocks/") {
+			if id := strings.TrimPrefix(href, "siyuan://blocks/"); r.labels[id] {
+				r.buf.WriteString("\\hyperref[" + latexLabel(id) + "]{")
+				r.inlines(n)
+				r.buf.WriteString("}~(\\ref{" + latexLabel(id) + "})")
+			} else {
+				r.inlines(n)
+			}
+			return
+		}
+		r.buf.WriteString("\\href{" + latexURL(href) + "}{")
+		r.inlines(n)
+		r.buf.WriteString("}")
+	case ast.NodeImage:
+		r.image(n)
+	case ast.NodeFootnotesRef:
+		def := r.footnoteDefs[n.TokensStr()]
+		if nil == def {
+			return
+		}
+		footnote := &latexRenderer{minted: r.minted, labels: r.labels, footnoteDefs: map[string]*ast.Node{}, addedAssets: r.addedAssets}
+		content := strings.TrimSpace(footnote.render(def))
+		r.assets = append(r.assets, footnote.assets...)
+		r.buf.WriteString("\\footnote{" + strings.ReplaceAll(content, "\n\n", "\\par ") + "}")
+	case ast.NodeEmojiImg:
+		r.inlines(n)
+	case ast.NodeInlineHTML, ast.NodeKramdownSpanIAL, ast.NodeLinkDest, ast.NodeLinkTitle, ast.NodeTaskListItemMarker:
+		// 忽略
+	default:
+		r.inlines(n)
+	}
+}
+
+func (r *latexRenderer) command(command string, n *ast.Node) {
+	r.buf.WriteString("\\" + command + "{")
+	r.inlines(n)
+	r.buf.WriteString("}")
+}
+
+func (r *latexRenderer) image(n *ast.Node) {
+	alt := ""
+	if text := n.ChildByType(ast.NodeLinkText); nil != text {
+		alt = text.TokensStr()
+	}
+	dest := n.ChildByType(ast.NodeLinkDest)
+	if nil == dest {
+		r.buf.WriteString(latexEscape(alt))
+		return
+	}
+	src := string(html.DecodeDestination(dest.Tokens))
+	if strings.Contains(src, "?") {
+		src = src[:strings.LastIndex(src, "?")]
+	}
+
+	// pdfLaTeX 仅支持 PNG、JPEG 和 PDF 格式的图片
+	switch strings.ToLower(path.Ext(src)) {
+	case ".png", ".jpg", ".jpeg", ".pdf":
+	default:
+		if "" == alt {
+			alt = src
+		}
+		r.buf.WriteString("\\href{" + latexURL(src) + "}{" + latexEscape(alt) + "}")
+		return
+	}
+	if !strings.HasPrefix(src, "assets/") {
+		r.buf.WriteString("\\href{" + latexURL(src) + "}{" + latexEscape(alt) + "}")
+		return
+	}
+
+	if !r.addedAssets[src] {
+		r.addedAssets[src] = true
+		r.assets = append(r.assets, src)
+	}
+	r.buf.WriteString("\\includegraphics[width=\\linewidth,height=0.5\\textheight,keepaspectratio]{" + src + "}")
+}
+
+func latexLabel(id string) string {
+	return "sy:" + id
+}
+
+// latexEscape 转义 LaTeX 特殊字符。
+func latexEscape(s string) string {
+	buf := bytes.Buffer{}
+	for _, c := range s {
+		switch c {
+		case '\\':
+			buf.WriteString("\\textbackslash{}")
+		case '{', '}', '$', '&', '#', '_', '%':
+			buf.WriteByte('\\')
+			buf.WriteRune(c)
+		case '^':
+			buf.WriteString("\\textasciicircum{}")
+		case '~':
+			buf.WriteString("\\textasciitilde{}")
+		case '<':
+			buf.WriteString("\\textless{}")
+		case '>':
+			buf.WriteString("\\textgreater{}")
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	return buf.String()
+}
+
+// latexURL 转义 \href 和 \url 中的链接地址。
+func latexURL(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "#", "\\#", "%", "\\%", "{", "\\{", "}", "\\}").Replace(s)
+}
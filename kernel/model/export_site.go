@@ -0,0 +1,446 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// sitePage 描述了静态站点中的一个文档页面。
+type sitePage struct {
+	doc     *exportDoc
+	parents []*exportDoc
+	tags    []string
+}
+
+// siteSearchItem 描述了客户端搜索索引中的一项。
+type siteSearchItem struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	HPath   string `json:"hPath"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
+}
+
+var siteBlockLinkRegexp = regexp.MustCompile(`(?:target="_blank" )?href="siyuan://blocks/([0-9]{14}-[0-9a-z]{7})"`)
+
+// ExportSite 导出静态站点。id 不为空时导出该文档及其子文档，否则导出笔记本 boxID 中路径 p 下的所有文档，p 为空时导出整个笔记本。
+//
+// 每个文档渲染为一个页面，块引用改为页面间的锚点链接，并生成文档树索引页、标签页和客户端搜索索引，仅复制文档中用到的资源文件。
+func ExportSite(boxID, p, id string) (name, zipPath string, err error) {
+	WaitForWritingFiles()
+
+	docs, name, err := loadExportDocTree(boxID, p, id)
+	if nil != err {
+		return
+	}
+
+	var pages []*sitePage
+	sitePages(docs, nil, &pages)
+	pageFiles := map[string]string{}
+	for _, page := range pages {
+		page.doc.file = page.doc.id + ".html"
+		pageFiles[page.doc.id] = page.doc.file
+	}
+
+	name = util.FilterFileName(name)
+	if "" == name {
+		name = "Untitled"
+	}
+	exportFolder := filepath.Join(util.TempDir, "export", name)
+	os.RemoveAll(exportFolder)
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		util.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+	defer os.RemoveAll(exportFolder)
+
+	// 标签页需要在渲染前汇总，页面中会列出文档的标签
+	tags := siteTags(BuildTags(), pages)
+	tagFiles := map[string]string{}
+	var flatTags []*Tag
+	flattenSiteTags(tags, &flatTags)
+	for i, tag := range flatTags {
+		tagFiles[tag.Label] = "tag-" + strconv.Itoa(i+1) + ".html"
+	}
+
+	luteEngine := NewLute()
+	luteEngine.SetFootnotes(true)
+	luteEngine.RenderOptions.ProtyleContenteditable = false
+	var searchIndex []*siteSearchItem
+	copiedAssets := map[string]bool{}
+	for _, page := range pages {
+		doc := page.doc
+		searchIndex = append(searchIndex, &siteSearchItem{
+			ID:      doc.id,
+			Title:   doc.title,
+			HPath:   doc.tree.HPath,
+			URL:     doc.file,
+			Content: siteSearchContent(doc.tree),
+		})
+
+		exportBlockRefsToLinks(doc.tree)
+		tree := exportTree(doc.tree, true)
+		for _, asset := range assetsLinkDestsInTree(tree) {
+			asset = string(html.DecodeDestination([]byte(asset)))
+			if strings.Contains(asset, "?") {
+				asset = asset[:strings.LastIndex(asset, "?")]
+			}
+			if !strings.HasPrefix(asset, "assets/") || copiedAssets[asset] {
+				continue
+			}
+			copiedAssets[asset] = true
+
+			srcPath, assetErr := GetAssetAbsPath(asset)
+			if nil != assetErr {
+				util.LogWarnf("resolve path of asset [%s] failed: %s", asset, assetErr)
+				continue
+			}
+			destPath := filepath.Join(exportFolder, asset)
+			if err = gulu.File.Copy(srcPath, destPath); nil != err {
+				util.LogErrorf("copy asset from [%s] to [%s] failed: %s", srcPath, destPath, err)
+				err = nil
+			}
+		}
+
+		renderer := render.NewBlockExportRenderer(tree, luteEngine.RenderOptions)
+		dom := siteBlockLinks(gulu.Str.FromBytes(renderer.Render()), pageFiles)
+		if err = sitePageWrite(exportFolder, doc.file, doc.title, name, sitePageBody(page, dom, tagFiles), true); nil != err {
+			return
+		}
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(`<h1 class="site__title">` + html.EscapeString(name) + "</h1>\n")
+	writeSiteDocList(&buf, docs)
+	if err = sitePageWrite(exportFolder, "index.html", name, name, buf.String(), false); nil != err {
+		return
+	}
+
+	buf.Reset()
+	buf.WriteString(`<h1 class="site__title">` + html.EscapeString(Conf.Language(141)) + "</h1>\n")
+	writeSiteTagList(&buf, tags, tagFiles)
+	if err = sitePageWrite(exportFolder, "tags.html", Conf.Language(141), name, buf.String(), false); nil != err {
+		return
+	}
+	for _, tag := range flatTags {
+		buf.Reset()
+		buf.WriteString(`<h1 class="site__title">#` + html.EscapeString(tag.Label) + "#</h1>\n<ul>\n")
+		for _, page := range pages {
+			if siteTagged(page, tag.Label) {
+				buf.WriteString(`<li><a href="` + page.doc.file + `">` + html.EscapeString(page.doc.title) + "</a></li>\n")
+			}
+		}
+		buf.WriteString("</ul>\n")
+		if err = sitePageWrite(exportFolder, tagFiles[tag.Label], "#"+tag.Label+"#", name, buf.String(), false); nil != err {
+			return
+		}
+	}
+
+	data, err := gulu.JSON.MarshalJSON(searchIndex)
+	if nil != err {
+		return
+	}
+	files := map[string][]byte{
+		"search-index.js": append(append([]byte("window.siteSearchIndex = "), data...), ';'),
+		"site.js":         []byte(siteJS),
+		"site.css":        []byte(siteCSS),
+	}
+	for file, content := range files {
+		if err = gulu.File.WriteFileSafer(filepath.Join(exportFolder, file), content, 0644); nil != err {
+			util.LogErrorf("write site file [%s] failed: %s", file, err)
+			return
+		}
+	}
+
+	srcs := []string{"stage/build/export", "stage/build/fonts", "stage/protyle"}
+	for _, src := range srcs {
+		from := filepath.Join(util.WorkingDir, src)
+		to := filepath.Join(exportFolder, src)
+		if err = gulu.File.Copy(from, to); nil != err {
+			util.LogErrorf("copy stage from [%s] to [%s] failed: %s", from, exportFolder, err)
+			return
+		}
+	}
+	srcs = []string{"icons", "themes/" + siteTheme()}
+	for _, src := range srcs {
+		from := filepath.Join(util.AppearancePath, src)
+		to := filepath.Join(exportFolder, "appearance", src)
+		if err = gulu.File.Copy(from, to); nil != err {
+			util.LogErrorf("copy appearance from [%s] to [%s] failed: %s", from, exportFolder, err)
+			return
+		}
+	}
+
+	zipFile := exportFolder + ".zip"
+	zip, err := gulu.Zip.Create(zipFile)
+	if nil != err {
+		util.LogErrorf("create export site zip [%s] failed: %s", zipFile, err)
+		return
+	}
+	if err = zip.AddDirectory(name, exportFolder); nil != err {
+		util.LogErrorf("create export site zip [%s] failed: %s", zipFile, err)
+		return
+	}
+	if err = zip.Close(); nil != err {
+		util.LogErrorf("close export site zip failed: %s", err)
+		return
+	}
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipFile))
+	return
+}
+
+// sitePages 按照文档树顺序展开页面，记录每个页面的上级文档和使用的标签。
+func sitePages(docs, parents []*exportDoc, ret *[]*sitePage) {
+	for _, doc := range docs {
+		page := &sitePage{doc: doc, parents: parents}
+		added := map[string]bool{}
+		ast.Walk(doc.tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || ast.NodeTag != n.Type {
+				return ast.WalkContinue
+			}
+			if label := strings.TrimSpace(n.Text()); "" != label && !added[label] {
+				added[label] = true
+				page.tags = append(page.tags, label)
+			}
+			return ast.WalkSkipChildren
+		})
+		*ret = append(*ret, page)
+
+		ancestors := append(append([]*exportDoc{}, parents...), doc)
+		sitePages(doc.children, ancestors, ret)
+	}
+}
+
+// siteTags 从所有标签中筛选出站点页面中使用的标签，上级标签在其下级标签被使用时保留。
+func siteTags(tags *Tags, pages []*sitePage) (ret []*Tag) {
+	used := map[string]bool{}
+	for _, page := range pages {
+		for _, label := range page.tags {
+			used[label] = true
+		}
+	}
+	return filterSiteTags(*tags, used)
+}
+
+func filterSiteTags(tags Tags, used map[string]bool) (ret []*Tag) {
+	for _, tag := range tags {
+		children := filterSiteTags(tag.Children, used)
+		if used[tag.Label] || 0 < len(children) {
+			ret = append(ret, &Tag{Name: tag.Name, Label: tag.Label, Children: children, Type: tag.Type, Depth: tag.Depth})
+		}
+	}
+	return
+}
+
+func flattenSiteTags(tags []*Tag, ret *[]*Tag) {
+	for _, tag := range tags {
+		*ret = append(*ret, tag)
+		flattenSiteTags(tag.Children, ret)
+	}
+}
+
+// siteTagged 判断页面是否使用了标签 label 或者其下级标签。
+func siteTagged(page *sitePage, label string) bool {
+	for _, tag := range page.tags {
+		if tag == label || strings.HasPrefix(tag, label+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// siteSearchContent 返回文档的纯文本内容，用于客户端搜索。
+func siteSearchContent(tree *parse.Tree) string {
+	var contents []string
+	for c := tree.Root.FirstChild; nil != c; c = c.Next {
+		if content := treenode.NodeStaticContent(c); "" != content {
+			contents = append(contents, content)
+		}
+	}
+	return strings.Join(contents, " ")
+}
+
+// siteBlockLinks 将指向站点中块的块链改为页面间的锚点链接，站点外的块链保持不变。
+func siteBlockLinks(dom string, pageFiles map[string]string) string {
+	return siteBlockLinkRegexp.ReplaceAllStringFunc(dom, func(link string) string {
+		id := siteBlockLinkRegexp.FindStringSubmatch(link)[1]
+		bt := treenode.GetBlockTree(id)
+		if nil == bt {
+			return link
+		}
+		file, ok := pageFiles[bt.RootID]
+		if !ok {
+			return link
+		}
+		if id == bt.RootID {
+			return `href="` + file + `"`
+		}
+		return `href="` + file + "#" + id + `"`
+	})
+}
+
+func sitePageBody(page *sitePage, dom string, tagFiles map[string]string) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<nav class="site__breadcrumb">`)
+	for _, parent := range page.parents {
+		buf.WriteString(`<a href="` + parent.file + `">` + html.EscapeString(parent.title) + "</a> / ")
+	}
+	buf.WriteString(html.EscapeString(page.doc.title) + "</nav>\n")
+	if !Conf.Export.AddTitle { // 导出设置中开启添加标题时导出的文档树中已经包含标题
+		buf.WriteString(`<h1 class="site__title">` + html.EscapeString(page.doc.title) + "</h1>\n")
+	}
+	if 0 < len(page.tags) {
+		buf.WriteString(`<div class="site__tags">`)
+		for _, label := range page.tags {
+			buf.WriteString(`<a href="` + tagFiles[label] + `">#` + html.EscapeString(label) + "#</a>")
+		}
+		buf.WriteString("</div>\n")
+	}
+	buf.WriteString(`<div class="protyle-wysiwyg protyle-wysiwyg--attr" id="preview">` + dom + "</div>\n")
+	if 0 < len(page.doc.children) {
+		buf.WriteString(`<h2 class="site__subtitle">` + html.EscapeString(Conf.Language(143)) + "</h2>\n")
+		writeSiteDocList(&buf, page.doc.children)
+	}
+	return buf.String()
+}
+
+func writeSiteDocList(buf *bytes.Buffer, docs []*exportDoc) {
+	buf.WriteString("<ul>\n")
+	for _, doc := range docs {
+		buf.WriteString(`<li><a href="` + doc.file + `">` + html.EscapeString(doc.title) + "</a>")
+		if 0 < len(doc.children) {
+			buf.WriteString("\n")
+			writeSiteDocList(buf, doc.children)
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n")
+}
+
+func writeSiteTagList(buf *bytes.Buffer, tags []*Tag, tagFiles map[string]string) {
+	buf.WriteString("<ul>\n")
+	for _, tag := range tags {
+		buf.WriteString(`<li><a href="` + tagFiles[tag.Label] + `">` + html.EscapeString(tag.Name) + "</a>")
+		if 0 < len(tag.Children) {
+			buf.WriteString("\n")
+			writeSiteTagList(buf, tag.Children, tagFiles)
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n")
+}
+
+func siteTheme() string {
+	if 1 == Conf.Appearance.Mode {
+		return Conf.Appearance.ThemeDark
+	}
+	return Conf.Appearance.ThemeLight
+}
+
+// sitePageWrite 写入站点页面，preview 为 true 时加载渲染代码块、公式和图表等所需的脚本。页面结构和前端导出 HTML 一致。
+func sitePageWrite(folder, file, title, siteName, body string, preview bool) (err error) {
+	ver := "?" + util.Ver
+	buf := bytes.Buffer{}
+	buf.WriteString(`<!DOCTYPE html><html>
+<head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+` + exportHTMLStyles(Conf.Appearance) + `    <link rel="stylesheet" type="text/css" href="site.css` + ver + `"/>
+    <title>` + html.EscapeString(title) + " - " + html.EscapeString(siteName) + `</title>
+</head>
+<body>
+<header class="site__header">
+    <a class="site__name" href="index.html">` + html.EscapeString(siteName) + `</a>
+    <input class="site__search" id="siteSearch" type="search" placeholder="` + html.EscapeString(Conf.Language(142)) + `">
+    <a href="tags.html">` + html.EscapeString(Conf.Language(141)) + `</a>
+</header>
+<div class="site__results" id="siteSearchResults"></div>
+<main class="site__main">
+` + body + `</main>
+<script src="search-index.js` + ver + `"></script>
+<script src="site.js` + ver + `"></script>
+`)
+	if preview {
+		buf.WriteString(exportHTMLScripts(Conf.Language(144), Conf.Appearance, Conf.Editor))
+	}
+	buf.WriteString("</body></html>")
+
+	p := filepath.Join(folder, file)
+	if err = gulu.File.WriteFileSafer(p, buf.Bytes(), 0644); nil != err {
+		util.LogErrorf("write site page [%s] failed: %s", p, err)
+	}
+	return
+}
+
+const siteCSS = `body {background-color: var(--b3-theme-background);color: var(--b3-theme-on-background);margin: 0}
+.site__header {display: flex;align-items: center;gap: 16px;max-width: 800px;margin: 0 auto;padding: 16px}
+.site__name {font-weight: bold;flex: 1}
+.site__search {padding: 4px 8px;border: 1px solid var(--b3-border-color);border-radius: 4px;background-color: transparent;color: inherit}
+.site__results {max-width: 800px;margin: 0 auto;padding: 0 16px}
+.site__results:empty {display: none}
+.site__result {display: block;padding: 8px 0;border-bottom: 1px solid var(--b3-border-color)}
+.site__result small {display: block;color: var(--b3-theme-on-surface)}
+.site__main {max-width: 800px;margin: 0 auto;padding: 0 16px 32px}
+.site__breadcrumb {font-size: 14px;color: var(--b3-theme-on-surface)}
+.site__tags a {margin-right: 8px}
+.site__subtitle {margin-top: 32px}
+`
+
+const siteJS = `(function () {
+    var input = document.getElementById("siteSearch");
+    var results = document.getElementById("siteSearchResults");
+    var escapeHTML = function (text) {
+        return text.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+    };
+    input.addEventListener("input", function () {
+        var keyword = input.value.trim().toLowerCase();
+        if (!keyword) {
+            results.innerHTML = "";
+            return;
+        }
+        var html = "";
+        var count = 0;
+        for (var i = 0; i < window.siteSearchIndex.length && count < 32; i++) {
+            var item = window.siteSearchIndex[i];
+            var index = item.content.toLowerCase().indexOf(keyword);
+            if (-1 === item.title.toLowerCase().indexOf(keyword) && -1 === index) {
+                continue;
+            }
+            var snippet = -1 === index ? item.content.substring(0, 128) : item.content.substring(Math.max(0, index - 32), index + 96);
+            html += '<a class="site__result" href="' + item.url + '">' + escapeHTML(item.title) + "<small>" + escapeHTML(snippet) + "</small></a>";
+            count++;
+        }
+        results.innerHTML = html;
+    });
+})();
+`
@@ -0,0 +1,405 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// siteMarkdownDoc 描述了静态站点 Markdown 导出中的一个文档。
+type siteMarkdownDoc struct {
+	doc  *exportDoc
+	url  string // 页面地址，以 / 开头和结尾，比如 /foo/bar/
+	file string // 导出后的 Markdown 文件路径
+}
+
+var siteMarkdownKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ExportSiteMarkdown 导出适用于 Hugo、Jekyll 等静态站点生成器的 Markdown。id 不为空时导出该文档及其子文档，否则导出笔记本 boxID 中路径 p 下的所有文档。
+//
+// generator、frontMatter 和 assetsPrefix 为空时使用导出设置：
+//   - 文档 IAL 中的标题、创建时间、更新时间、标签、别名（aliases）和 custom-* 属性写入 YAML 或者 TOML 格式的 Front Matter，custom-* 属性和生成的字段重名时保留 custom- 前缀
+//   - 文档路径按照标题转换为 slug 作为页面地址，并写入 Front Matter（Hugo 使用 url，Jekyll 使用 permalink）
+//   - 块引用转换为指向目标页面的相对链接，被引用的块前插入 HTML 锚点（Hugo 需要开启 markup.goldmark.renderer.unsafe）
+//   - 资源文件链接加上 assetsPrefix 前缀，资源文件复制到 static/assets（Hugo）或者 assets（Jekyll）下
+func ExportSiteMarkdown(boxID, p, id, generator, frontMatter, assetsPrefix string) (name, zipPath string, err error) {
+	WaitForWritingFiles()
+
+	if "" == generator {
+		generator = Conf.Export.SiteGenerator
+	}
+	if "" == frontMatter {
+		frontMatter = Conf.Export.FrontMatter
+	}
+	if "" == assetsPrefix {
+		assetsPrefix = Conf.Export.SiteAssetsPrefix
+	}
+	jekyll := "jekyll" == generator
+	toml := "toml" == frontMatter && !jekyll
+
+	docs, name, err := loadExportDocTree(boxID, p, id)
+	if nil != err {
+		return
+	}
+
+	var siteDocs []*siteMarkdownDoc
+	siteMarkdownDocs(docs, "/", jekyll, &siteDocs)
+	docURLs := map[string]string{}
+	for _, siteDoc := range siteDocs {
+		docURLs[siteDoc.doc.id] = siteDoc.url
+	}
+
+	name = util.FilterFileName(name)
+	if "" == name {
+		name = "Untitled"
+	}
+	exportFolder := filepath.Join(util.TempDir, "export", name)
+	os.RemoveAll(exportFolder)
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		util.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+	defer os.RemoveAll(exportFolder)
+
+	// 先转换所有文档，汇总被引用的块，然后再插入锚点和改写链接
+	var trees []*parse.Tree
+	anchors := map[string]bool{}
+	for _, siteDoc := range siteDocs {
+		exportBlockRefsToLinks(siteDoc.doc.tree)
+		tree := exportTree(siteDoc.doc.tree, false)
+		if Conf.Export.AddTitle { // 标题写入 Front Matter，移除导出时添加的标题
+			if first := tree.Root.FirstChild; nil != first && ast.NodeHeading == first.Type && "" == first.ID {
+				first.Unlink()
+			}
+		}
+		for _, dest := range siteMarkdownBlockLinkDests(tree) {
+			anchors[strings.TrimPrefix(dest.TokensStr(), "siyuan://blocks/")] = true
+		}
+		trees = append(trees, tree)
+	}
+
+	assetsDir := "assets"
+	if !jekyll {
+		assetsDir = filepath.Join("static", "assets")
+	}
+	copiedAssets := map[string]bool{}
+	luteEngine := NewLute()
+	luteEngine.SetFootnotes(true)
+	luteEngine.SetKramdownIAL(false)
+	for i, siteDoc := range siteDocs {
+		tree := trees[i]
+		siteMarkdownAnchors(tree, anchors)
+		siteMarkdownLinks(tree, siteDoc.url, docURLs)
+
+		for _, asset := range assetsLinkDestsInTree(tree) {
+			asset = string(html.DecodeDestination([]byte(asset)))
+			if strings.Contains(asset, "?") {
+				asset = asset[:strings.LastIndex(asset, "?")]
+			}
+			if !strings.HasPrefix(asset, "assets/") || copiedAssets[asset] {
+				continue
+			}
+			copiedAssets[asset] = true
+
+			srcPath, assetErr := GetAssetAbsPath(asset)
+			if nil != assetErr {
+				util.LogWarnf("resolve path of asset [%s] failed: %s", asset, assetErr)
+				continue
+			}
+			destPath := filepath.Join(exportFolder, assetsDir, strings.TrimPrefix(asset, "assets/"))
+			if err = gulu.File.Copy(srcPath, destPath); nil != err {
+				util.LogErrorf("copy asset from [%s] to [%s] failed: %s", srcPath, destPath, err)
+				err = nil
+			}
+		}
+		siteMarkdownAssets(tree, assetsPrefix)
+
+		buf := bytes.Buffer{}
+		buf.WriteString(siteMarkdownFrontMatter(siteDoc, tree.Root, jekyll, toml))
+		buf.WriteString(formatExportMd(tree.Root, luteEngine.ParseOptions, luteEngine.RenderOptions))
+		writePath := filepath.Join(exportFolder, siteDoc.file)
+		if err = os.MkdirAll(filepath.Dir(writePath), 0755); nil != err {
+			util.LogErrorf("create export temp folder [%s] failed: %s", filepath.Dir(writePath), err)
+			return
+		}
+		if err = gulu.File.WriteFileSafer(writePath, buf.Bytes(), 0644); nil != err {
+			util.LogErrorf("write export markdown file [%s] failed: %s", writePath, err)
+			return
+		}
+	}
+
+	zipFile := exportFolder + ".zip"
+	zip, err := gulu.Zip.Create(zipFile)
+	if nil != err {
+		util.LogErrorf("create export site markdown zip [%s] failed: %s", zipFile, err)
+		return
+	}
+	if err = zip.AddDirectory(name, exportFolder); nil != err {
+		util.LogErrorf("create export site markdown zip [%s] failed: %s", zipFile, err)
+		return
+	}
+	if err = zip.Close(); nil != err {
+		util.LogErrorf("close export site markdown zip failed: %s", err)
+		return
+	}
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipFile))
+	return
+}
+
+// siteMarkdownDocs 按照文档树分配页面地址和文件路径，同级文档 slug 重复时加上文档 ID。
+//
+// 包含子文档的文档在 Hugo 中作为章节写入 _index.md，在 Jekyll 中写入 index.md。
+func siteMarkdownDocs(docs []*exportDoc, parentURL string, jekyll bool, ret *[]*siteMarkdownDoc) {
+	used := map[string]bool{}
+	for _, doc := range docs {
+		slug := siteMarkdownSlug(doc.title)
+		if "" == slug || used[slug] {
+			slug = strings.TrimPrefix(slug+"-"+doc.id, "-")
+		}
+		used[slug] = true
+
+		docURL := parentURL + slug + "/"
+		file := strings.TrimSuffix(docURL, "/") + ".md"
+		if 0 < len(doc.children) {
+			if jekyll {
+				file = docURL + "index.md"
+			} else {
+				file = docURL + "_index.md"
+			}
+		}
+		if !jekyll {
+			file = "/content" + file
+		}
+		*ret = append(*ret, &siteMarkdownDoc{doc: doc, url: docURL, file: file})
+		siteMarkdownDocs(doc.children, docURL, jekyll, ret)
+	}
+}
+
+// siteMarkdownSlug 将标题转换为 slug：转为小写，保留字母和数字，其他字符替换为 -。
+func siteMarkdownSlug(title string) string {
+	buf := strings.Builder{}
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = true
+			continue
+		}
+		if dash && 0 < buf.Len() {
+			buf.WriteByte('-')
+		}
+		dash = false
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func siteMarkdownBlockLinkDests(tree *parse.Tree) (ret []*ast.Node) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && ast.NodeLinkDest == n.Type && bytes.HasPrefix(n.Tokens, []byte("siyuan://blocks/")) {
+			ret = append(ret, n)
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// siteMarkdownAnchors 在被引用的块前插入 HTML 锚点，段落和标题中插入行级锚点，其他块前插入 HTML 块。
+func siteMarkdownAnchors(tree *parse.Tree, anchors map[string]bool) {
+	var blocks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && ast.NodeDocument != n.Type && anchors[n.ID] {
+			blocks = append(blocks, n)
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range blocks {
+		anchor := []byte(`<a id="` + n.ID + `"></a>`)
+		leaf := treenode.FirstLeafBlock(n)
+		if nil != leaf && (ast.NodeParagraph == leaf.Type || ast.NodeHeading == leaf.Type) {
+			leaf.PrependChild(&ast.Node{Type: ast.NodeInlineHTML, Tokens: anchor})
+			continue
+		}
+		n.InsertBefore(&ast.Node{Type: ast.NodeHTMLBlock, Tokens: anchor})
+	}
+}
+
+// siteMarkdownLinks 将指向导出文档的块链改为页面间的相对链接，指向其他文档的块链改为锚文本。
+func siteMarkdownLinks(tree *parse.Tree, docURL string, docURLs map[string]string) {
+	for _, dest := range siteMarkdownBlockLinkDests(tree) {
+		id := strings.TrimPrefix(dest.TokensStr(), "siyuan://blocks/")
+		targetURL := ""
+		if bt := treenode.GetBlockTree(id); nil != bt {
+			targetURL = docURLs[bt.RootID]
+			if "" != targetURL {
+				targetURL = siteMarkdownRelURL(docURL, targetURL)
+				if id != bt.RootID {
+					targetURL += "#" + id
+				}
+			}
+		}
+		if "" != targetURL {
+			dest.Tokens = []byte(targetURL)
+			continue
+		}
+
+		link := dest.Parent
+		if linkText := link.ChildByType(ast.NodeLinkText); nil != linkText {
+			link.InsertBefore(&ast.Node{Type: ast.NodeText, Tokens: linkText.Tokens})
+		}
+		link.Unlink()
+	}
+}
+
+// siteMarkdownRelURL 返回从页面 from 到页面 to 的相对地址，比如从 /a/b/ 到 /a/c/ 为 ../c/。
+func siteMarkdownRelURL(from, to string) string {
+	if from == to {
+		return "./"
+	}
+	fromParts := strings.Split(strings.Trim(from, "/"), "/")
+	toParts := strings.Split(strings.Trim(to, "/"), "/")
+	i := 0
+	for ; i < len(fromParts) && i < len(toParts) && fromParts[i] == toParts[i]; i++ {
+	}
+	ret := strings.Repeat("../", len(fromParts)-i)
+	if i < len(toParts) {
+		ret += strings.Join(toParts[i:], "/") + "/"
+	}
+	return ret
+}
+
+// siteMarkdownAssets 为资源文件链接加上前缀 prefix。
+func siteMarkdownAssets(tree *parse.Tree, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeLinkDest != n.Type {
+			return ast.WalkContinue
+		}
+		if dest := n.TokensStr(); strings.HasPrefix(dest, "assets/") {
+			n.Tokens = []byte(prefix + dest)
+		}
+		return ast.WalkContinue
+	})
+}
+
+// siteMarkdownFrontMatter 根据文档 IAL 生成 Front Matter，toml 为 true 时使用 TOML 格式，否则使用 YAML 格式。
+func siteMarkdownFrontMatter(siteDoc *siteMarkdownDoc, root *ast.Node, jekyll, toml bool) string {
+	type field struct {
+		key   string
+		value string
+	}
+	var fields []*field
+	added := map[string]bool{}
+	add := func(key, value string) {
+		if !added[key] {
+			added[key] = true
+			fields = append(fields, &field{key: key, value: value})
+		}
+	}
+
+	add("title", siteMarkdownString(siteDoc.doc.title))
+	if created, parseErr := time.ParseInLocation("20060102150405", util.TimeFromID(siteDoc.doc.id), time.Local); nil == parseErr {
+		add("date", created.Format(time.RFC3339))
+	}
+	lastmod := "lastmod"
+	if jekyll {
+		lastmod = "last_modified_at"
+	}
+	if updated, parseErr := time.ParseInLocation("20060102150405", root.IALAttr("updated"), time.Local); nil == parseErr {
+		add(lastmod, updated.Format(time.RFC3339))
+	}
+	if tags := siteMarkdownList(root.IALAttr("tags")); "" != tags {
+		add("tags", tags)
+	}
+	if alias := siteMarkdownList(root.IALAttr("alias")); "" != alias {
+		add("aliases", alias)
+	}
+	if jekyll {
+		add("permalink", siteMarkdownString(siteDoc.url))
+	} else {
+		add("url", siteMarkdownString(siteDoc.url))
+	}
+
+	var customKeys []string
+	for _, kv := range root.KramdownIAL {
+		if key := strings.TrimPrefix(kv[0], "custom-"); key != kv[0] && siteMarkdownKeyRegexp.MatchString(key) {
+			customKeys = append(customKeys, kv[0])
+		}
+	}
+	sort.Strings(customKeys)
+	for _, key := range customKeys {
+		name := strings.TrimPrefix(key, "custom-")
+		if added[name] {
+			// 和生成的字段重名时保留 custom- 前缀，避免被丢弃
+			util.LogWarnf("custom attr [%s] of doc [%s] conflicts with the generated front matter key [%s], export it as [%s]", key, siteDoc.doc.id, name, key)
+			name = key
+		}
+		add(name, siteMarkdownString(html.UnescapeAttrVal(root.IALAttr(key))))
+	}
+
+	buf := bytes.Buffer{}
+	marker, sep := "---\n", ": "
+	if toml {
+		marker, sep = "+++\n", " = "
+	}
+	buf.WriteString(marker)
+	for _, f := range fields {
+		buf.WriteString(f.key + sep + f.value + "\n")
+	}
+	buf.WriteString(marker + "\n")
+	return buf.String()
+}
+
+// siteMarkdownString 返回双引号包裹的字符串，JSON 字符串同时也是合法的 YAML 和 TOML 字符串。
+func siteMarkdownString(s string) string {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); nil != err {
+		return `""`
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// siteMarkdownList 将逗号分隔的属性值转换为字符串数组，YAML 和 TOML 都使用 ["a", "b"] 的形式。
+func siteMarkdownList(val string) string {
+	var items []string
+	for _, item := range strings.Split(html.UnescapeAttrVal(val), ",") {
+		if item = strings.TrimSpace(item); "" != item {
+			items = append(items, siteMarkdownString(item))
+		}
+	}
+	if 1 > len(items) {
+		return ""
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
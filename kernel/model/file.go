@@ -171,8 +171,10 @@ func readDocIAL(data []byte) (ret map[string]string) {
 	return
 }
 
-func SearchDocsByKeyword(keyword string) (ret []map[string]string) {
+// SearchDocsByKeyword 按文档路径搜索文档。fuzzy 为 true 时同时匹配拼写相近的标题，suggestions 返回纠正拼写后的关键字。
+func SearchDocsByKeyword(keyword string, fuzzy bool) (ret []map[string]string, suggestions []string) {
 	ret = []map[string]string{}
+	suggestions = []string{}
 
 	openedBoxes := Conf.GetOpenedBoxes()
 	boxes := map[string]*Box{}
@@ -195,6 +197,11 @@ func SearchDocsByKeyword(keyword string) (ret []map[string]string) {
 				condition += " " + namCondition
 			}
 		}
+		if fuzzy {
+			_, vocabulary := vocabularies(true)
+			condition = "(" + condition + " OR (" + fuzzyHPathCondition(keyword, vocabulary) + "))"
+			suggestions = didYouMean(keyword, vocabulary)
+		}
 		rootBlocks = sql.QueryRootBlockByCondition(condition)
 	} else {
 		for _, box := range boxes {
@@ -1472,7 +1479,7 @@ func ChangeFileTreeSort(boxID string, paths []string) {
 	absParentPath := filepath.Join(util.DataDir, boxID, parentPath)
 	files, err := os.ReadDir(absParentPath)
 	if nil != err {
-		util.LogErrorf("read dir [%s] failed: %s", err)
+		util.LogErrorf("read dir [%s] failed: %s", absParentPath, err)
 	}
 
 	sortFolderIDs := map[string]int{}
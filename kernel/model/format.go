@@ -18,14 +18,10 @@ package model
 
 import (
 	"bytes"
-	"os"
-	"path/filepath"
 
-	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
-	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -94,22 +90,5 @@ func generateFormatHistory(tree *parse.Tree) {
 		util.LogErrorf("get history dir failed: %s", err)
 		return
 	}
-
-	historyPath := filepath.Join(historyDir, tree.Box, tree.Path)
-	if err = os.MkdirAll(filepath.Dir(historyPath), 0755); nil != err {
-		util.LogErrorf("generate history failed: %s", err)
-		return
-	}
-
-	var data []byte
-	if data, err = filesys.NoLockFileRead(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
-		util.LogErrorf("generate history failed: %s", err)
-		return
-	}
-
-	if err = gulu.File.WriteFileSafer(historyPath, data, 0644); err != nil {
-		util.LogErrorf("generate history failed: %s", err)
-		return
-	}
-
+	generateTreeHistory(historyDir, tree)
 }
@@ -0,0 +1,178 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	vocabularyLock     = sync.Mutex{}
+	contentVocabulary  *search.Vocabulary // 块内容词表
+	titleVocabulary    *search.Vocabulary // 文档标题词表
+	vocabularyTime     time.Time
+	vocabularyBuilding bool
+	vocabularyBuilt    = sync.NewCond(&vocabularyLock) // 词表构建完成时通知等待的调用
+)
+
+const (
+	vocabularyTTL        = 10 * time.Minute // 词表过期时间，过期后在后台重建
+	maxFuzzyVariants     = 8                // 模糊搜索时每个词最多扩展的相近词数
+	maxDidYouMeanQueries = 3                // 最多返回的纠正查询数
+)
+
+// vocabularies 返回块内容词表和文档标题词表。
+//
+// 词表只在后台构建，同时只有一个构建。词表尚未构建时，wait 为 true 则等待构建完成，否则返回 nil；词表过期后在后台重建，重建完成前继续使用旧词表。
+func vocabularies(wait bool) (content, title *search.Vocabulary) {
+	vocabularyLock.Lock()
+	defer vocabularyLock.Unlock()
+
+	if (nil == contentVocabulary || vocabularyTTL < time.Since(vocabularyTime)) && !vocabularyBuilding {
+		vocabularyBuilding = true
+		go buildVocabularies()
+	}
+	for wait && nil == contentVocabulary && vocabularyBuilding {
+		vocabularyBuilt.Wait()
+	}
+	return contentVocabulary, titleVocabulary
+}
+
+func buildVocabularies() {
+	start := time.Now()
+	content, title := search.NewVocabulary(), search.NewVocabulary()
+	sql.QueryBlockContents(func(typ, text string) {
+		content.Add(text)
+		if "d" == typ {
+			title.Add(text)
+		}
+	})
+
+	vocabularyLock.Lock()
+	contentVocabulary, titleVocabulary = content, title
+	vocabularyTime = time.Now()
+	vocabularyBuilding = false
+	vocabularyBuilt.Broadcast()
+	vocabularyLock.Unlock()
+	util.LogInfof("built fuzzy search vocabulary [words=%d, titleWords=%d] in [%.2fs]", content.Len(), title.Len(), time.Since(start).Seconds())
+}
+
+// didYouMean 将 query 中不在词表中的词替换为拼写相近的词，返回纠正后的查询。
+func didYouMean(query string, vocabulary *search.Vocabulary) (ret []string) {
+	ret = []string{}
+	if nil == vocabulary {
+		return
+	}
+
+	terms := strings.Fields(query)
+	alternatives := make([][]string, len(terms))
+	corrected := false
+	for i, term := range terms {
+		if !isFuzzyTerm(term) || vocabulary.Contains(term) {
+			continue
+		}
+		alternatives[i] = vocabulary.Suggest(term, search.MaxEditDistance(term), maxDidYouMeanQueries)
+		if 0 < len(alternatives[i]) {
+			corrected = true
+		}
+	}
+	if !corrected {
+		return
+	}
+
+	// 第 n 个纠正查询使用每个词的第 n 个候选，候选不足时使用最佳候选
+	for n := 0; n < maxDidYouMeanQueries; n++ {
+		var parts []string
+		changed := false
+		for i, term := range terms {
+			switch alts := alternatives[i]; {
+			case n < len(alts):
+				parts = append(parts, alts[n])
+				changed = true
+			case 0 < len(alts):
+				parts = append(parts, alts[0])
+			default:
+				parts = append(parts, term)
+			}
+		}
+		if !changed {
+			break
+		}
+		ret = append(ret, strings.Join(parts, " "))
+	}
+	return
+}
+
+// fuzzyTerms 返回 query 中每个词及其在编辑距离内的相近词。
+func fuzzyTerms(query string, vocabulary *search.Vocabulary) (ret [][]string) {
+	for _, term := range strings.Fields(query) {
+		variants := []string{term}
+		if nil != vocabulary && isFuzzyTerm(term) {
+			for _, word := range vocabulary.Suggest(term, search.MaxEditDistance(term), maxFuzzyVariants) {
+				if !strings.EqualFold(word, term) {
+					variants = append(variants, word)
+				}
+			}
+		}
+		ret = append(ret, variants)
+	}
+	return
+}
+
+// fuzzyQuery 将 query 转换为全文搜索查询语法，每个词匹配自身或者任一相近词。
+func fuzzyQuery(query string, vocabulary *search.Vocabulary) string {
+	buf := bytes.Buffer{}
+	for _, variants := range fuzzyTerms(query, vocabulary) {
+		buf.WriteString("(")
+		for i, variant := range variants {
+			if 0 < i {
+				buf.WriteString(" OR ")
+			}
+			variant = strings.ReplaceAll(variant, "\"", "\"\"")
+			variant = strings.ReplaceAll(variant, "'", "''")
+			buf.WriteString("\"" + variant + "\"")
+		}
+		buf.WriteString(") ")
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// fuzzyHPathCondition 生成匹配文档路径的 SQL 条件，每个词匹配自身或者任一相近词。
+func fuzzyHPathCondition(query string, vocabulary *search.Vocabulary) string {
+	var terms []string
+	for _, variants := range fuzzyTerms(query, vocabulary) {
+		var likes []string
+		for _, variant := range variants {
+			likes = append(likes, "hpath LIKE '%"+strings.ReplaceAll(variant, "'", "''")+"%'")
+		}
+		terms = append(terms, "("+strings.Join(likes, " OR ")+")")
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// isFuzzyTerm 判断 term 是否是可以进行模糊匹配的单个词。
+func isFuzzyTerm(term string) bool {
+	words := search.Words(term)
+	return 1 == len(words) && words[0] == strings.ToLower(term)
+}
@@ -0,0 +1,80 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/search"
+)
+
+func testVocabulary() *search.Vocabulary {
+	vocabulary := search.NewVocabulary()
+	vocabulary.Add("search engine searching kernel keyword keyword")
+	vocabulary.Add("cat cat car can 思源笔记")
+	return vocabulary
+}
+
+func TestDidYouMean(t *testing.T) {
+	vocabulary := testVocabulary()
+	tests := []struct {
+		name       string
+		query      string
+		vocabulary *search.Vocabulary
+		want       []string
+	}{
+		{"no vocabulary", "serch", nil, []string{}},
+		{"misspelled", "serch", vocabulary, []string{"search"}},
+		{"keep known terms", "serch engine", vocabulary, []string{"search engine"}},
+		{"correct every term", "kernal keywrd", vocabulary, []string{"kernel keyword"}},
+		{"all known", "search engine", vocabulary, []string{}},
+		{"no candidate", "zzzzz", vocabulary, []string{}},
+		{"too short", "ab", vocabulary, []string{}},
+		{"cjk", "思源", vocabulary, []string{}},
+		{"candidates by frequency", "cax", vocabulary, []string{"cat", "can", "car"}},
+		{"best candidate for other terms", "cax serch", vocabulary, []string{"cat search", "can search", "car search"}},
+	}
+	for _, test := range tests {
+		if got := didYouMean(test.query, test.vocabulary); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFuzzyQuery(t *testing.T) {
+	vocabulary := testVocabulary()
+	tests := []struct {
+		name       string
+		query      string
+		vocabulary *search.Vocabulary
+		want       string
+	}{
+		{"empty", "", vocabulary, ""},
+		{"no vocabulary", "serch", nil, `("serch")`},
+		{"variants", "serch", vocabulary, `("serch" OR "search")`},
+		{"exact term", "search engine", vocabulary, `("search") ("engine")`},
+		{"escape double quote", `say"hi`, vocabulary, `("say""hi")`},
+		{"escape single quote", "it's", vocabulary, `("it''s")`},
+		{"cjk", "思源", vocabulary, `("思源")`},
+	}
+	for _, test := range tests {
+		if got := fuzzyQuery(test.query, test.vocabulary); test.want != got {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
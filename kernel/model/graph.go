@@ -138,7 +138,8 @@ func BuildTreeGraph(id, query string) (boxID string, nodes []*GraphNode, links [
 	return
 }
 
-func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
+// BuildGraph 构建全局关系图，savedSearch 不为空时仅包含该保存的搜索命中的文档。
+func BuildGraph(query, savedSearch string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
 	nodes = []*GraphNode{}
 	links = []*GraphLink{}
 
@@ -147,6 +148,9 @@ func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphL
 	stmt += graphTypeFilter(false)
 	stmt += graphDailyNoteFilter(false)
 	stmt = strings.ReplaceAll(stmt, "content", "ref.content")
+	if "" != savedSearch {
+		stmt += savedSearchGraphFilter(savedSearch)
+	}
 	forwardlinks, backlinks := buildFullLinks(stmt)
 
 	var blocks []*Block
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
 	"github.com/88250/protyle"
 	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
@@ -186,7 +187,7 @@ func RollbackDocHistory(boxID, historyPath string) (err error) {
 	}
 	syncLock.Unlock()
 
-	RefreshFileTree()
+	RefreshFileTree(nil)
 	IncWorkspaceDataVer()
 	return nil
 }
@@ -240,7 +241,7 @@ func RollbackNotebookHistory(historyPath string) (err error) {
 		return
 	}
 
-	RefreshFileTree()
+	RefreshFileTree(nil)
 	IncWorkspaceDataVer()
 	return nil
 }
@@ -505,6 +506,26 @@ func (box *Box) generateDocHistory0() {
 	return
 }
 
+// generateTreeHistory 将 tree 对应的文件复制到历史目录 historyDir 下。
+func generateTreeHistory(historyDir string, tree *parse.Tree) {
+	historyPath := filepath.Join(historyDir, tree.Box, tree.Path)
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); nil != err {
+		util.LogErrorf("generate history failed: %s", err)
+		return
+	}
+
+	data, err := filesys.NoLockFileRead(filepath.Join(util.DataDir, tree.Box, tree.Path))
+	if err != nil {
+		util.LogErrorf("generate history failed: %s", err)
+		return
+	}
+
+	if err = gulu.File.WriteFileSafer(historyPath, data, 0644); err != nil {
+		util.LogErrorf("generate history failed: %s", err)
+		return
+	}
+}
+
 func clearOutdatedHistoryDir(historyDir string) {
 	if !gulu.File.IsExist(historyDir) {
 		return
@@ -530,7 +551,7 @@ func clearOutdatedHistoryDir(historyDir string) {
 	}
 	for _, dir := range removes {
 		if err = os.RemoveAll(dir); nil != err {
-			util.LogErrorf("remove history dir [%s] failed: %s", err)
+			util.LogErrorf("remove history dir [%s] failed: %s", dir, err)
 			continue
 		}
 		//util.LogInfof("auto removed history dir [%s]", dir)
@@ -18,12 +18,14 @@ package model
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"math/rand"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -279,7 +281,7 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 	}
 
 	IncWorkspaceDataVer()
-	refreshFileTree()
+	refreshFileTree(nil)
 	return
 }
 
@@ -339,7 +341,7 @@ func ImportData(zipPath string) (err error) {
 	}
 
 	IncWorkspaceDataVer()
-	refreshFileTree()
+	refreshFileTree(nil)
 	return
 }
 
@@ -510,7 +512,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		}
 
 		IncWorkspaceDataVer()
-		refreshFileTree()
+		refreshFileTree(nil)
 	} else { // 导入单个文件
 		fileName := filepath.Base(localPath)
 		if !strings.HasSuffix(fileName, ".md") && !strings.HasSuffix(fileName, ".markdown") {
@@ -644,3 +646,272 @@ func randStr(length int) string {
 	}
 	return string(b)
 }
+
+// importDoc 描述了从第三方格式导入的一篇文档，children 为子文档。
+type importDoc struct {
+	id       string
+	title    string
+	tree     *parse.Tree
+	children []*importDoc
+}
+
+var importTitleReplacer = strings.NewReplacer("/", " ", "\r\n", " ", "\r", " ", "\n", " ", "\t", " ")
+
+// newImportDoc 使用 Markdown md 创建导入的文档。
+func newImportDoc(title string, md []byte) (ret *importDoc) {
+	ret = &importDoc{id: ast.NewNodeID(), title: importTitle(title)}
+	ret.parse(md)
+	return
+}
+
+// importTitle 移除标题中的 / 和换行等字符，标题为空时使用 Untitled。
+func importTitle(title string) string {
+	if title = strings.TrimSpace(importTitleReplacer.Replace(title)); "" != title {
+		return title
+	}
+	return "Untitled"
+}
+
+// parse 使用 Markdown md 重新生成文档的内容，文档 ID 和标题保持不变。
+func (doc *importDoc) parse(md []byte) {
+	tree := parseKTree(md)
+	tree.ID = doc.id
+	tree.Root.ID = doc.id
+	tree.Root.SetIALAttr("id", doc.id)
+	setImportAttr(tree.Root, "title", doc.title)
+	tree.Root.SetIALAttr("updated", util.TimeFromID(doc.id))
+	doc.tree = tree
+}
+
+// setImportAttr 设置导入的块属性，属性值和 SetBlockAttrs 一样需要转义。
+func setImportAttr(node *ast.Node, name, value string) {
+	node.SetIALAttr(name, html.EscapeAttrVal(value))
+}
+
+// importBaseHPath 返回导入到笔记本 boxID 的文档路径 toPath 下时的可读路径，/ 表示笔记本根目录。
+func importBaseHPath(boxID, toPath string) (ret string, err error) {
+	if "/" == toPath || "" == toPath {
+		return "/", nil
+	}
+	block := treenode.GetBlockTreeRootByPath(boxID, toPath)
+	if nil == block {
+		return "", errors.New(fmt.Sprintf(Conf.Language(15), toPath))
+	}
+	return block.HPath, nil
+}
+
+// writeImportDocs 将导入的文档按照层级写入笔记本 boxID 的 toPath 下，写入完成后重建索引。
+func writeImportDocs(boxID, toPath string, docs []*importDoc) (err error) {
+	box := Conf.Box(boxID)
+	if nil == box {
+		return errors.New(Conf.Language(0))
+	}
+
+	WaitForWritingFiles()
+	syncLock.Lock()
+	defer syncLock.Unlock()
+
+	baseHPath, baseTargetPath := "/", "/"
+	if "/" != toPath && "" != toPath {
+		block := treenode.GetBlockTreeRootByPath(boxID, toPath)
+		if nil == block {
+			return errors.New(fmt.Sprintf(Conf.Language(15), toPath))
+		}
+		baseHPath = block.HPath
+		baseTargetPath = strings.TrimSuffix(block.Path, ".sy")
+	}
+
+	count := 0
+	var write func(docs []*importDoc, targetDir, hPath string) error
+	write = func(docs []*importDoc, targetDir, hPath string) error {
+		for _, doc := range docs {
+			doc.tree.Box = boxID
+			doc.tree.Path = path.Join(targetDir, doc.id+".sy")
+			doc.tree.HPath = path.Join(hPath, doc.title)
+			if writeErr := filesys.WriteTree(doc.tree); nil != writeErr {
+				return writeErr
+			}
+			count++
+			if 0 == count%4 {
+				util.PushEndlessProgress(fmt.Sprintf(Conf.Language(66), util.ShortPathForBootingDisplay(doc.tree.Path)))
+			}
+			if writeErr := write(doc.children, path.Join(targetDir, doc.id), doc.tree.HPath); nil != writeErr {
+				return writeErr
+			}
+		}
+		return nil
+	}
+	if err = write(docs, baseTargetPath, baseHPath); nil != err {
+		return
+	}
+
+	IncWorkspaceDataVer()
+	refreshFileTree(nil)
+	return
+}
+
+// importAssets 负责将导入时引用的本地文件复制到工作空间 data/assets 下，同一个文件只复制一次。
+//
+// 只复制位于导入根目录（使用 allow 添加）下的普通文件，避免导入文件中的链接通过 ../ 或者符号链接复制其他文件。
+type importAssets struct {
+	copied map[string]string
+	roots  []string
+}
+
+func newImportAssets() *importAssets {
+	return &importAssets{copied: map[string]string{}}
+}
+
+// allow 添加导入根目录 root，只有 root 下的文件才会被复制。
+func (a *importAssets) allow(root string) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if nil != err {
+		util.LogErrorf("eval import root [%s] failed: %s", root, err)
+		return
+	}
+	a.roots = append(a.roots, filepath.Clean(realRoot))
+}
+
+// allowed 判断本地文件 absPath 是否为导入根目录下的普通文件。
+func (a *importAssets) allowed(absPath string) bool {
+	info, err := os.Lstat(absPath)
+	if nil != err || !info.Mode().IsRegular() {
+		return false
+	}
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if nil != err {
+		return false
+	}
+	for _, root := range a.roots {
+		if strings.HasPrefix(realPath, root+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// copy 复制本地文件 absPath，返回以 assets/ 开头的资源文件链接。
+func (a *importAssets) copy(absPath string) (ret string, err error) {
+	absPath = filepath.Clean(absPath)
+	if ret = a.copied[absPath]; "" != ret {
+		return
+	}
+	if !a.allowed(absPath) {
+		err = errors.New(fmt.Sprintf("asset [%s] is not a regular file under the import root", absPath))
+		util.LogWarnf("skipped import asset: %s", err)
+		return
+	}
+
+	name := filepath.Base(absPath)
+	ext := filepath.Ext(name)
+	name = util.FilterFileName(strings.TrimSuffix(name, ext)) + "-" + ast.NewNodeID() + ext
+	if err = gulu.File.Copy(absPath, filepath.Join(util.DataDir, "assets", name)); nil != err {
+		util.LogErrorf("copy asset from [%s] failed: %s", absPath, err)
+		return
+	}
+	ret = "assets/" + name
+	a.copied[absPath] = ret
+	return
+}
+
+// write 将数据 data 写入 assets 下的文件 name，返回以 assets/ 开头的资源文件链接，相同内容的数据只写入一次。
+func (a *importAssets) write(name string, data []byte) (ret string, err error) {
+	hash := fmt.Sprintf("md5:%x", md5.Sum(data))
+	if ret = a.copied[hash]; "" != ret {
+		return
+	}
+
+	ext := filepath.Ext(name)
+	name = util.FilterFileName(strings.TrimSuffix(name, ext)) + "-" + ast.NewNodeID() + ext
+	if err = gulu.File.WriteFileSafer(filepath.Join(util.DataDir, "assets", name), data, 0644); nil != err {
+		util.LogErrorf("write asset [%s] failed: %s", name, err)
+		return
+	}
+	ret = "assets/" + name
+	a.copied[hash] = ret
+	return
+}
+
+// newImportBlockRef 创建指向块 id 的块引用，text 为锚文本。
+func newImportBlockRef(id, text string) (ret *ast.Node) {
+	ret = &ast.Node{Type: ast.NodeBlockRef}
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenParen})
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenParen})
+	ret.AppendChild(&ast.Node{Type: ast.NodeBlockRefID, Tokens: []byte(id)})
+	ret.AppendChild(&ast.Node{Type: ast.NodeBlockRefSpace})
+	ret.AppendChild(&ast.Node{Type: ast.NodeBlockRefText, Tokens: []byte(text)})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseParen})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseParen})
+	return
+}
+
+// rewriteImportLinks 将指向导入文档的链接转换为块引用，指向本地文件的链接复制文件到 assets 下并改写链接。
+//
+// dir 为文档所在的本地文件夹，resolve 根据链接目标 dest 和解析出的本地路径 absPath（dest 不是相对路径时为空）返回链接指向的文档。
+func rewriteImportLinks(root *ast.Node, dir string, assets *importAssets, resolve func(dest, absPath string) *importDoc) {
+	var unlinks []*ast.Node
+	ast.Walk(root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeLinkDest != n.Type {
+			return ast.WalkContinue
+		}
+
+		dest := n.TokensStr()
+		if "" == dest {
+			return ast.WalkContinue
+		}
+
+		absPath := ""
+		if util.IsRelativePath(dest) {
+			filePath := dest
+			if i := strings.IndexAny(filePath, "?#"); 0 <= i {
+				filePath = filePath[:i]
+			}
+			decoded, unescapeErr := url.PathUnescape(filePath)
+			if nil != unescapeErr {
+				decoded = filePath
+			}
+			absPath = filepath.Join(dir, filepath.FromSlash(decoded))
+		}
+
+		target := resolve(dest, absPath)
+		if nil == target {
+			if "" != absPath && gulu.File.IsExist(absPath) && !gulu.File.IsDir(absPath) {
+				if assetPath, copyErr := assets.copy(absPath); nil == copyErr {
+					n.Tokens = []byte(assetPath)
+				}
+			}
+			return ast.WalkContinue
+		}
+
+		link := n.Parent
+		if ast.NodeLink != link.Type {
+			return ast.WalkContinue
+		}
+		text := target.title
+		if linkText := link.ChildByType(ast.NodeLinkText); nil != linkText && "" != strings.TrimSpace(linkText.TokensStr()) {
+			text = linkText.TokensStr()
+		}
+		link.InsertBefore(newImportBlockRef(target.id, text))
+		unlinks = append(unlinks, link)
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+}
+
+// importAttrName 将第三方属性名转换为自定义属性名（不含 custom- 前缀），仅保留字母、数字和 -，转换后为空时使用 prop-index。
+func importAttrName(name string, index int) string {
+	buf := strings.Builder{}
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if ('a' <= r && 'z' >= r) || ('0' <= r && '9' >= r) || '-' == r {
+			buf.WriteRune(r)
+		} else if 0 < buf.Len() && !strings.HasSuffix(buf.String(), "-") {
+			buf.WriteByte('-')
+		}
+	}
+	if ret := strings.Trim(buf.String(), "-"); "" != ret {
+		return ret
+	}
+	return "prop-" + strconv.Itoa(index)
+}
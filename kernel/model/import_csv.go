@@ -0,0 +1,204 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	textUnicode "golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// csvDelimiters 是嗅探 CSV 分隔符时的候选分隔符。
+var csvDelimiters = []rune{',', '\t', ';', '|'}
+
+// ImportCSV 导入 CSV 或者 TSV 文件到笔记本 boxID 的 toPath 下，name 为文件名，data 为文件内容，第一行为列名。
+//
+// rowDocs 为 false 时创建一篇以文件名命名的文档，所有数据作为文档中的一个表格块；
+// 否则每行数据创建一篇文档，列名为 titleColumn 的列作为文档标题（为空时使用第一列），其他列作为文档的自定义属性。
+func ImportCSV(boxID, toPath, name string, data []byte, rowDocs bool, titleColumn string) (ids []string, err error) {
+	box := Conf.Box(boxID)
+	if nil == box {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	records, err := parseCSV(name, data)
+	if nil != err {
+		util.LogErrorf("parse csv [%s] failed: %s", name, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(150), name))
+		return
+	}
+	if 1 > len(records) {
+		err = errors.New(fmt.Sprintf(Conf.Language(150), name))
+		return
+	}
+
+	baseHPath, err := importBaseHPath(boxID, toPath)
+	if nil != err {
+		return
+	}
+
+	header := records[0]
+	if !rowDocs {
+		buf := bytes.Buffer{}
+		for i, record := range records {
+			buf.WriteString("|")
+			for j := range header {
+				cell := ""
+				if j < len(record) {
+					cell = strings.TrimSpace(record[j])
+				}
+				buf.WriteString(" " + queryResultTableCell(cell) + " |")
+			}
+			buf.WriteString("\n")
+			if 0 == i {
+				buf.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+			}
+		}
+		title := strings.TrimSuffix(name, filepath.Ext(name))
+		id, createErr := CreateWithMarkdown(boxID, path.Join(baseHPath, importTitle(title)), buf.String())
+		if nil != createErr {
+			err = createErr
+			return
+		}
+		ids = append(ids, id)
+		return
+	}
+
+	titleIndex := 0
+	if "" != titleColumn {
+		titleIndex = -1
+		for i, col := range header {
+			if strings.TrimSpace(col) == strings.TrimSpace(titleColumn) {
+				titleIndex = i
+				break
+			}
+		}
+		if 0 > titleIndex {
+			err = errors.New(fmt.Sprintf(Conf.Language(151), titleColumn))
+			return
+		}
+	}
+
+	for i, record := range records[1:] {
+		title := ""
+		if titleIndex < len(record) {
+			title = record[titleIndex]
+		}
+		id, createErr := CreateWithMarkdown(boxID, path.Join(baseHPath, importTitle(title)), "")
+		if nil != createErr {
+			err = createErr
+			return
+		}
+		ids = append(ids, id)
+
+		attrs := map[string]string{}
+		for j, value := range record {
+			if j == titleIndex || j >= len(header) {
+				continue
+			}
+			if value = strings.TrimSpace(value); "" != value {
+				attrs["custom-"+importAttrName(header[j], j)] = value
+			}
+		}
+		if 0 < len(attrs) {
+			if err = SetBlockAttrs(id, attrs); nil != err {
+				return
+			}
+		}
+		if 0 == (i+1)%32 {
+			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(66), fmt.Sprintf("%d/%d", i+1, len(records)-1)))
+		}
+	}
+	return
+}
+
+// parseCSV 解析 CSV 或者 TSV 文件，自动识别编码（UTF-8、带 BOM 的 UTF-16 和 GB18030）和分隔符。
+func parseCSV(name string, data []byte) (ret [][]string, err error) {
+	if 2 < len(data) {
+		if 0xFF == data[0] && 0xFE == data[1] {
+			data, _, err = transform.Bytes(textUnicode.UTF16(textUnicode.LittleEndian, textUnicode.ExpectBOM).NewDecoder(), data)
+		} else if 0xFE == data[0] && 0xFF == data[1] {
+			data, _, err = transform.Bytes(textUnicode.UTF16(textUnicode.BigEndian, textUnicode.ExpectBOM).NewDecoder(), data)
+		}
+		if nil != err {
+			return
+		}
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		// 中文版 Excel 默认使用 GBK 编码保存 CSV
+		if data, _, err = transform.Bytes(simplifiedchinese.GB18030.NewDecoder(), data); nil != err {
+			return
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = sniffCSVDelimiter(name, data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.ReadAll()
+}
+
+// sniffCSVDelimiter 返回 CSV 的分隔符。.tsv 文件使用制表符，否则选择在前几行中每行（引号外）都出现且最少出现次数最多的候选分隔符。
+func sniffCSVDelimiter(name string, data []byte) rune {
+	if ".tsv" == strings.ToLower(filepath.Ext(name)) {
+		return '\t'
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); "" != strings.TrimSpace(line) {
+			lines = append(lines, line)
+		}
+		if 10 <= len(lines) {
+			break
+		}
+	}
+
+	ret, retCount := ',', 0
+	for _, delimiter := range csvDelimiters {
+		minCount := -1
+		for _, line := range lines {
+			count, quoted := 0, false
+			for _, r := range line {
+				if '"' == r {
+					quoted = !quoted
+				} else if r == delimiter && !quoted {
+					count++
+				}
+			}
+			if -1 == minCount || count < minCount {
+				minCount = count
+			}
+		}
+		if minCount > retCount {
+			ret, retCount = delimiter, minCount
+		}
+	}
+	return ret
+}
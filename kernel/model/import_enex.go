@@ -0,0 +1,290 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/render"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ENEX https://evernote.com/blog/how-evernotes-xml-export-format-works
+type enexNote struct {
+	Title     string          `xml:"title"`
+	Content   string          `xml:"content"`
+	Created   string          `xml:"created"`
+	Updated   string          `xml:"updated"`
+	Tags      []string        `xml:"tag"`
+	Attrs     enexNoteAttrs   `xml:"note-attributes"`
+	Resources []*enexResource `xml:"resource"`
+}
+
+type enexNoteAttrs struct {
+	Author    string `xml:"author"`
+	SourceURL string `xml:"source-url"`
+}
+
+type enexResource struct {
+	Data  string            `xml:"data"`
+	Mime  string            `xml:"mime"`
+	Attrs enexResourceAttrs `xml:"resource-attributes"`
+}
+
+type enexResourceAttrs struct {
+	FileName string `xml:"file-name"`
+}
+
+// enexMedia 是笔记中 <en-media hash="..."> 引用的资源文件。
+type enexMedia struct {
+	name  string
+	mime  string
+	asset string
+}
+
+var (
+	enexSelfClosingRegexp = regexp.MustCompile(`<(en-media|en-todo)([^>]*?)\s*/>`)
+	enexTimeLayout        = "20060102T150405Z"
+	enexMimeExts          = map[string]string{"image/png": ".png", "image/jpeg": ".jpg", "image/gif": ".gif", "image/svg+xml": ".svg", "image/webp": ".webp", "application/pdf": ".pdf", "audio/mpeg": ".mp3", "audio/wav": ".wav", "video/mp4": ".mp4"}
+)
+
+// ImportENEX 导入 Evernote 导出的 ENEX 文件到笔记本 boxID 的 toPath 下。
+//
+// 每个 ENEX 文件对应 Evernote 中的一个笔记本，导入为一篇以文件名命名的文档，其中的笔记导入为子文档。
+// 笔记的创建时间和更新时间用于生成块 ID 和 updated 属性，标签转换为文档开头的标签，资源文件按照内容去重后写入 assets 下。
+func ImportENEX(enexPaths []string, boxID, toPath string) (err error) {
+	util.PushEndlessProgress(Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	assets := newImportAssets()
+	var docs []*importDoc
+	for _, enexPath := range enexPaths {
+		notebook := newImportDoc(strings.TrimSuffix(filepath.Base(enexPath), filepath.Ext(enexPath)), nil)
+		if notebook.children, err = loadENEX(enexPath, assets); nil != err {
+			return
+		}
+		docs = append(docs, notebook)
+	}
+	return writeImportDocs(boxID, toPath, docs)
+}
+
+// loadENEX 逐个解析 ENEX 文件中的笔记，避免将整个文件读入内存。
+func loadENEX(enexPath string, assets *importAssets) (ret []*importDoc, err error) {
+	f, err := os.Open(enexPath)
+	if nil != err {
+		util.LogErrorf("open enex [%s] failed: %s", enexPath, err)
+		return
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	for {
+		token, tokenErr := decoder.Token()
+		if io.EOF == tokenErr {
+			break
+		}
+		if nil != tokenErr {
+			util.LogErrorf("parse enex [%s] failed: %s", enexPath, tokenErr)
+			err = errors.New(fmt.Sprintf(Conf.Language(148), tokenErr))
+			return
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || "note" != start.Name.Local {
+			continue
+		}
+		note := &enexNote{}
+		if err = decoder.DecodeElement(note, &start); nil != err {
+			util.LogErrorf("parse enex [%s] failed: %s", enexPath, err)
+			err = errors.New(fmt.Sprintf(Conf.Language(148), err))
+			return
+		}
+
+		doc, noteErr := newENEXDoc(note, assets)
+		if nil != noteErr {
+			util.LogErrorf("import note [%s] failed: %s", note.Title, noteErr)
+			continue
+		}
+		ret = append(ret, doc)
+	}
+	return
+}
+
+func newENEXDoc(note *enexNote, assets *importAssets) (ret *importDoc, err error) {
+	medias := map[string]*enexMedia{}
+	for _, resource := range note.Resources {
+		data, decodeErr := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+		if nil != decodeErr {
+			util.LogWarnf("decode resource of note [%s] failed: %s", note.Title, decodeErr)
+			continue
+		}
+
+		hash := fmt.Sprintf("%x", md5.Sum(data))
+		name := resource.Attrs.FileName
+		if "" == name {
+			name = hash + enexMimeExt(resource.Mime)
+		}
+		asset, writeErr := assets.write(name, data)
+		if nil != writeErr {
+			continue
+		}
+		medias[hash] = &enexMedia{name: name, mime: resource.Mime, asset: asset}
+	}
+
+	dom, err := enml2HTML(note.Content, medias)
+	if nil != err {
+		return
+	}
+	luteEngine := NewLute()
+	tree, err := HTML2Tree(dom, luteEngine)
+	if nil != err {
+		return
+	}
+	md := string(render.NewFormatRenderer(tree, luteEngine.RenderOptions).Render())
+
+	var tags []string
+	for _, tag := range note.Tags {
+		if tag = strings.Trim(strings.TrimSpace(tag), "#"); "" != tag {
+			tags = append(tags, "#"+tag+"#")
+		}
+	}
+	if 0 < len(tags) {
+		md = strings.Join(tags, " ") + "\n\n" + md
+	}
+
+	created, updated := enexTime(note.Created), enexTime(note.Updated)
+	if "" == created {
+		created = time.Now().Format("20060102150405")
+	}
+	if updated < created {
+		updated = created
+	}
+	ret = &importDoc{id: newID(created), title: importTitle(note.Title)}
+	ret.parse([]byte(md))
+
+	// 笔记中所有块的 ID 使用笔记的创建时间，updated 使用笔记的更新时间
+	ast.Walk(ret.tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || "" == n.IALAttr("id") {
+			return ast.WalkContinue
+		}
+		if ast.NodeDocument != n.Type {
+			n.ID = newID(created)
+			n.SetIALAttr("id", n.ID)
+		}
+		n.SetIALAttr("updated", updated)
+		return ast.WalkContinue
+	})
+	if "" != note.Attrs.SourceURL {
+		setImportAttr(ret.tree.Root, "custom-source-url", note.Attrs.SourceURL)
+	}
+	if "" != note.Attrs.Author {
+		setImportAttr(ret.tree.Root, "custom-author", note.Attrs.Author)
+	}
+	return
+}
+
+// enml2HTML 将 ENML 转换为 HTML，<en-media> 转换为资源文件的图片或者超链接，<en-todo> 和 Evernote 清单转换为任务列表。
+func enml2HTML(content string, medias map[string]*enexMedia) (ret string, err error) {
+	// HTML 解析器不支持自定义元素的自闭合写法
+	content = enexSelfClosingRegexp.ReplaceAllString(content, "<$1$2></$1>")
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if nil != err {
+		return
+	}
+
+	doc.Find("en-media").Each(func(i int, media *goquery.Selection) {
+		hash, _ := media.Attr("hash")
+		m := medias[strings.ToLower(hash)]
+		if nil == m {
+			media.Remove()
+			return
+		}
+		if strings.HasPrefix(m.mime, "image/") {
+			media.ReplaceWithHtml("<img src=\"" + m.asset + "\" alt=\"" + html.EscapeString(m.name) + "\">")
+		} else {
+			media.ReplaceWithHtml("<a href=\"" + m.asset + "\">" + html.EscapeString(m.name) + "</a>")
+		}
+	})
+	doc.Find("en-todo").Each(func(i int, todo *goquery.Selection) {
+		checked, _ := todo.Attr("checked")
+		checkbox := enexCheckbox("true" == checked)
+		// 段落中的待办转换为任务列表项
+		if parent := todo.Parent(); parent.Is("div, p") && 0 == parent.Closest("li").Length() {
+			todo.Remove()
+			inner, _ := parent.Html()
+			parent.ReplaceWithHtml("<ul><li>" + checkbox + inner + "</li></ul>")
+			return
+		}
+		todo.ReplaceWithHtml(checkbox)
+	})
+	doc.Find("li[style*='--en-checked']").Each(func(i int, li *goquery.Selection) {
+		style, _ := li.Attr("style")
+		li.PrependHtml(enexCheckbox(strings.Contains(strings.ReplaceAll(style, " ", ""), "--en-checked:true")))
+	})
+	doc.Find("en-crypt").Each(func(i int, crypt *goquery.Selection) {
+		util.LogWarnf("skipped encrypted content in note")
+		crypt.Remove()
+	})
+
+	note := doc.Find("en-note")
+	if 0 == note.Length() {
+		note = doc.Find("body")
+	}
+	return note.Html()
+}
+
+func enexCheckbox(checked bool) string {
+	if checked {
+		return "<input type=\"checkbox\" checked>"
+	}
+	return "<input type=\"checkbox\">"
+}
+
+// enexTime 将 ENEX 中的 UTC 时间转换为本地时间 20060102150405，时间为空或者无效时返回空字符串。
+func enexTime(t string) string {
+	parsed, err := time.Parse(enexTimeLayout, strings.TrimSpace(t))
+	if nil != err {
+		return ""
+	}
+	return parsed.Local().Format("20060102150405")
+}
+
+func enexMimeExt(mimeType string) string {
+	if ext := enexMimeExts[mimeType]; "" != ext {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); 0 < len(exts) {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,381 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/render"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	htmlUnlikelyRegexp       = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|footer|header|menu|modal|nav|pager|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe`)
+	htmlLikelyRegexp         = regexp.MustCompile(`(?i)article|body|column|content|main|post|entry|text|blog|story`)
+	htmlPositiveRegexp       = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	htmlNegativeRegexp       = regexp.MustCompile(`(?i)hidden|banner|comment|contact|foot|masthead|media|meta|promo|related|scroll|share|shoutbox|sidebar|sponsor|shopping|tags|widget`)
+	htmlSavedFromRegexp      = regexp.MustCompile(`saved from url=\(\d+\)(\S+?)\s*-->`)
+	htmlSingleFileURLRegexp  = regexp.MustCompile(`(?m)^\s*url:\s*(\S+)\s*$`)
+	htmlSingleFileDateRegexp = regexp.MustCompile(`(?m)^\s*saved date:\s*(.+?)\s*(?:\(.*\))?\s*$`)
+	htmlDataURIRegexp        = regexp.MustCompile(`^data:(image/[\w.+-]+);base64,`)
+)
+
+// htmlImport 描述了一次 HTML 网页的导入过程。
+type htmlImport struct {
+	docsByPath map[string]*importDoc // 本地 .html 文件路径到文档
+	sources    map[*importDoc]string // 文档到本地 .html 文件路径
+	assets     *importAssets
+}
+
+// ImportHTML 导入保存的网页 .html 文件或者包含网页的 .zip 压缩包到笔记本 boxID 的 toPath 下。
+//
+// 网页仅保留正文内容，正文按照可读性评分提取。网页中的图片等资源文件仅从本地文件（包括 data URI）复制，不会下载远程资源。
+// 网页的来源地址和保存时间分别写入 custom-source-url 和 custom-capture-date 属性。
+func ImportHTML(paths []string, boxID, toPath string) (err error) {
+	util.PushEndlessProgress(Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	ctx := &htmlImport{
+		docsByPath: map[string]*importDoc{},
+		sources:    map[*importDoc]string{},
+		assets:     newImportAssets(),
+	}
+
+	var docs []*importDoc
+	for _, p := range paths {
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".zip":
+			unzipPath := filepath.Join(util.TempDir, "import", "html-"+gulu.Rand.String(7))
+			defer os.RemoveAll(unzipPath)
+			if err = gulu.Zip.Unzip(p, unzipPath); nil != err {
+				util.LogErrorf("unzip [%s] failed: %s", p, err)
+				return
+			}
+			ctx.assets.allow(unzipPath)
+			docs = append(docs, ctx.loadDir(unzipPath)...)
+		case ".html", ".htm":
+			ctx.assets.allow(filepath.Dir(p))
+			if doc := ctx.loadPage(p); nil != doc {
+				docs = append(docs, doc)
+			}
+		default:
+			util.LogWarnf("skipped import file [%s]", p)
+		}
+	}
+	if 1 > len(docs) {
+		return errors.New(Conf.Language(149))
+	}
+
+	// 网页之间的链接转换为块引用，本地资源文件复制到 assets 下
+	for doc, p := range ctx.sources {
+		rewriteImportLinks(doc.tree.Root, filepath.Dir(p), ctx.assets, func(dest, absPath string) *importDoc {
+			return ctx.docsByPath[absPath]
+		})
+	}
+	return writeImportDocs(boxID, toPath, docs)
+}
+
+// loadDir 加载文件夹 dir 中的网页，包含网页的子文件夹作为一篇文档导入。浏览器保存网页时生成的 xxx_files 资源文件夹不导入。
+func (ctx *htmlImport) loadDir(dir string) (ret []*importDoc) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		util.LogErrorf("read dir [%s] failed: %s", dir, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "__MACOSX") {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		if entry.IsDir() {
+			if strings.HasSuffix(name, "_files") || strings.HasSuffix(name, ".files") {
+				continue
+			}
+			children := ctx.loadDir(p)
+			if 1 > len(children) {
+				continue
+			}
+			doc := newImportDoc(name, nil)
+			doc.children = children
+			ret = append(ret, doc)
+			continue
+		}
+
+		if ext := strings.ToLower(filepath.Ext(name)); ".html" == ext || ".htm" == ext {
+			if doc := ctx.loadPage(p); nil != doc {
+				ret = append(ret, doc)
+			}
+		}
+	}
+	return
+}
+
+func (ctx *htmlImport) loadPage(p string) (ret *importDoc) {
+	data, err := os.ReadFile(p)
+	if nil != err {
+		util.LogErrorf("read html [%s] failed: %s", p, err)
+		return
+	}
+	if enc, name, _ := charset.DetermineEncoding(data, ""); "utf-8" != name {
+		if decoded, decodeErr := enc.NewDecoder().Bytes(data); nil == decodeErr {
+			data = decoded
+		}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if nil != err {
+		util.LogErrorf("parse html [%s] failed: %s", p, err)
+		return
+	}
+
+	sourceURL, captureDate := htmlSource(doc, data)
+	if "" == captureDate {
+		if info, statErr := os.Stat(p); nil == statErr {
+			captureDate = info.ModTime().Format("2006-01-02 15:04:05")
+		}
+	}
+	title := htmlTitle(doc)
+	if "" == title {
+		title = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+
+	content := htmlReadable(doc)
+	content.Find("h1").FilterFunction(func(i int, h1 *goquery.Selection) bool {
+		return strings.TrimSpace(h1.Text()) == title
+	}).First().Remove()
+	ctx.inlineImages(content)
+	dom, err := content.Html()
+	if nil != err {
+		return
+	}
+
+	luteEngine := NewLute()
+	tree, err := HTML2Tree(dom, luteEngine)
+	if nil != err {
+		util.LogErrorf("convert html [%s] failed: %s", p, err)
+		return
+	}
+	ret = newImportDoc(title, render.NewFormatRenderer(tree, luteEngine.RenderOptions).Render())
+	if "" != sourceURL {
+		setImportAttr(ret.tree.Root, "custom-source-url", sourceURL)
+	}
+	if "" != captureDate {
+		setImportAttr(ret.tree.Root, "custom-capture-date", captureDate)
+	}
+	ctx.docsByPath[p] = ret
+	ctx.sources[ret] = p
+	return
+}
+
+// htmlSource 返回网页的来源地址和保存时间，支持浏览器和 SingleFile 保存的网页中的注释以及 canonical 链接。
+func htmlSource(doc *goquery.Document, data []byte) (sourceURL, captureDate string) {
+	head := string(data[:int(math.Min(float64(len(data)), 16*1024))])
+	if m := htmlSingleFileURLRegexp.FindStringSubmatch(head); nil != m {
+		sourceURL = m[1]
+	} else if m = htmlSavedFromRegexp.FindStringSubmatch(head); nil != m {
+		sourceURL = m[1]
+	} else if href, ok := doc.Find("link[rel=canonical]").Attr("href"); ok {
+		sourceURL = href
+	} else if content, ok := doc.Find("meta[property='og:url']").Attr("content"); ok {
+		sourceURL = content
+	}
+	sourceURL = strings.TrimSpace(sourceURL)
+
+	if m := htmlSingleFileDateRegexp.FindStringSubmatch(head); nil != m {
+		if t, err := time.Parse("Mon Jan 02 2006 15:04:05 GMT-0700", m[1]); nil == err {
+			captureDate = t.Local().Format("2006-01-02 15:04:05")
+		}
+	}
+	return
+}
+
+func htmlTitle(doc *goquery.Document) (ret string) {
+	if content, ok := doc.Find("meta[property='og:title']").Attr("content"); ok {
+		ret = content
+	}
+	if "" == strings.TrimSpace(ret) {
+		ret = doc.Find("title").First().Text()
+	}
+	if "" == strings.TrimSpace(ret) {
+		ret = doc.Find("h1").First().Text()
+	}
+	return strings.Join(strings.Fields(ret), " ")
+}
+
+// htmlReadable 提取网页的正文，优先使用 article 和 main 元素，否则按照段落文本长度、逗号数量和 class、id 计算容器元素的得分，返回得分最高的元素。
+func htmlReadable(doc *goquery.Document) *goquery.Selection {
+	doc.Find("script, style, noscript, iframe, form, nav, footer, aside, button, input, select, textarea, template, link, meta, object, embed, [hidden]").Remove()
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		if s.Is("html, body, article, main") {
+			return
+		}
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if match := class + " " + id; htmlUnlikelyRegexp.MatchString(match) && !htmlLikelyRegexp.MatchString(match) {
+			s.Remove()
+		}
+	})
+
+	body := doc.Find("body")
+	if 0 == body.Length() {
+		body = doc.Selection
+	}
+	bodyTextLen := utf8.RuneCountInString(strings.TrimSpace(body.Text()))
+
+	// 语义元素中包含了大部分正文时直接使用
+	var article *goquery.Selection
+	articleTextLen := 0
+	doc.Find("article, main, [role=main]").Each(func(i int, s *goquery.Selection) {
+		if l := utf8.RuneCountInString(strings.TrimSpace(s.Text())); l > articleTextLen {
+			article, articleTextLen = s, l
+		}
+	})
+	if nil != article && float64(articleTextLen) >= float64(bodyTextLen)*0.5 {
+		return article
+	}
+
+	scores := map[*html.Node]float64{}
+	var candidates []*goquery.Selection
+	addCandidate := func(s *goquery.Selection, score float64) {
+		if 0 == s.Length() || s.Is("html") {
+			return
+		}
+		node := s.Nodes[0]
+		if _, ok := scores[node]; !ok {
+			scores[node] = htmlInitialScore(s)
+			candidates = append(candidates, s)
+		}
+		scores[node] += score
+	}
+	doc.Find("p, pre, td, blockquote, div").Each(func(i int, s *goquery.Selection) {
+		if s.Is("div") && 0 < s.Find("p, div, table, ul, ol, pre, blockquote, h1, h2, h3, h4, h5, h6, article, section").Length() {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		textLen := utf8.RuneCountInString(text)
+		if 25 > textLen {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(textLen)/100, 3)
+		parent := s.Parent()
+		addCandidate(parent, score)
+		addCandidate(parent.Parent(), score/2)
+	})
+
+	var top *goquery.Selection
+	topScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate.Nodes[0]] * (1 - htmlLinkDensity(candidate))
+		if nil == top || score > topScore {
+			top, topScore = candidate, score
+		}
+	}
+	if nil == top {
+		return body
+	}
+	return top
+}
+
+func htmlInitialScore(s *goquery.Selection) (ret float64) {
+	switch goquery.NodeName(s) {
+	case "div", "article", "section", "main":
+		ret = 5
+	case "pre", "td", "blockquote":
+		ret = 3
+	case "form", "ol", "ul", "dl", "dd", "dt", "li", "address":
+		ret = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		ret = -5
+	}
+
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	for _, match := range []string{class, id} {
+		if "" == match {
+			continue
+		}
+		if htmlNegativeRegexp.MatchString(match) {
+			ret -= 25
+		}
+		if htmlPositiveRegexp.MatchString(match) {
+			ret += 25
+		}
+	}
+	return
+}
+
+// htmlLinkDensity 返回元素中超链接文本占全部文本的比例。
+func htmlLinkDensity(s *goquery.Selection) float64 {
+	textLen := utf8.RuneCountInString(strings.TrimSpace(s.Text()))
+	if 0 == textLen {
+		return 0
+	}
+	linkLen := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linkLen += utf8.RuneCountInString(strings.TrimSpace(a.Text()))
+	})
+	return float64(linkLen) / float64(textLen)
+}
+
+// inlineImages 处理图片的懒加载属性，并将 data URI 图片写入 assets 下。
+func (ctx *htmlImport) inlineImages(content *goquery.Selection) {
+	content.Find("img").Each(func(i int, img *goquery.Selection) {
+		src, _ := img.Attr("src")
+		for _, attr := range []string{"data-src", "data-original", "data-lazy-src"} {
+			if lazy, ok := img.Attr(attr); ok && "" != lazy && ("" == src || strings.HasPrefix(src, "data:")) {
+				src = lazy
+				break
+			}
+		}
+		img.RemoveAttr("srcset")
+
+		if m := htmlDataURIRegexp.FindStringSubmatch(src); nil != m {
+			data, err := base64.StdEncoding.DecodeString(src[len(m[0]):])
+			if nil != err {
+				img.Remove()
+				return
+			}
+			ext := enexMimeExt(m[1])
+			if "" == ext {
+				ext = ".png"
+			}
+			if src, err = ctx.assets.write("image"+ext, data); nil != err {
+				img.Remove()
+				return
+			}
+		}
+		img.SetAttr("src", src)
+	})
+}
@@ -0,0 +1,338 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Notion 导出的文件名和文件夹名以空格加 32 位十六进制的页面 ID 结尾，比如 Page 0123456789abcdef0123456789abcdef.md
+var (
+	notionHashRegexp = regexp.MustCompile(`\s+([0-9a-f]{32})$`)
+	notionURLRegexp  = regexp.MustCompile(`notion\.so/.*?([0-9a-f]{32})(?:[?#].*)?$`)
+)
+
+// notionImport 描述了一次 Notion 导出包的导入过程。
+type notionImport struct {
+	dbAsDocs    bool                  // 数据库导入为带属性的文档，否则导入为表格
+	docsByPath  map[string]*importDoc // 本地文件路径（.md 或者 .csv）到文档
+	docsByHash  map[string]*importDoc // Notion 页面 ID 到文档
+	sourcePaths map[*importDoc]string // 文档到本地 .md 文件路径
+	assets      *importAssets
+}
+
+// ImportNotion 导入 Notion 导出的 Markdown & CSV 压缩包到笔记本 boxID 的 toPath 下。
+//
+// 文件名中的页面 ID 会被移除，页面文件夹中的页面作为子文档；页面间的链接转换为块引用；附件复制到 assets 下并改写链接。
+// 数据库 CSV 默认转换为表格，dbAsDocs 为 true 时数据库中的每一行作为带有自定义属性的子文档。
+func ImportNotion(zipPath, boxID, toPath string, dbAsDocs bool) (err error) {
+	util.PushEndlessProgress(Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	unzipPath := filepath.Join(util.TempDir, "import", "notion-"+gulu.Rand.String(7))
+	defer os.RemoveAll(unzipPath)
+	if err = notionUnzip(zipPath, unzipPath); nil != err {
+		util.LogErrorf("unzip notion export [%s] failed: %s", zipPath, err)
+		return
+	}
+
+	ctx := &notionImport{
+		dbAsDocs:    dbAsDocs,
+		docsByPath:  map[string]*importDoc{},
+		docsByHash:  map[string]*importDoc{},
+		sourcePaths: map[*importDoc]string{},
+		assets:      newImportAssets(),
+	}
+	ctx.assets.allow(unzipPath)
+
+	// Notion 导出包中通常只有一个包含所有页面的文件夹
+	root := unzipPath
+	for {
+		entries, readErr := os.ReadDir(root)
+		if nil != readErr || 1 != len(entries) || !entries[0].IsDir() {
+			break
+		}
+		root = filepath.Join(root, entries[0].Name())
+	}
+
+	docs := ctx.loadDir(root)
+	for doc, sourcePath := range ctx.sourcePaths {
+		ctx.rewriteLinks(doc, filepath.Dir(sourcePath))
+	}
+	return writeImportDocs(boxID, toPath, docs)
+}
+
+// notionUnzip 解压 Notion 导出包，较大的导出包中还会包含多个分卷压缩包。
+func notionUnzip(zipPath, unzipPath string) (err error) {
+	if err = gulu.Zip.Unzip(zipPath, unzipPath); nil != err {
+		return
+	}
+	entries, err := os.ReadDir(unzipPath)
+	if nil != err {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(".zip", filepath.Ext(entry.Name())) {
+			continue
+		}
+		partPath := filepath.Join(unzipPath, entry.Name())
+		if err = gulu.Zip.Unzip(partPath, unzipPath); nil != err {
+			return
+		}
+		os.Remove(partPath)
+	}
+	return
+}
+
+// notionTitle 移除文件名中的扩展名和页面 ID，返回标题和页面 ID。
+func notionTitle(name string) (title, hash string) {
+	title = strings.TrimSuffix(name, filepath.Ext(name))
+	if m := notionHashRegexp.FindStringSubmatch(title); nil != m {
+		hash = m[1]
+		title = strings.TrimSpace(strings.TrimSuffix(title, m[0]))
+	}
+	return
+}
+
+// loadDir 加载文件夹 dir 中的页面和数据库，和页面（数据库）同名的文件夹中的页面作为其子文档。
+func (ctx *notionImport) loadDir(dir string) (ret []*importDoc) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		util.LogErrorf("read dir [%s] failed: %s", dir, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	consumed := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		switch ext {
+		case ".md":
+			doc := ctx.loadPage(filepath.Join(dir, name))
+			if nil == doc {
+				continue
+			}
+			if names[base] {
+				consumed[base] = true
+				doc.children = ctx.loadDir(filepath.Join(dir, base))
+			}
+			ret = append(ret, doc)
+		case ".csv":
+			// 新版导出的数据库有两个 CSV，X.csv 为当前视图，X_all.csv 包含所有行，优先使用 X_all.csv
+			if strings.HasSuffix(base, "_all") {
+				continue
+			}
+			csvPath := filepath.Join(dir, name)
+			if names[base+"_all.csv"] {
+				csvPath = filepath.Join(dir, base+"_all.csv")
+			}
+			var rows []*importDoc
+			if names[base] {
+				consumed[base] = true
+				rows = ctx.loadDir(filepath.Join(dir, base))
+			}
+			if doc := ctx.loadDatabase(csvPath, name, rows); nil != doc {
+				ret = append(ret, doc)
+			}
+		}
+	}
+
+	// 没有对应页面的文件夹中如果包含页面，则作为一篇文档导入
+	for _, entry := range entries {
+		if !entry.IsDir() || consumed[entry.Name()] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		children := ctx.loadDir(filepath.Join(dir, entry.Name()))
+		if 1 > len(children) {
+			continue
+		}
+		title, _ := notionTitle(entry.Name())
+		doc := newImportDoc(title, nil)
+		doc.children = children
+		ret = append(ret, doc)
+	}
+	return
+}
+
+func (ctx *notionImport) loadPage(mdPath string) (ret *importDoc) {
+	data, err := os.ReadFile(mdPath)
+	if nil != err {
+		util.LogErrorf("read notion page [%s] failed: %s", mdPath, err)
+		return
+	}
+
+	title, hash := notionTitle(filepath.Base(mdPath))
+	ret = newImportDoc(title, data)
+
+	// Notion 页面以一级标题开头，标题已经作为文档标题
+	if first := ret.tree.Root.FirstChild; nil != first && ast.NodeHeading == first.Type && 1 == first.HeadingLevel {
+		if heading := strings.TrimSpace(first.Text()); "" != heading {
+			ret.title = importTitle(heading)
+			setImportAttr(ret.tree.Root, "title", ret.title)
+		}
+		if next := first.Next; nil != next && ast.NodeKramdownBlockIAL == next.Type {
+			next.Unlink()
+		}
+		first.Unlink()
+	}
+
+	ctx.docsByPath[mdPath] = ret
+	if "" != hash {
+		ctx.docsByHash[hash] = ret
+	}
+	ctx.sourcePaths[ret] = mdPath
+	return
+}
+
+// loadDatabase 加载数据库 CSV，rows 为数据库中每一行对应的页面。
+func (ctx *notionImport) loadDatabase(csvPath, name string, rows []*importDoc) (ret *importDoc) {
+	data, err := os.ReadFile(csvPath)
+	if nil != err {
+		util.LogErrorf("read notion database [%s] failed: %s", csvPath, err)
+		return
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if nil != err {
+		util.LogErrorf("parse notion database [%s] failed: %s", csvPath, err)
+		return
+	}
+
+	// 第一列为页面标题，按照标题匹配行对应的页面
+	rowDocs := map[string]*importDoc{}
+	for _, row := range rows {
+		rowDocs[row.title] = row
+	}
+
+	title, hash := notionTitle(name)
+	if ctx.dbAsDocs || 1 > len(records) {
+		ret = newImportDoc(title, nil)
+		if 0 < len(records) {
+			header := records[0]
+			for _, record := range records[1:] {
+				if 1 > len(record) {
+					continue
+				}
+				row := rowDocs[strings.TrimSpace(record[0])]
+				if nil == row {
+					continue
+				}
+				for i := 1; i < len(record) && i < len(header); i++ {
+					if value := strings.TrimSpace(record[i]); "" != value {
+						setImportAttr(row.tree.Root, "custom-"+importAttrName(header[i], i), value)
+					}
+				}
+				notionStripProperties(row, header)
+			}
+		}
+	} else {
+		buf := bytes.Buffer{}
+		for i, record := range records {
+			buf.WriteString("|")
+			for j, cell := range record {
+				cell = strings.TrimSpace(cell)
+				cellMd := queryResultTableCell(cell)
+				if 0 < i && 0 == j {
+					if row := rowDocs[cell]; nil != row {
+						cellMd = "((" + row.id + " \"" + strings.ReplaceAll(cellMd, "\"", "&quot;") + "\"))"
+					}
+				}
+				buf.WriteString(" " + cellMd + " |")
+			}
+			buf.WriteString("\n")
+			if 0 == i {
+				buf.WriteString("|" + strings.Repeat(" --- |", len(record)) + "\n")
+			}
+		}
+		ret = newImportDoc(title, buf.Bytes())
+	}
+	ret.children = rows
+
+	ctx.docsByPath[csvPath] = ret
+	ctx.docsByPath[filepath.Join(filepath.Dir(csvPath), name)] = ret
+	if "" != hash {
+		ctx.docsByHash[hash] = ret
+	}
+	return
+}
+
+// notionStripProperties 移除数据库行页面开头的属性段落（比如 Status: Done），这些属性已经转换为自定义属性。
+func notionStripProperties(row *importDoc, header []string) {
+	for {
+		first := row.tree.Root.FirstChild
+		if nil == first || ast.NodeParagraph != first.Type {
+			return
+		}
+		text := first.Text()
+		matched := false
+		for _, col := range header {
+			if strings.HasPrefix(text, col+": ") || text == col+":" {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return
+		}
+		if next := first.Next; nil != next && ast.NodeKramdownBlockIAL == next.Type {
+			next.Unlink()
+		}
+		first.Unlink()
+	}
+}
+
+// rewriteLinks 将指向导出包中页面和数据库的链接转换为块引用，指向附件的链接改为资源文件链接。
+func (ctx *notionImport) rewriteLinks(doc *importDoc, dir string) {
+	rewriteImportLinks(doc.tree.Root, dir, ctx.assets, func(dest, absPath string) *importDoc {
+		if m := notionURLRegexp.FindStringSubmatch(dest); nil != m {
+			return ctx.docsByHash[m[1]]
+		}
+		if "" == absPath {
+			return nil
+		}
+		if ret := ctx.docsByPath[absPath]; nil != ret {
+			return ret
+		}
+		if _, hash := notionTitle(filepath.Base(absPath)); "" != hash {
+			return ctx.docsByHash[hash]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,620 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	vaultWikilinkRegexp     = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+	vaultEmbedMacroRegexp   = regexp.MustCompile(`\{\{embed\s+(?:\[\[([^\[\]\n]+?)\]\]|\(\(([^()\n]+?)\)\))\s*\}\}`)
+	vaultUUIDRefRegexp      = regexp.MustCompile(`\(\(([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\)\)`)
+	vaultAnchorRegexp       = regexp.MustCompile(`\s\^([A-Za-z0-9-]+)\s*$`)
+	vaultPropertyRegexp     = regexp.MustCompile(`^(\s*)([A-Za-z][\w-]*)::(?:\s+(.*?))?\s*$`)
+	vaultPagePropertyRegexp = regexp.MustCompile(`^\s*[-*+]\s+([A-Za-z][\w-]*)::(?:\s+(.*?))?\s*$`)
+	vaultHeadingRegexp      = regexp.MustCompile(`^(\s*(?:[-*+]\s+)?)#{1,6}\s+(.*?)(?:\s+#+)?\s*$`)
+	vaultListItemRegexp     = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	vaultJournalRegexp      = regexp.MustCompile(`^(\d{4})_(\d{2})_(\d{2})$`)
+	vaultImageExts          = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".bmp": true}
+)
+
+// vaultFile 描述了笔记库中的一个页面。
+type vaultFile struct {
+	doc      *importDoc
+	path     string               // 本地 .md 文件路径，命名空间中不存在的上级页面为空
+	name     string               // 页面名，Logseq 命名空间页面的页面名包含 /
+	aliases  []string             // 页面别名
+	md       string               // 预处理后的 Markdown
+	attrs    [][]string           // 文档属性
+	headings map[string]string    // 标题文本（小写）到标题块 ID
+	anchors  map[string][2]string // Obsidian 块锚点 ^anchor 到块 ID 和块文本
+}
+
+// vaultIAL 是预处理时为块生成的 kramdown IAL。
+type vaultIAL struct {
+	indent string
+	id     string
+	text   string
+	attrs  [][]string
+}
+
+// vaultImport 描述了一次 Obsidian 或者 Logseq 笔记库的导入过程。
+type vaultImport struct {
+	root        string
+	files       []*vaultFile
+	pages       map[string]*vaultFile // 页面名、相对路径和别名（小写）到页面
+	paths       map[string]*vaultFile // 本地 .md 文件路径到页面
+	uuids       map[string][2]string  // Logseq 块 UUID 到块 ID 和块文本
+	attachments map[string]string     // 附件文件名和相对路径（小写）到本地路径
+	assets      *importAssets
+}
+
+// ImportVault 导入 Obsidian 或者 Logseq 笔记库 localPath 到笔记本 boxID 的 toPath 下。
+//
+// 导入分为两遍：第一遍预处理所有页面并分配文档、标题和块锚点的 ID，第二遍将 [[wikilink]] 转换为块引用，
+// ![[embed]] 和 {{embed}} 转换为嵌入块，^block-id 锚点和 ((uuid)) 引用转换为块引用。
+// YAML Front Matter 和 Logseq 的 key:: value 属性转换为文档和块的属性。
+func ImportVault(localPath, boxID, toPath string) (err error) {
+	if !gulu.File.IsDir(localPath) {
+		return errors.New(fmt.Sprintf(Conf.Language(15), localPath))
+	}
+
+	util.PushEndlessProgress(Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	ctx := &vaultImport{
+		root:        filepath.Clean(localPath),
+		pages:       map[string]*vaultFile{},
+		paths:       map[string]*vaultFile{},
+		uuids:       map[string][2]string{},
+		attachments: map[string]string{},
+		assets:      newImportAssets(),
+	}
+
+	ctx.assets.allow(ctx.root)
+
+	// 第一遍：加载页面并分配 ID
+	docs := ctx.loadDir(ctx.root)
+	for _, f := range ctx.files {
+		keys := append([]string{f.name, f.doc.title}, f.aliases...)
+		if "" != f.path {
+			// Obsidian 使用文件名或者相对路径链接页面
+			relPath, _ := filepath.Rel(ctx.root, f.path)
+			relPath = strings.TrimSuffix(filepath.ToSlash(relPath), filepath.Ext(relPath))
+			keys = append(keys, path.Base(relPath), relPath)
+		}
+		for _, key := range keys {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if _, ok := ctx.pages[key]; !ok && "" != key {
+				ctx.pages[key] = f
+			}
+		}
+	}
+
+	// 第二遍：改写链接和引用
+	for _, f := range ctx.files {
+		if "" == f.path {
+			continue
+		}
+		f.doc.parse([]byte(ctx.rewrite(f)))
+		for _, attr := range f.attrs {
+			setImportAttr(f.doc.tree.Root, attr[0], attr[1])
+		}
+		rewriteImportLinks(f.doc.tree.Root, filepath.Dir(f.path), ctx.assets, func(dest, absPath string) *importDoc {
+			if target := ctx.paths[absPath]; nil != target {
+				return target.doc
+			}
+			return nil
+		})
+	}
+	return writeImportDocs(boxID, toPath, docs)
+}
+
+// loadDir 加载文件夹 dir 中的页面，和页面同名的文件夹中的页面作为其子文档，Logseq 命名空间页面作为上级页面的子文档。
+func (ctx *vaultImport) loadDir(dir string) (ret []*importDoc) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		util.LogErrorf("read dir [%s] failed: %s", dir, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	var files []*vaultFile
+	consumed := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || entry.IsDir() {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		ext := strings.ToLower(filepath.Ext(name))
+		if ".md" != ext && ".markdown" != ext {
+			relPath, _ := filepath.Rel(ctx.root, p)
+			for _, key := range []string{name, filepath.ToSlash(relPath)} {
+				if _, ok := ctx.attachments[strings.ToLower(key)]; !ok {
+					ctx.attachments[strings.ToLower(key)] = p
+				}
+			}
+			continue
+		}
+
+		f := ctx.loadPage(p)
+		if nil == f {
+			continue
+		}
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		if names[base] {
+			consumed[base] = true
+			f.doc.children = ctx.loadDir(filepath.Join(dir, base))
+		}
+		files = append(files, f)
+	}
+
+	// Logseq 命名空间页面 a/b 作为页面 a 的子文档，页面 a 不存在时创建一个空文档
+	byName := map[string]*vaultFile{}
+	for _, f := range files {
+		byName[strings.ToLower(f.name)] = f
+	}
+	var parentOf func(name string) *vaultFile
+	parentOf = func(name string) *vaultFile {
+		i := strings.LastIndex(name, "/")
+		if 0 >= i {
+			return nil
+		}
+		parentName := name[:i]
+		if parent := byName[strings.ToLower(parentName)]; nil != parent {
+			return parent
+		}
+		parent := &vaultFile{doc: newImportDoc(parentName[strings.LastIndex(parentName, "/")+1:], nil), name: parentName}
+		byName[strings.ToLower(parentName)] = parent
+		ctx.files = append(ctx.files, parent)
+		if grandParent := parentOf(parentName); nil != grandParent {
+			grandParent.doc.children = append(grandParent.doc.children, parent.doc)
+		} else {
+			ret = append(ret, parent.doc)
+		}
+		return parent
+	}
+	for _, f := range files {
+		if parent := parentOf(f.name); nil != parent {
+			parent.doc.children = append(parent.doc.children, f.doc)
+		} else {
+			ret = append(ret, f.doc)
+		}
+	}
+
+	// 其他文件夹中如果包含页面，则作为一篇文档导入。Logseq 的配置文件夹 logseq/ 不导入
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || consumed[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		subDir := filepath.Join(dir, name)
+		if "logseq" == name && gulu.File.IsExist(filepath.Join(subDir, "config.edn")) {
+			continue
+		}
+		children := ctx.loadDir(subDir)
+		if 1 > len(children) {
+			continue
+		}
+		doc := newImportDoc(name, nil)
+		doc.children = children
+		ret = append(ret, doc)
+	}
+	return
+}
+
+func (ctx *vaultImport) loadPage(p string) (ret *vaultFile) {
+	data, err := os.ReadFile(p)
+	if nil != err {
+		util.LogErrorf("read page [%s] failed: %s", p, err)
+		return
+	}
+
+	// Logseq 使用 a___b.md 或者 a%2Fb.md 保存命名空间页面 a/b，日志页面保存为 journals/yyyy_MM_dd.md
+	name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	name = strings.ReplaceAll(name, "___", "/")
+	if strings.Contains(name, "%") {
+		if unescaped, unescapeErr := url.PathUnescape(name); nil == unescapeErr {
+			name = unescaped
+		}
+	}
+	if m := vaultJournalRegexp.FindStringSubmatch(name); nil != m && "journals" == filepath.Base(filepath.Dir(p)) {
+		name = m[1] + "-" + m[2] + "-" + m[3]
+	}
+
+	ret = &vaultFile{
+		doc:      newImportDoc(name, nil),
+		path:     p,
+		name:     name,
+		headings: map[string]string{},
+		anchors:  map[string][2]string{},
+	}
+	ctx.preprocess(ret, string(data))
+	ret.doc.title = importTitle(path.Base(ret.name))
+	ctx.files = append(ctx.files, ret)
+	ctx.paths[p] = ret
+	return
+}
+
+// preprocess 解析页面属性和块属性，为标题和带有锚点、属性的块生成 kramdown IAL，结果保存在 f.md 中。
+func (ctx *vaultImport) preprocess(f *vaultFile, content string) {
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(content, "\n")
+
+	// YAML Front Matter
+	if 1 < len(lines) && "---" == strings.TrimSpace(lines[0]) {
+		for i := 1; i < len(lines); i++ {
+			if end := strings.TrimSpace(lines[i]); "---" != end && "..." != end {
+				continue
+			}
+			frontMatter := yaml.MapSlice{}
+			if yamlErr := yaml.Unmarshal([]byte(strings.Join(lines[1:i], "\n")), &frontMatter); nil != yamlErr {
+				util.LogWarnf("parse front matter of [%s] failed: %s", f.path, yamlErr)
+				break
+			}
+			for _, item := range frontMatter {
+				ctx.setPageProp(f, fmt.Sprint(item.Key), vaultPropValue(item.Value))
+			}
+			lines = lines[i+1:]
+			break
+		}
+	}
+
+	buf := strings.Builder{}
+	var pending *vaultIAL
+	flush := func() {
+		if nil == pending {
+			return
+		}
+		buf.WriteString(pending.indent + "{: id=\"" + pending.id + "\"")
+		for _, attr := range pending.attrs {
+			buf.WriteString(" " + attr[0] + "=\"" + html.EscapeAttrVal(attr[1]) + "\"")
+		}
+		buf.WriteString("}\n")
+		pending = nil
+	}
+
+	contentSeen, fence, lastText := false, "", ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if "" != fence {
+			buf.WriteString(line + "\n")
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+
+		if m := vaultPropertyRegexp.FindStringSubmatch(line); nil != m {
+			if !contentSeen {
+				ctx.setPageProp(f, m[2], m[3])
+				continue
+			}
+			if nil == pending {
+				pending = &vaultIAL{indent: m[1], id: ast.NewNodeID(), text: lastText}
+			}
+			ctx.setBlockProp(pending, m[2], m[3])
+			continue
+		}
+		if m := vaultPagePropertyRegexp.FindStringSubmatch(line); nil != m && !contentSeen {
+			ctx.setPageProp(f, m[1], m[2])
+			continue
+		}
+
+		flush()
+		if "" == trimmed {
+			buf.WriteString(line + "\n")
+			continue
+		}
+		contentSeen = true
+
+		listPrefix := vaultListItemRegexp.FindString(line)
+		lastText = strings.TrimSpace(line[len(listPrefix):])
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			buf.WriteString(line + "\n")
+			continue
+		}
+
+		if m := vaultHeadingRegexp.FindStringSubmatch(line); nil != m {
+			lastText = m[2]
+			pending = &vaultIAL{indent: vaultIndent(m[1]), id: ast.NewNodeID(), text: lastText}
+			if _, ok := f.headings[strings.ToLower(lastText)]; !ok {
+				f.headings[strings.ToLower(lastText)] = pending.id
+			}
+		}
+		if loc := vaultAnchorRegexp.FindStringSubmatchIndex(line); nil != loc {
+			anchor := line[loc[2]:loc[3]]
+			line = line[:loc[0]]
+			lastText = strings.TrimSpace(line[len(listPrefix):])
+			if nil == pending {
+				pending = &vaultIAL{indent: vaultIndent(listPrefix), id: ast.NewNodeID(), text: lastText}
+				if "" == listPrefix {
+					pending.indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				}
+			}
+			f.anchors[anchor] = [2]string{pending.id, lastText}
+		}
+		buf.WriteString(line + "\n")
+	}
+	flush()
+	f.md = buf.String()
+}
+
+// vaultIndent 返回和 prefix 等宽的缩进，prefix 开头的空白字符（Logseq 使用制表符缩进）保持不变。
+func vaultIndent(prefix string) string {
+	ws := prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t"))]
+	return ws + strings.Repeat(" ", len(prefix)-len(ws))
+}
+
+// setPageProp 设置页面属性，title、alias 和 tags 分别对应文档标题、别名和标签，其他属性转换为自定义属性。
+func (ctx *vaultImport) setPageProp(f *vaultFile, key, value string) {
+	value = strings.TrimSpace(value)
+	if "" == value {
+		return
+	}
+
+	switch strings.ToLower(key) {
+	case "title":
+		f.name = vaultPlainValue(value)
+	case "alias", "aliases":
+		aliases := vaultListValue(value)
+		f.aliases = append(f.aliases, aliases...)
+		f.attrs = append(f.attrs, []string{"alias", strings.Join(aliases, ",")})
+	case "tags", "tag":
+		f.attrs = append(f.attrs, []string{"tags", strings.Join(vaultListValue(value), ",")})
+	default:
+		f.attrs = append(f.attrs, []string{"custom-" + importAttrName(key, len(f.attrs)), vaultPlainValue(value)})
+	}
+}
+
+// setBlockProp 设置块属性，Logseq 的块 UUID 属性 id 用于解析 ((uuid)) 引用，折叠状态等内部属性忽略。
+func (ctx *vaultImport) setBlockProp(ial *vaultIAL, key, value string) {
+	value = strings.TrimSpace(value)
+	if "" == value {
+		return
+	}
+
+	switch strings.ToLower(key) {
+	case "id":
+		ctx.uuids[strings.ToLower(value)] = [2]string{ial.id, ial.text}
+	case "collapsed", "heading":
+	default:
+		ial.attrs = append(ial.attrs, []string{"custom-" + importAttrName(key, len(ial.attrs)), vaultPlainValue(value)})
+	}
+}
+
+// vaultPropValue 将 YAML Front Matter 中的属性值转换为字符串，列表使用逗号分隔。
+func vaultPropValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, vaultPropValue(item))
+		}
+		return strings.Join(items, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// vaultListValue 将逗号分隔的属性值转换为列表，并移除其中的 [[ ]] 和 #。
+func vaultListValue(value string) (ret []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimPrefix(vaultPlainValue(item), "#"); "" != item && !gulu.Str.Contains(item, ret) {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
+func vaultPlainValue(value string) string {
+	value = strings.ReplaceAll(value, "[[", "")
+	value = strings.ReplaceAll(value, "]]", "")
+	return strings.TrimSpace(value)
+}
+
+// rewrite 改写页面中的 wikilink、嵌入和块引用，代码块和行内代码中的内容保持不变。
+func (ctx *vaultImport) rewrite(f *vaultFile) string {
+	buf := strings.Builder{}
+	fence := ""
+	for _, line := range strings.Split(strings.TrimSuffix(f.md, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if "" != fence {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			buf.WriteString(line + "\n")
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			buf.WriteString(line + "\n")
+			continue
+		}
+
+		// 单独占一行的嵌入转换为嵌入块
+		listPrefix := vaultListItemRegexp.FindString(line)
+		if id := ctx.embedBlock(f, strings.TrimSpace(line[len(listPrefix):])); "" != id {
+			if "" == listPrefix {
+				listPrefix = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			}
+			buf.WriteString(listPrefix + "{{select * from blocks where id='" + id + "'}}\n")
+			continue
+		}
+
+		segments := strings.Split(line, "`")
+		for i := 0; i < len(segments); i += 2 {
+			segments[i] = ctx.rewriteInline(f, segments[i])
+		}
+		buf.WriteString(strings.Join(segments, "`") + "\n")
+	}
+	return buf.String()
+}
+
+// embedBlock 如果 text 为 ![[page]]、{{embed [[page]]}} 或者 {{embed ((uuid))}}，返回嵌入的块 ID。
+func (ctx *vaultImport) embedBlock(f *vaultFile, text string) string {
+	if m := vaultEmbedMacroRegexp.FindStringSubmatch(text); nil != m && m[0] == text {
+		if "" != m[1] {
+			id, _ := ctx.resolve(f, m[1])
+			return id
+		}
+		return ctx.uuids[strings.ToLower(strings.TrimSpace(m[2]))][0]
+	}
+	if m := vaultWikilinkRegexp.FindStringSubmatch(text); nil != m && m[0] == text && "!" == m[1] {
+		id, _ := ctx.resolve(f, m[2])
+		return id
+	}
+	return ""
+}
+
+func (ctx *vaultImport) rewriteInline(f *vaultFile, text string) string {
+	text = vaultEmbedMacroRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		m := vaultEmbedMacroRegexp.FindStringSubmatch(s)
+		if "" != m[1] {
+			return "[[" + m[1] + "]]"
+		}
+		return "((" + m[2] + "))"
+	})
+	text = vaultWikilinkRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		m := vaultWikilinkRegexp.FindStringSubmatch(s)
+		if id, anchor := ctx.resolve(f, m[2]); "" != id {
+			return vaultBlockRef(id, anchor)
+		}
+		if "!" == m[1] {
+			if ret := ctx.embedAttachment(m[2]); "" != ret {
+				return ret
+			}
+		}
+		return s
+	})
+	return vaultUUIDRefRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		uuid := vaultUUIDRefRegexp.FindStringSubmatch(s)[1]
+		if block, ok := ctx.uuids[uuid]; ok {
+			return vaultBlockRef(block[0], block[1])
+		}
+		return s
+	})
+}
+
+// resolve 解析 wikilink 的目标 page#heading、page#^anchor 或者 page|alias，返回块 ID 和锚文本。
+func (ctx *vaultImport) resolve(f *vaultFile, target string) (id, anchor string) {
+	target = strings.TrimSpace(target)
+	if i := strings.Index(target, "|"); 0 <= i {
+		anchor = strings.TrimSpace(target[i+1:])
+		target = strings.TrimSuffix(strings.TrimSpace(target[:i]), "\\") // 表格中的 wikilink 使用 \| 分隔别名
+	}
+
+	name, sub := target, ""
+	if i := strings.Index(target, "#"); 0 <= i {
+		name, sub = strings.TrimSpace(target[:i]), strings.TrimSpace(target[i+1:])
+	}
+
+	page := f
+	if "" != name {
+		if page = ctx.page(name); nil == page {
+			return
+		}
+	}
+	id = page.doc.id
+	if "" == anchor {
+		anchor = name
+		if "" == anchor {
+			anchor = strings.TrimPrefix(sub, "^")
+		}
+	}
+
+	if strings.HasPrefix(sub, "^") {
+		if block, ok := page.anchors[sub[1:]]; ok {
+			id = block[0]
+			if "" == name && "" != block[1] {
+				anchor = block[1]
+			}
+		}
+	} else if "" != sub {
+		sub = sub[strings.LastIndex(sub, "#")+1:]
+		if headingID, ok := page.headings[strings.ToLower(strings.TrimSpace(sub))]; ok {
+			id = headingID
+		}
+	}
+	return
+}
+
+func (ctx *vaultImport) page(name string) *vaultFile {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, key := range []string{name, strings.TrimSuffix(name, ".md"), path.Base(strings.TrimSuffix(name, ".md"))} {
+		if ret := ctx.pages[key]; nil != ret {
+			return ret
+		}
+	}
+	return nil
+}
+
+// embedAttachment 复制 ![[file]] 嵌入的附件到 assets 下，图片转换为图片，其他文件转换为超链接。
+func (ctx *vaultImport) embedAttachment(target string) string {
+	name := strings.TrimSpace(target)
+	if i := strings.Index(name, "|"); 0 <= i {
+		name = strings.TrimSpace(name[:i]) // ![[image.png|100]] 中的 100 为图片宽度
+	}
+	p := ctx.attachments[strings.ToLower(name)]
+	if "" == p {
+		p = ctx.attachments[strings.ToLower(path.Base(name))]
+	}
+	if "" == p {
+		return ""
+	}
+	assetPath, err := ctx.assets.copy(p)
+	if nil != err {
+		return ""
+	}
+	if vaultImageExts[strings.ToLower(filepath.Ext(p))] {
+		return "![" + path.Base(name) + "](" + assetPath + ")"
+	}
+	return "[" + path.Base(name) + "](" + assetPath + ")"
+}
+
+func vaultBlockRef(id, anchor string) string {
+	anchor = strings.ReplaceAll(strings.TrimSpace(anchor), "\"", "&quot;")
+	if "" == anchor {
+		return "((" + id + "))"
+	}
+	return "((" + id + " \"" + anchor + "\"))"
+}
@@ -76,7 +76,7 @@ func (box *Box) BootIndex() {
 	return
 }
 
-func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
+func (box *Box) Index(fullRebuildIndex bool, progress *util.Progress) (treeCount int, treeSize int64) {
 	defer debug.FreeOSMemory()
 
 	sql.IndexMode()
@@ -99,7 +99,7 @@ func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
 	idTitleMap := map[string]string{}
 	idHashMap := map[string]string{}
 
-	util.PushEndlessProgress(fmt.Sprintf("["+box.Name+"] "+Conf.Language(64), len(files)))
+	progress.PushEndless(fmt.Sprintf("["+box.Name+"] "+Conf.Language(64), len(files)))
 
 	i := 0
 	// 读取并缓存路径映射
@@ -129,7 +129,7 @@ func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
 		// 缓存 ID-Hash，后面需要用于判断是否要重建库
 		idHashMap[tree.ID] = tree.Hash
 		if 1 < i && 0 == i%64 {
-			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(88), i, len(files)-i))
+			progress.PushEndless(fmt.Sprintf(Conf.Language(88), i, len(files)-i))
 			filesys.ReleaseAllFileLocks()
 		}
 		i++
@@ -172,7 +172,7 @@ func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
 		}
 		sql.PutBoxHash(tx, box.ID, boxHash)
 		util.SetBootDetails("Cleaning obsolete indexes...")
-		util.PushEndlessProgress(Conf.Language(108))
+		progress.PushEndless(Conf.Language(108))
 		if err = sql.DeleteByBoxTx(tx, box.ID); nil != err {
 			return
 		}
@@ -209,7 +209,7 @@ func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
 			continue
 		}
 		if 1 < i && 0 == i%64 {
-			util.PushEndlessProgress(fmt.Sprintf("["+box.Name+"] "+Conf.Language(53), i, treeCount-i))
+			progress.PushEndless(fmt.Sprintf("["+box.Name+"] "+Conf.Language(53), i, treeCount-i))
 			filesys.ReleaseAllFileLocks()
 		}
 		i++
@@ -219,17 +219,17 @@ func (box *Box) Index(fullRebuildIndex bool) (treeCount int, treeSize int64) {
 	elapsed := end.Sub(start).Seconds()
 	util.LogInfof("rebuilt database for notebook [%s] in [%.2fs], tree [count=%d, size=%s]", box.ID, elapsed, treeCount, humanize.Bytes(uint64(treeSize)))
 
-	util.PushEndlessProgress(fmt.Sprintf(Conf.Language(56), treeCount))
+	progress.PushEndless(fmt.Sprintf(Conf.Language(56), treeCount))
 	return
 }
 
-func IndexRefs() {
+func IndexRefs(progress *util.Progress) {
 	sql.EnableCache()
 	defer sql.ClearBlockCache()
 
 	start := time.Now()
 	util.SetBootDetails("Resolving refs...")
-	util.PushEndlessProgress(Conf.Language(54))
+	progress.PushEndless(Conf.Language(54))
 
 	// 解析并更新引用块
 	util.SetBootDetails("Resolving ref block content...")
@@ -251,7 +251,7 @@ func IndexRefs() {
 			dynamicRefTreeIDs.Add(refBlock.RootID)
 			sql.CommitTx(tx)
 			if 1 < i && 0 == i%64 {
-				util.PushEndlessProgress(fmt.Sprintf(Conf.Language(53), i, len(refUnresolvedBlocks)-i))
+				progress.PushEndless(fmt.Sprintf(Conf.Language(53), i, len(refUnresolvedBlocks)-i))
 			}
 		}
 
@@ -274,7 +274,7 @@ func IndexRefs() {
 			sql.InsertBlock(tx, refBlock)
 			sql.CommitTx(tx)
 			if 1 < i && 0 == i%64 {
-				util.PushEndlessProgress(fmt.Sprintf(Conf.Language(53), i, len(refUnresolvedBlocks)-i))
+				progress.PushEndless(fmt.Sprintf(Conf.Language(53), i, len(refUnresolvedBlocks)-i))
 			}
 		}
 
@@ -347,7 +347,7 @@ func IndexRefs() {
 					continue
 				}
 				if 1 < i && 0 == i%64 {
-					util.PushEndlessProgress(fmt.Sprintf(Conf.Language(55), i))
+					progress.PushEndless(fmt.Sprintf(Conf.Language(55), i))
 					filesys.ReleaseAllFileLocks()
 				}
 				i++
@@ -0,0 +1,332 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// OPML 2.0 http://opml.org/spec2.opml
+type opml struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    *opmlHead `xml:"head"`
+	Body    *opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Note     string         `xml:"_note,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	URL      string         `xml:"url,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+var opmlTitleRegexp = regexp.MustCompile("\r\n|\r|\n|\u2028|\u2029|\t|/")
+
+// ExportOPML 导出 OPML 大纲。id 不为空时导出该文档的内容，否则按照文档树导出笔记本 boxID 下的所有文档，每个文档为一个大纲项，子文档为下级大纲项。
+//
+// 标题按照层级转换为大纲项，标题下的块作为下级大纲项；列表项转换为大纲项，列表项中第一个段落之后的段落写入 _note。
+func ExportOPML(boxID, id string) (name, opmlPath string, err error) {
+	WaitForWritingFiles()
+
+	var outlines []*opmlOutline
+	if "" != id {
+		block := treenode.GetBlockTree(id)
+		if nil == block {
+			err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+			return
+		}
+		doc := loadExportDoc(block.RootID)
+		if nil == doc {
+			err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+			return
+		}
+		name = doc.title
+		outlines = opmlBlockOutlines(doc.tree.Root)
+	} else {
+		box := Conf.Box(boxID)
+		if nil == box {
+			err = errors.New(Conf.Language(0))
+			return
+		}
+		name = box.Name
+		outlines = opmlDocOutlines(loadExportDocs(boxID, "/"))
+	}
+
+	data, err := xml.MarshalIndent(&opml{
+		Version: "2.0",
+		Head:    &opmlHead{Title: name, DateCreated: time.Now().Format(time.RFC1123Z)},
+		Body:    &opmlBody{Outlines: outlines},
+	}, "", "  ")
+	if nil != err {
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export")
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		util.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+	fileName := util.FilterFileName(name)
+	if "" == fileName {
+		fileName = "Untitled"
+	}
+	p := filepath.Join(exportFolder, fileName+".opml")
+	if err = gulu.File.WriteFileSafer(p, append([]byte(xml.Header), data...), 0644); nil != err {
+		util.LogErrorf("write opml [%s] failed: %s", p, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(14), err))
+		return
+	}
+	opmlPath = "/export/" + url.PathEscape(filepath.Base(p))
+	return
+}
+
+func opmlDocOutlines(docs []*exportDoc) (ret []*opmlOutline) {
+	for _, doc := range docs {
+		outline := &opmlOutline{Text: doc.title}
+		outline.Outlines = append(opmlBlockOutlines(doc.tree.Root), opmlDocOutlines(doc.children)...)
+		ret = append(ret, outline)
+	}
+	return
+}
+
+// opmlBlockOutlines 将容器块 node 的子块转换为大纲项，标题之后的块作为该标题的下级大纲项。
+func opmlBlockOutlines(node *ast.Node) (ret []*opmlOutline) {
+	type headingOutline struct {
+		level   int
+		outline *opmlOutline
+	}
+	var stack []*headingOutline
+	appendOutlines := func(outlines ...*opmlOutline) {
+		if 0 < len(stack) {
+			top := stack[len(stack)-1].outline
+			top.Outlines = append(top.Outlines, outlines...)
+		} else {
+			ret = append(ret, outlines...)
+		}
+	}
+
+	for c := node.FirstChild; nil != c; c = c.Next {
+		switch c.Type {
+		case ast.NodeHeading:
+			for 0 < len(stack) && stack[len(stack)-1].level >= c.HeadingLevel {
+				stack = stack[:len(stack)-1]
+			}
+			outline := &opmlOutline{Text: treenode.NodeStaticContent(c)}
+			appendOutlines(outline)
+			stack = append(stack, &headingOutline{level: c.HeadingLevel, outline: outline})
+		case ast.NodeList:
+			appendOutlines(opmlListOutlines(c)...)
+		case ast.NodeSuperBlock:
+			appendOutlines(opmlBlockOutlines(c)...)
+		case ast.NodeKramdownBlockIAL, ast.NodeSuperBlockOpenMarker, ast.NodeSuperBlockLayoutMarker, ast.NodeSuperBlockCloseMarker:
+		default:
+			if text := treenode.NodeStaticContent(c); "" != text {
+				appendOutlines(&opmlOutline{Text: text})
+			}
+		}
+	}
+	return
+}
+
+func opmlListOutlines(list *ast.Node) (ret []*opmlOutline) {
+	for li := list.FirstChild; nil != li; li = li.Next {
+		if ast.NodeListItem != li.Type {
+			continue
+		}
+
+		outline := &opmlOutline{}
+		var notes []string
+		first := true
+		for c := li.FirstChild; nil != c; c = c.Next {
+			switch c.Type {
+			case ast.NodeList:
+				outline.Outlines = append(outline.Outlines, opmlListOutlines(c)...)
+			case ast.NodeParagraph:
+				if first {
+					outline.Text = treenode.NodeStaticContent(c)
+					first = false
+				} else {
+					notes = append(notes, treenode.NodeStaticContent(c))
+				}
+			case ast.NodeKramdownBlockIAL, ast.NodeTaskListItemMarker:
+			default:
+				if text := treenode.NodeStaticContent(c); "" != text {
+					outline.Outlines = append(outline.Outlines, &opmlOutline{Text: text})
+				}
+			}
+		}
+		outline.Note = strings.Join(notes, "\n")
+		ret = append(ret, outline)
+	}
+	return
+}
+
+// ImportOPML 导入 OPML 大纲，toPath 为导入到的文档路径，/ 表示笔记本根目录。
+//
+// docs 为 true 时每个顶层大纲项创建一篇文档，下级大纲项作为文档中的嵌套列表；否则创建一篇以 OPML 标题命名的文档，所有大纲项作为嵌套列表。
+// 大纲项的 _note 作为列表项中的段落。
+func ImportOPML(boxID, toPath string, data []byte, docs bool) (ids []string, err error) {
+	box := Conf.Box(boxID)
+	if nil == box {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	doc := &opml{}
+	if err = xml.Unmarshal(data, doc); nil != err || nil == doc.Body {
+		if nil == err {
+			err = errors.New("missing body")
+		}
+		util.LogErrorf("parse opml failed: %s", err)
+		err = errors.New(fmt.Sprintf(Conf.Language(145), err))
+		return
+	}
+
+	baseHPath := "/"
+	if "/" != toPath && "" != toPath {
+		block := treenode.GetBlockTreeRootByPath(boxID, toPath)
+		if nil == block {
+			err = errors.New(fmt.Sprintf(Conf.Language(15), toPath))
+			return
+		}
+		baseHPath = block.HPath
+	}
+
+	if !docs {
+		title := ""
+		if nil != doc.Head {
+			title = doc.Head.Title
+		}
+		buf := bytes.Buffer{}
+		writeOPMLMarkdown(&buf, doc.Body.Outlines, 0)
+		id, createErr := CreateWithMarkdown(boxID, opmlDocHPath(baseHPath, title), buf.String())
+		if nil != createErr {
+			err = createErr
+			return
+		}
+		ids = append(ids, id)
+		return
+	}
+
+	for _, outline := range doc.Body.Outlines {
+		// 文档标题使用大纲项的纯文本，订阅源等大纲项的链接写入文档开头
+		title, link := opmlOutlineTitle(outline), opmlOutlineLink(outline)
+		buf := bytes.Buffer{}
+		if "" != link {
+			buf.WriteString(opmlOutlineText(outline) + "\n\n")
+		}
+		if "" != outline.Note {
+			buf.WriteString(outline.Note + "\n\n")
+		}
+		writeOPMLMarkdown(&buf, outline.Outlines, 0)
+		id, createErr := CreateWithMarkdown(boxID, opmlDocHPath(baseHPath, title), buf.String())
+		if nil != createErr {
+			err = createErr
+			return
+		}
+		ids = append(ids, id)
+	}
+	return
+}
+
+func opmlDocHPath(baseHPath, title string) string {
+	title = strings.TrimSpace(opmlTitleRegexp.ReplaceAllString(title, ""))
+	if "" == title {
+		title = "Untitled"
+	}
+	return path.Join(baseHPath, title)
+}
+
+// opmlOutlineText 返回大纲项的文本，订阅源等大纲项中的链接转换为 Markdown 超链接。
+func opmlOutlineText(outline *opmlOutline) (ret string) {
+	ret = opmlOutlineTitle(outline)
+	if link := opmlOutlineLink(outline); "" != link {
+		if "" == ret {
+			ret = link
+		}
+		ret = "[" + ret + "](" + link + ")"
+	}
+	return
+}
+
+// opmlOutlineTitle 返回大纲项的纯文本，text 为空时使用 title。
+func opmlOutlineTitle(outline *opmlOutline) (ret string) {
+	ret = outline.Text
+	if "" == ret {
+		ret = outline.Title
+	}
+	return
+}
+
+// opmlOutlineLink 返回订阅源等大纲项中的链接，依次使用 htmlUrl、xmlUrl 和 url。
+func opmlOutlineLink(outline *opmlOutline) (ret string) {
+	ret = outline.HTMLURL
+	if "" == ret {
+		ret = outline.XMLURL
+	}
+	if "" == ret {
+		ret = outline.URL
+	}
+	return
+}
+
+func writeOPMLMarkdown(buf *bytes.Buffer, outlines []*opmlOutline, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, outline := range outlines {
+		text := strings.ReplaceAll(opmlOutlineText(outline), "\n", " ")
+		buf.WriteString(indent + "* " + text + "\n")
+		if note := strings.TrimSpace(outline.Note); "" != note {
+			for _, line := range strings.Split(note, "\n") {
+				buf.WriteString("\n")
+				if line = strings.TrimSpace(line); "" != line {
+					buf.WriteString(indent + "  " + line + "\n")
+				}
+			}
+			buf.WriteString("\n")
+		}
+		writeOPMLMarkdown(buf, outline.Outlines, depth+1)
+	}
+}
@@ -565,7 +565,7 @@ func getCloudSync(cloudDir string) (assetSize, backupSize int64, device string,
 	}
 
 	if elapsed := time.Now().Sub(start).Milliseconds(); 5000 < elapsed {
-		util.LogInfof("get cloud sync [%s] elapsed [%dms]", elapsed)
+		util.LogInfof("get cloud sync [%s] elapsed [%dms]", cloudDir, elapsed)
 	}
 	return
 }
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
 	"github.com/emirpasic/gods/stacks/linkedliststack"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 )
@@ -33,7 +34,14 @@ func Outline(rootID string) (ret []*Path, err error) {
 	if nil == tree {
 		return
 	}
+	ret = outline(tree)
+	return
+}
 
+// outline 返回文档树 tree 中标题构成的大纲。
+func outline(tree *parse.Tree) (ret []*Path) {
+	ret = []*Path{}
+	rootID := tree.ID
 	luteEngine := NewLute()
 	var headings []*Block
 	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
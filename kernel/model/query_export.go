@@ -0,0 +1,165 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// QueryExportFormats 是 SQL 查询结果支持的导出格式和对应的扩展名、MIME 类型。
+var QueryExportFormats = map[string][2]string{
+	"csv":   {".csv", "text/csv; charset=utf-8"},
+	"jsonl": {".jsonl", "application/x-ndjson; charset=utf-8"},
+	"md":    {".md", "text/markdown; charset=utf-8"},
+}
+
+// CheckQueryExport 检查 SQL 查询结果导出的参数，stmt 必须是只读的查询语句。
+func CheckQueryExport(stmt, format string) (err error) {
+	if _, ok := QueryExportFormats[format]; !ok {
+		return errors.New(fmt.Sprintf(Conf.Language(147), format))
+	}
+	if !sql.IsReadOnlyStmt(stmt) {
+		return errors.New(Conf.Language(146))
+	}
+	return
+}
+
+// ExportQueryResult 执行只读查询语句 stmt，将查询结果按照 format 逐行写入 w。
+//
+// format 为 csv 时第一行为列名，为 jsonl 时每行一个 JSON 对象，为 md 时生成 Markdown 表格。
+func ExportQueryResult(stmt, format string, w io.Writer) (err error) {
+	if err = CheckQueryExport(stmt, format); nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	sql.WaitForWritingDatabase()
+
+	var cols []string
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		err = sql.QueryEachReadOnly(stmt, func(columns []string) error {
+			return writer.Write(columns)
+		}, func(vals []interface{}) error {
+			record := make([]string, len(vals))
+			for i, val := range vals {
+				record[i] = queryResultString(val)
+			}
+			return writer.Write(record)
+		})
+		writer.Flush()
+		if nil == err {
+			err = writer.Error()
+		}
+	case "jsonl":
+		writer := bufio.NewWriter(w)
+		err = sql.QueryEachReadOnly(stmt, func(columns []string) error {
+			cols = columns
+			return nil
+		}, func(vals []interface{}) error {
+			// 逐列写入以保持列的顺序
+			writer.WriteByte('{')
+			for i, val := range vals {
+				if 0 < i {
+					writer.WriteByte(',')
+				}
+				key, _ := gulu.JSON.MarshalJSON(cols[i])
+				writer.Write(key)
+				writer.WriteByte(':')
+				if data, ok := val.([]byte); ok {
+					val = string(data)
+				}
+				value, marshalErr := gulu.JSON.MarshalJSON(val)
+				if nil != marshalErr {
+					return marshalErr
+				}
+				writer.Write(value)
+			}
+			_, writeErr := writer.WriteString("}\n")
+			return writeErr
+		})
+		if flushErr := writer.Flush(); nil == err {
+			err = flushErr
+		}
+	case "md":
+		writer := bufio.NewWriter(w)
+		err = sql.QueryEachReadOnly(stmt, func(columns []string) error {
+			var delimiters []string
+			for _, col := range columns {
+				writer.WriteString("| " + queryResultTableCell(col) + " ")
+				delimiters = append(delimiters, "---")
+			}
+			writer.WriteString("|\n| " + strings.Join(delimiters, " | ") + " |\n")
+			return nil
+		}, func(vals []interface{}) error {
+			for _, val := range vals {
+				writer.WriteString("| " + queryResultTableCell(queryResultString(val)) + " ")
+			}
+			_, writeErr := writer.WriteString("|\n")
+			return writeErr
+		})
+		if flushErr := writer.Flush(); nil == err {
+			err = flushErr
+		}
+	}
+	return
+}
+
+// QueryResultTable 执行只读查询语句 stmt，返回 Markdown 表格形式的查询结果，用于作为表格块插入文档。
+func QueryResultTable(stmt string) (md string, err error) {
+	buf := bytes.Buffer{}
+	if err = ExportQueryResult(stmt, "md", &buf); nil != err {
+		return
+	}
+	md = buf.String()
+	return
+}
+
+func queryResultString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// queryResultTableCell 转义表格单元格中的竖线，并将换行替换为空格。
+func queryResultTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", " ")
+	return strings.ReplaceAll(s, "\n", " ")
+}
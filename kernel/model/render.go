@@ -25,10 +25,42 @@ import (
 	"github.com/88250/lute/html"
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
+	"github.com/88250/protyle"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+// HTML2Tree 将 HTML 转换为语法树，粘贴 HTML 和导入 HTML 时使用。
+func HTML2Tree(dom string, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	markdown, err := luteEngine.HTML2Markdown(dom)
+	if nil != err {
+		return
+	}
+
+	var unlinks []*ast.Node
+	ret = parse.Parse("", []byte(markdown), luteEngine.ParseOptions)
+	ast.Walk(ret.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if ast.NodeListItem == n.Type && nil == n.FirstChild {
+			newNode := protyle.NewParagraph()
+			n.AppendChild(newNode)
+			n.SetIALAttr("updated", util.TimeFromID(newNode.ID))
+			return ast.WalkSkipChildren
+		} else if ast.NodeBlockquote == n.Type && nil == n.FirstChild.Next {
+			unlinks = append(unlinks, n)
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+	return
+}
+
 func renderOutline(node *ast.Node, luteEngine *lute.Lute) (ret string) {
 	if nil == node {
 		return ""
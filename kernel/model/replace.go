@@ -0,0 +1,420 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// FindReplaceOptions 描述了查找替换的匹配选项和作用范围。
+type FindReplaceOptions struct {
+	Method        int             `json:"method"`        // 0：关键字，3：正则表达式
+	CaseSensitive bool            `json:"caseSensitive"` // 是否大小写敏感
+	WholeWord     bool            `json:"wholeWord"`     // 是否全字匹配
+	Box           string          `json:"box"`           // 限定笔记本
+	Path          string          `json:"path"`          // 限定文档路径前缀
+	Types         map[string]bool `json:"types"`         // 限定块类型，为空时使用搜索设置中的类型
+	IAL           bool            `json:"ial"`           // 是否查找替换命名、别名和备注
+}
+
+// FindReplaceMatch 描述了查找替换预览中的一处命中。
+type FindReplaceMatch struct {
+	ID          string `json:"id"`          // 块 ID
+	RootID      string `json:"rootID"`      // 文档 ID
+	Box         string `json:"box"`         // 笔记本 ID
+	HPath       string `json:"hPath"`       // 文档可读路径
+	Type        string `json:"type"`        // 块类型
+	Field       string `json:"field"`       // 命中字段：content、title、name、alias、memo
+	Index       int    `json:"index"`       // 该命中在块字段中的序号，应用替换时用于定位
+	Before      string `json:"before"`      // 命中前的上下文
+	Match       string `json:"match"`       // 命中文本
+	After       string `json:"after"`       // 命中后的上下文
+	Replacement string `json:"replacement"` // 替换后的文本
+}
+
+const (
+	maxFindReplaceBlocks  = 4096 // 查找替换最多处理的块数
+	findReplaceContextLen = 24   // 预览上下文长度
+)
+
+func FindReplacePreview(keyword, replacement string, opts *FindReplaceOptions) (ret []*FindReplaceMatch, err error) {
+	ret = []*FindReplaceMatch{}
+	reg, err := findReplaceRegexp(keyword, opts)
+	if nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	replacer := &findReplacer{reg: reg, replacement: findReplaceTemplate(replacement, opts), preview: true}
+	for _, ids := range findReplaceCandidates(reg, opts) {
+		tree, loadErr := loadTreeByBlockID(ids[0])
+		if nil != loadErr {
+			continue
+		}
+		for _, id := range ids {
+			if node := treenode.GetNodeInTree(tree, id); nil != node {
+				replacer.block(tree, node, opts)
+			}
+		}
+	}
+	ret = append(ret, replacer.matches...)
+	return
+}
+
+// FindReplaceApply 替换 selected 中选中的命中，selected 为空时替换所有命中。
+// 所有被修改的文档在替换前会生成在同一个历史快照目录下，返回该目录相对于工作空间的路径，用于整批撤销。
+// 写入失败时 historyPath 也会返回，此时部分文档可能已经写入，可以使用 historyPath 回滚。
+func FindReplaceApply(keyword, replacement string, opts *FindReplaceOptions, selected []*FindReplaceMatch) (historyPath string, count int, err error) {
+	reg, err := findReplaceRegexp(keyword, opts)
+	if nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	replacer := &findReplacer{reg: reg, replacement: findReplaceTemplate(replacement, opts)}
+	var roots [][]string
+	if 0 < len(selected) {
+		replacer.selected = map[string]bool{}
+		rootIDs := map[string][]string{}
+		var rootOrder []string
+		for _, m := range selected {
+			replacer.selected[findReplaceKey(m.ID, m.Field, m.Index)] = true
+			if _, ok := rootIDs[m.RootID]; !ok {
+				rootOrder = append(rootOrder, m.RootID)
+			}
+			if !gulu.Str.Contains(m.ID, rootIDs[m.RootID]) {
+				rootIDs[m.RootID] = append(rootIDs[m.RootID], m.ID)
+			}
+		}
+		for _, rootID := range rootOrder {
+			roots = append(roots, rootIDs[rootID])
+		}
+	} else {
+		roots = findReplaceCandidates(reg, opts)
+	}
+	if 1 > len(roots) {
+		return
+	}
+
+	// 先在内存中完成替换，写入前为所有要修改的文档生成历史快照，这样写入中途失败时也能通过 historyPath 整批回滚
+	var trees []*parse.Tree
+	for _, ids := range roots {
+		tree, loadErr := loadTreeByBlockID(ids[0])
+		if nil != loadErr {
+			// 文档可能在预览后被删除了
+			util.LogWarnf("load tree by block id [%s] failed: %s", ids[0], loadErr)
+			continue
+		}
+
+		changed := false
+		for _, id := range ids {
+			if node := treenode.GetNodeInTree(tree, id); nil != node {
+				if replacer.block(tree, node, opts) {
+					changed = true
+				}
+			}
+		}
+		if changed {
+			trees = append(trees, tree)
+		}
+	}
+	if 1 > len(trees) {
+		return
+	}
+
+	historyDir, err := util.GetHistoryDir("replace")
+	if nil != err {
+		util.LogErrorf("get history dir failed: %s", err)
+		return
+	}
+	for _, tree := range trees {
+		generateTreeHistory(historyDir, tree)
+	}
+	historyPath = filepath.ToSlash(strings.TrimPrefix(historyDir, util.WorkspaceDir))
+
+	for _, tree := range trees {
+		if err = writeJSONQueue(tree); nil != err {
+			return
+		}
+	}
+	count = replacer.replaced
+
+	WaitForWritingFiles()
+	if 0 < count {
+		IncWorkspaceDataVer()
+		go func() {
+			time.Sleep(time.Second)
+			util.ReloadUI()
+		}()
+	}
+	return
+}
+
+// RollbackFindReplace 使用 FindReplaceApply 生成的历史快照整批恢复被替换的文档。
+func RollbackFindReplace(historyPath string) (err error) {
+	historyDir := filepath.Join(util.WorkspaceDir, historyPath)
+	if !strings.HasPrefix(historyDir, filepath.Join(util.WorkspaceDir, "history")+string(os.PathSeparator)) || !strings.HasSuffix(historyDir, "-replace") || !gulu.File.IsDir(historyDir) {
+		return errors.New(fmt.Sprintf(Conf.Language(137), historyPath))
+	}
+
+	WaitForWritingFiles()
+	var trees []*parse.Tree
+	syncLock.Lock()
+	err = filepath.Walk(historyDir, func(path string, info fs.FileInfo, err error) error {
+		if nil != err {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".sy") {
+			return nil
+		}
+
+		p := filepath.ToSlash(strings.TrimPrefix(path, historyDir))
+		p = strings.TrimPrefix(p, "/")
+		boxID, p, found := strings.Cut(p, "/")
+		if !found || !util.IsIDPattern(boxID) {
+			// 历史文件夹根目录下的文件不属于任何笔记本
+			util.LogWarnf("skip rollback file [%s]", path)
+			return nil
+		}
+		p = "/" + p
+		data, readErr := filesys.NoLockFileRead(path)
+		if nil != readErr {
+			util.LogErrorf("read file [%s] failed: %s", path, readErr)
+			return readErr
+		}
+		if writeErr := filesys.LockFileWrite(filepath.Join(util.DataDir, boxID, p), data); nil != writeErr {
+			util.LogErrorf("write file [%s] failed: %s", p, writeErr)
+			return writeErr
+		}
+
+		tree, loadErr := LoadTree(boxID, p)
+		if nil != loadErr {
+			return loadErr
+		}
+		trees = append(trees, tree)
+		return nil
+	})
+	syncLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	for _, tree := range trees {
+		treenode.ReindexBlockTree(tree)
+		sql.UpsertTreeQueue(tree)
+	}
+	IncWorkspaceDataVer()
+	go func() {
+		time.Sleep(time.Second)
+		util.ReloadUI()
+	}()
+	return
+}
+
+func findReplaceRegexp(keyword string, opts *FindReplaceOptions) (ret *regexp.Regexp, err error) {
+	if "" == keyword {
+		return nil, errors.New(fmt.Sprintf(Conf.Language(136), keyword))
+	}
+
+	exp := keyword
+	if SearchMethodRegex != opts.Method {
+		exp = regexp.QuoteMeta(strings.Trim(keyword, "\"")) // FTS 字符串需要去除双引号
+	}
+	if opts.WholeWord {
+		exp = "\\b(?:" + exp + ")\\b"
+	}
+	if !opts.CaseSensitive {
+		exp = "(?i)" + exp
+	}
+	if ret, err = regexp.Compile(exp); nil != err {
+		util.LogWarnf("compile regexp [%s] failed: %s", exp, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(136), err))
+	}
+	return
+}
+
+// findReplaceTemplate 返回替换模板，关键字模式下 $ 不作为捕获组引用。
+func findReplaceTemplate(replacement string, opts *FindReplaceOptions) string {
+	if SearchMethodRegex == opts.Method {
+		return replacement
+	}
+	return strings.ReplaceAll(replacement, "$", "$$")
+}
+
+// findReplaceCandidates 查询可能命中的块，按文档分组返回块 ID。
+func findReplaceCandidates(reg *regexp.Regexp, opts *FindReplaceOptions) (ret [][]string) {
+	exp := strings.ReplaceAll(reg.String(), "'", "''")
+	stmt := "SELECT * FROM blocks WHERE (content REGEXP '" + exp + "'"
+	if opts.IAL {
+		stmt += " OR name REGEXP '" + exp + "' OR alias REGEXP '" + exp + "' OR memo REGEXP '" + exp + "'"
+	}
+	stmt += ") AND type IN " + searchFilter(opts.Types)
+	if "" != opts.Box {
+		stmt += " AND box = '" + opts.Box + "'"
+	}
+	if "" != opts.Path {
+		stmt += " AND path LIKE '" + opts.Path + "%'"
+	}
+	stmt += " ORDER BY root_id, sort ASC LIMIT " + strconv.Itoa(maxFindReplaceBlocks)
+	sqlBlocks := sql.SelectBlocksRawStmt(stmt, maxFindReplaceBlocks)
+
+	roots := map[string]int{}
+	for _, b := range sqlBlocks {
+		i, ok := roots[b.RootID]
+		if !ok {
+			i = len(ret)
+			roots[b.RootID] = i
+			ret = append(ret, []string{})
+		}
+		ret[i] = append(ret[i], b.ID)
+	}
+	return
+}
+
+type findReplacer struct {
+	reg         *regexp.Regexp
+	replacement string
+	preview     bool                // 预览时仅收集命中不修改
+	selected    map[string]bool     // 应用替换时选中的命中，为空时替换所有命中
+	counts      map[string]int      // 块字段当前命中序号
+	matches     []*FindReplaceMatch // 预览收集的命中
+	replaced    int                 // 已替换的命中数
+}
+
+// block 在块 node 自身的文本和属性上执行查找替换，不处理子块。返回是否修改了块。
+func (r *findReplacer) block(tree *parse.Tree, node *ast.Node, opts *FindReplaceOptions) (changed bool) {
+	if nil == r.counts {
+		r.counts = map[string]int{}
+	}
+
+	attr := func(field string) {
+		if value := node.IALAttr(field); "" != value {
+			if newValue := r.replace(tree, node, field, value); newValue != value {
+				node.SetIALAttr(field, newValue)
+				changed = true
+			}
+		}
+	}
+	if ast.NodeDocument == node.Type {
+		attr("title")
+	}
+	if opts.IAL {
+		attr("name")
+		attr("alias")
+		attr("memo")
+	}
+
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		if n != node && n.IsBlock() {
+			return ast.WalkSkipChildren
+		}
+
+		switch n.Type {
+		case ast.NodeText, ast.NodeLinkText, ast.NodeLinkTitle, ast.NodeCodeSpanContent, ast.NodeCodeBlockCode, ast.NodeInlineMathContent, ast.NodeMathBlockContent:
+			text := string(n.Tokens)
+			if newText := r.replace(tree, node, "content", text); newText != text {
+				n.Tokens = []byte(newText)
+				changed = true
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+func (r *findReplacer) replace(tree *parse.Tree, node *ast.Node, field, text string) string {
+	locs := r.reg.FindAllStringSubmatchIndex(text, -1)
+	if 1 > len(locs) {
+		return text
+	}
+
+	buf := bytes.Buffer{}
+	last := 0
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue
+		}
+
+		index := r.counts[node.ID+"/"+field]
+		r.counts[node.ID+"/"+field]++
+		replacement := string(r.reg.ExpandString(nil, r.replacement, text, loc))
+		buf.WriteString(text[last:loc[0]])
+		last = loc[1]
+
+		if r.preview {
+			r.matches = append(r.matches, &FindReplaceMatch{
+				ID:          node.ID,
+				RootID:      tree.ID,
+				Box:         tree.Box,
+				HPath:       tree.HPath,
+				Type:        treenode.TypeAbbr(node.Type.String()),
+				Field:       field,
+				Index:       index,
+				Before:      findReplaceContext(text[:loc[0]], true),
+				Match:       text[loc[0]:loc[1]],
+				After:       findReplaceContext(text[loc[1]:], false),
+				Replacement: replacement,
+			})
+			buf.WriteString(text[loc[0]:loc[1]])
+			continue
+		}
+
+		if nil == r.selected || r.selected[findReplaceKey(node.ID, field, index)] {
+			buf.WriteString(replacement)
+			r.replaced++
+		} else {
+			buf.WriteString(text[loc[0]:loc[1]])
+		}
+	}
+	buf.WriteString(text[last:])
+	return buf.String()
+}
+
+func findReplaceKey(id, field string, index int) string {
+	return id + "/" + field + "/" + strconv.Itoa(index)
+}
+
+func findReplaceContext(text string, before bool) string {
+	runes := []rune(text)
+	if len(runes) <= findReplaceContextLen {
+		return text
+	}
+	if before {
+		return "..." + string(runes[len(runes)-findReplaceContextLen:])
+	}
+	return string(runes[:findReplaceContextLen]) + "..."
+}
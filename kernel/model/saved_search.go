@@ -0,0 +1,241 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// SavedSearch 描述了保存的搜索，保存在工作空间 data/storage/searches.json 中。
+type SavedSearch struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Query   string          `json:"query"`
+	Method  int             `json:"method"`  // 0：关键字，1：查询语法，2：SQL，3：正则表达式，4：模糊匹配
+	Box     string          `json:"box"`     // 限定笔记本
+	Path    string          `json:"path"`    // 限定文档路径
+	Types   map[string]bool `json:"types"`   // 限定块类型，为空时使用搜索设置
+	OrderBy int             `json:"orderBy"` // 0：相关度，1：更新时间降序，2：更新时间升序，3：创建时间降序，4：创建时间升序
+	Created string          `json:"created"`
+	Updated string          `json:"updated"`
+}
+
+// savedSearchEmbedPrefix 是使用保存的搜索作为嵌入块查询时的前缀，比如 {{savedsearch:20221018120000-abcdefg}}。
+const savedSearchEmbedPrefix = "savedsearch:"
+
+var savedSearchesLock = sync.Mutex{}
+
+func ListSavedSearches() (ret []*SavedSearch, err error) {
+	savedSearchesLock.Lock()
+	defer savedSearchesLock.Unlock()
+	return loadSavedSearches()
+}
+
+func GetSavedSearch(id string) (ret *SavedSearch, err error) {
+	savedSearchesLock.Lock()
+	defer savedSearchesLock.Unlock()
+
+	searches, err := loadSavedSearches()
+	if nil != err {
+		return
+	}
+	for _, s := range searches {
+		if id == s.ID {
+			ret = s
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf(Conf.Language(138), id))
+	return
+}
+
+// SaveSearch 保存搜索，search.ID 为空时新建。
+func SaveSearch(search *SavedSearch) (ret *SavedSearch, err error) {
+	search.Name = strings.TrimSpace(search.Name)
+	if "" == search.Name {
+		err = errors.New(Conf.Language(139))
+		return
+	}
+
+	savedSearchesLock.Lock()
+	defer savedSearchesLock.Unlock()
+
+	searches, err := loadSavedSearches()
+	if nil != err {
+		return
+	}
+
+	now := time.Now().Format("20060102150405")
+	search.Updated = now
+	if "" == search.ID {
+		search.ID = ast.NewNodeID()
+		search.Created = now
+		searches = append(searches, search)
+	} else {
+		found := false
+		for i, s := range searches {
+			if search.ID == s.ID {
+				search.Created = s.Created
+				searches[i] = search
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = errors.New(fmt.Sprintf(Conf.Language(138), search.ID))
+			return
+		}
+	}
+
+	if err = saveSavedSearches(searches); nil != err {
+		return
+	}
+	ret = search
+	return
+}
+
+func RemoveSavedSearch(id string) (err error) {
+	savedSearchesLock.Lock()
+	defer savedSearchesLock.Unlock()
+
+	searches, err := loadSavedSearches()
+	if nil != err {
+		return
+	}
+
+	var tmp []*SavedSearch
+	for _, s := range searches {
+		if id != s.ID {
+			tmp = append(tmp, s)
+		}
+	}
+	if len(tmp) == len(searches) {
+		return errors.New(fmt.Sprintf(Conf.Language(138), id))
+	}
+	return saveSavedSearches(tmp)
+}
+
+// ExecSavedSearch 执行保存的搜索，结果中排除 excludeIDs 中的块。
+func ExecSavedSearch(id string, excludeIDs []string, page *SearchPage) (ret []*Block, err error) {
+	search, err := GetSavedSearch(id)
+	if nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	ret, _, err = FullTextSearchBlock(search.Query, search.Box, search.Path, search.Types, excludeIDs, search.Method, search.OrderBy, page)
+	return
+}
+
+// savedSearchEmbedID 判断嵌入块查询 stmt 是否引用了保存的搜索，如果是则返回保存的搜索 ID。
+func savedSearchEmbedID(stmt string) (id string, ok bool) {
+	stmt = strings.TrimSpace(stmt)
+	if !strings.HasPrefix(strings.ToLower(stmt), savedSearchEmbedPrefix) {
+		return
+	}
+	return strings.TrimSpace(stmt[len(savedSearchEmbedPrefix):]), true
+}
+
+// savedSearchGraphFilter 返回将关系图限定在保存的搜索命中文档内的过滤条件。
+//
+// 关系图需要全部命中的文档，所以这里直接查询命中块所在的文档，不受搜索结果数限制。
+func savedSearchGraphFilter(id string) string {
+	search, err := GetSavedSearch(id)
+	if nil != err {
+		util.LogErrorf("get saved search [%s] for graph failed: %s", id, err)
+		return " AND 1 = 0"
+	}
+	from, where, err := searchFromWhere(search.Query, search.Box, search.Path, search.Types, search.Method)
+	if nil != err {
+		util.LogErrorf("exec saved search [%s] for graph failed: %s", id, err)
+		return " AND 1 = 0"
+	}
+
+	WaitForWritingFiles()
+	var rootIDs []string
+	err = sql.QueryEach("SELECT DISTINCT root_id FROM "+from+" WHERE "+where, func([]string) error { return nil }, func(vals []interface{}) error {
+		if rootID, ok := vals[0].(string); ok {
+			rootIDs = append(rootIDs, "'"+rootID+"'")
+		}
+		return nil
+	})
+	if nil != err {
+		util.LogErrorf("exec saved search [%s] for graph failed: %s", id, err)
+		return " AND 1 = 0"
+	}
+	if 1 > len(rootIDs) {
+		return " AND 1 = 0"
+	}
+	return " AND ref.root_id IN (" + strings.Join(rootIDs, ",") + ")"
+}
+
+func loadSavedSearches() (ret []*SavedSearch, err error) {
+	ret = []*SavedSearch{}
+	p := savedSearchesPath()
+	if !gulu.File.IsExist(p) {
+		return
+	}
+
+	data, err := filesys.LockFileRead(p)
+	if nil != err {
+		util.LogErrorf("read saved searches [%s] failed: %s", p, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		util.LogErrorf("unmarshal saved searches [%s] failed: %s", p, err)
+		return
+	}
+	return
+}
+
+func saveSavedSearches(searches []*SavedSearch) (err error) {
+	if nil == searches {
+		searches = []*SavedSearch{}
+	}
+
+	p := savedSearchesPath()
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		util.LogErrorf("create storage dir failed: %s", err)
+		return
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(searches, "", "  ")
+	if nil != err {
+		return
+	}
+	if err = filesys.LockFileWrite(p, data); nil != err {
+		util.LogErrorf("write saved searches [%s] failed: %s", p, err)
+		return
+	}
+	IncWorkspaceDataVer()
+	return
+}
+
+func savedSearchesPath() string {
+	return filepath.Join(util.DataDir, "storage", "searches.json")
+}
@@ -18,7 +18,13 @@ package model
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"path"
+	"regexp"
+	"regexp/syntax"
 	"strconv"
 	"strings"
 	"time"
@@ -34,23 +40,37 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
-	"github.com/xrash/smetrics"
 )
 
-func SearchEmbedBlock(stmt string, excludeIDs []string, headingMode int) (ret []*Block) {
+func SearchEmbedBlock(stmt string, excludeIDs []string, headingMode int, page *SearchPage) (ret []*Block) {
 	WaitForWritingFiles()
-	return searchEmbedBlock(stmt, excludeIDs, headingMode)
+	return searchEmbedBlock(stmt, excludeIDs, headingMode, page)
 }
 
-func searchEmbedBlock(stmt string, excludeIDs []string, headingMode int) (ret []*Block) {
-	sqlBlocks := sql.SelectBlocksRawStmtNoParse(stmt, Conf.Search.Limit)
-	var tmp []*sql.Block
-	for _, b := range sqlBlocks {
-		if !gulu.Str.Contains(b.ID, excludeIDs) {
-			tmp = append(tmp, b)
+func searchEmbedBlock(stmt string, excludeIDs []string, headingMode int, page *SearchPage) (ret []*Block) {
+	var sqlBlocks []*sql.Block
+	if id, ok := savedSearchEmbedID(stmt); ok {
+		// 使用保存的搜索作为嵌入块的数据源
+		blocks, err := ExecSavedSearch(id, excludeIDs, page)
+		if nil != err {
+			util.LogErrorf("exec saved search [%s] for embed block failed: %s", id, err)
+		}
+		for _, b := range blocks {
+			sqlBlocks = append(sqlBlocks, &sql.Block{ID: b.ID})
 		}
+	} else if nil != page && page.Paging {
+		// 分页时在查询中排除，这样每页的结果数和总数才是准确的
+		from, where := sqlSearchFromWhere(stmt, excludeIDs)
+		sqlBlocks, _ = searchBlocks("SELECT * FROM "+from+" WHERE "+where, 0, sqlSearchSortKeys, page)
+		page.paginate(from, where)
+	} else {
+		for _, b := range sql.SelectBlocksRawStmtNoParse(stmt, Conf.Search.Limit) {
+			if !gulu.Str.Contains(b.ID, excludeIDs) {
+				sqlBlocks = append(sqlBlocks, b)
+			}
+		}
+		page.paginate(sqlSearchFromWhere(stmt, excludeIDs))
 	}
-	sqlBlocks = tmp
 	for _, sb := range sqlBlocks {
 		block := getBlockRendered(sb.ID, headingMode)
 		if nil == block {
@@ -65,7 +85,7 @@ func searchEmbedBlock(stmt string, excludeIDs []string, headingMode int) (ret []
 	return
 }
 
-func SearchRefBlock(id, rootID, keyword string, beforeLen int) (ret []*Block, newDoc bool) {
+func SearchRefBlock(id, rootID, keyword string, beforeLen int, page *SearchPage) (ret []*Block, newDoc bool) {
 	if "" == keyword {
 		// 查询为空时默认的块引排序规则按最近使用优先 https://github.com/siyuan-note/siyuan/issues/3218
 		refs := sql.QueryRefsRecent()
@@ -88,35 +108,14 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int) (ret []*Block, ne
 		return
 	}
 
-	ret = fullTextSearchRefBlock(keyword, beforeLen)
-	tmp := ret[:0]
-	trees := map[string]*parse.Tree{}
+	ret = fullTextSearchRefBlock(keyword, refBlockExcludeIDs(id, rootID), beforeLen, page)
 	for _, b := range ret {
-		hitFirstChildID := false
 		b.RefText = b.Content
 		if b.IsContainerBlock() {
 			b.RefText = b.FContent // `((` 引用列表项时使用第一个子块作为动态锚文本 https://github.com/siyuan-note/siyuan/issues/4536
-
-			// `((` 引用候选中排除当前块的父块 https://github.com/siyuan-note/siyuan/issues/4538
-			tree := trees[b.RootID]
-			if nil == tree {
-				tree, _ = loadTreeByBlockID(b.RootID)
-				trees[b.RootID] = tree
-			}
-			if nil != tree {
-				bNode := treenode.GetNodeInTree(tree, b.ID)
-				if fc := treenode.FirstLeafBlock(bNode); nil != fc && fc.ID == id {
-					hitFirstChildID = true
-				}
-			}
-		}
-
-		if b.ID != id && !hitFirstChildID && b.ID != rootID {
-			b.Content = maxContent(b.Content, Conf.Editor.BlockRefDynamicAnchorTextMaxLen)
-			tmp = append(tmp, b)
 		}
+		b.Content = maxContent(b.Content, Conf.Editor.BlockRefDynamicAnchorTextMaxLen)
 	}
-	ret = tmp
 
 	if "" != keyword {
 		if block := treenode.GetBlockTree(id); nil != block {
@@ -127,11 +126,51 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int) (ret []*Block, ne
 	return
 }
 
-func FindReplace(keyword, replacement string, ids []string) (err error) {
-	keyword = strings.Trim(keyword, "\"") // FTS 字符串需要去除双引号
-	if keyword == replacement {
+// refBlockExcludeIDs 返回 `((` 引用候选中需要排除的块：当前块、当前文档以及第一个子块是当前块的父块 https://github.com/siyuan-note/siyuan/issues/4538
+func refBlockExcludeIDs(id, rootID string) (ret []string) {
+	ret = []string{id, rootID}
+	tree, _ := loadTreeByBlockID(id)
+	if nil == tree {
 		return
 	}
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		return
+	}
+	for parent := node.Parent; nil != parent && ast.NodeDocument != parent.Type; parent = parent.Parent {
+		if fc := treenode.FirstLeafBlock(parent); nil != fc && fc.ID == id {
+			ret = append(ret, parent.ID)
+		}
+	}
+	return
+}
+
+// notInIDs 返回排除块 ids 的查询条件，ids 为空时返回空字符串。
+func notInIDs(ids []string) string {
+	var quoted []string
+	for _, id := range ids {
+		if "" != id {
+			quoted = append(quoted, "'"+strings.ReplaceAll(id, "'", "''")+"'")
+		}
+	}
+	if 1 > len(quoted) {
+		return ""
+	}
+	return " AND id NOT IN (" + strings.Join(quoted, ", ") + ")"
+}
+
+func FindReplace(keyword, replacement string, ids []string, method int) (err error) {
+	var reg *regexp.Regexp
+	if SearchMethodRegex == method {
+		if reg, err = compileSearchRegexp(keyword); nil != err {
+			return
+		}
+	} else {
+		keyword = strings.Trim(keyword, "\"") // FTS 字符串需要去除双引号
+		if keyword == replacement {
+			return
+		}
+	}
 
 	ids = util.RemoveDuplicatedElem(ids)
 	for _, id := range ids {
@@ -154,11 +193,20 @@ func FindReplace(keyword, replacement string, ids []string) (err error) {
 			switch n.Type {
 			case ast.NodeDocument:
 				title := n.IALAttr("title")
-				if strings.Contains(title, keyword) {
+				if nil != reg {
+					// 正则替换支持使用 $1、${name} 引用捕获组
+					if reg.MatchString(title) {
+						n.SetIALAttr("title", reg.ReplaceAllString(title, replacement))
+					}
+				} else if strings.Contains(title, keyword) {
 					n.SetIALAttr("title", strings.ReplaceAll(title, keyword, replacement))
 				}
 			case ast.NodeText, ast.NodeLinkText, ast.NodeLinkTitle, ast.NodeCodeSpanContent, ast.NodeCodeBlockCode, ast.NodeInlineMathContent, ast.NodeMathBlockContent:
-				if bytes.Contains(n.Tokens, []byte(keyword)) {
+				if nil != reg {
+					if reg.Match(n.Tokens) {
+						n.Tokens = reg.ReplaceAll(n.Tokens, []byte(replacement))
+					}
+				} else if bytes.Contains(n.Tokens, []byte(keyword)) {
 					n.Tokens = bytes.ReplaceAll(n.Tokens, []byte(keyword), []byte(replacement))
 				}
 			}
@@ -180,13 +228,340 @@ func FindReplace(keyword, replacement string, ids []string) (err error) {
 	return
 }
 
-func FullTextSearchBlock(query, box, path string, types map[string]bool, querySyntax bool) (ret []*Block) {
+const (
+	SearchMethodKeyword     = 0 // 关键字
+	SearchMethodQuerySyntax = 1 // 查询语法
+	SearchMethodSQL         = 2 // SQL
+	SearchMethodRegex       = 3 // 正则表达式
+	SearchMethodFuzzy       = 4 // 模糊匹配
+)
+
+const (
+	SearchOrderByRank        = 0 // 相关度
+	SearchOrderByUpdatedDesc = 1 // 更新时间降序
+	SearchOrderByUpdatedAsc  = 2 // 更新时间升序
+	SearchOrderByCreatedDesc = 3 // 创建时间降序
+	SearchOrderByCreatedAsc  = 4 // 创建时间升序
+)
+
+// searchBlocks 在 inner 的结果上按 keys 查询一页块，inner 的结果列为块的所有字段加上 extras 个附加字段，返回块和对应的附加字段值。
+func searchBlocks(inner string, extras int, keys []*searchSortKey, page *SearchPage) (blocks []*sql.Block, extraVals [][]interface{}) {
+	stmt, args := page.stmt(inner, keys)
+	blocks, vals := sql.SelectBlocksWithExtrasRawStmt(stmt, extras+len(keys), args...)
+	var keyVals [][]interface{}
+	for _, v := range vals {
+		extraVals = append(extraVals, v[:extras])
+		keyVals = append(keyVals, v[extras:])
+	}
+	got := page.next(keyVals)
+	blocks, extraVals = blocks[:got], extraVals[:got]
+	return
+}
+
+// searchSortKey 描述了搜索结果的一个排序键，name 为查询结果中的列名。
+type searchSortKey struct {
+	name string
+	desc bool
+}
+
+// searchSortKeys 返回 orderBy 对应的排序键，按相关度排序时使用 rank。
+//
+// 分页游标需要唯一确定上一页最后一个结果的位置，所以最后总是按块 ID 排序。
+func searchSortKeys(orderBy int, rank ...*searchSortKey) (ret []*searchSortKey) {
+	switch orderBy {
+	case SearchOrderByUpdatedDesc:
+		ret = []*searchSortKey{{name: "updated", desc: true}}
+	case SearchOrderByUpdatedAsc:
+		ret = []*searchSortKey{{name: "updated"}}
+	case SearchOrderByCreatedDesc:
+		ret = []*searchSortKey{{name: "created", desc: true}}
+	case SearchOrderByCreatedAsc:
+		ret = []*searchSortKey{{name: "created"}}
+	default:
+		ret = append(ret, rank...)
+	}
+	return append(ret, &searchSortKey{name: "id"})
+}
+
+// SearchPage 描述了搜索结果的分页游标和命中统计，为 nil 时不分页，仅返回前 Conf.Search.Limit 个结果。
+//
+// 游标记录了上一页最后一个结果的排序键，下一页从该位置之后开始查询（keyset 分页），所以翻页期间有块新增或者删除时已经返回的结果不会重复，之后的结果也不会遗漏。
+type SearchPage struct {
+	Cursor     string       `json:"-"`          // 当前页游标，为空表示第一页
+	Paging     bool         `json:"-"`          // 是否传入了游标，未传入时 SQL 查询保持语句中的排序且不分页
+	GroupByDoc bool         `json:"-"`          // 是否按文档分组统计命中数
+	NextCursor string       `json:"nextCursor"` // 下一页游标，为空表示没有更多结果
+	Total      int          `json:"total"`      // 命中总数
+	Docs       []*SearchDoc `json:"docs"`       // 按文档分组的命中数
+
+	now     string        // 计算相关度时使用的当前时间，翻页时保持不变，这样同一个块在各页的得分是一致的
+	after   []interface{} // 上一页最后一个结果的排序键，为空表示第一页
+	anchors []string      // 上一页最后几个结果的 ID，从最后一个开始
+}
+
+// searchCursor 是分页游标编码前的内容。
+type searchCursor struct {
+	Now     string        `json:"now"`
+	After   []interface{} `json:"after"`
+	Anchors []string      `json:"anchors,omitempty"`
+}
+
+const maxSearchCursorAnchors = 16 // 分页游标中最多记录的上一页结果数
+
+// SearchDoc 描述了某个文档下的命中数。
+type SearchDoc struct {
+	RootID string `json:"rootID"`
+	Box    string `json:"box"`
+	HPath  string `json:"hPath"`
+	Count  int    `json:"count"`
+}
+
+const maxSearchDocs = 256 // 按文档分组时最多返回的文档数
+
+// NewSearchPage 解析分页游标 cursor，cursor 为空表示第一页，游标不是上一页返回的 nextCursor 时返回错误。
+func NewSearchPage(cursor string) (ret *SearchPage, err error) {
+	ret = &SearchPage{Cursor: cursor, Docs: []*SearchDoc{}}
+	if "" == cursor {
+		ret.now = time.Now().UTC().Format("2006-01-02 15:04:05")
+		return
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf("invalid cursor [%s]", cursor))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	c := &searchCursor{}
+	if err = decoder.Decode(c); nil != err || "" == c.Now || 1 > len(c.After) {
+		return nil, errors.New(fmt.Sprintf("invalid cursor [%s]", cursor))
+	}
+	ret.now = c.Now
+	ret.anchors = c.Anchors
+	for _, v := range c.After {
+		switch v := v.(type) {
+		case string:
+			ret.after = append(ret.after, v)
+		case json.Number:
+			// 绑定参数时需要保持数值类型，否则 SQLite 会按文本和数值比较
+			if i, intErr := v.Int64(); nil == intErr {
+				ret.after = append(ret.after, i)
+			} else if f, floatErr := v.Float64(); nil == floatErr {
+				ret.after = append(ret.after, f)
+			} else {
+				return nil, errors.New(fmt.Sprintf("invalid cursor [%s]", cursor))
+			}
+		default:
+			return nil, errors.New(fmt.Sprintf("invalid cursor [%s]", cursor))
+		}
+	}
+	return
+}
+
+// scoreTime 返回计算相关度时使用的当前时间。
+func (page *SearchPage) scoreTime() string {
+	if nil == page || "" == page.now {
+		return "now"
+	}
+	return page.now
+}
+
+// stmt 返回在 inner 的结果上按 keys 排序并从游标位置开始取一页的查询语句和查询参数。
+//
+// inner 的结果列为块的所有字段以及附加字段，返回的查询在这些列之后再追加 keys 对应的列，用于计算下一页游标。
+// 多取一个结果用于判断是否还有下一页，由 next 去掉。
+//
+// 游标位置使用上一页最后几个块中第一个仍然在结果中的块当前的排序键：索引变化后 bm25() 的得分会整体变化，使用游标中记录的得分会跳过或者重复结果。
+// 这些块都已经不在结果中时才使用游标中记录的排序键。
+func (page *SearchPage) stmt(inner string, keys []*searchSortKey) (stmt string, args []interface{}) {
+	var keyCols, orders []string
+	for _, key := range keys {
+		keyCols = append(keyCols, "r."+key.name)
+		order := "r." + key.name + " ASC"
+		if key.desc {
+			order = "r." + key.name + " DESC"
+		}
+		orders = append(orders, order)
+	}
+
+	stmt = "WITH r AS (" + inner + ")"
+	from := " FROM r"
+	limit := Conf.Search.Limit
+	if nil != page {
+		if 0 < len(page.after) {
+			if len(keys) != len(page.after) {
+				// 游标不是使用当前的排序方式生成的
+				from += " WHERE 1 = 0"
+			} else {
+				var anchorCols, afterCols, anchors []string
+				for _, key := range keys {
+					anchorCols = append(anchorCols, "r."+key.name+" AS "+key.name)
+					afterCols = append(afterCols, "? AS "+key.name)
+				}
+				for i, anchor := range page.anchors {
+					anchors = append(anchors, "(?, "+strconv.Itoa(i)+")")
+					args = append(args, anchor)
+				}
+				stmt += ", c AS ("
+				if 0 < len(anchors) {
+					stmt += "SELECT " + strings.Join(anchorCols, ", ") + ", a.column2 AS p FROM r JOIN (VALUES " + strings.Join(anchors, ", ") + ") AS a ON r.id = a.column1 UNION ALL "
+				}
+				stmt += "SELECT " + strings.Join(afterCols, ", ") + ", " + strconv.Itoa(len(anchors)) + " AS p ORDER BY p LIMIT 1)"
+				args = append(args, page.after...)
+				from += ", c WHERE " + afterCond(keys)
+			}
+		}
+		limit++
+	}
+	stmt += " SELECT r.*, " + strings.Join(keyCols, ", ") + from + " ORDER BY " + strings.Join(orders, ", ") + " LIMIT " + strconv.Itoa(limit)
+	return
+}
+
+// afterCond 返回 r 中排在游标位置 c 之后的条件，即 (r.k0 > c.k0) OR (r.k0 = c.k0 AND r.k1 > c.k1) OR ...，降序的键使用 <。
+func afterCond(keys []*searchSortKey) string {
+	var ors []string
+	for i, key := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, "r."+keys[j].name+" = c."+keys[j].name)
+		}
+		op := " > "
+		if key.desc {
+			op = " < "
+		}
+		ands = append(ands, "r."+key.name+op+"c."+key.name)
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+// next 根据 stmt 查询到的每个结果的排序键 keys 计算下一页游标，返回当前页的结果数。
+func (page *SearchPage) next(keys [][]interface{}) (got int) {
+	got = len(keys)
+	if nil == page || got <= Conf.Search.Limit {
+		return
+	}
+
+	got = Conf.Search.Limit
+	c := &searchCursor{Now: page.now, After: keys[got-1]}
+	for i := got - 1; 0 <= i && len(c.Anchors) < maxSearchCursorAnchors; i-- {
+		if id, ok := keys[i][len(keys[i])-1].(string); ok {
+			c.Anchors = append(c.Anchors, id)
+		}
+	}
+	data, err := json.Marshal(c)
+	if nil != err {
+		util.LogErrorf("marshal search cursor failed: %s", err)
+		return
+	}
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	return
+}
+
+// paginate 统计 from 中满足 where 条件的命中总数，按文档分组时统计每个文档下的命中数。
+func (page *SearchPage) paginate(from, where string) {
+	if nil == page {
+		return
+	}
+
+	if result, err := sql.Query("SELECT COUNT(*) AS total FROM " + from + " WHERE " + where); nil == err && 0 < len(result) {
+		if count, ok := result[0]["total"].(int64); ok {
+			page.Total = int(count)
+		}
+	}
+
+	if !page.GroupByDoc {
+		return
+	}
+	result, err := sql.Query("SELECT root_id, COUNT(*) AS count FROM " + from + " WHERE " + where + " GROUP BY root_id ORDER BY count DESC LIMIT " + strconv.Itoa(maxSearchDocs))
+	if nil != err {
+		return
+	}
+	for _, row := range result {
+		rootID, _ := row["root_id"].(string)
+		count, _ := row["count"].(int64)
+		doc := &SearchDoc{RootID: rootID, Count: int(count)}
+		if root := sql.GetBlock(rootID); nil != root {
+			doc.Box = root.Box
+			doc.HPath = root.HPath
+		}
+		page.Docs = append(page.Docs, doc)
+	}
+}
+
+// FullTextSearchBlock 搜索块。
+//
+// 模糊匹配时会将查询中的每个词扩展为拼写相近的词；模糊匹配或者关键字搜索没有结果时，suggestions 返回纠正拼写后的查询。
+func FullTextSearchBlock(query, box, path string, types map[string]bool, excludeIDs []string, method, orderBy int, page *SearchPage) (ret []*Block, suggestions []string, err error) {
 	query = strings.TrimSpace(query)
-	if queryStrLower := strings.ToLower(query); strings.Contains(queryStrLower, "select ") && strings.Contains(queryStrLower, " * ") && strings.Contains(queryStrLower, " from ") {
-		ret = searchBySQL(query, 12)
-	} else {
+	suggestions = []string{}
+	switch method {
+	case SearchMethodSQL:
+		ret = searchBySQL(query, excludeIDs, 12, page)
+	case SearchMethodRegex:
+		filter := searchFilter(types)
+		ret, err = regexSearch(query, box, path, filter, excludeIDs, 12, orderBy, page)
+	case SearchMethodFuzzy:
+		vocabulary, _ := vocabularies(true)
 		filter := searchFilter(types)
-		ret = fullTextSearch(query, box, path, filter, 12, querySyntax)
+		fuzzy := fuzzyQuery(query, vocabulary)
+		if "" == fuzzy {
+			fuzzy = stringQuery(query)
+		}
+		ret = fullTextSearch(fuzzy, box, path, filter, excludeIDs, 12, true, orderBy, page)
+		suggestions = didYouMean(query, vocabulary)
+	default:
+		if queryStrLower := strings.ToLower(query); strings.Contains(queryStrLower, "select ") && strings.Contains(queryStrLower, " * ") && strings.Contains(queryStrLower, " from ") {
+			ret = searchBySQL(query, excludeIDs, 12, page)
+		} else {
+			filter := searchFilter(types)
+			ret = fullTextSearch(query, box, path, filter, excludeIDs, 12, SearchMethodQuerySyntax == method, orderBy, page)
+			if 1 > len(ret) && SearchMethodKeyword == method {
+				// 关键字搜索时输入频繁，词表尚未构建时不等待
+				vocabulary, _ := vocabularies(false)
+				suggestions = didYouMean(query, vocabulary)
+			}
+		}
+	}
+	return
+}
+
+// searchFromWhere 返回搜索使用的表（或者子查询）和查询条件，不包含排序和分页，用于获取全部搜索结果。
+func searchFromWhere(query, box, path string, types map[string]bool, method int) (from, where string, err error) {
+	query = strings.TrimSpace(query)
+	isSQL := SearchMethodSQL == method
+	if !isSQL && SearchMethodRegex != method && SearchMethodFuzzy != method {
+		queryStrLower := strings.ToLower(query)
+		isSQL = strings.Contains(queryStrLower, "select ") && strings.Contains(queryStrLower, " * ") && strings.Contains(queryStrLower, " from ")
+	}
+	if isSQL {
+		from = "(" + strings.TrimSuffix(util.RemoveInvisible(query), ";") + ")"
+		where = "1 = 1"
+		return
+	}
+
+	filter := searchFilter(types)
+	switch method {
+	case SearchMethodRegex:
+		var reg *regexp.Regexp
+		if reg, err = compileSearchRegexp(util.RemoveInvisible(query)); nil != err {
+			return
+		}
+		from, where = regexSearchWhere(reg, box, path, filter, nil)
+	case SearchMethodFuzzy:
+		vocabulary, _ := vocabularies(true)
+		fuzzy := fuzzyQuery(query, vocabulary)
+		if "" == fuzzy {
+			fuzzy = stringQuery(query)
+		}
+		from, where = fullTextSearchWhere(util.RemoveInvisible(fuzzy), box, path, filter, nil, true)
+	default:
+		query = util.RemoveInvisible(query)
+		if util.IsIDPattern(query) {
+			from, where = "blocks", "id = '"+query+"'"
+			return
+		}
+		from, where = fullTextSearchWhere(query, box, path, filter, nil, SearchMethodQuerySyntax == method)
 	}
 	return
 }
@@ -224,9 +599,28 @@ func searchFilter(types map[string]bool) string {
 	return s.TypeFilter()
 }
 
-func searchBySQL(stmt string, beforeLen int) (ret []*Block) {
+// sqlSearchSortKeys 是 SQL 查询分页时使用的排序键。查询语句中的排序无法用于定位游标，所以分页时按块 ID 排序。
+var sqlSearchSortKeys = []*searchSortKey{{name: "id"}}
+
+// sqlSearchFromWhere 返回将 SQL 查询语句 stmt 作为子查询并排除 excludeIDs 的表和查询条件。
+func sqlSearchFromWhere(stmt string, excludeIDs []string) (from, where string) {
+	from = "(" + strings.TrimSuffix(strings.TrimSpace(stmt), ";") + ")"
+	where = "1 = 1" + notInIDs(excludeIDs)
+	return
+}
+
+func searchBySQL(stmt string, excludeIDs []string, beforeLen int, page *SearchPage) (ret []*Block) {
 	stmt = util.RemoveInvisible(stmt)
-	blocks := sql.SelectBlocksRawStmt(stmt, Conf.Search.Limit)
+	var blocks []*sql.Block
+	from, where := sqlSearchFromWhere(stmt, excludeIDs)
+	if nil != page && page.Paging {
+		blocks, _ = searchBlocks("SELECT * FROM "+from+" WHERE "+where, 0, sqlSearchSortKeys, page)
+	} else if 1 > len(excludeIDs) {
+		blocks = sql.SelectBlocksRawStmt(stmt, Conf.Search.Limit)
+	} else {
+		blocks = sql.SelectBlocksRawStmtNoParse("SELECT * FROM "+from+" WHERE "+where+" LIMIT "+strconv.Itoa(Conf.Search.Limit), Conf.Search.Limit)
+	}
+	page.paginate(from, where)
 	ret = fromSQLBlocks(&blocks, "", beforeLen)
 	if 1 > len(ret) {
 		ret = []*Block{}
@@ -234,11 +628,11 @@ func searchBySQL(stmt string, beforeLen int) (ret []*Block) {
 	return
 }
 
-func fullTextSearchRefBlock(keyword string, beforeLen int) (ret []*Block) {
+func fullTextSearchRefBlock(keyword string, excludeIDs []string, beforeLen int, page *SearchPage) (ret []*Block) {
 	keyword = util.RemoveInvisible(keyword)
 
 	if util.IsIDPattern(keyword) {
-		ret = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+keyword+"'", 12)
+		ret = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+keyword+"'", excludeIDs, 12, page)
 		return
 	}
 
@@ -256,8 +650,8 @@ func fullTextSearchRefBlock(keyword string, beforeLen int) (ret []*Block) {
 		"tag, " +
 		"highlight(" + table + ", 11, '__@mark__', '__mark@__') AS content, " +
 		"fcontent, markdown, length, type, subtype, ial, sort, created, updated"
-	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(" + quotedKeyword + ")' AND type IN " + Conf.Search.TypeFilter()
-	orderBy := ` order by case
+	where := table + " MATCH '" + columnFilter() + ":(" + quotedKeyword + ")' AND type IN " + Conf.Search.TypeFilter() + notInIDs(excludeIDs)
+	refRank := `case
              when name = '${keyword}' then 10
              when alias = '${keyword}' then 20
              when memo = '${keyword}' then 30
@@ -271,10 +665,11 @@ func fullTextSearchRefBlock(keyword string, beforeLen int) (ret []*Block) {
              when fcontent LIKE '%${keyword}%' and type = 'i' then 81
              when memo LIKE '%${keyword}%' then 90
              when content LIKE '%${keyword}%' and type != 'i' and type != 'l' then 100
-             else 65535 end ASC, sort ASC, length ASC`
-	orderBy = strings.ReplaceAll(orderBy, "${keyword}", keyword)
-	stmt += orderBy + " LIMIT " + strconv.Itoa(Conf.Search.Limit)
-	blocks := sql.SelectBlocksRawStmt(stmt, Conf.Search.Limit)
+             else 65535 end`
+	refRank = strings.ReplaceAll(refRank, "${keyword}", strings.ReplaceAll(keyword, "'", "''"))
+	inner := "SELECT " + projections + ", " + refRank + " AS ref_rank FROM " + table + " WHERE " + where
+	blocks, _ := searchBlocks(inner, 1, []*searchSortKey{{name: "ref_rank"}, {name: "sort"}, {name: "length"}, {name: "id"}}, page)
+	page.paginate(table, where)
 	ret = fromSQLBlocks(&blocks, "", beforeLen)
 	if 1 > len(ret) {
 		ret = []*Block{}
@@ -282,21 +677,14 @@ func fullTextSearchRefBlock(keyword string, beforeLen int) (ret []*Block) {
 	return
 }
 
-func fullTextSearch(query, box, path, filter string, beforeLen int, querySyntax bool) (ret []*Block) {
+func fullTextSearch(query, box, path, filter string, excludeIDs []string, beforeLen int, querySyntax bool, orderBy int, page *SearchPage) (ret []*Block) {
 	query = util.RemoveInvisible(query)
 	if util.IsIDPattern(query) {
-		ret = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen)
+		ret = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", excludeIDs, beforeLen, page)
 		return
 	}
 
-	if !querySyntax {
-		query = stringQuery(query)
-	}
-
-	table := "blocks_fts" // 大小写敏感
-	if !Conf.Search.CaseSensitive {
-		table = "blocks_fts_case_insensitive"
-	}
+	table, where := fullTextSearchWhere(query, box, path, filter, excludeIDs, querySyntax)
 	projections := "id, parent_id, root_id, hash, box, path, " +
 		"highlight(" + table + ", 6, '__@mark__', '__mark@__') AS hpath, " +
 		"highlight(" + table + ", 7, '__@mark__', '__mark@__') AS name, " +
@@ -305,22 +693,189 @@ func fullTextSearch(query, box, path, filter string, beforeLen int, querySyntax
 		"tag, " +
 		"highlight(" + table + ", 11, '__@mark__', '__mark@__') AS content, " +
 		"fcontent, markdown, length, type, subtype, ial, sort, created, updated"
-	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(" + query + ")' AND type IN " + filter
+	projections += ", " + searchScore(table, page.scoreTime()) + " AS score"
+	inner := "SELECT " + projections + " FROM " + table + " WHERE " + where
+	blocks, extras := searchBlocks(inner, 1, searchSortKeys(orderBy, &searchSortKey{name: "score", desc: true}, &searchSortKey{name: "sort"}), page)
+	page.paginate(table, where)
+	ret = fromSQLBlocks(&blocks, "", beforeLen)
+	for i, b := range ret {
+		if score, ok := extras[i][0].(float64); ok && nil != b {
+			b.Score = score
+		}
+	}
+	if 1 > len(ret) {
+		ret = []*Block{}
+	}
+	return
+}
+
+// fullTextSearchWhere 返回全文搜索使用的表和查询条件。
+func fullTextSearchWhere(query, box, path, filter string, excludeIDs []string, querySyntax bool) (table, where string) {
+	if !querySyntax {
+		query = stringQuery(query)
+	}
+
+	table = "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+	where = table + " MATCH '" + columnFilter() + ":(" + query + ")' AND type IN " + filter
 	if "" != box {
-		stmt += " AND box = '" + box + "'"
+		where += " AND box = '" + box + "'"
 	}
 	if "" != path {
-		stmt += " AND path LIKE '" + path + "%'"
+		where += " AND path LIKE '" + path + "%'"
+	}
+	where += notInIDs(excludeIDs)
+	return
+}
+
+// searchScore 返回全文搜索结果的相关度得分表达式，得分越大越相关。
+//
+// 得分基于 bm25() 按字段加权计算，文档标题按标题权重加权，再根据最近更新时间（相对于 now）和被引用次数进行提升。
+func searchScore(table, now string) string {
+	w := Conf.Search.Weight
+	titleFactor := strconv.FormatFloat(w.TitleFactor(), 'f', -1, 64)
+	recent := strconv.FormatFloat(w.Recent, 'f', -1, 64)
+	ref := strconv.FormatFloat(w.Ref, 'f', -1, 64)
+	updatedDays := "(julianday('" + now + "') - julianday(substr(updated, 1, 4) || '-' || substr(updated, 5, 2) || '-' || substr(updated, 7, 2)))"
+	refCount := "(SELECT COUNT(*) FROM refs WHERE def_block_id = " + table + ".id)" // 只统计命中的块，refs 在 def_block_id 上有索引
+	return "(-bm25(" + table + ", " + w.BM25() + ")" +
+		" * (CASE WHEN type = 'd' THEN " + titleFactor + " ELSE 1 END)" +
+		" * (1 + IFNULL(" + recent + " / (1 + MAX(" + updatedDays + ", 0) / 30.0), 0))" + // 30 天内更新的块提升明显，之后逐渐衰减
+		" * (1 + " + ref + " * " + refCount + " / (" + refCount + " + 5.0)))" // 被引用越多提升越多，最多提升 ref 倍
+}
+
+// SearchMatch 描述了正则表达式在块内容中的一处命中，偏移是去掉 <mark> 标签后返回的块内容（已经转义）中的字符（rune）位置。
+type SearchMatch struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func regexSearch(exp, box, path, filter string, excludeIDs []string, beforeLen, orderBy int, page *SearchPage) (ret []*Block, err error) {
+	exp = util.RemoveInvisible(exp)
+	reg, err := compileSearchRegexp(exp)
+	if nil != err {
+		return
+	}
+
+	table, where := regexSearchWhere(reg, box, path, filter, excludeIDs)
+	sqlBlocks, _ := searchBlocks("SELECT * FROM "+table+" WHERE "+where, 0, searchSortKeys(orderBy, &searchSortKey{name: "sort"}, &searchSortKey{name: "updated", desc: true}), page)
+	page.paginate(table, where)
+	for _, sqlBlock := range sqlBlocks {
+		sqlBlock.Content = search.EncloseRegexpHighlighting(sqlBlock.Content, reg, "__@mark__", "__mark@__")
+		block := fromSQLBlock(sqlBlock, "", beforeLen)
+		block.Matches = markedMatches(block.Content)
+		ret = append(ret, block)
 	}
-	stmt += " ORDER BY sort ASC, rank ASC LIMIT " + strconv.Itoa(Conf.Search.Limit)
-	blocks := sql.SelectBlocksRawStmt(stmt, Conf.Search.Limit)
-	ret = fromSQLBlocks(&blocks, "", beforeLen)
 	if 1 > len(ret) {
 		ret = []*Block{}
 	}
 	return
 }
 
+// regexSearchWhere 返回正则表达式搜索使用的表和查询条件。
+//
+// 正则表达式中有必须出现的字面量时先通过全文索引找到 content 中包含这些字面量的候选，再在候选的 content 和 markdown 上执行正则匹配，
+// 没有可用的字面量时才扫描全部块。全文索引不包含 markdown 列，所以有字面量时只出现在 markdown 中的字面量（比如链接地址）不会命中。
+func regexSearchWhere(reg *regexp.Regexp, box, path, filter string, excludeIDs []string) (table, where string) {
+	table = "blocks"
+	var ftsCond string
+	if literals, foldCase := regexpLiterals(reg.String()); 0 < len(literals) {
+		table = "blocks_fts" // 大小写敏感
+		if foldCase {
+			table = "blocks_fts_case_insensitive"
+		}
+		var phrases []string
+		for _, literal := range literals {
+			phrases = append(phrases, "\""+strings.ReplaceAll(literal, "\"", "\"\"")+"\"")
+		}
+		ftsCond = table + " MATCH '{content}:(" + strings.ReplaceAll(strings.Join(phrases, " AND "), "'", "''") + ")' AND "
+	}
+
+	quotedExp := strings.ReplaceAll(reg.String(), "'", "''")
+	where = ftsCond + "(content REGEXP '" + quotedExp + "' OR markdown REGEXP '" + quotedExp + "') AND type IN " + filter
+	if "" != box {
+		where += " AND box = '" + box + "'"
+	}
+	if "" != path {
+		where += " AND path LIKE '" + path + "%'"
+	}
+	where += notInIDs(excludeIDs)
+	return
+}
+
+// compileSearchRegexp 编译搜索使用的正则表达式，未开启大小写敏感时忽略大小写。
+func compileSearchRegexp(exp string) (ret *regexp.Regexp, err error) {
+	if "" == exp {
+		return nil, errors.New(Conf.Language(152))
+	}
+	if !Conf.Search.CaseSensitive && !strings.HasPrefix(exp, "(?i)") {
+		exp = "(?i)" + exp
+	}
+	if ret, err = regexp.Compile(exp); nil != err {
+		util.LogWarnf("compile regexp [%s] failed: %s", exp, err)
+		err = errors.New(fmt.Sprintf(Conf.Language(136), err))
+	}
+	return
+}
+
+// markedMatches 返回 content 中使用 <mark> 标记的命中的位置，偏移是去掉 <mark> 标签后的 content 中的字符（rune）位置。
+func markedMatches(content string) (ret []*SearchMatch) {
+	ret = []*SearchMatch{}
+	offset := 0
+	for {
+		i := strings.Index(content, "<mark>")
+		if 0 > i {
+			return
+		}
+		start := offset + utf8.RuneCountInString(content[:i])
+		content = content[i+len("<mark>"):]
+		j := strings.Index(content, "</mark>")
+		if 0 > j {
+			// 内容过长被截断时最后一个命中可能没有结束标记
+			return
+		}
+		end := start + utf8.RuneCountInString(content[:j])
+		ret = append(ret, &SearchMatch{Start: start, End: end})
+		content = content[j+len("</mark>"):]
+		offset = end
+	}
+}
+
+// regexpLiterals 提取正则表达式命中时必须出现的字面量。foldCase 为 true 表示字面量需要忽略大小写匹配。
+func regexpLiterals(exp string) (ret []string, foldCase bool) {
+	re, err := syntax.Parse(exp, syntax.Perl)
+	if nil != err {
+		return
+	}
+
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			if literal := strings.TrimSpace(string(re.Rune)); "" != literal {
+				ret = append(ret, literal)
+				if 0 != re.Flags&syntax.FoldCase {
+					foldCase = true
+				}
+			}
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				walk(sub)
+			}
+		case syntax.OpCapture, syntax.OpPlus:
+			walk(re.Sub[0])
+		case syntax.OpRepeat:
+			if 0 < re.Min {
+				walk(re.Sub[0])
+			}
+		}
+	}
+	walk(re)
+	return
+}
+
 func query2Stmt(queryStr string) (ret string) {
 	buf := bytes.Buffer{}
 	if util.IsIDPattern(queryStr) {
@@ -383,7 +938,10 @@ func query2Stmt(queryStr string) (ret string) {
 	return
 }
 
-func markSearch(text string, keyword string, beforeLen int) (pos int, marked string, score float64) {
+// markSearch 使用 <mark> 标记 text 中命中 keyword 的部分，keyword 为空时标记 text 中已有的 __@mark__ 和 __mark@__。
+//
+// 搜索结果的排序由查询中的相关度得分决定，这里只负责高亮。
+func markSearch(text string, keyword string, beforeLen int) (pos int, marked string) {
 	if 0 == len(keyword) {
 		marked = text
 		if maxLen := 5120; maxLen < utf8.RuneCountInString(marked) {
@@ -396,15 +954,6 @@ func markSearch(text string, keyword string, beforeLen int) (pos int, marked str
 	}
 
 	pos, marked = search.MarkText(text, keyword, beforeLen, Conf.Search.CaseSensitive)
-	if -1 < pos {
-		if 0 == pos {
-			score = 1
-		}
-		score += float64(strings.Count(marked, "<mark>"))
-		winkler := smetrics.JaroWinkler(text, keyword, 0.7, 4)
-		score += winkler
-	}
-	score = -score // 分越小排序越靠前
 	return
 }
 
@@ -424,7 +973,7 @@ func fromSQLBlock(sqlBlock *sql.Block, terms string, beforeLen int) (block *Bloc
 	content := sqlBlock.Content
 	p := sqlBlock.Path
 
-	_, content, _ = markSearch(content, terms, beforeLen)
+	_, content = markSearch(content, terms, beforeLen)
 	markdown := maxContent(sqlBlock.Markdown, 5120)
 	content = maxContent(content, 5120)
 
@@ -454,20 +1003,20 @@ func fromSQLBlock(sqlBlock *sql.Block, terms string, beforeLen int) (block *Bloc
 		}
 	}
 
-	_, hPath, _ := markSearch(sqlBlock.HPath, terms, 18)
+	_, hPath := markSearch(sqlBlock.HPath, terms, 18)
 	if !strings.HasPrefix(hPath, "/") {
 		hPath = "/" + hPath
 	}
 	block.HPath = hPath
 
 	if "" != block.Name {
-		_, block.Name, _ = markSearch(block.Name, terms, 256)
+		_, block.Name = markSearch(block.Name, terms, 256)
 	}
 	if "" != block.Alias {
-		_, block.Alias, _ = markSearch(block.Alias, terms, 256)
+		_, block.Alias = markSearch(block.Alias, terms, 256)
 	}
 	if "" != block.Memo {
-		_, block.Memo, _ = markSearch(block.Memo, terms, 256)
+		_, block.Memo = markSearch(block.Memo, terms, 256)
 	}
 	return
 }
@@ -0,0 +1,253 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestMarkedMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []*SearchMatch
+	}{
+		{"empty", "", []*SearchMatch{}},
+		{"no mark", "foo bar", []*SearchMatch{}},
+		{"one", "a <mark>bc</mark> d", []*SearchMatch{{Start: 2, End: 4}}},
+		{"rune offsets", "<mark>思源</mark>笔记<mark>笔</mark>", []*SearchMatch{{Start: 0, End: 2}, {Start: 4, End: 5}}},
+		{"adjacent", "<mark>a</mark><mark>b</mark>", []*SearchMatch{{Start: 0, End: 1}, {Start: 1, End: 2}}},
+		{"truncated", "<mark>a</mark> b <mark>c", []*SearchMatch{{Start: 0, End: 1}}},
+	}
+	for _, test := range tests {
+		if got := markedMatches(test.content); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRegexpLiterals(t *testing.T) {
+	tests := []struct {
+		exp      string
+		want     []string
+		foldCase bool
+	}{
+		{"foo", []string{"foo"}, false},
+		{"(?i)foo", []string{"FOO"}, true}, // 忽略大小写时字面量为大写形式
+		{"foo.*bar", []string{"foo", "bar"}, false},
+		{"foo bar", []string{"foo bar"}, false},
+		{"^(foo)+\\d{2}$", []string{"foo"}, false},
+		{"(foo){2,}", []string{"foo"}, false},
+		{"(foo)?bar", []string{"bar"}, false},
+		{"(foo){0,2}", nil, false},
+		{"foo|bar", nil, false},
+		{"\\d+", nil, false},
+		{"   ", nil, false},
+		{"[", nil, false},
+	}
+	for _, test := range tests {
+		got, foldCase := regexpLiterals(test.exp)
+		if !reflect.DeepEqual(test.want, got) || test.foldCase != foldCase {
+			t.Errorf("regexpLiterals(%q): got %q %v, want %q %v", test.exp, got, foldCase, test.want, test.foldCase)
+		}
+	}
+}
+
+func TestRegexSearchWhere(t *testing.T) {
+	tests := []struct {
+		name       string
+		exp        string
+		box, path  string
+		excludeIDs []string
+		table      string
+		where      string
+	}{
+		{"no literal", `\d+`, "", "", nil, "blocks", `(content REGEXP '\d+' OR markdown REGEXP '\d+') AND type IN ('p')`},
+		{"literals", "foo.*bar", "", "", nil, "blocks_fts", `blocks_fts MATCH '{content}:("foo" AND "bar")' AND (content REGEXP 'foo.*bar' OR markdown REGEXP 'foo.*bar') AND type IN ('p')`},
+		{"fold case", "(?i)it's", "", "", nil, "blocks_fts_case_insensitive", `blocks_fts_case_insensitive MATCH '{content}:("IT''S")' AND (content REGEXP '(?i)it''s' OR markdown REGEXP '(?i)it''s') AND type IN ('p')`},
+		{"quote", `say "hi"`, "", "", nil, "blocks_fts", `blocks_fts MATCH '{content}:("say ""hi""")' AND (content REGEXP 'say "hi"' OR markdown REGEXP 'say "hi"') AND type IN ('p')`},
+		{"scope", `\d+`, "20210808180117-6v0mkxr", "/20200812220555-lj3enxa", []string{"20210808180117-czj9bvb"}, "blocks", `(content REGEXP '\d+' OR markdown REGEXP '\d+') AND type IN ('p') AND box = '20210808180117-6v0mkxr' AND path LIKE '/20200812220555-lj3enxa%' AND id NOT IN ('20210808180117-czj9bvb')`},
+	}
+	for _, test := range tests {
+		table, where := regexSearchWhere(regexp.MustCompile(test.exp), test.box, test.path, "('p')", test.excludeIDs)
+		if test.table != table || test.where != where {
+			t.Errorf("%s: got %s %s, want %s %s", test.name, table, where, test.table, test.where)
+		}
+	}
+}
+
+func TestSearchSortKeys(t *testing.T) {
+	rank := &searchSortKey{name: "score"}
+	tests := []struct {
+		orderBy int
+		want    []*searchSortKey
+	}{
+		{SearchOrderByRank, []*searchSortKey{rank, {name: "id"}}},
+		{SearchOrderByUpdatedDesc, []*searchSortKey{{name: "updated", desc: true}, {name: "id"}}},
+		{SearchOrderByUpdatedAsc, []*searchSortKey{{name: "updated"}, {name: "id"}}},
+		{SearchOrderByCreatedDesc, []*searchSortKey{{name: "created", desc: true}, {name: "id"}}},
+		{SearchOrderByCreatedAsc, []*searchSortKey{{name: "created"}, {name: "id"}}},
+	}
+	for _, test := range tests {
+		if got := searchSortKeys(test.orderBy, rank); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("searchSortKeys(%d): got %v, want %v", test.orderBy, got, test.want)
+		}
+	}
+	if got := searchSortKeys(SearchOrderByRank); !reflect.DeepEqual([]*searchSortKey{{name: "id"}}, got) {
+		t.Errorf("searchSortKeys without rank: got %v", got)
+	}
+}
+
+func TestAfterCond(t *testing.T) {
+	tests := []struct {
+		keys []*searchSortKey
+		want string
+	}{
+		{[]*searchSortKey{{name: "id"}}, "((r.id > c.id))"},
+		{[]*searchSortKey{{name: "updated", desc: true}, {name: "id"}}, "((r.updated < c.updated) OR (r.updated = c.updated AND r.id > c.id))"},
+		{[]*searchSortKey{{name: "score"}, {name: "sort", desc: true}, {name: "id"}}, "((r.score > c.score) OR (r.score = c.score AND r.sort < c.sort) OR (r.score = c.score AND r.sort = c.sort AND r.id > c.id))"},
+	}
+	for _, test := range tests {
+		if got := afterCond(test.keys); test.want != got {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestNewSearchPage(t *testing.T) {
+	encode := func(cursor string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(cursor))
+	}
+	tests := []struct {
+		name    string
+		cursor  string
+		after   []interface{}
+		anchors []string
+		err     bool
+	}{
+		{"first page", "", nil, nil, false},
+		{"keys", encode(`{"now":"2022-10-19 00:00:00","after":["20221019","x",3,-1.5],"anchors":["b","a"]}`), []interface{}{"20221019", "x", int64(3), -1.5}, []string{"b", "a"}, false},
+		{"large integer", encode(`{"now":"2022-10-19 00:00:00","after":[9007199254740993]}`), []interface{}{int64(9007199254740993)}, nil, false},
+		{"not base64", "!!!", nil, nil, true},
+		{"not json", encode("foo"), nil, nil, true},
+		{"no time", encode(`{"after":["x"]}`), nil, nil, true},
+		{"no keys", encode(`{"now":"2022-10-19 00:00:00","after":[]}`), nil, nil, true},
+		{"invalid key", encode(`{"now":"2022-10-19 00:00:00","after":[true]}`), nil, nil, true},
+	}
+	for _, test := range tests {
+		page, err := NewSearchPage(test.cursor)
+		if test.err != (nil != err) {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if nil != err {
+			continue
+		}
+		if !reflect.DeepEqual(test.after, page.after) || !reflect.DeepEqual(test.anchors, page.anchors) || "" == page.now {
+			t.Errorf("%s: got %#v %v %q, want %#v %v", test.name, page.after, page.anchors, page.now, test.after, test.anchors)
+		}
+	}
+}
+
+// TestSearchPageStmt 逐页查询，检查分页结果和一次查询全部结果的顺序一致，并且翻页期间增删结果时不会重复或者遗漏。
+func TestSearchPageStmt(t *testing.T) {
+	db, err := sql.Open("sqlite3_extended", ":memory:")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(`CREATE TABLE t (id TEXT, updated TEXT, score REAL);
+INSERT INTO t VALUES ('a', '2', 1.5), ('b', '1', 2), ('c', '2', 1.5), ('d', '3', -1), ('e', '1', 2), ('f', '2', 0.25), ('g', '3', 2)`); nil != err {
+		t.Fatal(err)
+	}
+
+	appConf := Conf
+	Conf = &AppConf{Search: &conf.Search{Limit: 2}}
+	defer func() { Conf = appConf }()
+
+	queryIDs := func(stmt string, args ...interface{}) (ids []string, keyVals [][]interface{}) {
+		rows, queryErr := db.Query(stmt, args...)
+		if nil != queryErr {
+			t.Fatalf("query [%s] failed: %s", stmt, queryErr)
+		}
+		defer rows.Close()
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			vals := make([]interface{}, len(cols))
+			pointers := make([]interface{}, len(cols))
+			for i := range vals {
+				pointers[i] = &vals[i]
+			}
+			rows.Scan(pointers...)
+			ids = append(ids, vals[0].(string))
+			keyVals = append(keyVals, vals[3:])
+		}
+		return
+	}
+	paginate := func(keys []*searchSortKey, onFirstPage func()) (ret []string) {
+		page, _ := NewSearchPage("")
+		for n := 0; n < 16; n++ {
+			stmt, args := page.stmt("SELECT * FROM t", keys)
+			ids, keyVals := queryIDs(stmt, args...)
+			ret = append(ret, ids[:page.next(keyVals)]...)
+			if "" == page.NextCursor {
+				return
+			}
+			if 0 == n && nil != onFirstPage {
+				onFirstPage()
+			}
+			if page, err = NewSearchPage(page.NextCursor); nil != err {
+				t.Fatal(err)
+			}
+		}
+		t.Fatal("too many pages")
+		return
+	}
+
+	for orderBy := SearchOrderByRank; orderBy <= SearchOrderByUpdatedAsc; orderBy++ {
+		keys := searchSortKeys(orderBy, &searchSortKey{name: "score", desc: true})
+		var orders []string
+		for _, key := range keys {
+			order := key.name
+			if key.desc {
+				order += " DESC"
+			}
+			orders = append(orders, order)
+		}
+		want, _ := queryIDs("SELECT *, 0, 0, 0 FROM t ORDER BY " + strings.Join(orders, ", "))
+		if got := paginate(keys, nil); !reflect.DeepEqual(want, got) {
+			t.Errorf("order by %d: got %v, want %v", orderBy, got, want)
+		}
+	}
+
+	// 第一页之后删除第一页的所有结果并在第一页之前插入结果，之后的结果仍然完整返回
+	keys := searchSortKeys(SearchOrderByRank, &searchSortKey{name: "score", desc: true})
+	got := paginate(keys, func() {
+		db.Exec("DELETE FROM t WHERE id IN ('b', 'e'); INSERT INTO t VALUES ('0', '9', 9)")
+	})
+	if want := []string{"b", "e", "g", "a", "c", "f", "d"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("paginate while changing: got %v, want %v", got, want)
+	}
+}
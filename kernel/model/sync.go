@@ -28,7 +28,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/88250/gulu"
@@ -52,6 +51,9 @@ var (
 
 	BootSyncSucc = -1 // -1：未执行，0：执行成功，1：执行失败
 	ExitSyncSucc = -1
+
+	syncDuration      = util.NewHistogram("siyuan_sync_duration_seconds", "Duration of successful data sync.", util.LongDurationBuckets, "direction")
+	syncTransferBytes = util.NewCounter("siyuan_sync_transfer_bytes_total", "Bytes transferred by data sync.", "direction")
 )
 
 func AutoSync() {
@@ -67,7 +69,7 @@ func AutoSync() {
 func SyncData(boot, exit, byHand bool) {
 	defer util.Recover()
 
-	if util.IsMutexLocked(&syncLock) {
+	if util.IsMutexLocked(&syncLock.Mutex) {
 		util.LogWarnf("sync has been locked")
 		syncInterval = 30 * time.Second
 		return
@@ -261,6 +263,8 @@ func SyncData(boot, exit, byHand bool) {
 		elapsed := time.Now().Sub(start).Seconds()
 		stat := fmt.Sprintf(Conf.Language(130), wroteFiles, humanize.Bytes(transferSize)) + fmt.Sprintf(Conf.Language(132), elapsed)
 		util.LogInfof("sync [cloud=%d, local=%d, wroteFiles=%d, transferSize=%s] uploaded in [%.2fs]", cloudSyncVer, syncConf.SyncVer, wroteFiles, humanize.Bytes(transferSize), elapsed)
+		syncDuration.Observe(elapsed, "upload")
+		syncTransferBytes.Add(float64(transferSize), "upload")
 
 		Conf.Sync.Uploaded = now
 		Conf.Sync.Stat = stat
@@ -362,6 +366,8 @@ func SyncData(boot, exit, byHand bool) {
 	elapsed := time.Now().Sub(start).Seconds()
 	stat := fmt.Sprintf(Conf.Language(129), fetchedFiles, humanize.Bytes(transferSize)) + fmt.Sprintf(Conf.Language(131), elapsed)
 	util.LogInfof("sync [cloud=%d, local=%d, fetchedFiles=%d, transferSize=%s] downloaded in [%.2fs]", cloudSyncVer, syncConf.SyncVer, fetchedFiles, humanize.Bytes(transferSize), elapsed)
+	syncDuration.Observe(elapsed, "download")
+	syncTransferBytes.Add(float64(transferSize), "download")
 
 	Conf.Sync.Downloaded = now
 	Conf.Sync.Stat = stat
@@ -488,7 +494,7 @@ func SetSyncEnable(b bool) (err error) {
 	return
 }
 
-var syncLock = sync.Mutex{}
+var syncLock = txMutex{}
 
 // syncDir2WorkspaceData 将 sync 的数据更新到 data 中。
 //   1. 删除 data 中冗余的文件
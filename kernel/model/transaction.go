@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -80,9 +81,88 @@ var (
 	txDelay     = txFixDelay
 
 	currentTx *Transaction
+
+	txDuration = util.NewHistogram("siyuan_tx_duration_seconds", "Duration of performing transactions.", util.DurationBuckets)
+)
+
+func init() {
+	util.NewGaugeFunc("siyuan_tx_queue_length", "Number of transactions waiting to be performed.", nil, func() []util.MetricValue {
+		txQueueLock.Lock()
+		defer txQueueLock.Unlock()
+		return []util.MetricValue{{Value: float64(len(txQueue))}}
+	})
+}
+
+// txOwner 是通过 LockTx 持有事务锁的协程 ID，为 0 时没有协程持有。
+//
+// 持有期间后台不执行事务队列，其他协程提交的事务留在事务队列中直到 UnlockTx；持有事务锁的协程提交的事务放入 ownerTxQueue，
+// 由该协程调用 WaitForWritingFiles 时直接执行。
+var (
+	txOwner      int64
+	ownerTxQueue []*Transaction
 )
 
+func isTxOwner() bool {
+	owner := atomic.LoadInt64(&txOwner)
+	return 0 != owner && util.GoroutineID() == owner
+}
+
+// LockTx 获取事务锁 writingTreeLock 和同步锁 syncLock 并一直持有到 UnlockTx，用于避免批量调用期间其他事务和文件操作写入批量调用涉及的文档。
+//
+// 持有期间当前协程可以重入同步锁，其他协程的文件操作会等待 UnlockTx。
+func LockTx() {
+	WaitForWritingFiles()
+	syncLock.Mutex.Lock()
+	writingTreeLock.Lock()
+	atomic.StoreInt64(&txOwner, util.GoroutineID())
+}
+
+// UnlockTx 释放 LockTx 获取的锁，当前协程提交后没有执行的事务会被丢弃。
+func UnlockTx() {
+	atomic.StoreInt64(&txOwner, 0)
+	ownerTxQueue = nil
+	writingTreeLock.Unlock()
+	syncLock.Mutex.Unlock()
+}
+
+// flushOwnerTx 执行持有事务锁的协程提交的事务。
+func flushOwnerTx() {
+	defer util.Recover()
+
+	tx := &Transaction{}
+	for _, t := range ownerTxQueue {
+		tx.DoOperations = append(tx.DoOperations, t.DoOperations...)
+	}
+	ownerTxQueue = nil
+	if txErr := performTx(tx); nil != txErr {
+		util.LogErrorf("transaction failed: %s", txErr.error())
+	}
+}
+
+// txMutex 是 LockTx 持有的锁，持有事务锁的协程可以重入。
+type txMutex struct {
+	sync.Mutex
+}
+
+func (m *txMutex) Lock() {
+	if !isTxOwner() {
+		m.Mutex.Lock()
+	}
+}
+
+func (m *txMutex) Unlock() {
+	if !isTxOwner() {
+		m.Mutex.Unlock()
+	}
+}
+
 func WaitForWritingFiles() {
+	if isTxOwner() {
+		// 持有事务锁时后台不执行事务队列，这里只执行当前协程提交的事务
+		flushOwnerTx()
+		return
+	}
+
 	var printLog bool
 	var lastPrintLog bool
 	for i := 0; isWritingFiles(); i++ {
@@ -175,12 +255,42 @@ func PerformTransactions(transactions *[]*Transaction) (err error) {
 		return
 	}
 
+	if isTxOwner() {
+		ownerTxQueue = append(ownerTxQueue, *transactions...)
+		return
+	}
+
 	txQueueLock.Lock()
 	txQueue = append(txQueue, *transactions...)
 	txQueueLock.Unlock()
 	return
 }
 
+// PerformTransactionsNow 将 transactions 合并为一个事务并立即执行，任一操作失败时该事务中的所有操作都不会写入。
+//
+// 和 PerformTransactions 不同，该函数会等待事务执行完成并返回执行错误。调用前需要使用 LockTx 获取事务锁。
+func PerformTransactionsNow(transactions *[]*Transaction) (err error) {
+	if !util.IsBooted() {
+		err = ErrNotFullyBoot
+		return
+	}
+	if !isTxOwner() {
+		err = errors.New("transaction lock is not held")
+		return
+	}
+
+	WaitForWritingFiles()
+
+	tx := &Transaction{}
+	for _, t := range *transactions {
+		tx.DoOperations = append(tx.DoOperations, t.DoOperations...)
+	}
+	if txErr := performTx(tx); nil != txErr {
+		err = txErr.error()
+	}
+	return
+}
+
 const (
 	TxErrCodeBlockNotFound  = 0
 	TxErrCodeUnableLockFile = 1
@@ -193,6 +303,17 @@ type TxErr struct {
 	id   string
 }
 
+func (txErr *TxErr) error() error {
+	msg := txErr.msg
+	if "" == msg && TxErrCodeBlockNotFound == txErr.code {
+		msg = ErrBlockNotFound.Error()
+	}
+	if "" != txErr.id {
+		msg += " [" + txErr.id + "]"
+	}
+	return errors.New(msg)
+}
+
 func performTx(tx *Transaction) (ret *TxErr) {
 	if 1 > len(tx.DoOperations) {
 		txDelay -= 1000
@@ -203,6 +324,7 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		}
 		return
 	}
+	defer txDuration.ObserveSince(time.Now())
 
 	//os.MkdirAll("pprof", 0755)
 	//cpuProfile, _ := os.Create("pprof/cpu_profile_tx")
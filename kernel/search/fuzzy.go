@@ -0,0 +1,206 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Vocabulary 是模糊搜索使用的词表，通过三元组（trigram）索引查找拼写相近的词。
+type Vocabulary struct {
+	words    []string
+	freqs    []int
+	ids      map[string]int
+	trigrams map[string][]int
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{ids: map[string]int{}, trigrams: map[string][]int{}}
+}
+
+// Add 将 text 中的词加入词表。
+func (v *Vocabulary) Add(text string) {
+	for _, word := range Words(text) {
+		if id, ok := v.ids[word]; ok {
+			v.freqs[id]++
+			continue
+		}
+
+		id := len(v.words)
+		v.ids[word] = id
+		v.words = append(v.words, word)
+		v.freqs = append(v.freqs, 1)
+		for _, t := range trigrams(word) {
+			v.trigrams[t] = append(v.trigrams[t], id)
+		}
+	}
+}
+
+// Contains 判断词表中是否存在 word。
+func (v *Vocabulary) Contains(word string) bool {
+	_, ok := v.ids[strings.ToLower(word)]
+	return ok
+}
+
+// Len 返回词表中的词数。
+func (v *Vocabulary) Len() int {
+	return len(v.words)
+}
+
+// Suggest 返回词表中与 term 编辑距离不超过 maxDistance 的词，按编辑距离升序、词频降序排列。
+func (v *Vocabulary) Suggest(term string, maxDistance, limit int) (ret []string) {
+	term = strings.ToLower(term)
+	termTrigrams := trigrams(term)
+	shared := map[int]int{}
+	for _, t := range termTrigrams {
+		for _, id := range v.trigrams[t] {
+			shared[id]++
+		}
+	}
+
+	// 每次编辑最多影响 3 个三元组，共享三元组过少的词不可能在编辑距离内
+	minShared := len(termTrigrams) - 3*maxDistance
+	if 1 > minShared {
+		minShared = 1
+	}
+
+	type candidate struct {
+		id, distance int
+	}
+	var candidates []*candidate
+	termLen := utf8.RuneCountInString(term)
+	for id, count := range shared {
+		if count < minShared {
+			continue
+		}
+		word := v.words[id]
+		if diff := utf8.RuneCountInString(word) - termLen; maxDistance < diff || -maxDistance > diff {
+			continue
+		}
+		if distance := EditDistance(term, word); distance <= maxDistance {
+			candidates = append(candidates, &candidate{id: id, distance: distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		if v.freqs[candidates[i].id] != v.freqs[candidates[j].id] {
+			return v.freqs[candidates[i].id] > v.freqs[candidates[j].id]
+		}
+		return v.words[candidates[i].id] < v.words[candidates[j].id]
+	})
+	for i, c := range candidates {
+		if i >= limit {
+			break
+		}
+		ret = append(ret, v.words[c.id])
+	}
+	return
+}
+
+// MaxEditDistance 返回 term 允许的最大编辑距离，短词只允许一处错误。
+func MaxEditDistance(term string) int {
+	if 5 > utf8.RuneCountInString(term) {
+		return 1
+	}
+	return 2
+}
+
+// Words 将 text 切分为用于模糊搜索的小写词。中日韩文字不按词切分，所以不参与模糊搜索。
+func Words(text string) (ret []string) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, field := range fields {
+		if l := utf8.RuneCountInString(field); 3 > l || 32 < l {
+			continue
+		}
+		if isCJKWord(field) || !hasLetter(field) {
+			continue
+		}
+		ret = append(ret, strings.ToLower(field))
+	}
+	return
+}
+
+// EditDistance 计算 a 和 b 的 Levenshtein 编辑距离。
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func trigrams(word string) (ret []string) {
+	runes := []rune("  " + word + " ")
+	seen := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		t := string(runes[i : i+3])
+		if !seen[t] {
+			seen[t] = true
+			ret = append(ret, t)
+		}
+	}
+	return
+}
+
+func isCJKWord(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLetter(word string) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
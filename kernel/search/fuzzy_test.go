@@ -0,0 +1,80 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"search", "serch", 1},
+		{"思源笔记", "思源", 2},
+	}
+	for _, test := range tests {
+		if got := EditDistance(test.a, test.b); test.want != got {
+			t.Errorf("EditDistance(%q, %q): got %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"a ab abc", []string{"abc"}},
+		{"2022 v2.0 abc123", []string{"abc123"}},
+		{"思源笔记 SiYuan", []string{"siyuan"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := Words(test.text); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("Words(%q): got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	vocabulary := NewVocabulary()
+	vocabulary.Add("cat cat car can search searching")
+	tests := []struct {
+		term        string
+		maxDistance int
+		limit       int
+		want        []string
+	}{
+		{"cax", 1, 3, []string{"cat", "can", "car"}},
+		{"cax", 1, 1, []string{"cat"}},
+		{"Serch", 1, 3, []string{"search"}},
+		{"searchin", 1, 3, []string{"searching"}},
+		{"zzz", 1, 3, nil},
+	}
+	for _, test := range tests {
+		if got := vocabulary.Suggest(test.term, test.maxDistance, test.limit); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("Suggest(%q, %d, %d): got %q, want %q", test.term, test.maxDistance, test.limit, got, test.want)
+		}
+	}
+}
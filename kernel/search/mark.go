@@ -89,9 +89,7 @@ func EncloseHighlighting(text string, keywords []string, openMark, closeMark str
 	}
 	re += ")"
 	if reg, err := regexp.Compile(re); nil == err {
-		text = reg.ReplaceAllStringFunc(text, func(s string) string {
-			return openMark + s + closeMark
-		})
+		text = EncloseRegexpHighlighting(text, reg, openMark, closeMark)
 	} else {
 		for _, k := range keywords {
 			k = keyword2regexp(k)
@@ -112,6 +110,16 @@ func EncloseHighlighting(text string, keywords []string, openMark, closeMark str
 	return text
 }
 
+// EncloseRegexpHighlighting 使用 openMark 和 closeMark 包裹 text 中所有命中正则表达式 reg 的部分。
+func EncloseRegexpHighlighting(text string, reg *regexp.Regexp, openMark, closeMark string) string {
+	return reg.ReplaceAllStringFunc(text, func(s string) string {
+		if "" == s {
+			return s
+		}
+		return openMark + s + closeMark
+	})
+}
+
 func keyword2regexp(k string) string {
 	k = strings.ReplaceAll(k, "*", ".*")
 	k = strings.ReplaceAll(k, "?", ".")
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,9 +43,31 @@ import (
 var cookieStore = cookie.NewStore([]byte("ATN51UlxVq1Gcvdf"))
 
 func Serve(fastMode bool) {
+	ginServer := NewServer()
+
+	var addr string
+	if model.Conf.System.NetworkServe || "docker" == util.Container {
+		addr = "0.0.0.0:" + util.ServerPort
+	} else {
+		addr = "127.0.0.1:" + util.ServerPort
+	}
+	util.LogInfof("kernel is booting [%s]", "http://"+addr)
+	util.HttpServing = true
+	if err := ginServer.Run(addr); nil != err {
+		if !fastMode {
+			util.LogErrorf("boot kernel failed: %s", err)
+			os.Exit(util.ExitCodeUnavailablePort)
+		}
+	}
+}
+
+// NewServer 创建伺服并注册所有路由，但是不监听端口。命令行子命令直接打开工作空间时使用该伺服在进程内调用 API。
+func NewServer() (ginServer *gin.Engine) {
 	gin.SetMode(gin.ReleaseMode)
-	ginServer := gin.New()
+	ginServer = gin.New()
 	ginServer.MaxMultipartMemory = 1024 * 1024 * 32 // 插入较大的资源文件时内存占用较大 https://github.com/siyuan-note/siyuan/issues/5023
+	// 统计需要在 Recovery 之前，这样发生 panic 的请求也会被统计为 500
+	ginServer.Use(recordRequestMetrics)
 	ginServer.Use(gin.Recovery())
 	ginServer.Use(cors.Default())
 	ginServer.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedExtensions([]string{".pdf", ".mp3", ".wav", ".ogg", ".mov", ".weba", ".mkv", ".mp4", ".webm"})))
@@ -68,21 +91,31 @@ func Serve(fastMode bool) {
 	serveWidgets(ginServer)
 	serveEmojis(ginServer)
 	api.ServeAPI(ginServer)
+	cmd.APIHandler = ginServer
+	return
+}
 
-	var addr string
-	if model.Conf.System.NetworkServe || "docker" == util.Container {
-		addr = "0.0.0.0:" + util.ServerPort
-	} else {
-		addr = "127.0.0.1:" + util.ServerPort
-	}
-	util.LogInfof("kernel is booting [%s]", "http://"+addr)
-	util.HttpServing = true
-	if err := ginServer.Run(addr); nil != err {
-		if !fastMode {
-			util.LogErrorf("boot kernel failed: %s", err)
-			os.Exit(util.ExitCodeUnavailablePort)
+var (
+	httpRequests        = util.NewCounter("siyuan_http_requests_total", "Number of HTTP requests.", "method", "route", "status")
+	httpRequestDuration = util.NewHistogram("siyuan_http_request_duration_seconds", "Duration of HTTP requests.", util.DurationBuckets, "method", "route")
+)
+
+// recordRequestMetrics 按路由统计请求数和耗时，使用路由模板而不是请求路径，避免资源文件等路径产生大量标签值。
+//
+// websocket 连接 /ws 的耗时是连接的存活时长，不统计耗时，只统计请求数。
+func recordRequestMetrics(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		route := c.FullPath()
+		if "" == route {
+			route = "unmatched"
 		}
-	}
+		httpRequests.Add(1, c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		if "/ws" != route {
+			httpRequestDuration.ObserveSince(start, c.Request.Method, route)
+		}
+	}()
+	c.Next()
 }
 
 func serveExport(ginServer *gin.Engine) {
@@ -276,19 +309,22 @@ func serveWebSocket(ginServer *gin.Engine) {
 			return
 		}
 
-		cmdStr := request["cmd"].(string)
-		cmdId := request["reqId"].(float64)
-		param := request["param"].(map[string]interface{})
+		cmdStr, _ := request["cmd"].(string)
+		cmdId, _ := request["reqId"].(float64)
+		param, _ := request["param"].(map[string]interface{})
+		if nil == param {
+			param = map[string]interface{}{}
+		}
 		command := cmd.NewCommand(cmdStr, cmdId, param, s)
 		if nil == command {
-			result := util.NewResult()
+			result := util.NewCmdResult(cmdStr, cmdId, util.PushModeSingleSelf, util.PushModeSingleSelf)
 			result.Code = -1
 			result.Msg = "can not find command [" + cmdStr + "]"
 			s.Write(result.Bytes())
 			return
 		}
 		if util.ReadOnly && !command.IsRead() {
-			result := util.NewResult()
+			result := util.NewCmdResult(cmdStr, cmdId, util.PushModeSingleSelf, util.PushModeSingleSelf)
 			result.Code = -1
 			result.Msg = model.Conf.Language(34)
 			s.Write(result.Bytes())
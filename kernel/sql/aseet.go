@@ -93,10 +93,11 @@ func docTitleImgAsset(root *ast.Node) *Asset {
 	return nil
 }
 
-func QueryAssetsByName(name string) (ret []*Asset) {
+// QueryAssetsByName 按 ID 降序返回名称包含 name 的资源记录，beforeID 不为空时只返回 ID 小于 beforeID 的记录。
+func QueryAssetsByName(name string, limit int, beforeID string) (ret []*Asset) {
 	ret = []*Asset{}
-	sqlStmt := "SELECT * FROM assets WHERE name LIKE ? GROUP BY id ORDER BY id DESC LIMIT 32"
-	rows, err := query(sqlStmt, "%"+name+"%")
+	sqlStmt := "SELECT * FROM assets WHERE name LIKE ? AND ('' = ? OR id < ?) GROUP BY id ORDER BY id DESC LIMIT ?"
+	rows, err := query(sqlStmt, "%"+name+"%", beforeID, beforeID, limit)
 	if nil != err {
 		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
@@ -109,6 +110,15 @@ func QueryAssetsByName(name string) (ret []*Asset) {
 	return
 }
 
+func QueryAssetsCountByName(name string) (ret int) {
+	sqlStmt := "SELECT COUNT(DISTINCT id) FROM assets WHERE name LIKE ?"
+	row := queryRow(sqlStmt, "%"+name+"%")
+	if err := row.Scan(&ret); nil != err {
+		util.LogErrorf("query scan field failed: %s", err)
+	}
+	return
+}
+
 func QueryAssetByHash(hash string) (ret *Asset) {
 	sqlStmt := "SELECT * FROM assets WHERE hash = ?"
 	row := queryRow(sqlStmt, hash)
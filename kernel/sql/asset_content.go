@@ -0,0 +1,143 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AssetContent 描述了资源文件中某一页的文本内容，非分页的资源文件只有第 1 页。
+type AssetContent struct {
+	ID      string
+	Path    string
+	Name    string
+	Page    int
+	Content string
+	Hash    string
+}
+
+// QueryAssetContentHashes 返回已经索引的资源文件路径和索引时的文件签名。
+func QueryAssetContentHashes() (ret map[string]string) {
+	ret = map[string]string{}
+	sqlStmt := "SELECT path, hash FROM asset_contents_fts GROUP BY path"
+	rows, err := query(sqlStmt)
+	if nil != err {
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p, hash string
+		if err = rows.Scan(&p, &hash); nil != err {
+			util.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[p] = hash
+	}
+	return
+}
+
+// IndexAssetContents 使用 contents 替换资源文件 path 已有的文本索引。
+//
+// contents 为空时仍然会写入一条空内容的记录，用于记录文件签名，避免无法提取文本的资源文件被反复提取。
+func IndexAssetContents(path, name, hash string, contents []*AssetContent) (err error) {
+	if 1 > len(contents) {
+		contents = append(contents, &AssetContent{Page: 1})
+	}
+
+	txLock.Lock()
+	defer txLock.Unlock()
+	tx, err := BeginTx()
+	if nil != err {
+		return
+	}
+	if err = execStmtTx(tx, "DELETE FROM asset_contents_fts WHERE path = ?", path); nil != err {
+		RollbackTx(tx)
+		return
+	}
+	stmt := "INSERT INTO asset_contents_fts (id, path, name, page, content, hash) VALUES (?, ?, ?, ?, ?, ?)"
+	for _, content := range contents {
+		if err = execStmtTx(tx, stmt, ast.NewNodeID(), path, name, content.Page, content.Content, hash); nil != err {
+			RollbackTx(tx)
+			return
+		}
+	}
+	err = CommitTx(tx)
+	return
+}
+
+// RemoveAssetContents 删除资源文件 paths 的文本索引。
+func RemoveAssetContents(paths []string) {
+	if 1 > len(paths) {
+		return
+	}
+
+	txLock.Lock()
+	defer txLock.Unlock()
+	tx, err := BeginTx()
+	if nil != err {
+		return
+	}
+	for _, p := range paths {
+		if err = execStmtTx(tx, "DELETE FROM asset_contents_fts WHERE path = ?", p); nil != err {
+			RollbackTx(tx)
+			return
+		}
+	}
+	CommitTx(tx)
+}
+
+// FullTextSearchAssetContents 在资源文件文本中搜索，match 为全文搜索查询语法，返回的内容为命中片段，命中处使用 __@mark__ 和 __mark@__ 包裹。
+func FullTextSearchAssetContents(match string, limit int) (ret []*AssetContent) {
+	ret = []*AssetContent{}
+	sqlStmt := "SELECT id, path, highlight(asset_contents_fts, 2, '__@mark__', '__mark@__') AS name, page, " +
+		"snippet(asset_contents_fts, 4, '__@mark__', '__mark@__', '...', 64) AS content, hash " +
+		"FROM asset_contents_fts WHERE asset_contents_fts MATCH ? ORDER BY rank LIMIT ?"
+	rows, err := query(sqlStmt, "{name content}:("+match+")", limit)
+	if nil != err {
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content AssetContent
+		if err = rows.Scan(&content.ID, &content.Path, &content.Name, &content.Page, &content.Content, &content.Hash); nil != err {
+			util.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret = append(ret, &content)
+	}
+	return
+}
+
+// QueryAssetsByPath 返回引用了资源文件 path 的资源记录。
+func QueryAssetsByPath(path string) (ret []*Asset) {
+	sqlStmt := "SELECT * FROM assets WHERE path = ?"
+	rows, err := query(sqlStmt, path)
+	if nil != err {
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if asset := scanAssetRows(rows); nil != asset {
+			ret = append(ret, asset)
+		}
+	}
+	return
+}
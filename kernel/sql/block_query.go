@@ -18,14 +18,21 @@ package sql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/88250/lute/ast"
 	"github.com/88250/vitess-sqlparser/sqlparser"
 	"github.com/emirpasic/gods/sets/hashset"
+	"github.com/mattn/go-sqlite3"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -34,7 +41,7 @@ func queryBlockHashes(rootID string) (ret map[string]string) {
 	stmt := "SELECT id, hash FROM blocks WHERE root_id = ?"
 	rows, err := query(stmt, rootID)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", stmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", stmt, err)
 		return
 	}
 	defer rows.Close()
@@ -54,7 +61,7 @@ func QueryRootBlockByCondition(condition string) (ret []*Block) {
 	sqlStmt := "SELECT *, length(hpath) - length(replace(hpath, '/', '')) AS lv FROM blocks WHERE type = 'd' AND " + condition + " ORDER BY box DESC,lv ASC LIMIT 128"
 	rows, err := query(sqlStmt)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -110,7 +117,7 @@ func queryBlockIDByParentID(parentID string) (ret []string) {
 	sqlStmt := "SELECT id FROM blocks WHERE parent_id = ?"
 	rows, err := query(sqlStmt, parentID)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -129,7 +136,7 @@ func QueryRecentUpdatedBlocks() (ret []*Block) {
 	}
 	rows, err := query(sqlStmt)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -152,7 +159,7 @@ func QueryBlockAliases(rootID string) (ret []string) {
 	sqlStmt := "SELECT alias FROM blocks WHERE root_id = ? AND alias != ''"
 	rows, err := query(sqlStmt, rootID)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -185,7 +192,7 @@ func queryNames() (ret []string) {
 	sqlStmt := "SELECT name FROM blocks WHERE name != '' LIMIT ?"
 	rows, err := query(sqlStmt, 10240)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -218,7 +225,7 @@ func queryAliases() (ret []string) {
 	sqlStmt := "SELECT alias FROM blocks WHERE alias != '' LIMIT ?"
 	rows, err := query(sqlStmt, 10240)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -252,7 +259,7 @@ func queryDocIDsByTitle(title string, excludeIDs []string) (ret []string) {
 	sqlStmt := "SELECT id FROM blocks WHERE type = 'd' AND content = ? AND id NOT IN " + notIn + " LIMIT ?"
 	rows, err := query(sqlStmt, title, 32)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -275,7 +282,7 @@ func queryDocTitles() (ret []string) {
 	sqlStmt := "SELECT content FROM blocks WHERE type = 'd' LIMIT ?"
 	rows, err := query(sqlStmt, 10240)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -307,7 +314,7 @@ func QueryBlockNamesByRootID(rootID string) (ret []string) {
 	sqlStmt := "SELECT DISTINCT name FROM blocks WHERE root_id = ? AND name != ''"
 	rows, err := query(sqlStmt, rootID)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
@@ -378,17 +385,176 @@ func QueryBookmarkLabels() (ret []string) {
 
 func Query(stmt string) (ret []map[string]interface{}, err error) {
 	ret = []map[string]interface{}{}
+	var cols []string
+	err = QueryEach(stmt, func(columns []string) error {
+		cols = columns
+		return nil
+	}, func(vals []interface{}) error {
+		m := make(map[string]interface{})
+		for i, colName := range cols {
+			m[colName] = vals[i]
+		}
+		ret = append(ret, m)
+		return nil
+	})
+	return
+}
+
+// QueryEach 执行查询语句 stmt，先使用列名回调 header，然后按照列的顺序使用每一行的值回调 row，用于逐行处理较大的查询结果。
+func QueryEach(stmt string, header func(cols []string) error, row func(vals []interface{}) error) (err error) {
 	rows, err := query(stmt)
 	if nil != err {
 		util.LogWarnf("sql query [%s] failed: %s", stmt, err)
 		return
 	}
+	return eachRow(rows, header, row)
+}
+
+const (
+	readOnlyQueryMaxBytes = 64 * 1024 * 1024 // 只读查询结果缓冲的最大字节数
+	readOnlyQueryTimeout  = 30 * time.Second // 只读查询占用数据库连接的最长时间
+)
+
+// QueryEachReadOnly 和 QueryEach 一样逐行处理查询结果，但是在开启了 PRAGMA query_only 的连接上执行 stmt，由 SQLite 保证 stmt 不会修改数据库。
+//
+// 查询结果先读入缓冲并释放数据库连接，然后再回调 header 和 row，避免回调中较慢的写入（比如下载导出文件）长时间占用连接。
+// 缓冲超过 readOnlyQueryMaxBytes 或者查询超过 readOnlyQueryTimeout 时返回错误。
+func QueryEachReadOnly(stmt string, header func(cols []string) error, row func(vals []interface{}) error) (err error) {
+	stmt = strings.TrimSpace(stmt)
+	if "" == stmt {
+		return errors.New("statement is empty")
+	}
+
+	cols, rows, err := queryReadOnly(stmt)
+	if nil != err || nil == cols {
+		return
+	}
+	if err = header(cols); nil != err {
+		return
+	}
+	for i, vals := range rows {
+		rows[i] = nil // 逐行释放已经回调过的缓冲
+		if err = row(vals); nil != err {
+			return
+		}
+	}
+	return
+}
+
+func queryReadOnly(stmt string) (cols []string, ret [][]interface{}, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readOnlyQueryTimeout)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	if nil != err {
+		util.LogErrorf("get database connection failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "PRAGMA query_only = ON"); nil != err {
+		util.LogErrorf("enable query only failed: %s", err)
+		return
+	}
+	defer func() {
+		if _, resetErr := conn.ExecContext(context.Background(), "PRAGMA query_only = OFF"); nil != resetErr {
+			util.LogErrorf("disable query only failed: %s", resetErr)
+			// 无法恢复时丢弃该连接，避免连接池中的其他写入失败
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	// 多条语句时前面的语句也会执行，禁止语句通过 PRAGMA 关闭 query_only 或者 ATTACH 其他数据库
+	conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*sqlite3.SQLiteConn).RegisterAuthorizer(readOnlyAuthorizer)
+		return nil
+	})
+	defer conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*sqlite3.SQLiteConn).RegisterAuthorizer(nil)
+		return nil
+	})
+
+	rows, err := conn.QueryContext(ctx, stmt)
+	if nil != err {
+		util.LogWarnf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	if columns, _ := rows.Columns(); nil == columns {
+		// 没有结果列的语句（比如 DELETE）在读取结果时才会执行，这里执行一次以返回 query_only 拒绝写入的错误
+		rows.Next()
+		if err = rows.Err(); nil == err {
+			err = errors.New("statement returns no columns")
+		}
+		rows.Close()
+		return
+	}
+
+	size := 0
+	err = eachRow(rows, func(columns []string) error {
+		cols = columns
+		return nil
+	}, func(vals []interface{}) error {
+		for _, val := range vals {
+			switch v := val.(type) {
+			case string:
+				size += len(v)
+			case []byte:
+				size += len(v)
+			default:
+				size += 8
+			}
+		}
+		if readOnlyQueryMaxBytes < size {
+			return errors.New(fmt.Sprintf("query result exceeds %d MB", readOnlyQueryMaxBytes/1024/1024))
+		}
+		ret = append(ret, vals)
+		return nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New(fmt.Sprintf("query exceeds %s", readOnlyQueryTimeout))
+	}
+	if nil != err {
+		cols, ret = nil, nil
+	}
+	return
+}
+
+// readOnlyPragmas 是只读查询中允许使用的 PRAGMA，比如 SELECT * FROM pragma_table_info('blocks')。
+var readOnlyPragmas = map[string]bool{
+	"table_info":       true,
+	"table_xinfo":      true,
+	"table_list":       true,
+	"index_list":       true,
+	"index_info":       true,
+	"index_xinfo":      true,
+	"foreign_key_list": true,
+	"function_list":    true,
+	"pragma_list":      true,
+	"collation_list":   true,
+	"compile_options":  true,
+}
+
+func readOnlyAuthorizer(op int, arg1, arg2, arg3 string) int {
+	switch op {
+	case sqlite3.SQLITE_PRAGMA:
+		if !readOnlyPragmas[strings.ToLower(arg1)] {
+			return sqlite3.SQLITE_DENY
+		}
+	case sqlite3.SQLITE_ATTACH, sqlite3.SQLITE_DETACH:
+		return sqlite3.SQLITE_DENY
+	}
+	return sqlite3.SQLITE_OK
+}
+
+func eachRow(rows *sql.Rows, header func(cols []string) error, row func(vals []interface{}) error) (err error) {
 	defer rows.Close()
 
 	cols, _ := rows.Columns()
 	if nil == cols {
 		return
 	}
+	if err = header(cols); nil != err {
+		return
+	}
 
 	for rows.Next() {
 		columns := make([]interface{}, len(cols))
@@ -400,15 +566,31 @@ func Query(stmt string) (ret []map[string]interface{}, err error) {
 		if err = rows.Scan(columnPointers...); nil != err {
 			return
 		}
+		if err = row(columns); nil != err {
+			return
+		}
+	}
+	return rows.Err()
+}
 
-		m := make(map[string]interface{})
-		for i, colName := range cols {
-			val := columnPointers[i].(*interface{})
-			m[colName] = *val
+var readOnlyStmtLiteralRegexp = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"|--[^\n]*|/\*[\s\S]*?\*/`)
+
+// IsReadOnlyStmt 判断 stmt 是否为单条查询语句：必须以 SELECT 或者 WITH 开头，并且字符串和注释之外不包含分号。
+//
+// 这里只是提前给出错误提示，是否只读由 QueryEachReadOnly 执行时的 PRAGMA query_only 保证。
+func IsReadOnlyStmt(stmt string) bool {
+	stmt = readOnlyStmtLiteralRegexp.ReplaceAllStringFunc(stmt, func(literal string) string {
+		if strings.HasPrefix(literal, "--") || strings.HasPrefix(literal, "/*") {
+			return " "
 		}
-		ret = append(ret, m)
+		return "''"
+	})
+	stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if strings.Contains(stmt, ";") {
+		return false
 	}
-	return
+	lower := strings.ToLower(stmt)
+	return strings.HasPrefix(lower, "select") || strings.HasPrefix(lower, "with")
 }
 
 func SelectBlocksRawStmtNoParse(stmt string, limit int) (ret []*Block) {
@@ -481,6 +663,50 @@ func selectBlocksRawStmt(stmt string, limit int) (ret []*Block) {
 	return
 }
 
+// SelectBlocksWithExtrasRawStmt 执行结果列为块的所有字段加上 extras 个附加字段的查询语句，返回块和对应的附加字段值。
+func SelectBlocksWithExtrasRawStmt(stmt string, extras int, args ...interface{}) (ret []*Block, extraVals [][]interface{}) {
+	rows, err := query(stmt, args...)
+	if nil != err {
+		util.LogWarnf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var block Block
+		vals := make([]interface{}, extras)
+		dest := []interface{}{&block.ID, &block.ParentID, &block.RootID, &block.Hash, &block.Box, &block.Path, &block.HPath, &block.Name, &block.Alias, &block.Memo, &block.Tag, &block.Content, &block.FContent, &block.Markdown, &block.Length, &block.Type, &block.SubType, &block.IAL, &block.Sort, &block.Created, &block.Updated}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err = rows.Scan(dest...); nil != err {
+			util.LogErrorf("query scan field failed: %s\n%s", err, util.ShortStack())
+			return
+		}
+		ret = append(ret, &block)
+		extraVals = append(extraVals, vals)
+	}
+	return
+}
+
+// QueryBlockContents 遍历全文索引中叶子块和文档块的类型和内容，容器块的内容和子块重复所以跳过。
+func QueryBlockContents(fn func(typ, content string)) {
+	stmt := "SELECT type, content FROM blocks_fts WHERE type NOT IN ('l', 'i', 'b', 's')"
+	rows, err := query(stmt)
+	if nil != err {
+		util.LogErrorf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var typ, content string
+		if err = rows.Scan(&typ, &content); nil != err {
+			util.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		fn(typ, content)
+	}
+}
+
 func scanBlockRows(rows *sql.Rows) (ret *Block) {
 	var block Block
 	if err := rows.Scan(&block.ID, &block.ParentID, &block.RootID, &block.Hash, &block.Box, &block.Path, &block.HPath, &block.Name, &block.Alias, &block.Memo, &block.Tag, &block.Content, &block.FContent, &block.Markdown, &block.Length, &block.Type, &block.SubType, &block.IAL, &block.Sort, &block.Created, &block.Updated); nil != err {
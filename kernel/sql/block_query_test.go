@@ -0,0 +1,123 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestIsReadOnlyStmt(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want bool
+	}{
+		{"SELECT * FROM blocks", true},
+		{"  select id FROM blocks;  ", true},
+		{"WITH r AS (SELECT id FROM blocks) SELECT * FROM r", true},
+		{"SELECT * FROM blocks WHERE content = 'a;b'", true},
+		{`SELECT * FROM blocks WHERE content = "a;b"`, true},
+		{"SELECT * FROM blocks WHERE content = 'it''s; ok'", true},
+		{"SELECT * FROM blocks -- ; DELETE FROM blocks\n", true},
+		{"/* comment; */ SELECT * FROM blocks", true},
+		{"", false},
+		{";", false},
+		{"DELETE FROM blocks", false},
+		{"UPDATE blocks SET content = ''", false},
+		{"PRAGMA query_only = OFF", false},
+		{"SELECT 1; DELETE FROM blocks", false},
+		{"SELECT 1;;", false},
+		{"SELECT 'a'; DROP TABLE blocks; SELECT 'b'", false},
+		{"-- SELECT\nDELETE FROM blocks", false},
+		{"EXPLAIN SELECT * FROM blocks", false},
+	}
+	for _, test := range tests {
+		if got := IsReadOnlyStmt(test.stmt); test.want != got {
+			t.Errorf("IsReadOnlyStmt(%q): got %v, want %v", test.stmt, got, test.want)
+		}
+	}
+}
+
+func TestQueryEachReadOnly(t *testing.T) {
+	// 查询失败时会记录日志，先初始化日志
+	util.LogPath = filepath.Join(t.TempDir(), "siyuan.log")
+	util.LogInfof("test read-only query")
+
+	testDB, err := sql.Open("sqlite3_extended", filepath.Join(t.TempDir(), "siyuan.db"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+	testDB.SetMaxOpenConns(1)
+	if _, err = testDB.Exec("CREATE TABLE t (id INTEGER, name TEXT); INSERT INTO t VALUES (1, 'a'), (2, NULL)"); nil != err {
+		t.Fatal(err)
+	}
+	db, testDB = testDB, db
+	defer func() { db, testDB = testDB, db }()
+
+	tests := []struct {
+		stmt string
+		cols []string
+		rows [][]interface{}
+		err  bool
+	}{
+		{"SELECT id, name FROM t ORDER BY id", []string{"id", "name"}, [][]interface{}{{int64(1), "a"}, {int64(2), nil}}, false},
+		{"SELECT id FROM t WHERE 0 = 1", []string{"id"}, nil, false},
+		{"", nil, nil, true},
+		{"SELECT * FROM missing", nil, nil, true},
+		{"DELETE FROM t", nil, nil, true},
+		{"INSERT INTO t VALUES (3, 'c')", nil, nil, true},
+		{"CREATE TABLE u (id INTEGER)", nil, nil, true},
+		{"DELETE FROM t RETURNING id", nil, nil, true},
+		{"PRAGMA query_only = OFF; DELETE FROM t RETURNING id", nil, nil, true},
+		{"PRAGMA query_only = OFF; DELETE FROM t", nil, nil, true},
+		{"ATTACH DATABASE 'other.db' AS other", nil, nil, true},
+		{"SELECT name FROM pragma_table_info('t')", []string{"name"}, [][]interface{}{{"id"}, {"name"}}, false},
+		{"SELECT * FROM pragma_query_only", nil, nil, true},
+	}
+	for _, test := range tests {
+		var cols []string
+		var rows [][]interface{}
+		err = QueryEachReadOnly(test.stmt, func(columns []string) error {
+			cols = columns
+			return nil
+		}, func(vals []interface{}) error {
+			rows = append(rows, vals)
+			return nil
+		})
+		if test.err != (nil != err) {
+			t.Errorf("%q: got error %v, want error %v", test.stmt, err, test.err)
+			continue
+		}
+		if !reflect.DeepEqual(test.cols, cols) || !reflect.DeepEqual(test.rows, rows) {
+			t.Errorf("%q: got %v %v, want %v %v", test.stmt, cols, rows, test.cols, test.rows)
+		}
+	}
+
+	// 只读查询结束后连接恢复可写
+	if _, err = db.Exec("INSERT INTO t VALUES (3, 'c')"); nil != err {
+		t.Errorf("write after read-only query failed: %s", err)
+	}
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM t").Scan(&count); nil != err || 3 != count {
+		t.Errorf("got %d rows (%v), want 3", count, err)
+	}
+}
@@ -57,7 +57,7 @@ func queryRefTexts() (ret []string) {
 	sqlStmt := "SELECT DISTINCT content FROM refs LIMIT 1024"
 	rows, err := query(sqlStmt)
 	if nil != err {
-		util.LogErrorf("sql query failed: %s", sqlStmt, err)
+		util.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
 		return
 	}
 	defer rows.Close()
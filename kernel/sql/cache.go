@@ -24,15 +24,29 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/dgraph-io/ristretto"
 	gcache "github.com/patrickmn/go-cache"
+	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 var memCache, _ = ristretto.NewCache(&ristretto.Config{
 	NumCounters: 100000,           // 10W
 	MaxCost:     1024 * 1024 * 10, // 10MB
 	BufferItems: 64,
+	Metrics:     true,
 })
 var disabled = true
 
+func init() {
+	util.NewCounterFunc("siyuan_sql_cache_hits_total", "Number of block cache hits.", nil, func() []util.MetricValue {
+		return []util.MetricValue{{Value: float64(memCache.Metrics.Hits())}}
+	})
+	util.NewCounterFunc("siyuan_sql_cache_misses_total", "Number of block cache misses.", nil, func() []util.MetricValue {
+		return []util.MetricValue{{Value: float64(memCache.Metrics.Misses())}}
+	})
+	util.NewGaugeFunc("siyuan_sql_cache_hit_ratio", "Ratio of block cache hits to all lookups.", nil, func() []util.MetricValue {
+		return []util.MetricValue{{Value: memCache.Metrics.Ratio()}}
+	})
+}
+
 func EnableCache() {
 	disabled = false
 }
@@ -122,6 +122,12 @@ func initDBTables() {
 		util.LogFatalf("create table [assets] failed: %s", err)
 	}
 
+	db.Exec("DROP TABLE asset_contents_fts")
+	_, err = db.Exec("CREATE VIRTUAL TABLE asset_contents_fts USING fts5(id UNINDEXED, path UNINDEXED, name, page UNINDEXED, content, hash UNINDEXED, tokenize=\"siyuan case_insensitive\")")
+	if nil != err {
+		util.LogFatalf("create table [asset_contents_fts] failed: %s", err)
+	}
+
 	db.Exec("DROP TABLE attributes")
 	_, err = db.Exec("CREATE TABLE attributes (id, name, value, type, block_id, root_id, box, path)")
 	if nil != err {
@@ -133,6 +139,10 @@ func initDBTables() {
 	if nil != err {
 		util.LogFatalf("create table [refs] failed: %s", err)
 	}
+	_, err = db.Exec("CREATE INDEX idx_refs_def_block_id ON refs (def_block_id)") // 搜索时统计命中块的被引用次数
+	if nil != err {
+		util.LogFatalf("create index [idx_refs_def_block_id] failed: %s", err)
+	}
 
 	db.Exec("DROP TABLE file_annotation_refs")
 	_, err = db.Exec("CREATE TABLE file_annotation_refs (id, file_path, annotation_id, block_id, root_id, box, path, content, type)")
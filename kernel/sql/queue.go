@@ -39,8 +39,18 @@ var (
 	upsertTreeQueueLock = sync.Mutex{}
 
 	txLock = sync.Mutex{}
+
+	flushTreeQueueDuration = util.NewHistogram("siyuan_sql_tree_queue_flush_duration_seconds", "Duration of flushing the tree operation queue into the database.", util.DurationBuckets)
 )
 
+func init() {
+	util.NewGaugeFunc("siyuan_sql_tree_queue_length", "Number of tree operations waiting to be flushed into the database.", nil, func() []util.MetricValue {
+		upsertTreeQueueLock.Lock()
+		defer upsertTreeQueueLock.Unlock()
+		return []util.MetricValue{{Value: float64(len(operationQueue))}}
+	})
+}
+
 type treeQueueOperation struct {
 	inQueueTime time.Time
 	action      string // upsert/delete/delete_id/rename
@@ -87,6 +97,7 @@ func flushTreeQueue() {
 	if 1 > len(ops) {
 		return
 	}
+	defer flushTreeQueueDuration.ObserveSince(time.Now())
 
 	txLock.Lock()
 	defer txLock.Unlock()
@@ -0,0 +1,302 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 参数校验失败时返回的错误码，此时 HTTP 状态码为 400，data 为 ArgErr。
+const (
+	ErrCodeInvalidJSON = 4000 // 请求体不是 JSON 对象
+	ErrCodeMissingArg  = 4001 // 缺少必填参数
+	ErrCodeInvalidArg  = 4002 // 参数类型或者取值不合法
+)
+
+// ArgErr 描述了校验失败的参数。
+type ArgErr struct {
+	Arg      string `json:"arg"`      // 参数名，嵌套参数使用 . 分隔
+	Expected string `json:"expected"` // 期望的参数类型或者取值
+}
+
+func (argErr *ArgErr) msg(code int) string {
+	if ErrCodeMissingArg == code {
+		return "missing argument [" + argErr.Arg + "], expected " + argErr.Expected
+	}
+	return "invalid argument [" + argErr.Arg + "], expected " + argErr.Expected
+}
+
+// ArgKind 是 JSON 参数值的类型。
+type ArgKind string
+
+const (
+	ArgAny    ArgKind = ""
+	ArgString ArgKind = "string"
+	ArgBool   ArgKind = "boolean"
+	ArgNumber ArgKind = "number"
+	ArgArray  ArgKind = "array"
+	ArgObject ArgKind = "object"
+)
+
+// JsonArgRule 是 JsonArg 校验参数时使用的规则。
+type JsonArgRule struct {
+	name     string
+	kind     ArgKind
+	elem     ArgKind // 数组元素或者对象属性值的类型
+	optional bool
+}
+
+func StrArg(name string) *JsonArgRule {
+	return &JsonArgRule{name: name, kind: ArgString}
+}
+
+func BoolArg(name string) *JsonArgRule {
+	return &JsonArgRule{name: name, kind: ArgBool}
+}
+
+func NumArg(name string) *JsonArgRule {
+	return &JsonArgRule{name: name, kind: ArgNumber}
+}
+
+func ArrArg(name string, elem ArgKind) *JsonArgRule {
+	return &JsonArgRule{name: name, kind: ArgArray, elem: elem}
+}
+
+func ObjArg(name string, elem ArgKind) *JsonArgRule {
+	return &JsonArgRule{name: name, kind: ArgObject, elem: elem}
+}
+
+// Optional 将参数标记为可选，可选参数未传入或者为 null 时不校验。
+func (rule *JsonArgRule) Optional() *JsonArgRule {
+	rule.optional = true
+	return rule
+}
+
+func (rule *JsonArgRule) check(arg map[string]interface{}) (code int, argErr *ArgErr) {
+	value := arg[rule.name]
+	if nil == value {
+		if rule.optional {
+			return
+		}
+		return ErrCodeMissingArg, &ArgErr{Arg: rule.name, Expected: rule.expected()}
+	}
+	if rule.kind != argKind(value) {
+		return ErrCodeInvalidArg, &ArgErr{Arg: rule.name, Expected: rule.expected()}
+	}
+
+	if ArgAny == rule.elem {
+		return
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if rule.elem != argKind(e) {
+				return ErrCodeInvalidArg, &ArgErr{Arg: rule.name, Expected: rule.expected()}
+			}
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			if rule.elem != argKind(e) {
+				return ErrCodeInvalidArg, &ArgErr{Arg: rule.name + "." + k, Expected: string(rule.elem)}
+			}
+		}
+	}
+	return
+}
+
+func (rule *JsonArgRule) expected() string {
+	if ArgAny == rule.elem {
+		return string(rule.kind)
+	}
+	if ArgArray == rule.kind {
+		return "array of " + string(rule.elem)
+	}
+	return "object of " + string(rule.elem)
+}
+
+func argKind(value interface{}) ArgKind {
+	switch value.(type) {
+	case string:
+		return ArgString
+	case bool:
+		return ArgBool
+	case float64:
+		return ArgNumber
+	case []interface{}:
+		return ArgArray
+	case map[string]interface{}:
+		return ArgObject
+	}
+	return ArgAny
+}
+
+func init() {
+	// 参数校验失败时使用 JSON 字段名而不是结构体字段名
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if "-" == name {
+				return ""
+			}
+			if "" == name {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// BindJsonArg 将请求参数解析到结构体 arg 中，并根据 arg 字段上的 binding 标签校验参数，校验失败时返回 400 和 ArgErr。
+func BindJsonArg(c *gin.Context, result *gulu.Result, arg interface{}) (ok bool) {
+	err := c.ShouldBindJSON(arg)
+	if nil == err {
+		ok = true
+		return
+	}
+
+	code, msg, argErr := bindErr(err)
+	argFailed(c, result, code, msg, argErr)
+	return
+}
+
+// BindArg 将已经解析为 map 的参数 arg 绑定到结构体 obj 中并校验，校验失败时返回错误码、错误消息和 ArgErr，成功时错误码为 0。
+func BindArg(arg map[string]interface{}, obj interface{}) (code int, msg string, argErr *ArgErr) {
+	data, err := gulu.JSON.MarshalJSON(arg)
+	if nil == err {
+		err = binding.JSON.BindBody(data, obj)
+	}
+	if nil == err {
+		return
+	}
+	return bindErr(err)
+}
+
+// BindMapArg 将 JsonArg 已经解析的参数 arg 绑定到结构体 obj 中并校验，用于校验数组元素等 JsonArgRule 无法描述的参数，校验失败时返回 400 和 ArgErr。
+func BindMapArg(c *gin.Context, result *gulu.Result, arg map[string]interface{}, obj interface{}) (ok bool) {
+	code, msg, argErr := BindArg(arg, obj)
+	if 0 != code {
+		argFailed(c, result, code, msg, argErr)
+		return
+	}
+	ok = true
+	return
+}
+
+// FormArg 解析 multipart/form-data 请求并检查必填的文件字段 file 和值字段 values，失败时返回 400，
+// 请求无法解析时错误码为 4002，缺少必填字段时错误码为 4001。
+func FormArg(c *gin.Context, result *gulu.Result, file string, values ...string) (form *multipart.Form, ok bool) {
+	form, err := c.MultipartForm()
+	if nil != err {
+		argFailed(c, result, ErrCodeInvalidArg, "parses multipart form failed: "+err.Error(), nil)
+		return
+	}
+
+	var argErr *ArgErr
+	if "" != file && 1 > len(form.File[file]) {
+		argErr = &ArgErr{Arg: file, Expected: "file"}
+	}
+	for _, name := range values {
+		if nil == argErr && 1 > len(form.Value[name]) {
+			argErr = &ArgErr{Arg: name, Expected: string(ArgString)}
+		}
+	}
+	if nil != argErr {
+		argFailed(c, result, ErrCodeMissingArg, argErr.msg(ErrCodeMissingArg), argErr)
+		return
+	}
+	ok = true
+	return
+}
+
+// InvalidArg 返回 400 和参数不合法的 ArgErr，用于 binding 标签无法描述的校验。
+func InvalidArg(c *gin.Context, result *gulu.Result, argErr *ArgErr) {
+	argFailed(c, result, ErrCodeInvalidArg, argErr.msg(ErrCodeInvalidArg), argErr)
+}
+
+func bindErr(err error) (code int, msg string, argErr *ArgErr) {
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		fieldErr := fieldErrs[0]
+		code = ErrCodeInvalidArg
+		argErr = &ArgErr{Arg: strings.SplitN(fieldErr.Namespace(), ".", 2)[1], Expected: fieldErr.Tag()}
+		if "required" == fieldErr.Tag() {
+			code = ErrCodeMissingArg
+			argErr.Expected = string(typeKind(fieldErr.Type()))
+		} else if "" != fieldErr.Param() {
+			argErr.Expected += " " + fieldErr.Param()
+		}
+		msg = argErr.msg(code)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		code = ErrCodeInvalidArg
+		argErr = &ArgErr{Arg: typeErr.Field, Expected: string(typeKind(typeErr.Type))}
+		msg = argErr.msg(code)
+		return
+	}
+
+	return ErrCodeInvalidJSON, "parses request failed: " + err.Error(), nil
+}
+
+func typeKind(t reflect.Type) ArgKind {
+	for reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return ArgString
+	case reflect.Bool:
+		return ArgBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return ArgNumber
+	case reflect.Slice, reflect.Array:
+		return ArgArray
+	case reflect.Map, reflect.Struct:
+		return ArgObject
+	}
+	return ArgAny
+}
+
+func argFailed(c *gin.Context, result *gulu.Result, code int, msg string, argErr *ArgErr) {
+	result.Code = code
+	result.Msg = msg
+	if nil != argErr {
+		result.Data = argErr
+	}
+	c.Writer = &badRequestWriter{c.Writer}
+}
+
+// badRequestWriter 将响应状态码固定为 400，这样处理函数中的 defer c.JSON(http.StatusOK, ret) 不需要修改。
+type badRequestWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *badRequestWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(http.StatusBadRequest)
+}
@@ -113,6 +113,11 @@ func openLogger() {
 	if nil != err {
 		stdlog.Fatalf("create log file [%s] failed: %s", LogPath, err)
 	}
+	if Cli {
+		// 命令行子命令的标准输出用于输出结果
+		logger = NewLogger(logFile)
+		return
+	}
 	logger = NewLogger(io.MultiWriter(os.Stdout, logFile))
 }
 
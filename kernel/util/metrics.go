@@ -0,0 +1,276 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内核运行指标，通过 /metrics 以 Prometheus 文本格式输出 https://prometheus.io/docs/instrumenting/exposition_formats/
+
+var (
+	metrics     = map[string]metric{}
+	metricsLock = sync.Mutex{}
+)
+
+// DurationBuckets 是耗时直方图默认的分桶（秒）。
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// LongDurationBuckets 是同步、备份等耗时较长的操作的耗时直方图分桶（秒）。
+var LongDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+// MetricValue 是指标的一个取值，Labels 为标签值，顺序和指标的标签名一致。
+type MetricValue struct {
+	Labels []string
+	Value  float64
+}
+
+type metricDesc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func registerMetric(desc *metricDesc, m metric) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metrics[desc.name] = m
+}
+
+func (desc *metricDesc) writeHeader(buf *bytes.Buffer) {
+	buf.WriteString("# HELP " + desc.name + " " + desc.help + "\n")
+	buf.WriteString("# TYPE " + desc.name + " " + desc.typ + "\n")
+}
+
+// writeSample 输出一个取值，extraName 和 extraValue 用于直方图的 le 标签。
+func (desc *metricDesc) writeSample(buf *bytes.Buffer, suffix string, labelValues []string, extraName, extraValue string, value float64) {
+	buf.WriteString(desc.name + suffix)
+	if 0 < len(desc.labelNames) || "" != extraName {
+		buf.WriteByte('{')
+		for i, labelName := range desc.labelNames {
+			if 0 < i {
+				buf.WriteByte(',')
+			}
+			labelValue := ""
+			if i < len(labelValues) {
+				labelValue = labelValues[i]
+			}
+			buf.WriteString(labelName + "=\"" + escapeLabelValue(labelValue) + "\"")
+		}
+		if "" != extraName {
+			if 0 < len(desc.labelNames) {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(extraName + "=\"" + extraValue + "\"")
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString(" " + formatMetricValue(value) + "\n")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func labelsKey(labelValues []string) string {
+	return strings.Join(labelValues, "\x00")
+}
+
+// Counter 是只增不减的计数器。
+type Counter struct {
+	desc   *metricDesc
+	lock   sync.Mutex
+	values map[string]*MetricValue
+}
+
+// NewCounter 创建并注册计数器。
+func NewCounter(name, help string, labelNames ...string) (ret *Counter) {
+	ret = &Counter{desc: &metricDesc{name: name, help: help, typ: "counter", labelNames: labelNames}, values: map[string]*MetricValue{}}
+	registerMetric(ret.desc, ret)
+	return
+}
+
+// Add 为标签值 labelValues 对应的计数增加 value。
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	key := labelsKey(labelValues)
+	v := counter.values[key]
+	if nil == v {
+		v = &MetricValue{Labels: labelValues}
+		counter.values[key] = v
+	}
+	v.Value += value
+}
+
+func (counter *Counter) write(buf *bytes.Buffer) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	var keys []string
+	for key := range counter.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	counter.desc.writeHeader(buf)
+	for _, key := range keys {
+		v := counter.values[key]
+		counter.desc.writeSample(buf, "", v.Labels, "", "", v.Value)
+	}
+}
+
+// Histogram 是直方图，用于统计耗时等分布。
+type Histogram struct {
+	desc    *metricDesc
+	buckets []float64
+	lock    sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // 每个分桶的计数，不累加
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 创建并注册直方图，buckets 为升序的分桶上界。
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) (ret *Histogram) {
+	ret = &Histogram{desc: &metricDesc{name: name, help: help, typ: "histogram", labelNames: labelNames}, buckets: buckets, values: map[string]*histogramValue{}}
+	registerMetric(ret.desc, ret)
+	return
+}
+
+// Observe 记录标签值 labelValues 对应的一个取值。
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+
+	key := labelsKey(labelValues)
+	v := histogram.values[key]
+	if nil == v {
+		v = &histogramValue{labels: labelValues, counts: make([]uint64, len(histogram.buckets))}
+		histogram.values[key] = v
+	}
+	for i, bucket := range histogram.buckets {
+		if value <= bucket {
+			v.counts[i]++
+			break
+		}
+	}
+	v.sum += value
+	v.count++
+}
+
+// ObserveSince 记录从 start 到现在的耗时（秒）。
+func (histogram *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	histogram.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (histogram *Histogram) write(buf *bytes.Buffer) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+
+	var keys []string
+	for key := range histogram.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	histogram.desc.writeHeader(buf)
+	for _, key := range keys {
+		v := histogram.values[key]
+		var cumulative uint64
+		for i, bucket := range histogram.buckets {
+			cumulative += v.counts[i]
+			histogram.desc.writeSample(buf, "_bucket", v.labels, "le", formatMetricValue(bucket), float64(cumulative))
+		}
+		histogram.desc.writeSample(buf, "_bucket", v.labels, "le", "+Inf", float64(v.count))
+		histogram.desc.writeSample(buf, "_sum", v.labels, "", "", v.sum)
+		histogram.desc.writeSample(buf, "_count", v.labels, "", "", float64(v.count))
+	}
+}
+
+// funcMetric 是在输出时才通过 collect 获取取值的指标，用于队列长度、会话数这类状态。
+type funcMetric struct {
+	desc    *metricDesc
+	collect func() []MetricValue
+}
+
+// NewGaugeFunc 注册一个仪表盘指标，输出时调用 collect 获取取值。
+func NewGaugeFunc(name, help string, labelNames []string, collect func() []MetricValue) {
+	desc := &metricDesc{name: name, help: help, typ: "gauge", labelNames: labelNames}
+	registerMetric(desc, &funcMetric{desc: desc, collect: collect})
+}
+
+// NewCounterFunc 注册一个计数器指标，输出时调用 collect 获取取值，用于已经由其他组件累计的计数。
+func NewCounterFunc(name, help string, labelNames []string, collect func() []MetricValue) {
+	desc := &metricDesc{name: name, help: help, typ: "counter", labelNames: labelNames}
+	registerMetric(desc, &funcMetric{desc: desc, collect: collect})
+}
+
+func (m *funcMetric) write(buf *bytes.Buffer) {
+	values := m.collect()
+	sort.Slice(values, func(i, j int) bool { return labelsKey(values[i].Labels) < labelsKey(values[j].Labels) })
+	m.desc.writeHeader(buf)
+	for _, v := range values {
+		m.desc.writeSample(buf, "", v.Labels, "", "", v.Value)
+	}
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出所有指标。
+func WriteMetrics(w io.Writer) (err error) {
+	metricsLock.Lock()
+	var names []string
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ms []metric
+	for _, name := range names {
+		ms = append(ms, metrics[name])
+	}
+	metricsLock.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, m := range ms {
+		m.write(buf)
+	}
+	_, err = w.Write(buf.Bytes())
+	return
+}
@@ -0,0 +1,111 @@
+// SiYuan - Build Your Eternal Digital Garden
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"/api/query/sql", "/api/query/sql"},
+		{`C:\SiYuan`, `C:\\SiYuan`},
+		{`say "hi"`, `say \"hi\"`},
+		{"a\nb", `a\nb`},
+		{"思源", "思源"},
+	}
+	for _, test := range tests {
+		if got := escapeLabelValue(test.value); test.want != got {
+			t.Errorf("escapeLabelValue(%q): got %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestFormatMetricValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.005, "0.005"},
+		{-1.5, "-1.5"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, test := range tests {
+		if got := formatMetricValue(test.value); test.want != got {
+			t.Errorf("formatMetricValue(%v): got %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestMetricsWrite(t *testing.T) {
+	counter := &Counter{desc: &metricDesc{name: "test_requests_total", help: "Requests.", typ: "counter", labelNames: []string{"path", "code"}}, values: map[string]*MetricValue{}}
+	counter.Add(1, "/b", "200")
+	counter.Add(2, "/a", "200")
+	counter.Add(1, "/a", "200")
+
+	histogram := &Histogram{desc: &metricDesc{name: "test_duration_seconds", help: "Duration.", typ: "histogram"}, buckets: []float64{.1, 1}, values: map[string]*histogramValue{}}
+	histogram.Observe(.05)
+	histogram.Observe(.5)
+	histogram.Observe(5)
+
+	gauge := &funcMetric{desc: &metricDesc{name: "test_queue", help: "Queue.", typ: "gauge", labelNames: []string{"name"}}, collect: func() []MetricValue {
+		return []MetricValue{{Labels: []string{`tx "b"`}, Value: 2}, {Labels: []string{"a"}, Value: 0}}
+	}}
+
+	tests := []struct {
+		name   string
+		metric metric
+		want   string
+	}{
+		{"counter", counter, `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{path="/a",code="200"} 3
+test_requests_total{path="/b",code="200"} 1
+`},
+		{"histogram", histogram, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 5.55
+test_duration_seconds_count 3
+`},
+		{"gauge", gauge, `# HELP test_queue Queue.
+# TYPE test_queue gauge
+test_queue{name="a"} 0
+test_queue{name="tx \"b\""} 2
+`},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		test.metric.write(buf)
+		if got := buf.String(); test.want != got {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
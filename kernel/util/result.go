@@ -39,6 +39,7 @@ type Result struct {
 	Code           int         `json:"code"`
 	Msg            string      `json:"msg"`
 	Data           interface{} `json:"data"`
+	Stream         bool        `json:"stream,omitempty"` // 是否是流式响应的中间结果，最终结果为 false
 }
 
 func NewResult() *Result {
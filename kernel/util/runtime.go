@@ -17,8 +17,10 @@
 package util
 
 import (
+	"bytes"
 	"reflect"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
@@ -27,15 +29,17 @@ import (
 	"github.com/dustin/go-humanize"
 )
 
-const DatabaseVer = "20220501" // 修改表结构的话需要修改这里
+const DatabaseVer = "20221019" // 修改表结构的话需要修改这里
 
 const (
 	ExitCodeReadOnlyDatabase = 20 // 数据库文件被锁
 	ExitCodeUnavailablePort  = 21 // 端口不可用
 	ExitCodeCreateConfDirErr = 22 // 创建配置目录失败
 	ExitCodeBlockTreeErr     = 23 // 无法读写 blocktree.msgpack 文件
+	ExitCodeWorkspaceLocked  = 24 // 工作空间正在被其他内核使用
 	ExitCodeOk               = 0  // 正常退出
 	ExitCodeFatal            = 1  // 致命错误
+	ExitCodeUsage            = 2  // 命令行参数错误
 )
 
 func logBootInfo() {
@@ -59,6 +63,17 @@ func IsMutexLocked(m *sync.Mutex) bool {
 	return state.Int()&1 == 1
 }
 
+// GoroutineID 返回当前协程的 ID。
+func GoroutineID() (ret int64) {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)] // goroutine 18 [running]: ...
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); 0 < i {
+		ret, _ = strconv.ParseInt(string(buf[:i]), 10, 64)
+	}
+	return
+}
+
 func RandomSleep(minMills, maxMills int) {
 	r := gulu.Rand.Int(minMills, maxMills)
 	time.Sleep(time.Duration(r) * time.Millisecond)
@@ -39,14 +39,21 @@ func GetRemoteAddr(session *melody.Session) string {
 	return strings.Split(ret, ",")[0]
 }
 
-func JsonArg(c *gin.Context, result *gulu.Result) (arg map[string]interface{}, ok bool) {
+// JsonArg 解析请求参数，并根据 rules 校验参数，校验失败时返回 400 和 ArgErr。
+func JsonArg(c *gin.Context, result *gulu.Result, rules ...*JsonArgRule) (arg map[string]interface{}, ok bool) {
 	arg = map[string]interface{}{}
-	if err := c.BindJSON(&arg); nil != err {
-		result.Code = -1
-		result.Msg = "parses request failed"
+	if err := c.ShouldBindJSON(&arg); nil != err {
+		argFailed(c, result, ErrCodeInvalidJSON, "parses request failed: "+err.Error(), nil)
 		return
 	}
 
+	for _, rule := range rules {
+		if code, argErr := rule.check(arg); nil != argErr {
+			argFailed(c, result, code, argErr.msg(code), argErr)
+			return
+		}
+	}
+
 	ok = true
 	return
 }
@@ -17,6 +17,7 @@
 package util
 
 import (
+	"context"
 	"sync"
 
 	"github.com/88250/melody"
@@ -29,6 +30,27 @@ var (
 	sessions = sync.Map{} // {appId, {sessionId, session}}
 )
 
+func init() {
+	NewGaugeFunc("siyuan_websocket_sessions", "Number of websocket sessions.", []string{"app", "type"}, func() (ret []MetricValue) {
+		counts := map[[2]string]int{}
+		sessions.Range(func(key, value interface{}) bool {
+			app := key.(string)
+			appSessions := value.(*sync.Map)
+			appSessions.Range(func(key, value interface{}) bool {
+				typ, _ := value.(*melody.Session).Get("type")
+				t, _ := typ.(string)
+				counts[[2]string{app, t}]++
+				return true
+			})
+			return true
+		})
+		for labels, count := range counts {
+			ret = append(ret, MetricValue{Labels: []string{labels[0], labels[1]}, Value: float64(count)})
+		}
+		return
+	})
+}
+
 // BroadcastByType 广播所有实例上 typ 类型的会话。
 func BroadcastByType(typ, cmd string, code int, msg string, data interface{}) {
 	typeSessions := SessionsByType(typ)
@@ -155,14 +177,64 @@ func PushEndlessProgress(msg string) {
 	PushProgress(PushProgressCodeEndless, 1, 1, msg)
 }
 
+// Progress 是进度推送的目标，由发起耗时操作的请求创建并显式传递给该操作，这样进度只会返回给发起该操作的请求，用于 websocket 命令流式返回执行进度。
+//
+// 推送的进度同时会广播给所有会话，广播的 data.reqId 为发起请求的 reqId。Progress 为 nil 时只广播。
+type Progress struct {
+	reqId    float64
+	listener func(code, current, total int, msg string)
+}
+
+// NewProgress 创建请求 reqId 的进度推送目标，listener 在推送进度时同步调用。
+func NewProgress(reqId float64, listener func(code, current, total int, msg string)) *Progress {
+	return &Progress{reqId: reqId, listener: listener}
+}
+
+func (progress *Progress) Push(code, current, total int, msg string) {
+	if nil == progress {
+		PushProgress(code, current, total, msg)
+		return
+	}
+
+	progress.listener(code, current, total, msg)
+	pushProgress(code, current, total, msg, map[string]interface{}{"reqId": progress.reqId})
+}
+
+func (progress *Progress) PushEndless(msg string) {
+	progress.Push(PushProgressCodeEndless, 1, 1, msg)
+}
+
+func (progress *Progress) Clear(total int) {
+	progress.Push(PushProgressCodeEnd, total, total, "")
+}
+
+type progressKey struct{}
+
+// WithProgress 返回带有进度推送目标 progress 的 ctx，用于在进程内调用 API 时传递进度推送目标。
+func WithProgress(ctx context.Context, progress *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// ProgressOf 返回 ctx 中的进度推送目标，没有时返回 nil。
+func ProgressOf(ctx context.Context) (ret *Progress) {
+	ret, _ = ctx.Value(progressKey{}).(*Progress)
+	return
+}
+
 func PushProgress(code, current, total int, msg string) {
+	pushProgress(code, current, total, msg, nil)
+}
+
+func pushProgress(code, current, total int, msg string, data map[string]interface{}) {
 	evt := NewCmdResult("progress", 0, PushModeBroadcast, 0)
 	evt.Msg = msg
 	evt.Code = code
-	evt.Data = map[string]interface{}{
-		"current": current,
-		"total":   total,
+	if nil == data {
+		data = map[string]interface{}{}
 	}
+	data["current"] = current
+	data["total"] = total
+	evt.Data = data
 	PushEvent(evt)
 }
 
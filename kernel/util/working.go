@@ -17,6 +17,7 @@
 package util
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"math/rand"
@@ -29,6 +30,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/88250/flock"
 	"github.com/88250/gulu"
 	figure "github.com/common-nighthawk/go-figure"
 	goPS "github.com/mitchellh/go-ps"
@@ -65,6 +67,7 @@ func Boot() {
 	lang := flag.String("lang", "en_US", "zh_CN/zh_CHT/en_US/fr_FR")
 
 	flag.Parse()
+	Cli = 0 < flag.NArg()
 
 	if "" != *wdPath {
 		WorkingDir = *wdPath
@@ -94,6 +97,17 @@ func Boot() {
 	}
 
 	initPathDir()
+	if Cli {
+		// 命令行子命令不启动伺服，不需要检查端口，也不输出启动信息
+		return
+	}
+	if err := TryLockWorkspace(); nil != err {
+		if ErrWorkspaceLocked == err {
+			LogErrorf("workspace [%s] is in use by another kernel", WorkspaceDir)
+			os.Exit(ExitCodeWorkspaceLocked)
+		}
+		os.Exit(ExitCodeFatal)
+	}
 	checkPort()
 
 	bootBanner := figure.NewColorFigure("SiYuan", "isometric3", "green", true)
@@ -103,6 +117,37 @@ func Boot() {
 	go cleanOld()
 }
 
+var workspaceLock *flock.Flock
+
+// ErrWorkspaceLocked 表示工作空间正在被其他内核或者命令行子命令使用。
+var ErrWorkspaceLocked = errors.New("workspace is in use by another kernel")
+
+// TryLockWorkspace 锁定工作空间下的 .lock 文件，锁定一直持有到进程退出或者调用 UnlockWorkspace。
+//
+// 工作空间正在被其他内核或者命令行子命令使用时返回 ErrWorkspaceLocked，无法创建或者锁定 .lock 文件时返回对应的错误。
+func TryLockWorkspace() (err error) {
+	workspaceLock = flock.New(filepath.Join(WorkspaceDir, ".lock"))
+	locked, err := workspaceLock.TryLock()
+	if nil != err {
+		LogErrorf("lock workspace [%s] failed: %s", WorkspaceDir, err)
+		return
+	}
+	if !locked {
+		err = ErrWorkspaceLocked
+	}
+	return
+}
+
+// UnlockWorkspace 释放 TryLockWorkspace 锁定的工作空间。
+func UnlockWorkspace() {
+	if nil == workspaceLock {
+		return
+	}
+	if err := workspaceLock.Unlock(); nil != err {
+		LogErrorf("unlock workspace [%s] failed: %s", WorkspaceDir, err)
+	}
+}
+
 func SetBootDetails(details string) {
 	if 100 <= bootProgress {
 		return
@@ -238,12 +283,14 @@ func initWorkspaceDir(workspaceArg string) {
 		}
 	}
 
-	if data, err := gulu.JSON.MarshalJSON(workspacePaths); nil == err {
-		if err = os.WriteFile(workspaceConf, data, 0644); nil != err {
-			log.Fatalf("write workspace conf [%s] failed: %s", workspaceConf, err)
+	if !Cli { // 命令行子命令不修改桌面端最近打开的工作空间
+		if data, err := gulu.JSON.MarshalJSON(workspacePaths); nil == err {
+			if err = os.WriteFile(workspaceConf, data, 0644); nil != err {
+				log.Fatalf("write workspace conf [%s] failed: %s", workspaceConf, err)
+			}
+		} else {
+			log.Fatalf("marshal workspace conf [%s] failed: %s", workspaceConf, err)
 		}
-	} else {
-		log.Fatalf("marshal workspace conf [%s] failed: %s", workspaceConf, err)
 	}
 
 	ConfDir = filepath.Join(WorkspaceDir, "conf")
@@ -260,6 +307,8 @@ var (
 	Lang           = "en_US"
 
 	Container string // docker, android, ios, std
+
+	Cli bool // 是否以命令行子命令方式运行，比如 SiYuan-Kernel query "SELECT * FROM blocks"
 )
 
 func initPathDir() {